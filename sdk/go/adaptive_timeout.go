@@ -0,0 +1,147 @@
+package msb
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AdaptiveTimeout configures [WithAdaptiveTimeout]. Zero fields take their defaults.
+type AdaptiveTimeout struct {
+	// Percentile of the recent latencies of a method its timeout is derived from. Defaults to
+	// 0.99.
+	Percentile float64
+	// Headroom multiplies the percentile, so that ordinary variation does not time out. Defaults
+	// to 2.
+	Headroom float64
+	// Window is the number of recent latencies kept per method. Defaults to 100.
+	Window int
+	// MinSamples is the number of latencies a method needs before its timeout adapts; until
+	// then its ceiling applies. Defaults to 10.
+	MinSamples int
+	// Floor and Ceiling bound the timeout of every method. They default to 1 second and 5
+	// minutes.
+	Floor   time.Duration
+	Ceiling time.Duration
+	// Bounds overrides Floor and Ceiling for JSON-RPC methods, such as "sandbox.start" or
+	// "sandbox.repl.run", e.g. to allow cold starts longer than executions.
+	Bounds map[string]TimeoutBounds
+}
+
+// TimeoutBounds bounds the adaptive timeout of a method; zero fields fall back to the
+// [AdaptiveTimeout] ones.
+type TimeoutBounds struct {
+	Floor   time.Duration
+	Ceiling time.Duration
+}
+
+// WithAdaptiveTimeout derives the deadline of each RPC from the latencies observed for its
+// method, instead of a fixed [WithTimeout], so that quick calls such as metrics reads fail fast
+// when the server hangs while slow ones such as cold starts are given the time they usually
+// need. An RPC that times out counts as taking its whole timeout, so timeouts grow again when the
+// server slows down. It takes precedence over WithTimeout.
+func WithAdaptiveTimeout(at AdaptiveTimeout) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.adaptiveTimeout = newLatencyTracker(at)
+	}
+}
+
+// latencyTracker keeps a window of recent latencies per method. It is shared by the config
+// snapshots of a sandbox.
+type latencyTracker struct {
+	settings AdaptiveTimeout
+
+	mu      sync.Mutex
+	samples map[rpcMethod]*latencyWindow
+}
+
+// latencyWindow is a ring buffer of latencies.
+type latencyWindow struct {
+	latencies []time.Duration
+	next      int
+}
+
+func newLatencyTracker(at AdaptiveTimeout) *latencyTracker {
+	if at.Percentile <= 0 || at.Percentile > 1 {
+		at.Percentile = 0.99
+	}
+	if at.Headroom <= 0 {
+		at.Headroom = 2
+	}
+	if at.Window <= 0 {
+		at.Window = 100
+	}
+	if at.MinSamples <= 0 {
+		at.MinSamples = 10
+	}
+	at.MinSamples = min(at.MinSamples, at.Window)
+	if at.Floor <= 0 {
+		at.Floor = time.Second
+	}
+	if at.Ceiling <= 0 {
+		at.Ceiling = 5 * time.Minute
+	}
+	return &latencyTracker{settings: at, samples: map[rpcMethod]*latencyWindow{}}
+}
+
+// bounds returns the floor and ceiling of method's timeout.
+func (t *latencyTracker) bounds(method rpcMethod) (floor, ceiling time.Duration) {
+	floor, ceiling = t.settings.Floor, t.settings.Ceiling
+	if b, ok := t.settings.Bounds[string(method)]; ok {
+		if b.Floor > 0 {
+			floor = b.Floor
+		}
+		if b.Ceiling > 0 {
+			ceiling = b.Ceiling
+		}
+	}
+	return floor, max(floor, ceiling)
+}
+
+// timeout returns the current timeout of method.
+func (t *latencyTracker) timeout(method rpcMethod) time.Duration {
+	floor, ceiling := t.bounds(method)
+
+	t.mu.Lock()
+	w := t.samples[method]
+	if w == nil || len(w.latencies) < t.settings.MinSamples {
+		t.mu.Unlock()
+		return ceiling
+	}
+	sorted := slices.Clone(w.latencies)
+	t.mu.Unlock()
+
+	slices.Sort(sorted)
+	i := int(math.Ceil(t.settings.Percentile*float64(len(sorted)))) - 1
+	d := time.Duration(float64(sorted[max(i, 0)]) * t.settings.Headroom)
+	return min(max(d, floor), ceiling)
+}
+
+// observe records the latency of a call to method.
+func (t *latencyTracker) observe(method rpcMethod, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := t.samples[method]
+	if w == nil {
+		w = &latencyWindow{}
+		t.samples[method] = w
+	}
+	if len(w.latencies) < t.settings.Window {
+		w.latencies = append(w.latencies, latency)
+		return
+	}
+	w.latencies[w.next] = latency
+	w.next = (w.next + 1) % t.settings.Window
+}
+
+// rpcTimeout returns the deadline of an RPC to method; zero means none.
+func (cfg *config) rpcTimeout(method rpcMethod) time.Duration {
+	if method == methodSandboxStart && cfg.startTimeout > 0 {
+		return cfg.startTimeout
+	}
+	if cfg.adaptiveTimeout != nil {
+		return cfg.adaptiveTimeout.timeout(method)
+	}
+	return cfg.timeout
+}
@@ -0,0 +1,129 @@
+package msb
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// ArtifactCollector gathers the files an execution produced inside the sandbox.
+type ArtifactCollector interface {
+	// GetArtifacts packs the given files and directories from the sandbox into a tar archive and
+	// transfers it in one go, e.g. generated plots, reports or compiled binaries. Relative paths
+	// are resolved against the working directory set with [WithWorkdir], and symbolic links are
+	// followed. Without paths, it collects the contents of the directory set with
+	// [WithArtifactsDir]. A missing path yields an error matching fs.ErrNotExist. The archive is
+	// compressed in transit as chosen with [WithDefaultCompression].
+	//
+	//	_, err := sandbox.Code().Run(ctx, "import matplotlib.pyplot as plt\nplt.plot([1, 2])\nplt.savefig('/out/plot.png')")
+	//	artifacts, err := sandbox.GetArtifacts(ctx, "/out/plot.png", "/out/report")
+	//	err = artifacts.Extract("./results")
+	GetArtifacts(ctx context.Context, paths ...string) (*Artifacts, error)
+}
+
+// WithArtifactsDir declares dir as the directory inside the sandbox where executions leave
+// their artifacts, collected by GetArtifacts when called without paths.
+func WithArtifactsDir(dir string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.artifactsDir = dir
+	}
+}
+
+// Artifacts is a tar archive of files collected from the sandbox. Entry names are the collected
+// paths without their leading "/", or, for the artifacts directory, paths relative to it.
+type Artifacts struct {
+	data []byte
+}
+
+// Reader returns the archive as a tar stream.
+func (a *Artifacts) Reader() io.Reader {
+	return bytes.NewReader(a.data)
+}
+
+// Size returns the size of the archive in bytes.
+func (a *Artifacts) Size() int {
+	return len(a.data)
+}
+
+// Names returns the names of the regular files in the archive, in archive order.
+func (a *Artifacts) Names() ([]string, error) {
+	var names []string
+	tr := tar.NewReader(a.Reader())
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			names = append(names, strings.TrimPrefix(hdr.Name, "./"))
+		}
+	}
+}
+
+// Extract writes the archive's files and directories under the local directory dir, rejecting
+// entries that would escape it.
+func (a *Artifacts) Extract(dir string) error {
+	return untarDir(a.Reader(), dir)
+}
+
+type artifactCollector struct {
+	b *baseMicroSandbox
+}
+
+func (c artifactCollector) GetArtifacts(ctx context.Context, paths ...string) (*Artifacts, error) {
+	cfg := c.b.config()
+	var pack string
+	if len(paths) == 0 {
+		if cfg.artifactsDir == "" {
+			return nil, ErrNoArtifactPaths
+		}
+		pack = `[ -d ` + shellQuote(cfg.artifactsDir) + ` ] || { echo ` + shellQuote(cfg.artifactsDir) + ` >&2; exit 2; }
+tar -chf "$a" -C ` + shellQuote(cfg.artifactsDir) + ` .`
+	} else {
+		quoted := make([]string, len(paths))
+		for i, p := range paths {
+			quoted[i] = shellQuote(p)
+		}
+		list := strings.Join(quoted, " ")
+		pack = `for p in ` + list + `; do [ -e "$p" ] || { echo "$p" >&2; exit 2; }; done
+tar -chf "$a" ` + list
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToCollectArtifacts, err)
+	}
+	archive := guestPayloadsDir + "/" + id + ".tar"
+	exec, err := c.b.runShell(ctx, workdirPrelude(cfg.workdir)+`a=`+shellQuote(archive)+`; mkdir -p `+shellQuote(guestPayloadsDir)+` || exit 1
+`+pack)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToCollectArtifacts, err)
+	}
+	if exec.GetExitCode() == 2 {
+		return nil, fmt.Errorf("%w: %w: %s", ErrFailedToCollectArtifacts, fs.ErrNotExist, commandStderr(exec))
+	}
+	fsys := fileSystem{c.b}
+	if !exec.IsSuccess() {
+		_ = fsys.RemoveFile(context.WithoutCancel(ctx), archive)
+		return nil, fmt.Errorf("%w: %s", ErrFailedToCollectArtifacts, commandStderr(exec))
+	}
+	data, err := fsys.ReadFile(ctx, archive)
+	_ = fsys.RemoveFile(context.WithoutCancel(ctx), archive)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToCollectArtifacts, err)
+	}
+	return &Artifacts{data: data}, nil
+}
+
+// Artifact-related errors
+var (
+	ErrNoArtifactPaths          = errors.New("no artifact paths given and no artifacts directory set")
+	ErrFailedToCollectArtifacts = errors.New("failed to collect artifacts")
+)
@@ -0,0 +1,89 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Attacher binds a sandbox to one that is already running on the server, so that processes can
+// share sandboxes instead of each owning the lifecycle of its own.
+type Attacher interface {
+	// Attach binds the sandbox to the running sandbox name of its namespace, started by another
+	// process or the CLI, instead of starting one; an empty name keeps the one set with
+	// [WithName]. Code and commands then run in it as after Start, and Stop stops it on the
+	// server; use Detach to let go of it instead. It fails with [ErrSandboxNotFound] if no such
+	// sandbox is running.
+	//
+	// Attaching is not starting: [WithOnStart] hooks are not called, so janitors do not track
+	// the sandbox, [WithIdleTimeout] does not apply, the sandbox's image is unknown to
+	// [Forker.Fork], and Uptime counts from Attach.
+	Attach(ctx context.Context, name string) error
+	// Detach unbinds the started or attached sandbox and leaves it running on the server, for
+	// another process to Attach to. It fails with [ErrSandboxNotStarted] if there is none.
+	Detach() error
+}
+
+type attacher struct {
+	b *baseMicroSandbox
+}
+
+func (a attacher) Attach(ctx context.Context, name string) error {
+	if !a.b.state.CompareAndSwap(off, starting) {
+		if a.b.state.Load() == started {
+			return ErrSandboxAlreadyStarted
+		}
+		return ErrSandboxTransitioning
+	}
+	if err := a.attach(ctx, name); err != nil {
+		a.b.state.Store(off)
+		return fmt.Errorf("%w: %w", ErrFailedToAttach, err)
+	}
+	a.b.state.Store(started)
+	a.b.startedAt.Store(time.Now().UnixNano())
+	a.b.lastStart.Store(nil)
+	a.b.recordActive(1)
+	a.b.startProbes()
+	a.b.logger().Info("Attached to sandbox", "sandbox", a.b.name(), "namespace", a.b.config().namespace)
+	return nil
+}
+
+// attach checks that the sandbox name is running, and addresses it from then on.
+func (a attacher) attach(ctx context.Context, name string) error {
+	if a.b.config().serverVersionCheck {
+		if err := a.b.checkServerVersion(ctx); err != nil {
+			return err
+		}
+	}
+	cfg := a.b.config()
+	if name != "" {
+		cfg.name = name
+	}
+	metrics, err := a.b.rpcClient.getMetrics(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if metrics.Name == "" || !metrics.Running {
+		return fmt.Errorf("%w: %q", ErrSandboxNotFound, cfg.name)
+	}
+	a.b.updateConfig(func(c *config) { c.name = cfg.name })
+	return nil
+}
+
+func (a attacher) Detach() error {
+	if !a.b.state.CompareAndSwap(started, stopping) {
+		if a.b.state.Load() == off {
+			return ErrSandboxNotStarted
+		}
+		return ErrSandboxTransitioning
+	}
+	a.b.stopProbes()
+	a.b.stopIdleWatch()
+	a.b.release()
+	a.b.logger().Info("Detached from sandbox", "sandbox", a.b.name())
+	return nil
+}
+
+// Attach-related errors
+var ErrFailedToAttach = errors.New("failed to attach to sandbox")
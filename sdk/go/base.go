@@ -2,18 +2,21 @@ package msb
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 	"sync/atomic"
 )
 
 // newBaseWithOptions creates a new [*baseMicroSandbox] instance with the provided configuration options.
-// Language must be specified via withLanguage(). API key must be provided via WithApiKey()
-// or MSB_API_KEY environment variable.
+// Language must be specified via withLanguage(). API key must be provided via WithApiKey(),
+// WithTokenProvider() or MSB_API_KEY environment variable.
 func newBaseWithOptions(options ...Option) *baseMicroSandbox {
-	msb := &baseMicroSandbox{}
+	msb := &baseMicroSandbox{options: options}
 	for _, opt := range append(options,
 		fillDefaultConfigs(),
 		fillDefaultLogger(),
 		fillDefaultRPCClient(),
+		startPreconnect(),
 	) {
 		opt(msb)
 	}
@@ -22,14 +25,81 @@ func newBaseWithOptions(options ...Option) *baseMicroSandbox {
 
 // container struct that holds state, configs, underpinning all microsandboxes
 type baseMicroSandbox struct {
+	cfgMu     sync.RWMutex // guards cfg against runtime mutation; RPCs work on a snapshot taken via config()
 	cfg       config
+	options   []Option      // as given to the constructor, to derive forks
 	state     atomic.Uint32 // we use a lightweight primitive to prevent racing starts / stops; every other method is safe to route concurrently to the underlying (thread-safe) http client
 	rpcClient rpcClient
+
+	execLimiter  *tokenBucket                // throttles Code().Run and Command().Run; nil when unlimited
+	execQueue    *execQueue                  // bounds concurrent executions; nil when unlimited
+	spec         atomic.Pointer[SandboxSpec] // recorded at start when WithSpecSnapshots is enabled
+	startedAt    atomic.Int64                // unix nanoseconds of the last successful Start; 0 when stopped
+	lastStart    atomic.Pointer[startArgs]   // arguments of the last successful Start
+	dedupe       *dedupeGroup                // collapses identical code executions; nil when disabled
+	prober       atomic.Pointer[prober]      // runs the probes while started; nil when stopped
+	idleWatcher  atomic.Pointer[idleWatcher] // stops the sandbox when idle; nil when stopped or without WithIdleTimeout
+	lastActive   atomic.Int64                // unix nanoseconds of the last execution or Touch
+	inFlight     atomic.Int64                // executions holding a slot of waitExecSlot
+	fileWrites   sync.WaitGroup              // file writes in flight, awaited by Sync
+	sharedWrites sharedWrites                // shared directory writes not yet seen in the guest
+
+	capabilities atomic.Pointer[Capabilities] // detected on first use after Start; nil until then
+	serverInfo   atomic.Pointer[ServerInfo]   // fetched on first use; nil until then and after Stop
+	replWorkdirs sync.Map                     // working directory each language's REPL was moved to since Start
+
+	sharedDirState atomic.Int32 // whether WithSharedDir's directory is reachable locally; probed once per Start
+}
+
+// config returns a snapshot of the current configuration. In-flight RPCs keep using the snapshot
+// they started with, so runtime mutation never races with them.
+func (b *baseMicroSandbox) config() *config {
+	b.cfgMu.RLock()
+	defer b.cfgMu.RUnlock()
+	cfg := b.cfg
+	return &cfg
+}
+
+// updateConfig applies fn to the configuration under the write lock.
+func (b *baseMicroSandbox) updateConfig(fn func(*config)) {
+	b.cfgMu.Lock()
+	defer b.cfgMu.Unlock()
+	fn(&b.cfg)
+}
+
+func (b *baseMicroSandbox) logger() Logger {
+	return b.config().logger
+}
+
+func (b *baseMicroSandbox) name() string {
+	return b.config().name
+}
+
+// parsedExecution is implemented by execution results that parse the server's raw output.
+type parsedExecution interface {
+	CodeExecution | CommandExecution
+}
+
+// checkParsed enforces strict parsing: when enabled, an unparseable execution result is
+// reported as an error wrapping failure and [ErrExecutionNotParsed] instead of being returned.
+func checkParsed[E parsedExecution](b *baseMicroSandbox, exec E, failure error) (E, error) {
+	var parsedOK bool
+	switch e := any(exec).(type) {
+	case CodeExecution:
+		parsedOK = e.parsedOK
+	case CommandExecution:
+		parsedOK = e.parsedOK
+	}
+	if !parsedOK && b.config().strictParsing {
+		return exec, fmt.Errorf("%w: %w", failure, ErrExecutionNotParsed)
+	}
+	return exec, nil
 }
 
 var (
 	ErrSandboxAlreadyStarted = errors.New("sandbox already started")
 	ErrSandboxNotStarted     = errors.New("sandbox not started")
+	ErrSandboxTransitioning  = errors.New("sandbox is starting or stopping")
 	ErrFailedToStartSandbox  = errors.New("failed to start sandbox")
 	ErrFailedToStopSandbox   = errors.New("failed to stop sandbox")
 	ErrFailedToRunCode       = errors.New("failed to run code")
@@ -0,0 +1,110 @@
+package msb
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CommandSpec is a command and its arguments, as run by [CommandRunner.RunCommands].
+type CommandSpec struct {
+	Cmd  string
+	Args []string
+}
+
+// BatchMode controls how RunCommands reacts to a failing command.
+type BatchMode int
+
+const (
+	// ContinueOnError runs every command regardless of earlier failures.
+	ContinueOnError BatchMode = iota
+	// StopOnError halts the batch at the first command exiting non-zero.
+	StopOnError
+)
+
+func (cr commandRunner) RunCommands(ctx context.Context, cmds []CommandSpec, mode BatchMode) ([]CommandExecution, error) {
+	if cr.b.state.Load() != started {
+		return nil, ErrSandboxNotStarted
+	}
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	release, err := cr.b.waitExecSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Each command's streams are captured separately and reported on one tagged line, base64-encoded
+	// so that they survive the line-oriented RPC. A ':' prefix keeps empty streams from vanishing
+	// with trailing whitespace.
+	var script strings.Builder
+	script.WriteString(`d=$(mktemp -d) || exit 1
+trap 'rm -rf "$d"' EXIT
+`)
+	script.WriteString(workdirPrelude(cr.b.config().workdir))
+	for _, c := range cmds {
+		script.WriteString(`( exec ` + commandScript(c.Cmd, c.Args) + ` ) >"$d/o" 2>"$d/e" </dev/null; s=$?
+echo "R $s :$(base64 <"$d/o" | tr -d '\n') :$(base64 <"$d/e" | tr -d '\n')"
+`)
+		if mode == StopOnError {
+			script.WriteString("[ $s -eq 0 ] || exit 0\n")
+		}
+	}
+	exec, err := cr.b.runShell(ctx, script.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+	}
+	out, err := exec.GetOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+	}
+
+	results := make([]CommandExecution, 0, len(cmds))
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() && len(results) < len(cmds) {
+		fields := strings.Split(sc.Text(), " ")
+		if len(fields) != 4 || fields[0] != "R" {
+			continue
+		}
+		code, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return results, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+		}
+		stdout, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(fields[2], ":"))
+		if err != nil {
+			return results, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+		}
+		stderr, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(fields[3], ":"))
+		if err != nil {
+			return results, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+		}
+		c := cmds[len(results)]
+		result := commandExecutionFromStreams(c.Cmd, c.Args, code, stdout, stderr, TerminationReason{})
+		result.spec = cr.b.spec.Load()
+		cr.b.emitExecutionOutput(ctx, result.parsed.OutputLines)
+		results = append(results, result)
+	}
+	if err := sc.Err(); err != nil {
+		return results, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+
+	if n := len(results); n > 0 && mode == StopOnError && !results[n-1].IsSuccess() {
+		return results, fmt.Errorf("%w: command %d (%s) exited with code %d", ErrBatchStopped, n-1, cmds[n-1].Cmd, results[n-1].GetExitCode())
+	}
+	if len(results) < len(cmds) {
+		stderr, _ := exec.GetError()
+		return results, fmt.Errorf("%w: batch ended after %d of %d commands: %s", ErrFailedToRunCommand, len(results), len(cmds), strings.TrimSpace(stderr))
+	}
+	return results, nil
+}
+
+// Batch execution errors
+var (
+	ErrBatchStopped = errors.New("command batch stopped at failing command")
+)
@@ -0,0 +1,232 @@
+// Package bench measures how a microsandbox server performs for a given image and configuration:
+// cold start time, execution round-trip latency and data transfer throughput. Reports are plain
+// structs with JSON tags, so runs against different regions, images or settings can be stored
+// and compared.
+//
+//	report, err := bench.Run(ctx, bench.Config{
+//		Label:   "eu-west",
+//		Options: []msb.Option{msb.WithServerUrl("https://eu.example.com")},
+//	})
+package bench
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// Config describes a benchmark run.
+type Config struct {
+	// Label identifies the run in its report, e.g. a region or configuration name.
+	Label string
+	// Image, MemoryMB and CPUs are passed to Start. Empty/zero values use the SDK defaults.
+	Image    string
+	MemoryMB int
+	CPUs     int
+	// Options configure the sandbox under test (server URL, API key, ...).
+	Options []msb.Option
+	// NewSandbox creates the sandbox under test. Defaults to a Python sandbox.
+	NewSandbox func(opts ...msb.Option) msb.LangSandBox
+	// ExecIterations is the number of round-trips timed. Defaults to 20.
+	ExecIterations int
+	// TransferBytes is the payload size for the throughput tests. Defaults to 1 MiB.
+	TransferBytes int
+}
+
+// Report is the outcome of a benchmark run.
+type Report struct {
+	Label     string        `json:"label"`
+	Image     string        `json:"image"`
+	MemoryMB  int           `json:"memory_mb"`
+	CPUs      int           `json:"cpus"`
+	StartedAt time.Time     `json:"started_at"`
+	ColdStart time.Duration `json:"cold_start_ns"`
+	// CommandRoundTrip times a no-op command; CodeRoundTrip times a no-op REPL execution.
+	CommandRoundTrip Latency `json:"command_round_trip"`
+	CodeRoundTrip    Latency `json:"code_round_trip"`
+	// Upload and download throughput in bytes per second, moving data through command executions.
+	UploadBytesPerSec   float64       `json:"upload_bytes_per_sec"`
+	DownloadBytesPerSec float64       `json:"download_bytes_per_sec"`
+	Stop                time.Duration `json:"stop_ns"`
+}
+
+// Latency summarizes a set of timed samples.
+type Latency struct {
+	Samples int           `json:"samples"`
+	Min     time.Duration `json:"min_ns"`
+	Mean    time.Duration `json:"mean_ns"`
+	P50     time.Duration `json:"p50_ns"`
+	P95     time.Duration `json:"p95_ns"`
+	P99     time.Duration `json:"p99_ns"`
+	Max     time.Duration `json:"max_ns"`
+}
+
+// uploadChunkBytes keeps each upload command below the guest's per-argument size limit.
+const uploadChunkBytes = 48 * 1024
+
+// Run starts a fresh sandbox, measures it and stops it again. On failure, the returned report
+// holds the measurements taken so far.
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	cfg = withDefaults(cfg)
+	report := Report{
+		Label:     cfg.Label,
+		Image:     cfg.Image,
+		MemoryMB:  cfg.MemoryMB,
+		CPUs:      cfg.CPUs,
+		StartedAt: time.Now(),
+	}
+	sandbox := cfg.NewSandbox(cfg.Options...)
+
+	start := time.Now()
+	if err := sandbox.Start(ctx, cfg.Image, cfg.MemoryMB, cfg.CPUs); err != nil {
+		return report, fmt.Errorf("%w: %w", ErrBenchmarkFailed, err)
+	}
+	report.ColdStart = time.Since(start)
+
+	err := measure(ctx, sandbox, cfg, &report)
+
+	stop := time.Now()
+	if stopErr := sandbox.Stop(context.WithoutCancel(ctx)); stopErr != nil {
+		err = errors.Join(err, stopErr)
+	} else {
+		report.Stop = time.Since(stop)
+	}
+	if err != nil {
+		return report, fmt.Errorf("%w: %w", ErrBenchmarkFailed, err)
+	}
+	return report, nil
+}
+
+func withDefaults(cfg Config) Config {
+	if cfg.NewSandbox == nil {
+		cfg.NewSandbox = func(opts ...msb.Option) msb.LangSandBox { return msb.NewPythonSandbox(opts...) }
+	}
+	if cfg.ExecIterations <= 0 {
+		cfg.ExecIterations = 20
+	}
+	if cfg.TransferBytes <= 0 {
+		cfg.TransferBytes = 1 << 20
+	}
+	return cfg
+}
+
+func measure(ctx context.Context, sandbox msb.LangSandBox, cfg Config, report *Report) error {
+	var err error
+	report.CommandRoundTrip, err = timeSamples(cfg.ExecIterations, func() error {
+		_, err := sandbox.Command().Run(ctx, "true", nil)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("command round-trip: %w", err)
+	}
+	report.CodeRoundTrip, err = timeSamples(cfg.ExecIterations, func() error {
+		_, err := sandbox.Code().Run(ctx, "0")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("code round-trip: %w", err)
+	}
+
+	if report.UploadBytesPerSec, err = measureUpload(ctx, sandbox, cfg.TransferBytes); err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	if report.DownloadBytesPerSec, err = measureDownload(ctx, sandbox, cfg.TransferBytes); err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	return nil
+}
+
+func timeSamples(n int, fn func() error) (Latency, error) {
+	samples := make([]time.Duration, 0, n)
+	for range n {
+		start := time.Now()
+		if err := fn(); err != nil {
+			return Latency{}, err
+		}
+		samples = append(samples, time.Since(start))
+	}
+	return summarize(samples), nil
+}
+
+func summarize(samples []time.Duration) Latency {
+	if len(samples) == 0 {
+		return Latency{}
+	}
+	slices.Sort(samples)
+	var total time.Duration
+	for _, s := range samples {
+		total += s
+	}
+	pct := func(p float64) time.Duration {
+		return samples[min(len(samples)-1, int(p*float64(len(samples))))]
+	}
+	return Latency{
+		Samples: len(samples),
+		Min:     samples[0],
+		Mean:    total / time.Duration(len(samples)),
+		P50:     pct(0.50),
+		P95:     pct(0.95),
+		P99:     pct(0.99),
+		Max:     samples[len(samples)-1],
+	}
+}
+
+// measureUpload sends n random bytes into the guest, base64-encoded in command arguments,
+// and checks that they all arrived.
+func measureUpload(ctx context.Context, sandbox msb.LangSandBox, n int) (float64, error) {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		return 0, err
+	}
+	f := "/tmp/.msb-bench-upload"
+	start := time.Now()
+	if _, err := sandbox.Command().Run(ctx, "sh", []string{"-c", ": >" + f}); err != nil {
+		return 0, err
+	}
+	for off := 0; off < n; off += uploadChunkBytes {
+		chunk := base64.StdEncoding.EncodeToString(data[off:min(off+uploadChunkBytes, n)])
+		if _, err := sandbox.Command().Run(ctx, "sh", []string{"-c", "printf '%s' " + chunk + " | base64 -d >>" + f}); err != nil {
+			return 0, err
+		}
+	}
+	exec, err := sandbox.Command().Run(ctx, "sh", []string{"-c", "wc -c <" + f + "; rm -f " + f})
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	out, _ := exec.GetOutput()
+	if got, _ := strconv.Atoi(strings.TrimSpace(out)); got != n {
+		return 0, fmt.Errorf("guest received %d of %d bytes", got, n)
+	}
+	return float64(n) / elapsed.Seconds(), nil
+}
+
+// measureDownload reads n random bytes generated in the guest back through a command execution.
+func measureDownload(ctx context.Context, sandbox msb.LangSandBox, n int) (float64, error) {
+	start := time.Now()
+	exec, err := sandbox.Command().Run(ctx, "sh", []string{"-c", "head -c " + strconv.Itoa(n) + " /dev/urandom | base64"})
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	out, _ := exec.GetOutput()
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(out, "\n", ""))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != n {
+		return 0, fmt.Errorf("received %d of %d bytes", len(data), n)
+	}
+	return float64(n) / elapsed.Seconds(), nil
+}
+
+// ErrBenchmarkFailed is returned when a benchmark run cannot complete.
+var ErrBenchmarkFailed = errors.New("benchmark failed")
@@ -0,0 +1,170 @@
+package msb
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// StartAll starts every sandbox concurrently, running at most parallelism starts at a time
+// (parallelism <= 0 means unbounded). image, memoryMB and cpus are passed to each Start call.
+// Sandboxes not yet started when ctx is done are skipped and reported with ctx's error.
+// The returned error is a [*MultiError] aggregating every failure by the sandbox's index.
+func StartAll[S Starter](ctx context.Context, sandboxes []S, parallelism int, image string, memoryMB int, cpus int) error {
+	errs := forEachBounded(ctx, len(sandboxes), parallelism, func(i int) error {
+		return sandboxes[i].Start(ctx, image, memoryMB, cpus)
+	})
+	return joinIndexed(errs)
+}
+
+// StopAll stops every sandbox concurrently, running at most parallelism stops at a time
+// (parallelism <= 0 means unbounded). The returned error is a [*MultiError] aggregating every
+// failure by the sandbox's index.
+func StopAll[S Stopper](ctx context.Context, sandboxes []S, parallelism int) error {
+	errs := forEachBounded(ctx, len(sandboxes), parallelism, func(i int) error {
+		return sandboxes[i].Stop(ctx)
+	})
+	return joinIndexed(errs)
+}
+
+// BatchSpec describes identically configured sandboxes created by [CreateSandboxes].
+type BatchSpec struct {
+	// Name prefixes the names of the sandboxes, which are suffixed with their index. Defaults
+	// to a random "batch-" name.
+	Name string
+	// NewSandbox creates a sandbox. Defaults to [NewPythonSandbox].
+	NewSandbox func(options ...Option) LangSandBox
+	// Options configure every sandbox. CreateSandboxes adds a WithName option of its own.
+	Options []Option
+	// Image, MemoryMB and CPUs are passed to Start.
+	Image    string
+	MemoryMB int
+	CPUs     int
+	// Parallelism bounds the starts in flight; zero starts all sandboxes at once.
+	Parallelism int
+}
+
+// CreateSandboxes creates and starts n sandboxes as described by spec, pipelining the starts so
+// that setting up a large parallel evaluation takes about as long as the slowest start rather
+// than the sum of them. It returns a handle and an error per sandbox, at the sandbox's index:
+// the handle of a sandbox that failed to start is nil. Sandboxes not yet started when ctx is
+// done are reported with ctx's error.
+func CreateSandboxes(ctx context.Context, spec BatchSpec, n int) ([]LangSandBox, []error) {
+	if spec.Name == "" {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			errs := make([]error, n)
+			for i := range errs {
+				errs[i] = err
+			}
+			return make([]LangSandBox, n), errs
+		}
+		spec.Name = fmt.Sprintf("batch-%x", suffix)
+	}
+	if spec.NewSandbox == nil {
+		spec.NewSandbox = func(options ...Option) LangSandBox { return NewPythonSandbox(options...) }
+	}
+	sandboxes := make([]LangSandBox, n)
+	errs := forEachBounded(ctx, n, spec.Parallelism, func(i int) error {
+		opts := append(spec.Options[:len(spec.Options):len(spec.Options)], WithName(fmt.Sprintf("%s-%d", spec.Name, i)))
+		sb := spec.NewSandbox(opts...)
+		if err := sb.Start(ctx, spec.Image, spec.MemoryMB, spec.CPUs); err != nil {
+			return err
+		}
+		sandboxes[i] = sb
+		return nil
+	})
+	return sandboxes, errs
+}
+
+// forEachBounded calls fn for every index in [0, n) with at most parallelism calls in flight,
+// and returns the per-index errors.
+func forEachBounded(ctx context.Context, n int, parallelism int, fn func(i int) error) []error {
+	if parallelism <= 0 || parallelism > n {
+		parallelism = n
+	}
+	errs := make([]error, n)
+	sem := make(chan struct{}, max(parallelism, 1))
+	var wg sync.WaitGroup
+	for i := range n {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < n; j++ {
+				errs[j] = ctx.Err()
+			}
+			wg.Wait()
+			return errs
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = fn(i)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// MultiError aggregates the failures of a bulk operation over sandboxes, such as [StartAll] and
+// [StopAll], by the index of the sandbox that failed. It matches errors.Is and errors.As against
+// any of the failures.
+type MultiError struct {
+	errs []error // by index; nil where the operation succeeded
+	item string  // what the indices refer to; "sandbox" if empty
+}
+
+// PerItem returns the error of every item of the operation, at the item's index; it is nil for
+// items that succeeded.
+func (e *MultiError) PerItem() []error {
+	return slices.Clone(e.errs)
+}
+
+// Failed returns the indices of the items that failed, in order.
+func (e *MultiError) Failed() []int {
+	var failed []int
+	for i, err := range e.errs {
+		if err != nil {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// Error lists the failures, one per line, each prefixed with the item's index.
+func (e *MultiError) Error() string {
+	item := cmp.Or(e.item, "sandbox")
+	var lines []string
+	for i, err := range e.errs {
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s %d: %v", item, i, err))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the failures, in index order.
+func (e *MultiError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// joinIndexed returns a *MultiError of the per-index errors, or nil if there are none.
+func joinIndexed(errs []error) error {
+	if !slices.ContainsFunc(errs, func(err error) bool { return err != nil }) {
+		return nil
+	}
+	return &MultiError{errs: errs}
+}
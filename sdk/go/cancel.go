@@ -0,0 +1,51 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Canceler cancels executions by ID, including ones started by other clients.
+type Canceler interface {
+	// Cancel kills the background execution with the given ID, as reported by [Process.ID], along
+	// with every process it spawned. It works from any client configured with the sandbox's name,
+	// namespace and credentials; the sandbox does not need to have been started by this client.
+	// It fails with [ErrGuestProcessNotFound] if no such execution exists.
+	Cancel(ctx context.Context, executionID string) error
+}
+
+type canceler struct {
+	b *baseMicroSandbox
+}
+
+func (c canceler) Cancel(ctx context.Context, executionID string) error {
+	if !validGuestJobID(executionID) {
+		return fmt.Errorf("%w: %q", ErrGuestProcessNotFound, executionID)
+	}
+	// Goes straight to the RPC client: the caller may not own the sandbox's lifecycle
+	script := `d=` + shellQuote(guestJobsDir+"/"+executionID) + `
+[ -f "$d/pid" ] || exit 3
+[ -f "$d/exit" ] && exit 0
+pid=$(cat "$d/pid")
+kill -KILL -- -"$pid" 2>/dev/null || kill -KILL "$pid" 2>/dev/null; true`
+	result, err := c.b.rpcClient.runCommand(ctx, c.b.config(), "sh", []string{"-c", script})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToCancel, err)
+	}
+	exec := newCommandExecution(result)
+	switch {
+	case exec.GetExitCode() == 3:
+		return fmt.Errorf("%w: %q", ErrGuestProcessNotFound, executionID)
+	case !exec.IsSuccess():
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("%w: %s", ErrFailedToCancel, stderr)
+	}
+	c.b.logger().Info("Execution cancelled", "sandbox", c.b.name(), "id", executionID)
+	return nil
+}
+
+// Cancellation errors
+var (
+	ErrFailedToCancel = errors.New("failed to cancel execution")
+)
@@ -0,0 +1,119 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CapabilityReporter reports which optional features a started sandbox supports.
+type CapabilityReporter interface {
+	// Capabilities detects the optional features of the guest image and the server the first
+	// time it is called after Start, and returns the cached result until the sandbox stops.
+	Capabilities(ctx context.Context) (Capabilities, error)
+}
+
+// Capabilities lists the optional features of a sandbox. APIs depending on a missing feature fail
+// with [ErrCapabilityUnavailable].
+type Capabilities struct {
+	// PTY reports whether the guest can allocate pseudo-terminals, for [ShellOpener]; it needs
+	// util-linux's script or Python.
+	PTY bool
+	// CPUMetrics, MemoryMetrics and DiskMetrics report whether the server measures the
+	// sandbox's resource usage, for [MetricsReader]. Disk usage is only measured for overlayfs
+	// and native root filesystems.
+	CPUMetrics    bool
+	MemoryMetrics bool
+	DiskMetrics   bool
+	// Languages lists the sandbox's language and those enabled with [WithLanguages] whose
+	// runtime is installed in the guest, as told by its [Runtime] Check. Executions selecting
+	// another language with [WithLanguage] fail with [ErrUnsupportedLanguage].
+	Languages []Language
+}
+
+// Capability names, reported by errors wrapping ErrCapabilityUnavailable
+const (
+	capabilityPTY           = "pty"
+	capabilityCPUMetrics    = "cpu metrics"
+	capabilityMemoryMetrics = "memory metrics"
+	capabilityDiskMetrics   = "disk metrics"
+	capabilityLanguage      = "language"
+)
+
+// capabilitiesScript prints the guest's capabilities, one per line.
+const capabilitiesScript = `if command -v script >/dev/null 2>&1 || command -v python3 >/dev/null 2>&1 || command -v python >/dev/null 2>&1; then echo ` + capabilityPTY + `; fi`
+
+// languagesScript extends capabilitiesScript to print "language <name>" for each of languages
+// whose runtime is installed.
+func languagesScript(languages []Language) string {
+	var sb strings.Builder
+	sb.WriteString(capabilitiesScript)
+	for _, l := range languages {
+		rt, err := lookupLanguage(string(l))
+		if err != nil {
+			continue
+		}
+		line := "echo " + shellQuote(capabilityLanguage+" "+string(rt.Name))
+		if rt.Check != "" {
+			line = "if (" + rt.Check + ") >/dev/null 2>&1; then " + line + "; fi"
+		}
+		sb.WriteString("\n" + line)
+	}
+	return sb.String()
+}
+
+type capabilityReporter struct {
+	b *baseMicroSandbox
+	l Language
+}
+
+func (c capabilityReporter) Capabilities(ctx context.Context) (Capabilities, error) {
+	if c.b.state.Load() != started {
+		return Capabilities{}, ErrSandboxNotStarted
+	}
+	if caps := c.b.capabilities.Load(); caps != nil {
+		return *caps, nil
+	}
+
+	exec, err := c.b.runShell(ctx, languagesScript(append([]Language{c.l}, c.b.config().languages...)))
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("%w: %w", ErrFailedToDetectCapabilities, err)
+	}
+	if !exec.IsSuccess() {
+		return Capabilities{}, fmt.Errorf("%w: %s", ErrFailedToDetectCapabilities, commandStderr(exec))
+	}
+	stdout, _ := exec.GetOutput()
+	metrics, err := c.b.rpcClient.getMetrics(ctx, c.b.config())
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("%w: %w", ErrFailedToDetectCapabilities, err)
+	}
+
+	caps := Capabilities{
+		CPUMetrics:    metrics.CPUUsage != nil,
+		MemoryMetrics: metrics.MemoryUsage != nil,
+		DiskMetrics:   metrics.DiskUsage != nil,
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		if line == capabilityPTY {
+			caps.PTY = true
+		} else if l, ok := strings.CutPrefix(line, capabilityLanguage+" "); ok && !slices.Contains(caps.Languages, Language(l)) {
+			caps.Languages = append(caps.Languages, Language(l))
+		}
+	}
+	c.b.logger().Debug("Detected sandbox capabilities", "sandbox", c.b.name(), "capabilities", caps)
+	c.b.capabilities.Store(&caps)
+	return caps, nil
+}
+
+// unavailable returns an error wrapping ErrCapabilityUnavailable for the named capability.
+func unavailable(capability string) error {
+	return fmt.Errorf("%w: %s", ErrCapabilityUnavailable, capability)
+}
+
+// Capability-related errors
+var (
+	ErrCapabilityUnavailable      = errors.New("capability unavailable in this sandbox")
+	ErrFailedToDetectCapabilities = errors.New("failed to detect sandbox capabilities")
+)
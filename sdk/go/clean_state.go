@@ -0,0 +1,292 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CleanStateVerifier checks that a sandbox holds no leftovers before it is stopped or reused.
+type CleanStateVerifier interface {
+	// VerifyCleanState lists the processes, listening TCP sockets and large temporary files left
+	// in the sandbox that rules do not allow. The processes serving the sandbox itself, such as
+	// the portal, the language REPLs and the idle watchdog, are never reported. The returned
+	// error tells whether the sandbox could be inspected; see [CleanStateReport.Err] to fail on
+	// violations.
+	//
+	//	report, err := sandbox.VerifyCleanState(ctx, msb.CleanStateRules{AllowedProcesses: []string{"redis-server"}})
+	//	if err == nil {
+	//		err = report.Err()
+	//	}
+	VerifyCleanState(ctx context.Context, rules CleanStateRules) (CleanStateReport, error)
+}
+
+// CleanStateRules tells VerifyCleanState what may remain in the sandbox.
+type CleanStateRules struct {
+	// AllowedProcesses are patterns, in the syntax of [path.Match], for the names of processes
+	// that may keep running, e.g. "redis-server" or "python*".
+	AllowedProcesses []string
+	// AllowedPorts are the TCP ports that may be listened on.
+	AllowedPorts []int
+	// TempDirs are the directories searched for large files. Defaults to /tmp and /var/tmp.
+	TempDirs []string
+	// MaxTempFileBytes is the size above which a file in TempDirs is reported. Defaults to
+	// 1 MiB; a negative value disables the check.
+	MaxTempFileBytes int64
+}
+
+// CleanStateViolationKind tells what a [CleanStateViolation] found.
+type CleanStateViolationKind string
+
+const (
+	ViolationProcess  CleanStateViolationKind = "process"
+	ViolationListener CleanStateViolationKind = "listener"
+	ViolationTempFile CleanStateViolationKind = "temp_file"
+)
+
+// CleanStateViolation is one leftover found by VerifyCleanState.
+type CleanStateViolation struct {
+	Kind CleanStateViolationKind
+	// PID, Name and Command describe the leftover process, or the process listening on Port;
+	// they are zero for a listener whose owner could not be found.
+	PID     int
+	Name    string
+	Command string
+	// Port is the listening port, for a listener.
+	Port int
+	// Path and Size describe the leftover file, for a temp file.
+	Path string
+	Size int64
+}
+
+// String describes the violation on one line.
+func (v CleanStateViolation) String() string {
+	switch v.Kind {
+	case ViolationProcess:
+		return fmt.Sprintf("process %d (%s) still running: %s", v.PID, v.Name, v.Command)
+	case ViolationListener:
+		if v.PID == 0 {
+			return fmt.Sprintf("port %d still listening", v.Port)
+		}
+		return fmt.Sprintf("port %d still listening, by process %d (%s)", v.Port, v.PID, v.Name)
+	case ViolationTempFile:
+		return fmt.Sprintf("temp file %s left behind (%d bytes)", v.Path, v.Size)
+	}
+	return string(v.Kind)
+}
+
+// CleanStateReport is the outcome of VerifyCleanState.
+type CleanStateReport struct {
+	Violations []CleanStateViolation
+}
+
+// Clean reports whether no violations were found.
+func (r CleanStateReport) Clean() bool {
+	return len(r.Violations) == 0
+}
+
+// Err returns nil if the report is clean, and otherwise an error wrapping ErrUncleanState that
+// lists the violations.
+func (r CleanStateReport) Err() error {
+	if r.Clean() {
+		return nil
+	}
+	lines := make([]string, len(r.Violations))
+	for i, v := range r.Violations {
+		lines[i] = v.String()
+	}
+	return fmt.Errorf("%w: %s", ErrUncleanState, strings.Join(lines, "; "))
+}
+
+// Defaults of CleanStateRules
+const defaultMaxTempFileBytes = 1 << 20
+
+var defaultTempDirs = []string{"/tmp", "/var/tmp"}
+
+// cleanStateScript lists the guest's processes, their sockets, the listening TCP sockets and the
+// files larger than {size} bytes in {dirs}, one tab-separated record per line tagged with its
+// kind. The SDK's own bookkeeping under /tmp/.msb is skipped.
+const cleanStateScript = `echo "S	$$"
+for d in /proc/[0-9]*; do
+  p=${d#/proc/}
+  s=$(cat "$d/stat" 2>/dev/null) || continue
+  s=${s##*) }; set -- $s
+  printf 'P\t%s\t%s\t%s\t%s\n' "$p" "$2" "$(cat "$d/comm" 2>/dev/null)" "$(tr '\0\t\n' '   ' <"$d/cmdline" 2>/dev/null)"
+  ls -l "$d/fd" 2>/dev/null | sed -n "s/.*socket:\[\([0-9]*\)\].*/I	$p	\1/p"
+done
+cat /proc/net/tcp /proc/net/tcp6 2>/dev/null | awk '$4 == "0A" { n = split($2, a, ":"); print "L\t" a[n] "\t" $10 }'
+if [ {size} -ge 0 ]; then
+  for t in {dirs}; do
+    find "$t" -xdev -path /tmp/.msb -prune -o -type f -size +{size}c -print 2>/dev/null | while IFS= read -r f; do
+      printf 'F\t%s\t%s\n' "$(wc -c <"$f" 2>/dev/null)" "$f"
+    done
+  done
+fi
+true`
+
+// replCommands are the command lines of the portal's language REPLs.
+var replCommands = []string{
+	`python3 -q -u -i -c import sys; sys.ps1=sys.ps2=''`,
+	`node -e const r=require('repl').start(`,
+}
+
+type cleanStateVerifier struct {
+	b *baseMicroSandbox
+}
+
+// guestProc is a process listed by cleanStateScript.
+type guestProc struct {
+	pid, ppid     int
+	name, command string
+}
+
+// listener is a listening socket listed by cleanStateScript.
+type listener struct {
+	port  int
+	inode string
+}
+
+func (v cleanStateVerifier) VerifyCleanState(ctx context.Context, rules CleanStateRules) (CleanStateReport, error) {
+	dirs := rules.TempDirs
+	if len(dirs) == 0 {
+		dirs = defaultTempDirs
+	}
+	size := rules.MaxTempFileBytes
+	if size == 0 {
+		size = defaultMaxTempFileBytes
+	}
+	quoted := make([]string, len(dirs))
+	for i, dir := range dirs {
+		quoted[i] = shellQuote(dir)
+	}
+	script := strings.NewReplacer("{size}", strconv.FormatInt(size, 10), "{dirs}", strings.Join(quoted, " ")).Replace(cleanStateScript)
+	exec, err := v.b.runShell(ctx, script)
+	if err != nil {
+		return CleanStateReport{}, fmt.Errorf("%w: %w", ErrFailedToVerifyCleanState, err)
+	}
+	out, err := exec.GetOutput()
+	if err != nil {
+		return CleanStateReport{}, fmt.Errorf("%w: %w", ErrFailedToVerifyCleanState, err)
+	}
+
+	var (
+		self      int
+		procs     = map[int]guestProc{}
+		order     []int
+		owners    = map[string]int{}
+		listeners []listener
+		files     []CleanStateViolation
+		seenPorts = map[int]bool{}
+	)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		switch {
+		case fields[0] == "S" && len(fields) == 2:
+			self, _ = strconv.Atoi(fields[1])
+		case fields[0] == "P" && len(fields) == 5:
+			pid, err1 := strconv.Atoi(fields[1])
+			ppid, err2 := strconv.Atoi(fields[2])
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			procs[pid] = guestProc{pid: pid, ppid: ppid, name: fields[3], command: strings.TrimSpace(fields[4])}
+			order = append(order, pid)
+		case fields[0] == "I" && len(fields) == 3:
+			if pid, err := strconv.Atoi(fields[1]); err == nil {
+				owners[fields[2]] = pid
+			}
+		case fields[0] == "L" && len(fields) == 3:
+			port, err := strconv.ParseInt(fields[1], 16, 32)
+			if err != nil || seenPorts[int(port)] || slices.Contains(rules.AllowedPorts, int(port)) {
+				continue
+			}
+			seenPorts[int(port)] = true
+			listeners = append(listeners, listener{port: int(port), inode: fields[2]})
+		case fields[0] == "F" && len(fields) == 3:
+			n, _ := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+			files = append(files, CleanStateViolation{Kind: ViolationTempFile, Path: fields[2], Size: n})
+		}
+	}
+	if self == 0 {
+		return CleanStateReport{}, fmt.Errorf("%w: unexpected output from the guest", ErrFailedToVerifyCleanState)
+	}
+
+	infra := sandboxProcesses(procs, self)
+	var report CleanStateReport
+	for _, pid := range order {
+		p := procs[pid]
+		if infra[pid] || allowedProcess(rules.AllowedProcesses, p.name) {
+			continue
+		}
+		report.Violations = append(report.Violations, CleanStateViolation{Kind: ViolationProcess, PID: pid, Name: p.name, Command: p.command})
+	}
+	for _, l := range listeners {
+		v := CleanStateViolation{Kind: ViolationListener, Port: l.port}
+		if pid, ok := owners[l.inode]; ok {
+			if infra[pid] {
+				continue
+			}
+			v.PID, v.Name, v.Command = pid, procs[pid].name, procs[pid].command
+		}
+		report.Violations = append(report.Violations, v)
+	}
+	report.Violations = append(report.Violations, files...)
+	return report, nil
+}
+
+// sandboxProcesses returns the processes serving the sandbox itself: init, kernel threads, the
+// checking shell with its ancestors and descendants, the REPLs, and the idle watchdog with its
+// descendants.
+func sandboxProcesses(procs map[int]guestProc, self int) map[int]bool {
+	infra := map[int]bool{1: true}
+	roots := map[int]bool{self: true}
+	for pid := self; pid > 1; pid = procs[pid].ppid {
+		infra[pid] = true
+		if _, ok := procs[pid]; !ok {
+			break
+		}
+	}
+	for pid, p := range procs {
+		switch {
+		case p.command == "" || p.ppid == 2:
+			infra[pid] = true
+		case strings.Contains(p.command, guestLeaseFile):
+			roots[pid] = true
+		default:
+			for _, repl := range replCommands {
+				if strings.HasPrefix(p.command, repl) {
+					infra[pid] = true
+				}
+			}
+		}
+	}
+	for pid := range procs {
+		for anc, hops := pid, 0; anc > 1 && hops < len(procs); anc, hops = procs[anc].ppid, hops+1 {
+			if roots[anc] {
+				infra[pid] = true
+				break
+			}
+		}
+	}
+	return infra
+}
+
+// allowedProcess reports whether name matches one of patterns.
+func allowedProcess(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Clean state-related errors
+var (
+	ErrFailedToVerifyCleanState = errors.New("failed to verify clean state")
+	ErrUncleanState             = errors.New("sandbox state is not clean")
+)
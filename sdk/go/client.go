@@ -0,0 +1,91 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Client manages the sandboxes on a server, whichever process started them, e.g. for cleanup
+// tooling and dashboards. It is configured with the same options as a sandbox; those naming or
+// configuring a single sandbox are ignored.
+//
+//	client := msb.NewClient(msb.WithNamespace("ci"))
+//	sandboxes, err := client.ListSandboxes(ctx)
+//	for _, sb := range sandboxes {
+//		if sb.IsRunning && sb.MemoryMiB > 2048 {
+//			err = client.StopSandbox(ctx, sb.Name)
+//		}
+//	}
+type Client struct {
+	b *baseMicroSandbox
+}
+
+// NewClient creates a client for the server and namespace configured by options. The API key
+// must be provided via WithApiKey() or the MSB_API_KEY environment variable.
+func NewClient(options ...Option) *Client {
+	return &Client{b: newBaseWithOptions(options...)}
+}
+
+// ListSandboxes returns the sandboxes of the client's namespace, with their current usage.
+// Uptime is not known for sandboxes listed by a client, and is zero.
+func (c *Client) ListSandboxes(ctx context.Context) ([]Metrics, error) {
+	return c.list(ctx, c.b.config().namespace)
+}
+
+// ListAllSandboxes returns the sandboxes of every namespace on the server.
+func (c *Client) ListAllSandboxes(ctx context.Context) ([]Metrics, error) {
+	return c.list(ctx, "*")
+}
+
+func (c *Client) list(ctx context.Context, namespace string) ([]Metrics, error) {
+	sandboxes, err := c.b.rpcClient.listSandboxes(ctx, c.b.config(), namespace)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToListSandboxes, err)
+	}
+	list := make([]Metrics, len(sandboxes))
+	for i, sb := range sandboxes {
+		list[i] = metricsFrom(sb)
+	}
+	return list, nil
+}
+
+// GetSandbox returns the sandbox of the client's namespace with the given name. It fails with
+// [ErrSandboxNotFound] if there is none.
+func (c *Client) GetSandbox(ctx context.Context, name string) (Metrics, error) {
+	metrics, err := c.b.rpcClient.getMetrics(ctx, c.sandboxConfig(name))
+	if err != nil {
+		return Metrics{}, fmt.Errorf("%w: %w", ErrFailedToGetMetrics, err)
+	}
+	if metrics.Name == "" {
+		return Metrics{}, fmt.Errorf("%w: %q", ErrSandboxNotFound, name)
+	}
+	return metricsFrom(*metrics), nil
+}
+
+// StopSandbox stops the sandbox of the client's namespace with the given name. A process using
+// the sandbox will see its requests fail.
+func (c *Client) StopSandbox(ctx context.Context, name string) error {
+	if err := c.b.rpcClient.stopSandbox(ctx, c.sandboxConfig(name)); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToStopSandbox, err)
+	}
+	return nil
+}
+
+// ServerInfo returns the server's name, version and features; see [ServerInfoReporter].
+func (c *Client) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	return serverInfoReporter{c.b}.ServerInfo(ctx)
+}
+
+// sandboxConfig returns the client's configuration addressing the named sandbox.
+func (c *Client) sandboxConfig(name string) *config {
+	cfg := c.b.config()
+	cfg.name = name
+	return cfg
+}
+
+// Client-related errors
+var (
+	ErrFailedToListSandboxes = errors.New("failed to list sandboxes")
+	ErrSandboxNotFound       = errors.New("sandbox not found")
+)
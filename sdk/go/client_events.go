@@ -0,0 +1,105 @@
+package msb
+
+import (
+	"context"
+	"time"
+)
+
+// Sandbox event types
+const (
+	EventSandboxStarted = "sandbox.started"
+	EventSandboxStopped = "sandbox.stopped"
+	EventWarning        = "warning"
+)
+
+// SandboxEvent is a change in the state of the sandboxes on a server, as delivered by
+// [Client.Subscribe].
+type SandboxEvent struct {
+	Type string    // EventSandboxStarted, EventSandboxStopped or EventWarning
+	Time time.Time // When the change was observed
+	// Sandbox is the sandbox's state when the change was observed, or its last known state for
+	// a sandbox that went away. It is zero for warnings.
+	Sandbox Metrics
+	Err     error // Why the server could not be watched, for warnings
+}
+
+// defaultSubscribeInterval is how often Subscribe checks the server when no interval is given.
+const defaultSubscribeInterval = 2 * time.Second
+
+// Subscribe watches the sandboxes of the client's namespace and sends an event on the returned
+// channel whenever one starts or stops, whichever process started it, e.g. to drive a dashboard
+// or an autoscaler. Sandboxes already running when Subscribe is called produce no event. The
+// server has no event stream, so changes are detected by listing the sandboxes every interval,
+// or every 2 seconds if interval <= 0; a sandbox started and stopped in between goes unnoticed.
+// A failure to list the sandboxes is sent as a single EventWarning until listing succeeds again.
+//
+// The channel is closed once ctx is done. Events are not dropped: a slow receiver delays the
+// next check.
+func (c *Client) Subscribe(ctx context.Context, interval time.Duration) <-chan SandboxEvent {
+	if interval <= 0 {
+		interval = defaultSubscribeInterval
+	}
+	events := make(chan SandboxEvent, 16)
+	go func() {
+		defer close(events)
+		send := func(ev SandboxEvent) bool {
+			ev.Time = time.Now()
+			select {
+			case events <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var (
+			running map[string]Metrics // nil until the first successful listing
+			failing bool
+		)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			sandboxes, err := c.ListSandboxes(ctx)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				if !failing && !send(SandboxEvent{Type: EventWarning, Err: err}) {
+					return
+				}
+				failing = true
+			default:
+				failing = false
+				current := make(map[string]Metrics, len(sandboxes))
+				for _, sb := range sandboxes {
+					if sb.IsRunning {
+						current[sb.Name] = sb
+					}
+				}
+				if running != nil {
+					for name, sb := range current {
+						if _, ok := running[name]; !ok && !send(SandboxEvent{Type: EventSandboxStarted, Sandbox: sb}) {
+							return
+						}
+					}
+					for name, sb := range running {
+						if _, ok := current[name]; !ok {
+							sb.IsRunning = false
+							if !send(SandboxEvent{Type: EventSandboxStopped, Sandbox: sb}) {
+								return
+							}
+						}
+					}
+				}
+				running = current
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return events
+}
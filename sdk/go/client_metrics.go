@@ -0,0 +1,109 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MetricsRecorder receives the SDK's operational metrics, e.g. to export them to Prometheus
+// with the msbprom package. Metrics are identified by the Metric* names and carry the labels
+// documented with each. Implementations must be safe for concurrent use and return quickly, as
+// they are called inline.
+type MetricsRecorder interface {
+	// AddCounter adds delta, which is positive, to a counter.
+	AddCounter(name string, labels map[string]string, delta float64)
+	// AddGauge adds delta, which may be negative, to a gauge.
+	AddGauge(name string, labels map[string]string, delta float64)
+	// SetGauge sets a gauge to value.
+	SetGauge(name string, labels map[string]string, value float64)
+	// ObserveHistogram records value in a histogram.
+	ObserveHistogram(name string, labels map[string]string, value float64)
+}
+
+// Metrics recorded through a [MetricsRecorder]
+const (
+	// MetricRPCDuration is a histogram of the seconds RPCs took, retries included, labelled with
+	// "method", the JSON-RPC method, and "outcome", as for MetricRPCErrors or "ok".
+	MetricRPCDuration = "msb_rpc_duration_seconds"
+	// MetricRPCErrors counts failed RPCs, labelled with "method" and "outcome": "timeout",
+	// "canceled", "server" for errors returned by the server, or "transport".
+	MetricRPCErrors = "msb_rpc_errors_total"
+	// MetricActiveSandboxes is a gauge of the sandboxes started and not yet stopped.
+	MetricActiveSandboxes = "msb_active_sandboxes"
+	// MetricQueuedExecutions is a gauge of the executions waiting for a slot under
+	// [WithMaxConcurrentExecutions].
+	MetricQueuedExecutions = "msb_queued_executions"
+	// MetricPoolSandboxes is a gauge of a pool's sandboxes, labelled with "pool", the pool's
+	// name, and "state": "idle", "in_use", "live" or "capacity", as in [PoolStats].
+	MetricPoolSandboxes = "msb_pool_sandboxes"
+	// MetricPoolUtilization is a gauge of the fraction of a pool's capacity in use, labelled
+	// with "pool".
+	MetricPoolUtilization = "msb_pool_utilization"
+)
+
+// WithMetrics records the sandbox's RPCs and whether it is active in r. The same recorder may be
+// shared by any number of sandboxes; see [PoolConfig].Metrics for pools.
+func WithMetrics(r MetricsRecorder) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.metrics = r
+		msb.cfg.interceptors = append(msb.cfg.interceptors, metricsInterceptor(r))
+	}
+}
+
+// metricsInterceptor times every RPC, retries included, and classifies its failures.
+func metricsInterceptor(r MetricsRecorder) Interceptor {
+	return func(next RPCFunc) RPCFunc {
+		return func(ctx context.Context, req *RPCRequest) (*RPCResponse, error) {
+			begin := time.Now()
+			resp, err := next(ctx, req)
+			outcome := rpcOutcome(err)
+			r.ObserveHistogram(MetricRPCDuration, map[string]string{"method": req.Method, "outcome": outcome}, time.Since(begin).Seconds())
+			if err != nil {
+				r.AddCounter(MetricRPCErrors, map[string]string{"method": req.Method, "outcome": outcome}, 1)
+			}
+			return resp, err
+		}
+	}
+}
+
+// rpcOutcome classifies the result of an RPC for the metrics.
+func rpcOutcome(err error) string {
+	var rpcErr *RPCError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &rpcErr):
+		return "server"
+	default:
+		return "transport"
+	}
+}
+
+// recordActive adds delta to the active sandboxes gauge, if metrics are recorded.
+func (b *baseMicroSandbox) recordActive(delta float64) {
+	if r := b.config().metrics; r != nil {
+		r.AddGauge(MetricActiveSandboxes, nil, delta)
+	}
+}
+
+// recordStats reports the pool's occupancy, if metrics are recorded.
+func (p *Pool) recordStats() {
+	r := p.cfg.Metrics
+	if r == nil {
+		return
+	}
+	stats := p.Stats()
+	for state, n := range map[string]int{"idle": stats.Idle, "in_use": stats.InUse, "live": stats.Live, "capacity": stats.Capacity} {
+		r.SetGauge(MetricPoolSandboxes, map[string]string{"pool": p.cfg.Name, "state": state}, float64(n))
+	}
+	utilization := 0.0
+	if stats.Capacity > 0 {
+		utilization = float64(stats.InUse) / float64(stats.Capacity)
+	}
+	r.SetGauge(MetricPoolUtilization, map[string]string{"pool": p.cfg.Name}, utilization)
+}
@@ -0,0 +1,107 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClockReporter measures how far the server's and the guest's clocks are off the client's.
+type ClockReporter interface {
+	// ClockSkew measures the skew between the client's clock and those of the server and the
+	// guest with a single round trip, so that timestamps from all three can be correlated.
+	ClockSkew(ctx context.Context) (ClockSkew, error)
+}
+
+// ClockSkew is the offset of the server's and the guest's clocks from the client's. A positive
+// skew means the clock is ahead of the client's. Each offset comes with the bound of its
+// measurement error, which grows with the round trip time.
+type ClockSkew struct {
+	Guest             time.Duration
+	GuestUncertainty  time.Duration
+	Server            time.Duration
+	ServerUncertainty time.Duration // Includes the one-second resolution of the server's Date header
+	ServerKnown       bool          // False if the server did not report its time; Server is then zero
+	MeasuredAt        time.Time     // The client's time at the measurement
+}
+
+// GuestToClient converts a timestamp taken by the guest's clock, e.g. parsed from a log line of
+// the code, to the client's clock.
+func (s ClockSkew) GuestToClient(t time.Time) time.Time {
+	return t.Add(-s.Guest)
+}
+
+// ServerToClient converts a timestamp taken by the server's clock to the client's clock.
+func (s ClockSkew) ServerToClient(t time.Time) time.Time {
+	return t.Add(-s.Server)
+}
+
+// guestClockScript prints the guest's time as seconds since the epoch. Busybox date may not
+// support %N, in which case the fraction comes out as a literal "N" and is ignored.
+const guestClockScript = `date -u +%s.%N`
+
+type clockReporter struct {
+	b *baseMicroSandbox
+}
+
+func (cr clockReporter) ClockSkew(ctx context.Context) (ClockSkew, error) {
+	if cr.b.state.Load() != started {
+		return ClockSkew{}, ErrSandboxNotStarted
+	}
+	sent := time.Now()
+	result, err := cr.b.rpcClient.runCommand(ctx, cr.b.config(), "sh", []string{"-c", guestClockScript})
+	received := time.Now()
+	if err != nil {
+		return ClockSkew{}, fmt.Errorf("%w: %w", ErrFailedToMeasureClock, err)
+	}
+	exec := newCommandExecution(result)
+	out, _ := exec.GetOutput()
+	if !exec.IsSuccess() {
+		return ClockSkew{}, fmt.Errorf("%w: %s", ErrFailedToMeasureClock, commandStderr(exec))
+	}
+	guest, precise, err := parseGuestClock(strings.TrimSpace(out))
+	if err != nil {
+		return ClockSkew{}, fmt.Errorf("%w: %w", ErrFailedToMeasureClock, err)
+	}
+
+	// The remote clocks were read at some point of the round trip; assume its middle
+	halfTrip := received.Sub(sent) / 2
+	mid := sent.Add(halfTrip)
+	skew := ClockSkew{
+		Guest:            guest.Sub(mid),
+		GuestUncertainty: halfTrip,
+		MeasuredAt:       mid,
+	}
+	if !precise {
+		skew.Guest += time.Second / 2
+		skew.GuestUncertainty += time.Second / 2
+	}
+	if !result.serverDate.IsZero() {
+		skew.Server = result.serverDate.Add(time.Second / 2).Sub(mid)
+		skew.ServerUncertainty = halfTrip + time.Second/2
+		skew.ServerKnown = true
+	}
+	cr.b.logger().Debug("Measured clock skew", "sandbox", cr.b.name(), "guest", skew.Guest, "server", skew.Server, "uncertainty", skew.GuestUncertainty)
+	return skew, nil
+}
+
+// parseGuestClock parses the output of guestClockScript. precise is false when the guest only
+// reported whole seconds.
+func parseGuestClock(out string) (t time.Time, precise bool, err error) {
+	secs, frac, _ := strings.Cut(out, ".")
+	s, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("unexpected guest time %q", out)
+	}
+	ns, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || len(frac) != 9 {
+		return time.Unix(s, 0), false, nil
+	}
+	return time.Unix(s, ns), true, nil
+}
+
+// Clock-related errors
+var ErrFailedToMeasureClock = errors.New("failed to measure clock skew")
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -10,6 +11,7 @@ import (
 // basicExample demonstrates basic command execution with proper lifecycle management.
 func basicExample() {
 	fmt.Println("\n=== Basic Command Example ===")
+	ctx := context.Background()
 
 	// Create a sandbox with explicit configuration
 	sandbox := msb.NewPythonSandbox(
@@ -17,17 +19,17 @@ func basicExample() {
 	)
 
 	// Start the sandbox
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Run a simple command
-	lsExecution, err := sandbox.Command().Run("ls", []string{"-la", "/"})
+	lsExecution, err := sandbox.Command().Run(ctx, "ls", []string{"-la", "/"})
 	if err != nil {
 		log.Fatalf("Failed to run ls command: %v", err)
 	}
@@ -42,7 +44,7 @@ func basicExample() {
 	}
 
 	// Execute a command with string arguments
-	echoExecution, err := sandbox.Command().Run("echo", []string{"Hello from", "sandbox command!"})
+	echoExecution, err := sandbox.Command().Run(ctx, "echo", []string{"Hello from", "sandbox command!"})
 	if err != nil {
 		log.Fatalf("Failed to run echo command: %v", err)
 	}
@@ -55,7 +57,7 @@ func basicExample() {
 	}
 
 	// Get system information
-	unameExecution, err := sandbox.Command().Run("uname", []string{"-a"})
+	unameExecution, err := sandbox.Command().Run(ctx, "uname", []string{"-a"})
 	if err != nil {
 		log.Fatalf("Failed to run uname command: %v", err)
 	}
@@ -71,22 +73,23 @@ func basicExample() {
 // errorHandlingExample demonstrates how to handle command errors.
 func errorHandlingExample() {
 	fmt.Println("\n=== Error Handling Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("error-example"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Run a command that generates an error
-	errorExecution, err := sandbox.Command().Run("ls", []string{"/nonexistent"})
+	errorExecution, err := sandbox.Command().Run(ctx, "ls", []string{"/nonexistent"})
 	if err != nil {
 		log.Printf("Command execution failed: %v", err)
 		return
@@ -103,7 +106,7 @@ func errorHandlingExample() {
 	}
 
 	// Deliberately cause a command not found error
-	_, err = sandbox.Command().Run("nonexistentcommand", []string{})
+	_, err = sandbox.Command().Run(ctx, "nonexistentcommand", []string{})
 	if err != nil {
 		fmt.Printf("\nCaught error for nonexistent command: %v\n", err)
 	}
@@ -112,29 +115,30 @@ func errorHandlingExample() {
 // advancedExample demonstrates more complex command usage patterns.
 func advancedExample() {
 	fmt.Println("\n=== Advanced Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("advanced-example"),
 	)
 
-	if err := sandbox.Start("", 1024, 2); err != nil {
+	if err := sandbox.Start(ctx, "", 1024, 2); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Write a file
-	writeCmd, err := sandbox.Command().Run("bash", []string{"-c", "echo 'Hello, file content!' > /tmp/test.txt"})
+	writeCmd, err := sandbox.Command().Run(ctx, "bash", []string{"-c", "echo 'Hello, file content!' > /tmp/test.txt"})
 	if err != nil {
 		log.Fatalf("Failed to write file: %v", err)
 	}
 	fmt.Printf("Created file, exit code: %d\n", writeCmd.GetExitCode())
 
 	// Read the file back
-	readCmd, err := sandbox.Command().Run("cat", []string{"/tmp/test.txt"})
+	readCmd, err := sandbox.Command().Run(ctx, "cat", []string{"/tmp/test.txt"})
 	if err != nil {
 		log.Fatalf("Failed to read file: %v", err)
 	}
@@ -145,7 +149,7 @@ func advancedExample() {
 	}
 
 	// Run a more complex pipeline
-	pipelineCmd, err := sandbox.Command().Run("bash", []string{
+	pipelineCmd, err := sandbox.Command().Run(ctx, "bash", []string{
 		"-c",
 		"mkdir -p /tmp/test_dir && " +
 			"echo 'Line 1' > /tmp/test_dir/data.txt && " +
@@ -163,7 +167,7 @@ func advancedExample() {
 	}
 
 	// Create and run a Python script
-	createScript, err := sandbox.Command().Run("bash", []string{
+	createScript, err := sandbox.Command().Run(ctx, "bash", []string{
 		"-c",
 		`cat > /tmp/test.py << 'EOF'
 import sys
@@ -177,7 +181,7 @@ EOF`,
 
 	if createScript.IsSuccess() {
 		// Run the script with arguments
-		scriptCmd, err := sandbox.Command().Run("python", []string{"/tmp/test.py", "arg1", "arg2", "arg3"})
+		scriptCmd, err := sandbox.Command().Run(ctx, "python", []string{"/tmp/test.py", "arg1", "arg2", "arg3"})
 		if err != nil {
 			log.Fatalf("Failed to run script: %v", err)
 		}
@@ -194,6 +198,7 @@ EOF`,
 // explicitLifecycleExample demonstrates explicit lifecycle management without defer.
 func explicitLifecycleExample() {
 	fmt.Println("\n=== Explicit Lifecycle Example ===")
+	ctx := context.Background()
 
 	// Create sandbox with custom server URL
 	sandbox := msb.NewPythonSandbox(
@@ -203,12 +208,12 @@ func explicitLifecycleExample() {
 
 	// Manually start the sandbox
 	fmt.Println("Starting sandbox...")
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 
 	// Execute commands
-	hostnameCmd, err := sandbox.Command().Run("hostname", []string{})
+	hostnameCmd, err := sandbox.Command().Run(ctx, "hostname", []string{})
 	if err != nil {
 		log.Printf("Failed to get hostname: %v", err)
 	} else if output, err := hostnameCmd.GetOutput(); err != nil {
@@ -217,7 +222,7 @@ func explicitLifecycleExample() {
 		fmt.Printf("Hostname: %s\n", output)
 	}
 
-	dateCmd, err := sandbox.Command().Run("date", []string{})
+	dateCmd, err := sandbox.Command().Run(ctx, "date", []string{})
 	if err != nil {
 		log.Printf("Failed to get date: %v", err)
 	} else if output, err := dateCmd.GetOutput(); err != nil {
@@ -228,7 +233,7 @@ func explicitLifecycleExample() {
 
 	// Manually stop the sandbox
 	fmt.Println("Stopping sandbox...")
-	if err := sandbox.Stop(); err != nil {
+	if err := sandbox.Stop(ctx); err != nil {
 		log.Printf("Failed to stop sandbox: %v", err)
 	}
 }
@@ -14,16 +14,17 @@ import (
 // sequentialExample demonstrates basic sequential usage.
 func sequentialExample() {
 	fmt.Println("\n=== Sequential Usage Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("sequential-example"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -33,7 +34,7 @@ func sequentialExample() {
 
 	for i := range 3 {
 		code := fmt.Sprintf("print('Task %d completed')", i+1)
-		execution, err := sandbox.Code().Run(code)
+		execution, err := sandbox.Code().Run(ctx, code)
 		if err != nil {
 			log.Printf("Failed to run task %d: %v", i+1, err)
 			continue
@@ -52,16 +53,17 @@ func sequentialExample() {
 // goroutineConcurrentExample demonstrates concurrent usage with goroutines.
 func goroutineConcurrentExample() {
 	fmt.Println("\n=== Goroutine Concurrent Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("concurrent-example"),
 	)
 
-	if err := sandbox.Start("", 1024, 2); err != nil {
+	if err := sandbox.Start(ctx, "", 1024, 2); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -82,7 +84,7 @@ time.sleep(0.1)  # Simulate some work
 print(f'Concurrent taskID {%v} completed')
 `, taskID+1)
 
-			execution, err := sandbox.Code().Run(code)
+			execution, err := sandbox.Code().Run(ctx, code)
 			if err != nil {
 				results <- fmt.Sprintf("Task %d failed: %v", taskID+1, err)
 				return
@@ -112,16 +114,17 @@ print(f'Concurrent taskID {%v} completed')
 // workerPoolExample demonstrates the worker pool pattern.
 func workerPoolExample() {
 	fmt.Println("\n=== Worker Pool Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("worker-pool-example"),
 	)
 
-	if err := sandbox.Start("", 1024, 2); err != nil {
+	if err := sandbox.Start(ctx, "", 1024, 2); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -149,7 +152,7 @@ result = %d * %d
 print(f'Worker %d processed task %d: result = {result}')
 `, taskID, taskID, workerID, taskID)
 
-				execution, err := sandbox.Code().Run(code)
+				execution, err := sandbox.Code().Run(ctx, code)
 				if err != nil {
 					results <- fmt.Sprintf("Worker %d, Task %d failed: %v", workerID, taskID, err)
 					continue
@@ -189,6 +192,7 @@ print(f'Worker %d processed task %d: result = {result}')
 // contextCancellationExample demonstrates context-based cancellation.
 func contextCancellationExample() {
 	fmt.Println("\n=== Context Cancellation Example ===")
+	ctx := context.Background()
 
 	// Create sandbox with custom HTTP client that respects context
 	client := &http.Client{
@@ -200,17 +204,17 @@ func contextCancellationExample() {
 		msb.WithHTTPClient(client),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	execCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
 	// Channel to signal completion
@@ -220,14 +224,15 @@ func contextCancellationExample() {
 	go func() {
 		defer close(done)
 
-		// This would normally take longer than our context timeout
+		// This would normally take longer than our context timeout; cancelling execCtx
+		// aborts the request instead of waiting for it
 		code := `
 import time
 print("Starting long-running task...")
 time.sleep(5)  # This will be interrupted by context timeout
 print("Task completed")  # This won't be reached
 `
-		_, executionErr = sandbox.Code().Run(code)
+		_, executionErr = sandbox.Code().Run(execCtx, code)
 	}()
 
 	// Wait for either completion or context cancellation
@@ -238,8 +243,8 @@ print("Task completed")  # This won't be reached
 		} else {
 			fmt.Println("Execution completed successfully")
 		}
-	case <-ctx.Done():
-		fmt.Printf("Operation cancelled due to context: %v\n", ctx.Err())
+	case <-execCtx.Done():
+		fmt.Printf("Operation cancelled due to context: %v\n", execCtx.Err())
 		// In a real application, you might want to handle cleanup here
 	}
 }
@@ -247,16 +252,17 @@ print("Task completed")  # This won't be reached
 // channelCoordinationExample demonstrates using channels for coordination.
 func channelCoordinationExample() {
 	fmt.Println("\n=== Channel Coordination Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("channel-coordination"),
 	)
 
-	if err := sandbox.Start("", 1024, 2); err != nil {
+	if err := sandbox.Start(ctx, "", 1024, 2); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -299,7 +305,7 @@ processed = {
 print(json.dumps(processed))
 `, data, data)
 
-			execution, err := sandbox.Code().Run(code)
+			execution, err := sandbox.Code().Run(ctx, code)
 			if err != nil {
 				errorChannel <- fmt.Errorf("failed to process %s: %w", data, err)
 				continue
@@ -338,16 +344,17 @@ print(json.dumps(processed))
 // metricsMonitoringExample demonstrates concurrent metrics monitoring.
 func metricsMonitoringExample() {
 	fmt.Println("\n=== Concurrent Metrics Monitoring Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("metrics-monitoring"),
 	)
 
-	if err := sandbox.Start("", 1024, 2); err != nil {
+	if err := sandbox.Start(ctx, "", 1024, 2); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -372,7 +379,7 @@ while time.time() - start < work_duration:
 print(f"Completed work iteration %d")
 `, i+1, i+1)
 
-			if _, err := sandbox.Code().Run(code); err != nil {
+			if _, err := sandbox.Code().Run(ctx, code); err != nil {
 				log.Printf("Workload iteration %d failed: %v", i+1, err)
 			}
 			time.Sleep(200 * time.Millisecond)
@@ -380,7 +387,7 @@ print(f"Completed work iteration %d")
 	}()
 
 	// Concurrent metrics monitoring
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	monitorCtx, cancel := context.WithTimeout(ctx, 8*time.Second)
 	defer cancel()
 
 	var wg sync.WaitGroup
@@ -394,10 +401,10 @@ print(f"Completed work iteration %d")
 
 		for {
 			select {
-			case <-ctx.Done():
+			case <-monitorCtx.Done():
 				return
 			case <-ticker.C:
-				if cpu, err := sandbox.Metrics().CPU(); err != nil {
+				if cpu, err := sandbox.Metrics().CPU(monitorCtx); err != nil {
 					log.Printf("CPU monitoring error: %v", err)
 				} else {
 					fmt.Printf("[CPU Monitor] CPU: %.2f%%\n", cpu)
@@ -415,10 +422,10 @@ print(f"Completed work iteration %d")
 
 		for {
 			select {
-			case <-ctx.Done():
+			case <-monitorCtx.Done():
 				return
 			case <-ticker.C:
-				if memory, err := sandbox.Metrics().MemoryMiB(); err != nil {
+				if memory, err := sandbox.Metrics().MemoryMiB(monitorCtx); err != nil {
 					log.Printf("Memory monitoring error: %v", err)
 				} else {
 					fmt.Printf("[Memory Monitor] Memory: %d MiB\n", memory)
@@ -436,10 +443,10 @@ print(f"Completed work iteration %d")
 
 		for {
 			select {
-			case <-ctx.Done():
+			case <-monitorCtx.Done():
 				return
 			case <-ticker.C:
-				if metrics, err := sandbox.Metrics().All(); err != nil {
+				if metrics, err := sandbox.Metrics().All(monitorCtx); err != nil {
 					log.Printf("All metrics error: %v", err)
 				} else {
 					fmt.Printf("[All Metrics] CPU: %.2f%%, Memory: %d MiB, Running: %t\n",
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -11,27 +12,28 @@ import (
 // basicMetricsExample demonstrates how to get individual metrics for a sandbox.
 func basicMetricsExample() {
 	fmt.Println("\n=== Basic Metrics Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("metrics-example"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Run commands to generate some load
 	fmt.Println("Running commands to generate some sandbox activity...")
-	if _, err := sandbox.Command().Run("ls", []string{"-la", "/"}); err != nil {
+	if _, err := sandbox.Command().Run(ctx, "ls", []string{"-la", "/"}); err != nil {
 		log.Printf("Failed to run ls command: %v", err)
 	}
 
-	if _, err := sandbox.Command().Run("dd", []string{"if=/dev/zero", "of=/tmp/testfile", "bs=1M", "count=10"}); err != nil {
+	if _, err := sandbox.Command().Run(ctx, "dd", []string{"if=/dev/zero", "of=/tmp/testfile", "bs=1M", "count=10"}); err != nil {
 		log.Printf("Failed to run dd command: %v", err)
 	}
 
@@ -42,28 +44,28 @@ func basicMetricsExample() {
 	fmt.Println("\nGetting individual metrics for this sandbox:")
 
 	// Get CPU usage
-	if cpu, err := sandbox.Metrics().CPU(); err != nil {
+	if cpu, err := sandbox.Metrics().CPU(ctx); err != nil {
 		fmt.Printf("Error getting CPU metrics: %v\n", err)
 	} else {
 		fmt.Printf("CPU Usage: %.2f%%\n", cpu)
 	}
 
 	// Get memory usage
-	if memory, err := sandbox.Metrics().MemoryMiB(); err != nil {
+	if memory, err := sandbox.Metrics().MemoryMiB(ctx); err != nil {
 		fmt.Printf("Error getting memory metrics: %v\n", err)
 	} else {
 		fmt.Printf("Memory Usage: %d MiB\n", memory)
 	}
 
 	// Get disk usage
-	if disk, err := sandbox.Metrics().DiskBytes(); err != nil {
+	if disk, err := sandbox.Metrics().DiskBytes(ctx); err != nil {
 		fmt.Printf("Error getting disk metrics: %v\n", err)
 	} else {
 		fmt.Printf("Disk Usage: %d bytes\n", disk)
 	}
 
 	// Check if running
-	if running, err := sandbox.Metrics().IsRunning(); err != nil {
+	if running, err := sandbox.Metrics().IsRunning(ctx); err != nil {
 		fmt.Printf("Error checking if running: %v\n", err)
 	} else {
 		fmt.Printf("Is Running: %t\n", running)
@@ -73,27 +75,28 @@ func basicMetricsExample() {
 // allMetricsExample demonstrates how to get all metrics at once.
 func allMetricsExample() {
 	fmt.Println("\n=== All Metrics Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("all-metrics-example"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Run some commands to generate activity
 	fmt.Println("Running commands to generate some sandbox activity...")
-	if _, err := sandbox.Command().Run("cat", []string{"/etc/os-release"}); err != nil {
+	if _, err := sandbox.Command().Run(ctx, "cat", []string{"/etc/os-release"}); err != nil {
 		log.Printf("Failed to run cat command: %v", err)
 	}
 
-	if _, err := sandbox.Command().Run("ls", []string{"-la", "/usr"}); err != nil {
+	if _, err := sandbox.Command().Run(ctx, "ls", []string{"-la", "/usr"}); err != nil {
 		log.Printf("Failed to run ls command: %v", err)
 	}
 
@@ -102,7 +105,7 @@ func allMetricsExample() {
 
 	// Get all metrics at once
 	fmt.Println("\nGetting all metrics as a single object:")
-	allMetrics, err := sandbox.Metrics().All()
+	allMetrics, err := sandbox.Metrics().All(ctx)
 	if err != nil {
 		log.Fatalf("Failed to get all metrics: %v", err)
 	}
@@ -118,16 +121,17 @@ func allMetricsExample() {
 // continuousMonitoringExample demonstrates how to continuously monitor sandbox metrics.
 func continuousMonitoringExample() {
 	fmt.Println("\n=== Continuous Monitoring Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("monitoring-example"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -135,7 +139,7 @@ func continuousMonitoringExample() {
 	fmt.Println("Starting continuous monitoring (5 seconds)...")
 
 	// Generate load with a simple and safe command (run in background)
-	if _, err := sandbox.Command().Run("sh", []string{
+	if _, err := sandbox.Command().Run(ctx, "sh", []string{
 		"-c",
 		"for i in $(seq 1 5); do ls -la / > /dev/null; sleep 0.2; done &",
 	}); err != nil {
@@ -146,8 +150,8 @@ func continuousMonitoringExample() {
 	startTime := time.Now()
 	for time.Since(startTime) < 5*time.Second {
 		// Get metrics
-		cpu, cpuErr := sandbox.Metrics().CPU()
-		memory, memErr := sandbox.Metrics().MemoryMiB()
+		cpu, cpuErr := sandbox.Metrics().CPU(ctx)
+		memory, memErr := sandbox.Metrics().MemoryMiB(ctx)
 
 		// Format and print current values
 		elapsed := time.Since(startTime).Seconds()
@@ -173,16 +177,17 @@ func continuousMonitoringExample() {
 // cpuLoadTestExample generates CPU load to test CPU metrics.
 func cpuLoadTestExample() {
 	fmt.Println("\n=== CPU Load Test Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("cpu-load-test"),
 	)
 
-	if err := sandbox.Start("", 1024, 2); err != nil {
+	if err := sandbox.Start(ctx, "", 1024, 2); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -214,13 +219,13 @@ print("CPU load test complete")
 `
 
 	// Write the script to a file
-	if _, err := sandbox.Command().Run("bash", []string{"-c", fmt.Sprintf("cat > /tmp/cpu_test.py << 'EOF'\n%s\nEOF", cpuScript)}); err != nil {
+	if _, err := sandbox.Command().Run(ctx, "bash", []string{"-c", fmt.Sprintf("cat > /tmp/cpu_test.py << 'EOF'\n%s\nEOF", cpuScript)}); err != nil {
 		log.Fatalf("Failed to create CPU test script: %v", err)
 	}
 
 	// Run the script in the background
 	fmt.Println("Starting CPU test (running for 10 seconds)...")
-	if _, err := sandbox.Command().Run("python", []string{"/tmp/cpu_test.py", "&"}); err != nil {
+	if _, err := sandbox.Command().Run(ctx, "python", []string{"/tmp/cpu_test.py", "&"}); err != nil {
 		log.Printf("Failed to start CPU test: %v", err)
 	}
 
@@ -231,8 +236,8 @@ print("CPU load test complete")
 		time.Sleep(2 * time.Second)
 
 		// Get metrics
-		cpu, cpuErr := sandbox.Metrics().CPU()
-		memory, memErr := sandbox.Metrics().MemoryMiB()
+		cpu, cpuErr := sandbox.Metrics().CPU(ctx)
+		memory, memErr := sandbox.Metrics().MemoryMiB(ctx)
 
 		// Format and print current values
 		cpuStr := "Not available"
@@ -254,6 +259,7 @@ print("CPU load test complete")
 // errorHandlingExample demonstrates error handling with metrics.
 func errorHandlingExample() {
 	fmt.Println("\n=== Error Handling Example ===")
+	ctx := context.Background()
 
 	// Create a sandbox without starting it immediately
 	sandbox := msb.NewPythonSandbox(
@@ -262,23 +268,23 @@ func errorHandlingExample() {
 
 	// Try to get metrics before starting the sandbox
 	fmt.Println("Trying to get metrics before starting the sandbox...")
-	if _, err := sandbox.Metrics().CPU(); err != nil {
+	if _, err := sandbox.Metrics().CPU(ctx); err != nil {
 		fmt.Printf("Expected error: %v\n", err)
 	}
 
 	// Now properly start the sandbox
 	fmt.Println("\nStarting the sandbox properly...")
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Get metrics after starting
-	if cpu, err := sandbox.Metrics().CPU(); err != nil {
+	if cpu, err := sandbox.Metrics().CPU(ctx); err != nil {
 		fmt.Printf("Error getting CPU after starting: %v\n", err)
 	} else {
 		fmt.Printf("CPU usage after starting: %.2f%%\n", cpu)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -10,23 +11,24 @@ import (
 // basicExample demonstrates basic JavaScript code execution.
 func basicExample() {
 	fmt.Println("\n=== Basic Node.js Example ===")
+	ctx := context.Background()
 
 	// Create a Node.js sandbox
 	sandbox := msb.NewNodeSandbox(
 		msb.WithName("node-basic"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Run a simple JavaScript code snippet
-	execution, err := sandbox.Code().Run("console.log('Hello from Node.js!');")
+	execution, err := sandbox.Code().Run(ctx, "console.log('Hello from Node.js!');")
 	if err != nil {
 		log.Fatalf("Failed to run code: %v", err)
 	}
@@ -42,7 +44,7 @@ func basicExample() {
 const version = process.version;
 const platform = process.platform;
 console.log(` + "`" + `Node.js ${version} running on ${platform}` + "`" + `);`
-	versionExecution, err := sandbox.Code().Run(versionCode)
+	versionExecution, err := sandbox.Code().Run(ctx, versionCode)
 	if err != nil {
 		log.Fatalf("Failed to run version code: %v", err)
 	}
@@ -57,16 +59,17 @@ console.log(` + "`" + `Node.js ${version} running on ${platform}` + "`" + `);`
 // errorHandlingExample demonstrates how to handle JavaScript errors.
 func errorHandlingExample() {
 	fmt.Println("\n=== Error Handling Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewNodeSandbox(
 		msb.WithName("node-error"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -80,7 +83,7 @@ try {
     console.error('Caught error:', error.message);
 }
 `
-	caughtExecution, err := sandbox.Code().Run(caughtErrorCode)
+	caughtExecution, err := sandbox.Code().Run(ctx, caughtErrorCode)
 	if err != nil {
 		log.Fatalf("Failed to run caught error code: %v", err)
 	}
@@ -103,16 +106,17 @@ try {
 // moduleExample demonstrates Node.js module usage.
 func moduleExample() {
 	fmt.Println("\n=== Module Usage Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewNodeSandbox(
 		msb.WithName("node-module"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -135,7 +139,7 @@ console.log('Hostname:', os.hostname());
 console.log('Platform:', os.platform());
 console.log('Architecture:', os.arch());
 `
-	fsExecution, err := sandbox.Code().Run(fsCode)
+	fsExecution, err := sandbox.Code().Run(ctx, fsCode)
 	if err != nil {
 		log.Fatalf("Failed to run fs code: %v", err)
 	}
@@ -150,38 +154,39 @@ console.log('Architecture:', os.arch());
 // executionChainingExample demonstrates execution chaining with variables.
 func executionChainingExample() {
 	fmt.Println("\n=== Execution Chaining Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewNodeSandbox(
 		msb.WithName("node-chain"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Execute a sequence of related code blocks that maintain state
-	if _, err := sandbox.Code().Run("const name = 'Node.js';"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "const name = 'Node.js';"); err != nil {
 		log.Fatalf("Failed to set name variable: %v", err)
 	}
 
-	if _, err := sandbox.Code().Run("const version = process.version;"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "const version = process.version;"); err != nil {
 		log.Fatalf("Failed to set version variable: %v", err)
 	}
 
-	if _, err := sandbox.Code().Run("const numbers = [1, 2, 3, 4, 5];"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "const numbers = [1, 2, 3, 4, 5];"); err != nil {
 		log.Fatalf("Failed to set numbers variable: %v", err)
 	}
 
 	// Use variables from previous executions
-	finalExecution, err := sandbox.Code().Run(`
-	console.log(` + "`" + `Hello from ${name} ${version}!` + "`" + `);
+	finalExecution, err := sandbox.Code().Run(ctx, `
+	console.log(`+"`"+`Hello from ${name} ${version}!`+"`"+`);
 const sum = numbers.reduce((a, b) => a + b, 0);
-console.log(` + "`" + `Sum of numbers: ${sum}` + "`" + `);
+console.log(`+"`"+`Sum of numbers: ${sum}`+"`"+`);
 `)
 	if err != nil {
 		log.Fatalf("Failed to run final code: %v", err)
@@ -197,16 +202,17 @@ console.log(` + "`" + `Sum of numbers: ${sum}` + "`" + `);
 // jsonAndDataExample demonstrates working with JSON and data structures.
 func jsonAndDataExample() {
 	fmt.Println("\n=== JSON and Data Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewNodeSandbox(
 		msb.WithName("node-json"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -242,7 +248,7 @@ const emailDomains = parsed.users
     .filter((domain, index, arr) => arr.indexOf(domain) === index);
 console.log('Unique email domains:', emailDomains);
 `
-	jsonExecution, err := sandbox.Code().Run(jsonCode)
+	jsonExecution, err := sandbox.Code().Run(ctx, jsonCode)
 	if err != nil {
 		log.Fatalf("Failed to run JSON code: %v", err)
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -10,6 +11,7 @@ import (
 // contextManagerEquivalentExample demonstrates the Go equivalent of Python's context manager pattern.
 func contextManagerEquivalentExample() {
 	fmt.Println("\n=== Context Manager Equivalent Example ===")
+	ctx := context.Background()
 
 	// Create a sandbox (equivalent to async with PythonSandbox.create())
 	sandbox := msb.NewPythonSandbox(
@@ -17,19 +19,19 @@ func contextManagerEquivalentExample() {
 	)
 
 	// Start the sandbox
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	// Use defer for automatic cleanup (Go's equivalent of context manager)
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Run some computation
 	code := `print("Hello, world!")`
-	execution, err := sandbox.Code().Run(code)
+	execution, err := sandbox.Code().Run(ctx, code)
 	if err != nil {
 		log.Fatalf("Failed to run code: %v", err)
 	}
@@ -44,6 +46,7 @@ func contextManagerEquivalentExample() {
 // explicitLifecycleExample demonstrates explicit lifecycle management.
 func explicitLifecycleExample() {
 	fmt.Println("\n=== Explicit Lifecycle Example ===")
+	ctx := context.Background()
 
 	// Create sandbox with custom configuration
 	sandbox := msb.NewPythonSandbox(
@@ -52,7 +55,7 @@ func explicitLifecycleExample() {
 	)
 
 	// Start with resource constraints
-	if err := sandbox.Start("", 1024, 2); err != nil { // 1GB RAM, 2 CPU cores
+	if err := sandbox.Start(ctx, "", 1024, 2); err != nil { // 1GB RAM, 2 CPU cores
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 
@@ -65,15 +68,15 @@ func explicitLifecycleExample() {
 	}()
 
 	// Run multiple code blocks with variable assignments
-	if _, err := sandbox.Code().Run("x = 42"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "x = 42"); err != nil {
 		log.Fatalf("Failed to set x: %v", err)
 	}
 
-	if _, err := sandbox.Code().Run("y = [i**2 for i in range(10)]"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "y = [i**2 for i in range(10)]"); err != nil {
 		log.Fatalf("Failed to set y: %v", err)
 	}
 
-	execution3, err := sandbox.Code().Run("print(f'x = {x}')\nprint(f'y = {y}')")
+	execution3, err := sandbox.Code().Run(ctx, "print(f'x = {x}')\nprint(f'y = {y}')")
 	if err != nil {
 		log.Fatalf("Failed to run final code: %v", err)
 	}
@@ -85,7 +88,7 @@ func explicitLifecycleExample() {
 	}
 
 	// Demonstrate error handling
-	errorExecution, err := sandbox.Code().Run("1/0") // This will raise a ZeroDivisionError
+	errorExecution, err := sandbox.Code().Run(ctx, "1/0") // This will raise a ZeroDivisionError
 	if err != nil {
 		fmt.Printf("Caught error: %v\n", err)
 	} else if errorOutput, err := errorExecution.GetError(); err != nil {
@@ -95,40 +98,41 @@ func explicitLifecycleExample() {
 	}
 
 	// Manual cleanup
-	stopErr = sandbox.Stop()
+	stopErr = sandbox.Stop(ctx)
 }
 
 // executionChainingExample demonstrates execution chaining with variables.
 func executionChainingExample() {
 	fmt.Println("\n=== Execution Chaining Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("sandbox-chain"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Execute a sequence of related code blocks
-	if _, err := sandbox.Code().Run("name = 'Python'"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "name = 'Python'"); err != nil {
 		log.Fatalf("Failed to set name: %v", err)
 	}
 
-	if _, err := sandbox.Code().Run("import sys"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "import sys"); err != nil {
 		log.Fatalf("Failed to import sys: %v", err)
 	}
 
-	if _, err := sandbox.Code().Run("version = sys.version"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "version = sys.version"); err != nil {
 		log.Fatalf("Failed to set version: %v", err)
 	}
 
-	exec, err := sandbox.Code().Run("print(f'Hello from {name} {version}!')")
+	exec, err := sandbox.Code().Run(ctx, "print(f'Hello from {name} {version}!')")
 	if err != nil {
 		log.Fatalf("Failed to run final code: %v", err)
 	}
@@ -144,16 +148,17 @@ func executionChainingExample() {
 // dataProcessingExample demonstrates more complex data processing scenarios.
 func dataProcessingExample() {
 	fmt.Println("\n=== Data Processing Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("sandbox-data"),
 	)
 
-	if err := sandbox.Start("", 1024, 2); err != nil {
+	if err := sandbox.Start(ctx, "", 1024, 2); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
@@ -175,7 +180,7 @@ data = [
 
 print(f"Loaded {len(data)} employee records")
 `
-	if _, err := sandbox.Code().Run(setupCode); err != nil {
+	if _, err := sandbox.Code().Run(ctx, setupCode); err != nil {
 		log.Fatalf("Failed to run setup code: %v", err)
 	}
 
@@ -207,7 +212,7 @@ print(f"\\nHighest paid in each department:")
 for dept, person in dept_salaries.items():
     print(f"  {dept}: {person['name']} (${person['salary']:,})")
 `
-	analysisExecution, err := sandbox.Code().Run(analysisCode)
+	analysisExecution, err := sandbox.Code().Run(ctx, analysisCode)
 	if err != nil {
 		log.Fatalf("Failed to run analysis code: %v", err)
 	}
@@ -222,22 +227,23 @@ for dept, person in dept_salaries.items():
 // errorRecoveryExample demonstrates error handling and recovery patterns.
 func errorRecoveryExample() {
 	fmt.Println("\n=== Error Recovery Example ===")
+	ctx := context.Background()
 
 	sandbox := msb.NewPythonSandbox(
 		msb.WithName("sandbox-error-recovery"),
 	)
 
-	if err := sandbox.Start("", 512, 1); err != nil {
+	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 		log.Fatalf("Failed to start sandbox: %v", err)
 	}
 	defer func() {
-		if err := sandbox.Stop(); err != nil {
+		if err := sandbox.Stop(ctx); err != nil {
 			log.Printf("Failed to stop sandbox: %v", err)
 		}
 	}()
 
 	// Set up some initial state
-	if _, err := sandbox.Code().Run("counter = 0"); err != nil {
+	if _, err := sandbox.Code().Run(ctx, "counter = 0"); err != nil {
 		log.Fatalf("Failed to initialize counter: %v", err)
 	}
 
@@ -254,12 +260,12 @@ def risky_operation(value):
 
 print("Risky operation function defined")
 `
-	if _, err := sandbox.Code().Run(setupCode); err != nil {
+	if _, err := sandbox.Code().Run(ctx, setupCode); err != nil {
 		log.Fatalf("Failed to setup risky operation: %v", err)
 	}
 
 	// Test with valid input
-	validExecution, err := sandbox.Code().Run(`
+	validExecution, err := sandbox.Code().Run(ctx, `
 result = risky_operation(25)
 print(f"Success: risky_operation(25) = {result}")
 print(f"Counter is now: {counter}")
@@ -273,7 +279,7 @@ print(f"Counter is now: {counter}")
 	}
 
 	// Test with invalid input (should show error but sandbox continues)
-	invalidExecution, err := sandbox.Code().Run(`
+	invalidExecution, err := sandbox.Code().Run(ctx, `
 try:
     result = risky_operation(-5)
     print(f"Unexpected success: {result}")
@@ -300,7 +306,7 @@ except ValueError as e:
 	}
 
 	// Verify sandbox is still functional
-	finalExecution, err := sandbox.Code().Run(`
+	finalExecution, err := sandbox.Code().Run(ctx, `
 print(f"Sandbox is still working! Final counter: {counter}")
 print("Error recovery complete")
 `)
@@ -3,7 +3,9 @@ package msb
 import (
 	"encoding/json"
 	"errors"
+	"slices"
 	"strings"
+	"time"
 )
 
 // ErrExecutionNotParsed is returned when execution output could not be parsed.
@@ -12,26 +14,58 @@ var ErrExecutionNotParsed = errors.New("execution output could not be parsed")
 // CodeExecution represents the result of code execution in the sandbox.
 // Use the Get* methods for parsed access to output, or access Output directly for raw JSON.
 type CodeExecution struct {
-	Output   json.RawMessage // Raw JSON response from the server
-	parsed   executionData   // Parsed data for convenience methods
-	parsedOK bool            // Whether parsing succeeded
+	Output      json.RawMessage   // Raw JSON response from the server
+	parsed      executionData     // Parsed data for convenience methods
+	parsedOK    bool              // Whether parsing succeeded
+	termination TerminationReason // Why the execution was terminated, if it was
+	spec        *SandboxSpec      // Environment snapshot, when WithSpecSnapshots is enabled
+	uploaded    bool              // Whether the code was uploaded as a file because it was too large
+	deduped     bool              // Whether the result was shared with an identical earlier execution
+	replayed    bool              // Whether the result was read from the journal instead of running
+	truncation  OutputTruncation  // How the output was cut to WithMaxOutputBytes, if it was
+	usage       *ResourceUsage    // What the execution consumed, when run with WithResourceUsage
 }
 
 // Internal structures for parsing execution results
 type (
 	executionData struct {
-		OutputLines []outputLine `json:"output"`
+		OutputLines []OutputLine `json:"output"`
 		Status      string       `json:"status"`
 		Language    string       `json:"language"`
 	}
+)
+
+// OutputLine is a single line of execution output.
+type OutputLine struct {
+	Stream string    `json:"stream"` // "stdout" or "stderr"
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time,omitzero"` // When the client received the line; zero when the server returned all output at once
+	// Skipped counts the lines [WithOutputSampling] dropped before this one
+	Skipped int `json:"skipped,omitempty"`
+}
 
-	outputLine struct {
-		Stream string `json:"stream"`
-		Text   string `json:"text"`
+// codeExecutionFromCommand presents a shell snippet's command result as a code execution.
+func codeExecutionFromCommand(ce CommandExecution, language Language) CodeExecution {
+	exec := CodeExecution{
+		parsed: executionData{
+			OutputLines: ce.parsed.OutputLines,
+			Status:      "success",
+			Language:    string(language),
+		},
+		parsedOK:    ce.parsedOK,
+		termination: ce.termination,
+		truncation:  ce.truncation,
+		usage:       ce.usage,
 	}
-)
+	if !ce.IsSuccess() {
+		exec.parsed.Status = "error"
+	}
+	exec.Output, _ = json.Marshal(exec.parsed)
+	return exec
+}
 
-// GetOutput returns the standard output from code execution as a string.
+// GetOutput returns the standard output from code execution as a string, without the rich
+// outputs returned by GetRichOutputs.
 // Returns ErrExecutionNotParsed if the raw JSON could not be parsed.
 func (ce CodeExecution) GetOutput() (string, error) {
 	if !ce.parsedOK {
@@ -40,7 +74,7 @@ func (ce CodeExecution) GetOutput() (string, error) {
 
 	var output strings.Builder
 	for _, line := range ce.parsed.OutputLines {
-		if line.Stream == "stdout" {
+		if line.Stream == "stdout" && !isRichOutputLine(line) {
 			output.WriteString(line.Text)
 			output.WriteString("\n")
 		}
@@ -65,6 +99,58 @@ func (ce CodeExecution) GetError() (string, error) {
 	return strings.TrimSuffix(errorOutput.String(), "\n"), nil
 }
 
+// GetOutputOrEmpty returns the standard output like GetOutput, or "" if the execution was not
+// parsed, for scripts that need not tell both apart.
+func (ce CodeExecution) GetOutputOrEmpty() string {
+	output, _ := ce.GetOutput()
+	return output
+}
+
+// GetErrorOrEmpty returns the error output like GetError, or "" if the execution was not parsed.
+func (ce CodeExecution) GetErrorOrEmpty() string {
+	errorOutput, _ := ce.GetError()
+	return errorOutput
+}
+
+// GetOutputLines returns stdout and stderr lines interleaved in the order they were produced, to
+// reconstruct the console as it appeared. Lines received in the same poll of a streamed or timed
+// execution are ordered stdout first, since their relative order is unknown. Rich outputs are
+// left out; see GetRichOutputs.
+// Returns ErrExecutionNotParsed if the raw JSON could not be parsed.
+func (ce CodeExecution) GetOutputLines() ([]OutputLine, error) {
+	if !ce.parsedOK {
+		return nil, ErrExecutionNotParsed
+	}
+	return slices.DeleteFunc(slices.Clone(ce.parsed.OutputLines), isRichOutputLine), nil
+}
+
+// GetRichOutputs returns the values the code displayed with the display function defined by
+// [RichOutputHelper], such as charts and HTML tables, in the order they were displayed.
+// Returns nil if there were none or the raw JSON could not be parsed.
+func (ce CodeExecution) GetRichOutputs() []RichOutput {
+	var outputs []RichOutput
+	for _, line := range ce.parsed.OutputLines {
+		if !isRichOutputLine(line) {
+			continue
+		}
+		if out, ok := parseRichOutput(line); ok {
+			outputs = append(outputs, out)
+		}
+	}
+	return outputs
+}
+
+// GetImages returns the PNG and JPEG images among the rich outputs, e.g. matplotlib charts.
+func (ce CodeExecution) GetImages() [][]byte {
+	var images [][]byte
+	for _, out := range ce.GetRichOutputs() {
+		if img, ok := out.Image(); ok {
+			images = append(images, img)
+		}
+	}
+	return images
+}
+
 // HasError reports whether the code execution encountered an error.
 // Checks both execution status and presence of stderr output.
 func (ce CodeExecution) HasError() bool {
@@ -95,6 +181,41 @@ func (ce CodeExecution) GetStatus() string {
 	return ce.parsed.Status
 }
 
+// GetExitCode maps the status to a process exit code, like [CommandExecution.GetExitCode]: 0 on
+// success, 1 on error, 128+N if the code was killed by signal N, and -1 if the raw JSON could not
+// be parsed or the execution was terminated otherwise.
+func (ce CodeExecution) GetExitCode() int {
+	switch {
+	case !ce.parsedOK:
+		return -1
+	case ce.termination.Kind == TerminationSignal:
+		return 128 + ce.termination.Signal
+	case ce.termination.Kind != TerminationNone:
+		return -1
+	case ce.HasError():
+		return 1
+	default:
+		return 0
+	}
+}
+
+// IsSuccess reports whether the code ran without error, i.e. the opposite of HasError for a
+// parsed result. Returns false if the raw JSON could not be parsed.
+func (ce CodeExecution) IsSuccess() bool {
+	return ce.parsedOK && !ce.HasError()
+}
+
+// GetTerminationReason reports why the execution was terminated by the server, if it was.
+func (ce CodeExecution) GetTerminationReason() TerminationReason {
+	return ce.termination
+}
+
+// Truncation reports how the output was cut to the cap set with [WithMaxOutputBytes] or
+// [WithExecMaxOutputBytes], if it was.
+func (ce CodeExecution) Truncation() OutputTruncation {
+	return ce.truncation
+}
+
 // GetLanguage returns the language used for code execution.
 // Returns "unknown" if the raw JSON could not be parsed.
 func (ce CodeExecution) GetLanguage() string {
@@ -104,3 +225,37 @@ func (ce CodeExecution) GetLanguage() string {
 	return ce.parsed.Language
 }
 
+// GetSpec returns the environment the execution ran in, or false unless [WithSpecSnapshots] is enabled.
+func (ce CodeExecution) GetSpec() (SandboxSpec, bool) {
+	if ce.spec == nil {
+		return SandboxSpec{}, false
+	}
+	return *ce.spec, true
+}
+
+// GetResourceUsage returns what the execution consumed in the guest, or false unless it was run
+// with [WithResourceUsage] and measured.
+func (ce CodeExecution) GetResourceUsage() (ResourceUsage, bool) {
+	if ce.usage == nil {
+		return ResourceUsage{}, false
+	}
+	return *ce.usage, true
+}
+
+// UploadedAsFile reports whether the code exceeded the server's request size limit and was
+// therefore uploaded to the sandbox and executed from a file instead of being sent inline.
+func (ce CodeExecution) UploadedAsFile() bool {
+	return ce.uploaded
+}
+
+// Replayed reports whether the result was recorded by an earlier run in the sandbox's journal
+// and the code did not run again; see [Journal].
+func (ce CodeExecution) Replayed() bool {
+	return ce.replayed
+}
+
+// Deduplicated reports whether the code did not run again because an identical execution was in
+// flight or had just finished, and this is that execution's result; see [WithDedupeWindow].
+func (ce CodeExecution) Deduplicated() bool {
+	return ce.deduped
+}
@@ -0,0 +1,72 @@
+package msb
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// CommandBuilder assembles a command, or a pipeline of commands, from unquoted words, as
+// returned by [CommandRunner.Cmd]. Arguments always reach the commands verbatim: a single command
+// runs without a shell, and a pipeline runs under `sh -c` with every word quoted.
+//
+//	exec, err := sandbox.Command().Cmd("grep").Args("-r", pattern, "/src").Pipe("wc", "-l").Run(ctx)
+//
+// A pipeline exits with the status of its last command, as in the shell.
+type CommandBuilder struct {
+	runner CommandRunner
+	stages []CommandSpec
+	opts   []RunOption
+}
+
+// Args appends args to the last command of the pipeline.
+func (cb *CommandBuilder) Args(args ...string) *CommandBuilder {
+	last := &cb.stages[len(cb.stages)-1]
+	last.Args = append(last.Args, args...)
+	return cb
+}
+
+// Pipe appends cmd to the pipeline, reading the output of the previous command.
+func (cb *CommandBuilder) Pipe(cmd string, args ...string) *CommandBuilder {
+	cb.stages = append(cb.stages, CommandSpec{Cmd: cmd, Args: slices.Clone(args)})
+	return cb
+}
+
+// Options sets the options to run the command with.
+func (cb *CommandBuilder) Options(opts ...RunOption) *CommandBuilder {
+	cb.opts = append(cb.opts, opts...)
+	return cb
+}
+
+// Run runs the command like [CommandRunner.Run].
+func (cb *CommandBuilder) Run(ctx context.Context) (CommandExecution, error) {
+	cmd, args := cb.command()
+	return cb.runner.Run(ctx, cmd, args, cb.opts...)
+}
+
+// Start launches the command in the background like [CommandRunner.Start].
+func (cb *CommandBuilder) Start(ctx context.Context) (*Process, error) {
+	cmd, args := cb.command()
+	return cb.runner.Start(ctx, cmd, args, cb.opts...)
+}
+
+// String returns the command line, with every word quoted for the shell.
+func (cb *CommandBuilder) String() string {
+	stages := make([]string, len(cb.stages))
+	for i, s := range cb.stages {
+		stages[i] = commandScript(s.Cmd, s.Args)
+	}
+	return strings.Join(stages, " | ")
+}
+
+// command returns the command to run: the command itself, or a shell running the pipeline.
+func (cb *CommandBuilder) command() (string, []string) {
+	if len(cb.stages) == 1 {
+		return cb.stages[0].Cmd, cb.stages[0].Args
+	}
+	return "sh", []string{"-c", cb.String()}
+}
+
+func (cr commandRunner) Cmd(cmd string, args ...string) *CommandBuilder {
+	return &CommandBuilder{runner: cr, stages: []CommandSpec{{Cmd: cmd, Args: slices.Clone(args)}}}
+}
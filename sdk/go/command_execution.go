@@ -2,33 +2,46 @@ package msb
 
 import (
 	"encoding/json"
+	"slices"
 	"strings"
+	"time"
 )
 
 // CommandExecution represents the result of command execution in the sandbox.
 // Use the Get* methods for parsed access to output, or access Output directly for raw JSON.
 type CommandExecution struct {
-	Output    json.RawMessage // Raw JSON response from the server
-	parsed    commandData     // Parsed data for convenience methods
-	parsedOK  bool           // Whether parsing succeeded
+	Output      json.RawMessage   // Raw JSON response from the server
+	parsed      commandData       // Parsed data for convenience methods
+	parsedOK    bool              // Whether parsing succeeded
+	termination TerminationReason // Why the command was terminated, if it was
+	spec        *SandboxSpec      // Environment snapshot, when WithSpecSnapshots is enabled
+	replayed    bool              // Whether the result was read from the journal instead of running
+	redactor    redactor          // Masks secrets in the error returned by Err
+	duration    time.Duration     // Wall-clock time the client waited for the command
+	truncation  OutputTruncation  // How the output was cut to WithMaxOutputBytes, if it was
+	usage       *ResourceUsage    // What the command consumed, when run with WithResourceUsage
 }
 
 // Internal structure for parsing command execution results
 type commandData struct {
-	OutputLines []outputLine `json:"output"`
+	OutputLines []OutputLine `json:"output"`
 	Command     string       `json:"command"`
 	Args        []string     `json:"args"`
 	ExitCode    int          `json:"exit_code"`
 	Success     bool         `json:"success"`
 }
 
+func newCommandExecution(result *executionResult) CommandExecution {
+	return NewCommandExecution(result.output)
+}
+
 // GetOutput returns the standard output from command execution as a string.
 // Returns ErrExecutionNotParsed if the raw JSON could not be parsed.
 func (ce CommandExecution) GetOutput() (string, error) {
 	if !ce.parsedOK {
 		return "", ErrExecutionNotParsed
 	}
-	
+
 	var output strings.Builder
 	for _, line := range ce.parsed.OutputLines {
 		if line.Stream == "stdout" {
@@ -45,7 +58,7 @@ func (ce CommandExecution) GetError() (string, error) {
 	if !ce.parsedOK {
 		return "", ErrExecutionNotParsed
 	}
-	
+
 	var errorOutput strings.Builder
 	for _, line := range ce.parsed.OutputLines {
 		if line.Stream == "stderr" {
@@ -56,8 +69,33 @@ func (ce CommandExecution) GetError() (string, error) {
 	return strings.TrimSuffix(errorOutput.String(), "\n"), nil
 }
 
+// GetOutputOrEmpty returns the standard output like GetOutput, or "" if the execution was not
+// parsed, for scripts that need not tell both apart.
+func (ce CommandExecution) GetOutputOrEmpty() string {
+	output, _ := ce.GetOutput()
+	return output
+}
+
+// GetErrorOrEmpty returns the error output like GetError, or "" if the execution was not parsed.
+func (ce CommandExecution) GetErrorOrEmpty() string {
+	errorOutput, _ := ce.GetError()
+	return errorOutput
+}
+
+// GetOutputLines returns stdout and stderr lines interleaved in the order they were produced, to
+// reconstruct the console as it appeared. Lines received in the same poll of a streamed or timed
+// execution are ordered stdout first, since their relative order is unknown.
+// Returns ErrExecutionNotParsed if the raw JSON could not be parsed.
+func (ce CommandExecution) GetOutputLines() ([]OutputLine, error) {
+	if !ce.parsedOK {
+		return nil, ErrExecutionNotParsed
+	}
+	return slices.Clone(ce.parsed.OutputLines), nil
+}
+
 // GetExitCode returns the exit code of the executed command.
-// Returns -1 if the raw JSON could not be parsed.
+// Returns -1 if the raw JSON could not be parsed, or the command did not run or was killed
+// without an exit code; see GetExitStatus.
 func (ce CommandExecution) GetExitCode() int {
 	if !ce.parsedOK {
 		return -1
@@ -74,6 +112,25 @@ func (ce CommandExecution) IsSuccess() bool {
 	return ce.parsed.Success
 }
 
+// HasError reports whether the command failed, i.e. the opposite of IsSuccess for a parsed
+// result, like [CodeExecution.HasError]. Returns false if the raw JSON could not be parsed.
+func (ce CommandExecution) HasError() bool {
+	return ce.parsedOK && !ce.parsed.Success
+}
+
+// GetStatus maps the outcome to a code execution status: "success" or "error".
+// Returns "unknown" if the raw JSON could not be parsed.
+func (ce CommandExecution) GetStatus() string {
+	switch {
+	case !ce.parsedOK:
+		return "unknown"
+	case ce.parsed.Success:
+		return "success"
+	default:
+		return "error"
+	}
+}
+
 // GetCommand returns the command that was executed.
 // Returns empty string if the raw JSON could not be parsed.
 func (ce CommandExecution) GetCommand() string {
@@ -90,4 +147,65 @@ func (ce CommandExecution) GetArgs() []string {
 		return nil
 	}
 	return ce.parsed.Args
-}
\ No newline at end of file
+}
+
+// GetTerminationReason reports why the command was terminated, if it was.
+func (ce CommandExecution) GetTerminationReason() TerminationReason {
+	return ce.termination
+}
+
+// Truncation reports how the output was cut to the cap set with [WithMaxOutputBytes] or
+// [WithExecMaxOutputBytes], if it was.
+func (ce CommandExecution) Truncation() OutputTruncation {
+	return ce.truncation
+}
+
+// TimedOut reports whether the command was killed because it exceeded its timeout.
+func (ce CommandExecution) TimedOut() bool {
+	return ce.termination.Kind == TerminationTimeout
+}
+
+// GetExitStatus reports how the command ended, telling apart the cases in which GetExitCode
+// returns -1.
+func (ce CommandExecution) GetExitStatus() ExitStatus {
+	switch {
+	case !ce.parsedOK && len(ce.Output) == 0:
+		return ExitNotRun
+	case !ce.parsedOK:
+		return ExitUnparsed
+	case ce.termination.Kind != TerminationNone:
+		return ExitKilled
+	default:
+		return ExitExited
+	}
+}
+
+// GetDuration returns the wall-clock time from sending the command until its result arrived, as
+// measured by the client, so it includes the round trips to the server. It is zero for results
+// that were not measured, such as replayed ones.
+func (ce CommandExecution) GetDuration() time.Duration {
+	return ce.duration
+}
+
+// GetSpec returns the environment the execution ran in, or false unless [WithSpecSnapshots] is enabled.
+func (ce CommandExecution) GetSpec() (SandboxSpec, bool) {
+	if ce.spec == nil {
+		return SandboxSpec{}, false
+	}
+	return *ce.spec, true
+}
+
+// GetResourceUsage returns what the command consumed in the guest, or false unless it was run
+// with [WithResourceUsage] and measured.
+func (ce CommandExecution) GetResourceUsage() (ResourceUsage, bool) {
+	if ce.usage == nil {
+		return ResourceUsage{}, false
+	}
+	return *ce.usage, true
+}
+
+// Replayed reports whether the result was recorded by an earlier run in the sandbox's journal
+// and the command did not run again; see [Journal].
+func (ce CommandExecution) Replayed() bool {
+	return ce.replayed
+}
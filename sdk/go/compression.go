@@ -0,0 +1,169 @@
+package msb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Compression is a codec applied to file transfers, compressed on one side and decompressed on
+// the other. The zero value transfers data as is, which suits already-compressed data such as
+// model weights; gzip suits source trees. Other codecs, such as zstd or lz4, plug in by providing
+// their client-side streams and the guest commands that handle them:
+//
+//	lz4Compression := msb.Compression{
+//		Name:            "lz4",
+//		NewWriter:       func(w io.Writer) (io.WriteCloser, error) { return lz4.NewWriter(w), nil },
+//		NewReader:       func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(lz4.NewReader(r)), nil },
+//		GuestCompress:   "lz4 -q -c",
+//		GuestDecompress: "lz4 -q -d -c",
+//	}
+//
+// Streams written by NewWriter must remain valid when concatenated, as gzip members and zstd
+// and lz4 frames do, since large uploads are compressed in parallel blocks that are joined.
+type Compression struct {
+	Name string
+	// NewWriter and NewReader compress and decompress on the client.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	// GuestCompress and GuestDecompress are shell commands in the guest that compress and
+	// decompress stdin to stdout.
+	GuestCompress   string
+	GuestDecompress string
+}
+
+// GzipCompression returns gzip compression at the given level, from [gzip.BestSpeed] to
+// [gzip.BestCompression], or [gzip.DefaultCompression]. The guest needs gzip, which every
+// common image has.
+func GzipCompression(level int) Compression {
+	return Compression{
+		Name:            "gzip",
+		NewWriter:       func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriterLevel(w, level) },
+		NewReader:       func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		GuestCompress:   "gzip -c",
+		GuestDecompress: "gzip -d -c",
+	}
+}
+
+func (c Compression) enabled() bool {
+	return c.NewWriter != nil
+}
+
+func (c Compression) validate() error {
+	if !c.enabled() && c.NewReader == nil && c.GuestCompress == "" && c.GuestDecompress == "" {
+		return nil
+	}
+	if c.NewWriter == nil || c.NewReader == nil || c.GuestCompress == "" || c.GuestDecompress == "" {
+		return fmt.Errorf("%w: %q: both client streams and both guest commands are required", ErrInvalidCompression, c.Name)
+	}
+	return nil
+}
+
+// TransferOption configures a single file transfer of a [FileSystem].
+type TransferOption func(*transferConfig)
+
+type transferConfig struct {
+	compression Compression
+	parallelism int
+	verify      string  // expected SHA-256 of the downloaded data, in hex; empty = none
+	digest      *string // receives the SHA-256 of the downloaded data; nil = none
+}
+
+// WithCompression compresses the transfer with c, overriding [WithDefaultCompression].
+func WithCompression(c Compression) TransferOption {
+	return func(tc *transferConfig) {
+		tc.compression = c
+	}
+}
+
+// WithCompressionParallelism compresses uploads in up to n blocks at a time. Defaults to the
+// number of CPUs.
+func WithCompressionParallelism(n int) TransferOption {
+	return func(tc *transferConfig) {
+		tc.parallelism = n
+	}
+}
+
+// WithDefaultCompression compresses every file transfer with c, unless the transfer chooses
+// otherwise with [WithCompression].
+func WithDefaultCompression(c Compression) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.compression = c
+	}
+}
+
+func (f fileSystem) transferConfig(opts []TransferOption) (transferConfig, error) {
+	tc := transferConfig{compression: f.b.config().compression, parallelism: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(&tc)
+	}
+	tc.parallelism = max(tc.parallelism, 1)
+	return tc, tc.compression.validate()
+}
+
+// compressBlockBytes is the size of the blocks uploads are compressed in parallel in. Smaller
+// blocks compress worse, since every block starts without history.
+const compressBlockBytes = 4 << 20
+
+// compress compresses data in blocks, up to tc.parallelism at a time, and joins them.
+func (tc transferConfig) compress(data []byte) ([]byte, error) {
+	nblocks := max((len(data)+compressBlockBytes-1)/compressBlockBytes, 1)
+	blocks := make([]bytes.Buffer, nblocks)
+	errs := make([]error, nblocks)
+	sem := make(chan struct{}, tc.parallelism)
+	var wg sync.WaitGroup
+	for i := range blocks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			block := data[i*compressBlockBytes : min((i+1)*compressBlockBytes, len(data))]
+			w, err := tc.compression.NewWriter(&blocks[i])
+			if err == nil {
+				_, err = w.Write(block)
+				err = errors.Join(err, w.Close())
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCompressionFailed, err)
+	}
+	var out bytes.Buffer
+	for i := range blocks {
+		out.Write(blocks[i].Bytes())
+	}
+	return out.Bytes(), nil
+}
+
+// decompress decompresses data compressed in the guest, feeding the result to h as well if it
+// is not nil.
+func (tc transferConfig) decompress(data []byte, h hash.Hash) ([]byte, error) {
+	r, err := tc.compression.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCompressionFailed, err)
+	}
+	defer r.Close()
+	var src io.Reader = r
+	if h != nil {
+		src = io.TeeReader(r, h)
+	}
+	out, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCompressionFailed, err)
+	}
+	return out, nil
+}
+
+// Compression-related errors
+var (
+	ErrInvalidCompression = errors.New("invalid compression")
+	ErrCompressionFailed  = errors.New("compression failed")
+)
@@ -1,14 +1,70 @@
 package msb
 
+import (
+	"context"
+	"time"
+)
+
 type ReqIdProducer func() string
 
 type config struct {
-	serverUrl string
-	namespace string
-	name      string
-	apiKey    string
-	logger    Logger
-	reqIDPrd  ReqIdProducer
+	serverUrl      string
+	serverOverride string // takes precedence over serverUrl when set
+	namespace      string
+	name           string
+	apiKey         string
+	tokenProvider  TokenProvider // takes precedence over apiKey
+	logger         Logger
+	reqIDPrd       ReqIdProducer
+	timeout        time.Duration     // per-RPC deadline; zero means none
+	languages      []Language        // additional languages enabled for per-execution selection
+	volumes        []string          // "host:guest" mounts; relative host paths resolve against the namespace directory
+	ports          map[int]int       // published ports, host port by guest port
+	image          string            // default image for Start
+	memoryMB       int               // default memory for Start; 0 means 512
+	cpus           int               // default vCPUs for Start; 0 means 1
+	env            EnvSpec           // guest environment, rendered at Start
+	envVars        map[string]string // plain guest environment variables; override env
+	secrets        []string          // values masked in command errors
+	compression    Compression       // default compression of file transfers
+	idleTimeout    time.Duration     // stop after this long without executions; zero means never
+
+	startProgress    StartProgressFunc
+	maxResponseBytes int64
+	strictParsing    bool
+	specSnapshots    bool
+	readOnlyVolumes  []string // guest paths of volumes remounted read-only after start
+
+	retry              retryPolicy
+	adaptiveTimeout    *latencyTracker // per-method deadlines learnt from latencies; overrides timeout
+	startTimeout       time.Duration   // deadline of sandbox.start; overrides timeout and adaptiveTimeout
+	readyTimeout       time.Duration   // how long Start waits for executions to be accepted; zero means not at all
+	signer             Signer
+	rpcCompression     *rpcCompression // gzip negotiation for RPC payloads; nil means disabled
+	preconnect         int             // connections opened when the sandbox is built
+	workdir            string          // guest directory executions run from; empty means the guest's default
+	sharedDir          sharedDir
+	artifactsDir       string // guest directory GetArtifacts collects by default
+	redactSecretEnv    bool   // mask secret-looking envVars values like secrets
+	metrics            MetricsRecorder
+	serverVersionCheck bool  // check the server version on Start
+	maxOutputBytes     int64 // cap on each output stream kept in results; 0 means none
+	maintenanceHandler MaintenanceHandler
+	outputSinks        []OutputSink
+	publishers         []Publisher
+	journal            *Journal
+	readinessProbe     *Probe
+	livenessProbe      *Probe
+	healthHandler      HealthHandler
+
+	preExec  []ExecHook
+	postExec []ExecHook
+
+	onStart     []func(context.Context, StartEvent)
+	onStop      []func(context.Context, StopEvent)
+	onExecution []func(context.Context, ExecutionEvent)
+
+	interceptors []Interceptor
 }
 
 const (
@@ -0,0 +1,153 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Copier copies files between sandboxes.
+type Copier interface {
+	// CopyTo copies the file or directory srcPath of this sandbox to dstPath in dst, which may be
+	// on another server, e.g. to test in one sandbox what was built in another. Parent
+	// directories of dstPath are created; a file at dstPath is replaced, and a directory copied
+	// over an existing one is merged into it. Ownership, modes and symlinks are preserved.
+	//
+	// The server has no API to move data between sandboxes, so the copy goes through a
+	// directory both sandboxes mount when there is one: a [WithVolume] of this sandbox that is
+	// not read-only and whose host directory dst mounts as well. The data then stays on the
+	// server's machine. Otherwise it is relayed through the client, as an archive read from this
+	// sandbox and written to dst with opts, e.g. [WithCompression] to shrink it in transit.
+	//
+	//	err := build.CopyTo(ctx, test, "/src/dist", "/app/dist")
+	CopyTo(ctx context.Context, dst LangSandBox, srcPath, dstPath string, opts ...TransferOption) error
+}
+
+type copier struct {
+	b *baseMicroSandbox
+}
+
+// copyArchiveScript archives $s in $a, as its content for a directory and as itself otherwise,
+// and prints which of both it is.
+const copyArchiveScript = `if [ -d "$s" ]; then
+	kind=dir; set -- -C "$s" .
+elif [ -e "$s" ] || [ -L "$s" ]; then
+	kind=file; set -- -C "$(dirname "$s")" "./$(basename "$s")"
+else
+	exit 2
+fi
+mkdir -p "$(dirname "$a")" && tar -cf "$a" "$@" && echo "$kind"`
+
+// copyFindScript sets a to the archive named $n in whichever mount of the guest holds it, and
+// exits with 3 if none does.
+const copyFindScript = `a=
+while read -r _ m _; do
+	if [ -f "$m/$n" ]; then a="$m/$n"; break; fi
+done </proc/mounts
+[ -n "$a" ] || exit 3`
+
+func (c copier) CopyTo(ctx context.Context, dst LangSandBox, srcPath, dstPath string, opts ...TransferOption) error {
+	if c.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	if dst == nil {
+		return fmt.Errorf("%w: no destination sandbox", ErrFailedToCopy)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToCopy, err)
+	}
+	name := ".msb-copy-" + id + ".tar"
+	archive := guestPayloadsDir + "/" + name
+	shared := c.writableVolume()
+	if shared != "" {
+		archive = shared + "/" + name
+	}
+
+	exec, err := c.b.runShell(ctx, `s=`+shellQuote(srcPath)+`; a=`+shellQuote(archive)+"\n"+copyArchiveScript)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToCopy, err)
+	}
+	defer func() {
+		_ = fileSystem{c.b}.RemoveFile(context.WithoutCancel(ctx), archive)
+	}()
+	if exec.GetExitCode() == 2 {
+		return fmt.Errorf("%w: %w: %s", ErrFailedToCopy, fs.ErrNotExist, srcPath)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToCopy, commandStderr(exec))
+	}
+	out, _ := exec.GetOutput()
+	extract := copyExtractScript(strings.TrimSpace(out) == "dir", path.Base(srcPath), dstPath)
+
+	if shared != "" {
+		exec, err := runGuestShell(ctx, dst, `n=`+shellQuote(name)+"\n"+copyFindScript+"\n"+extract)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrFailedToCopy, err)
+		}
+		if exec.IsSuccess() {
+			c.b.logger().Debug("Copied through a shared volume", "sandbox", c.b.name(), "from", srcPath, "to", dstPath)
+			return nil
+		}
+		if exec.GetExitCode() != 3 {
+			return fmt.Errorf("%w: %s", ErrFailedToCopy, commandStderr(exec))
+		}
+	}
+
+	// No volume in common: relay the archive
+	data, err := fileSystem{c.b}.ReadFile(ctx, archive, opts...)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToCopy, err)
+	}
+	relayed := guestPayloadsDir + "/" + name
+	if err := dst.Files().WriteFile(ctx, relayed, data, 0o600, opts...); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToCopy, err)
+	}
+	exec, err = runGuestShell(ctx, dst, `a=`+shellQuote(relayed)+"\n"+extract+`; s=$?; rm -f "$a"; exit $s`)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToCopy, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToCopy, commandStderr(exec))
+	}
+	c.b.logger().Debug("Copied through the client", "sandbox", c.b.name(), "from", srcPath, "to", dstPath, "bytes", len(data))
+	return nil
+}
+
+// writableVolume returns the guest path of a volume the sandbox can write to, if any.
+func (c copier) writableVolume() string {
+	cfg := c.b.config()
+	for _, volume := range cfg.volumes {
+		_, guest, _ := strings.Cut(volume, ":")
+		guest = path.Clean(guest)
+		if !slices.ContainsFunc(cfg.readOnlyVolumes, func(ro string) bool { return path.Clean(ro) == guest }) {
+			return guest
+		}
+	}
+	return ""
+}
+
+// copyExtractScript unpacks the archive $a made by copyArchiveScript at dstPath.
+func copyExtractScript(dir bool, base, dstPath string) string {
+	d := shellQuote(dstPath)
+	if dir {
+		return `mkdir -p ` + d + ` && tar -xpf "$a" -C ` + d
+	}
+	return `t=$(mktemp -d) && mkdir -p "$(dirname ` + d + `)" && tar -xpf "$a" -C "$t" && rm -rf ` + d +
+		` && mv "$t"/` + shellQuote(base) + ` ` + d + `; s=$?; rm -rf "$t"; [ $s -eq 0 ]`
+}
+
+// runGuestShell runs script in sandbox, which may be any implementation of LangSandBox.
+func runGuestShell(ctx context.Context, sandbox LangSandBox, script string) (CommandExecution, error) {
+	if ls, ok := sandbox.(*langSandbox); ok {
+		return ls.b.runShell(ctx, script)
+	}
+	return sandbox.Command().Run(ctx, "sh", []string{"-c", script})
+}
+
+// Copy-related errors
+var ErrFailedToCopy = errors.New("failed to copy between sandboxes")
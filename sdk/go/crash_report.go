@@ -0,0 +1,96 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// CrashReporter explains why an execution was killed.
+type CrashReporter interface {
+	// CrashReport inspects the sandbox after exec terminated abnormally and gathers the
+	// killing signal, related kernel log lines and any core dumps left behind.
+	CrashReport(ctx context.Context, exec CommandExecution) (CrashReport, error)
+}
+
+// CrashReport describes how and why a command died.
+type CrashReport struct {
+	ExitCode    int
+	Signal      int      // Terminating signal inferred from a shell's 128+N exit code or an OOM kill; 0 if unknown
+	SignalName  string   // e.g. "SIGKILL"; empty if Signal is 0
+	OOMKilled   bool     // Whether the kernel log shows the OOM killer terminating the command
+	KernelLog   []string // Recent kernel log lines about OOM kills, segfaults and traps
+	CorePattern string   // The guest's kernel.core_pattern, telling where core dumps go
+	CoreDumps   []string // Core dump files found in the usual locations
+}
+
+// Crashed reports whether the report found evidence of an abnormal termination.
+func (r CrashReport) Crashed() bool {
+	return r.Signal != 0 || r.OOMKilled || len(r.CoreDumps) > 0
+}
+
+type crashReporter struct {
+	b *baseMicroSandbox
+}
+
+// crashReportScript collects crash evidence; each output line is tagged with its kind.
+const crashReportScript = `echo "P $(cat /proc/sys/kernel/core_pattern 2>/dev/null)"
+dmesg 2>/dev/null | tail -n 500 | grep -iE 'out of memory|oom-kill|killed process|segfault|general protection|traps:' | tail -n 50 | sed 's/^/K /'
+for f in ./core ./core.* /tmp/core* /var/crash/*; do [ -f "$f" ] && echo "C $f"; done
+true`
+
+func (c crashReporter) CrashReport(ctx context.Context, exec CommandExecution) (CrashReport, error) {
+	report := CrashReport{ExitCode: exec.GetExitCode()}
+	// Shells report death by signal N as exit code 128+N
+	if code := report.ExitCode; code > 128 && code < 128+65 {
+		report.Signal = code - 128
+		report.SignalName = signalName(report.Signal)
+	}
+
+	probe, err := c.b.runShell(ctx, crashReportScript)
+	if err != nil {
+		return report, fmt.Errorf("%w: %w", ErrFailedToGetCrashReport, err)
+	}
+	out, err := probe.GetOutput()
+	if err != nil {
+		return report, fmt.Errorf("%w: %w", ErrFailedToGetCrashReport, err)
+	}
+
+	name := path.Base(exec.GetCommand())
+	for _, line := range strings.Split(out, "\n") {
+		tag, value, _ := strings.Cut(line, " ")
+		switch tag {
+		case "P":
+			report.CorePattern = value
+		case "K":
+			report.KernelLog = append(report.KernelLog, value)
+			lower := strings.ToLower(value)
+			if strings.Contains(lower, "killed process") && (name == "." || name == "" || strings.Contains(value, "("+name+")")) {
+				report.OOMKilled = true
+			}
+		case "C":
+			report.CoreDumps = append(report.CoreDumps, value)
+		}
+	}
+	if report.OOMKilled && report.Signal == 0 {
+		report.Signal, report.SignalName = 9, signalName(9)
+	}
+	return report, nil
+}
+
+func signalName(sig int) string {
+	names := map[int]string{
+		1: "SIGHUP", 2: "SIGINT", 3: "SIGQUIT", 4: "SIGILL", 5: "SIGTRAP", 6: "SIGABRT",
+		7: "SIGBUS", 8: "SIGFPE", 9: "SIGKILL", 11: "SIGSEGV", 13: "SIGPIPE", 14: "SIGALRM",
+		15: "SIGTERM", 24: "SIGXCPU", 25: "SIGXFSZ", 31: "SIGSYS",
+	}
+	if name, ok := names[sig]; ok {
+		return name
+	}
+	return fmt.Sprintf("SIG%d", sig)
+}
+
+// ErrFailedToGetCrashReport is returned when crash evidence cannot be collected from the sandbox.
+var ErrFailedToGetCrashReport = errors.New("failed to get crash report")
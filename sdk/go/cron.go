@@ -0,0 +1,498 @@
+package msb
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronScheduler installs recurring commands inside the sandbox.
+type CronScheduler interface {
+	// Cron runs the shell command inside the sandbox on the given schedule, until the returned
+	// CronJob is stopped or the sandbox goes away. The schedule is a standard five-field cron
+	// expression ("*/15 9-17 * * mon-fri") or one of @hourly, @daily, @weekly, @monthly and
+	// @yearly, evaluated against the guest's clock and time zone, which is UTC unless the image
+	// sets TZ. The scheduler runs inside the guest, so the client need not stay connected.
+	Cron(ctx context.Context, schedule, command string) (*CronJob, error)
+	// Schedule runs code on the given schedule like Cron, in the sandbox's language unless opts
+	// select another one with [WithLanguage]; opts also set its environment and working directory
+	// as for Code().Run, e.g. to generate a report every night:
+	//
+	//	job, err := sandbox.Schedule(ctx, "@daily", "import report; report.generate()")
+	Schedule(ctx context.Context, schedule, code string, opts ...RunOption) (*CronJob, error)
+	// CronJobs lists the jobs scheduled in the sandbox, including those scheduled by other
+	// clients or by an earlier run of this program, which need not have started the sandbox.
+	CronJobs(ctx context.Context) ([]*CronJob, error)
+}
+
+// CronJob is a handle to a command or code scheduled with Cron or Schedule.
+type CronJob struct {
+	proc     *guestProcess
+	schedule string
+	command  string // Shell command run on schedule; for code, the interpreter's command line
+}
+
+// CronRun is one run of a [CronJob].
+type CronRun struct {
+	Started time.Time
+	Running bool
+	// Execution holds the run's output and exit code; while the run is in progress, it holds
+	// the output so far and an exit code of -1.
+	Execution CommandExecution
+}
+
+// cronHistoryLimit is how many finished runs the guest keeps for History.
+const cronHistoryLimit = 100
+
+// cronScript is the scheduler. From the next minute on, it wakes at the start of every minute
+// and launches the command in the background when the minute matches the schedule, whose fields
+// arrive expanded into lists of values, so the guest only checks membership. Each run records
+// its output under runs/<minute>, and the oldest finished runs are pruned.
+const cronScript = `has() { [ "$1" = "*" ] && return 0; case " $1 " in *" $2 "*) return 0;; esac; return 1; }
+day() {
+  if [ "$MSB_CRON_DAYOR" = 1 ]; then has "$MSB_CRON_DOM" "$1" || has "$MSB_CRON_DOW" "$2"
+  else has "$MSB_CRON_DOM" "$1" && has "$MSB_CRON_DOW" "$2"; fi
+}
+mkdir -p "$MSB_CRON_DIR/runs"
+last=$(date +%Y%m%d%H%M)
+while :; do
+  set -- $(date '+%Y%m%d%H%M %M %H %d %m %w %S')
+  if [ "$1" != "$last" ]; then
+    last=$1
+    if [ ! -f "$MSB_CRON_DIR/paused" ] && has "$MSB_CRON_MIN" "${2#0}" && has "$MSB_CRON_HOUR" "${3#0}" && has "$MSB_CRON_MON" "${5#0}" && day "${4#0}" "$6"; then
+      r="$MSB_CRON_DIR/runs/$1"; mkdir -p "$r"; date +%s >"$r/start"
+      ( (eval "$MSB_CRON_CMD") >"$r/out" 2>"$r/err" </dev/null; echo $? >"$r/exit.tmp"; mv "$r/exit.tmp" "$r/exit" ) &
+      n=$(ls "$MSB_CRON_DIR/runs" | wc -l)
+      [ "$n" -gt ` + "{limit}" + ` ] && ls "$MSB_CRON_DIR/runs" | head -n $((n - ` + "{limit}" + `)) | while read -r x; do
+        [ -f "$MSB_CRON_DIR/runs/$x/exit" ] && rm -rf "$MSB_CRON_DIR/runs/$x"
+      done
+    fi
+  fi
+  sleep $((60 - ${7#0}))
+done`
+
+type cronScheduler struct {
+	b *baseMicroSandbox
+	l Language
+}
+
+func (cs cronScheduler) Cron(ctx context.Context, schedule, command string) (*CronJob, error) {
+	if command == "" {
+		return nil, fmt.Errorf("%w: empty command", ErrFailedToScheduleCron)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToScheduleCron, err)
+	}
+	return cs.schedule(ctx, id, schedule, command)
+}
+
+func (cs cronScheduler) Schedule(ctx context.Context, schedule, code string, opts ...RunOption) (*CronJob, error) {
+	if cs.b.state.Load() != started {
+		return nil, ErrSandboxNotStarted
+	}
+	if code == "" {
+		return nil, fmt.Errorf("%w: empty code", ErrFailedToScheduleCron)
+	}
+	rc := newRunConfig(opts)
+	rt, err := cs.b.resolveLanguage(ctx, cs.l, rc.language)
+	if err != nil {
+		return nil, err
+	}
+	prelude, err := cs.b.shellPrelude(rc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToScheduleCron, err)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToScheduleCron, err)
+	}
+	command := prelude + code
+	if !rt.shell {
+		// The source lives in the job's directory, so stopping the job cleans it up too
+		path := guestJobsDir + "/" + id + "/code"
+		if err := cs.b.uploadPayload(ctx, path, []byte(code)); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToScheduleCron, err)
+		}
+		command = prelude + rt.command(path)
+	}
+	return cs.schedule(ctx, id, schedule, command)
+}
+
+// schedule launches the scheduler for command in the job directory id, after recording the
+// schedule and the command there for CronJobs.
+func (cs cronScheduler) schedule(ctx context.Context, id, schedule, command string) (*CronJob, error) {
+	fields, dayOr, err := parseCronSchedule(schedule)
+	if err != nil {
+		return nil, err
+	}
+	dir := shellQuote(guestJobsDir + "/" + id)
+	exec, err := cs.b.runShell(ctx, `mkdir -p `+dir+` && cd `+dir+` &&
+printf '%s' `+shellQuote(schedule)+` >schedule && printf '%s' `+shellQuote(command)+` >command`)
+	if err == nil && !exec.IsSuccess() {
+		err = errors.New(commandStderr(exec))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToScheduleCron, err)
+	}
+	vars := []string{
+		"MSB_CRON_DIR=" + shellQuote(guestJobsDir+"/"+id),
+		"MSB_CRON_CMD=" + shellQuote(command),
+		"MSB_CRON_MIN=" + shellQuote(fields[0]),
+		"MSB_CRON_HOUR=" + shellQuote(fields[1]),
+		"MSB_CRON_DOM=" + shellQuote(fields[2]),
+		"MSB_CRON_MON=" + shellQuote(fields[3]),
+		"MSB_CRON_DOW=" + shellQuote(fields[4]),
+		"MSB_CRON_DAYOR=0",
+	}
+	if dayOr {
+		vars[len(vars)-1] = "MSB_CRON_DAYOR=1"
+	}
+	script := strings.Join(vars, "; ") + "\n" + strings.ReplaceAll(cronScript, "{limit}", strconv.Itoa(cronHistoryLimit))
+	proc, err := cs.b.spawnWithID(ctx, id, script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToScheduleCron, err)
+	}
+	cs.b.logger().Info("Cron job scheduled", "sandbox", cs.b.name(), "schedule", schedule, "command", command, "id", id)
+	return &CronJob{proc: proc, schedule: schedule, command: command}, nil
+}
+
+// cronListScript prints, for each live scheduler in the jobs directory, its ID, PID, whether it
+// is paused, and its schedule and command base64-encoded.
+const cronListScript = `cd ` + guestJobsDir + ` 2>/dev/null || exit 0
+for j in *; do
+  [ -f "$j/schedule" ] && kill -0 "$(cat "$j/pid" 2>/dev/null)" 2>/dev/null || continue
+  p=0; [ -f "$j/paused" ] && p=1
+  echo "$j $(cat "$j/pid") $p $(base64 <"$j/schedule" | tr -d '\n') $(base64 <"$j/command" | tr -d '\n')"
+done`
+
+func (cs cronScheduler) CronJobs(ctx context.Context) ([]*CronJob, error) {
+	// Listing goes through the command RPC directly, as for an attached process, so that it
+	// works whether or not this client started the sandbox
+	lister := &guestProcess{b: cs.b, foreign: true}
+	exec, err := lister.shell(ctx, cronListScript)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToListCronJobs, err)
+	}
+	if !exec.IsSuccess() {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToListCronJobs, commandStderr(exec))
+	}
+	out, _ := exec.GetOutput()
+	var jobs []*CronJob
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		schedule, err := base64.StdEncoding.DecodeString(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+		}
+		command, err := base64.StdEncoding.DecodeString(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+		}
+		jobs = append(jobs, &CronJob{
+			proc:     &guestProcess{b: cs.b, id: fields[0], pid: pid, foreign: true},
+			schedule: string(schedule),
+			command:  string(command),
+		})
+	}
+	return jobs, nil
+}
+
+// ID identifies the job within the sandbox.
+func (c *CronJob) ID() string {
+	return c.proc.id
+}
+
+// Schedule returns the job's schedule, as passed to Cron or Schedule.
+func (c *CronJob) Schedule() string {
+	return c.schedule
+}
+
+// Command returns the shell command the job runs; for code scheduled with Schedule, it is the
+// command line running the code's interpreter.
+func (c *CronJob) Command() string {
+	return c.command
+}
+
+// Pause skips the job's runs until Resume is called, leaving a run in progress alone.
+func (c *CronJob) Pause(ctx context.Context) error {
+	return c.setPaused(ctx, `: >`)
+}
+
+// Resume undoes Pause: the job runs again from its next scheduled time. Runs skipped while
+// paused are not caught up.
+func (c *CronJob) Resume(ctx context.Context) error {
+	return c.setPaused(ctx, `rm -f `)
+}
+
+// Paused reports whether the job is paused.
+func (c *CronJob) Paused(ctx context.Context) (bool, error) {
+	exec, err := c.proc.shell(ctx, `[ -f `+shellQuote(c.proc.dir()+"/paused")+` ]`)
+	if err != nil {
+		return false, err
+	}
+	return exec.IsSuccess(), nil
+}
+
+func (c *CronJob) setPaused(ctx context.Context, op string) error {
+	exec, err := c.proc.shell(ctx, `[ -d `+shellQuote(c.proc.dir())+` ] && `+op+shellQuote(c.proc.dir()+"/paused"))
+	if err != nil {
+		return err
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %q", ErrGuestProcessNotFound, c.proc.id)
+	}
+	return nil
+}
+
+// LastRun returns the job's most recent finished run, or false if none has finished yet.
+func (c *CronJob) LastRun(ctx context.Context) (CronRun, bool, error) {
+	runs, err := c.History(ctx)
+	if err != nil {
+		return CronRun{}, false, err
+	}
+	for _, run := range slices.Backward(runs) {
+		if !run.Running {
+			return run, true, nil
+		}
+	}
+	return CronRun{}, false, nil
+}
+
+// History returns the job's runs, oldest first. The sandbox keeps the last 100 finished runs.
+func (c *CronJob) History(ctx context.Context) ([]CronRun, error) {
+	records, err := readGuestRuns(ctx, c.proc, ErrFailedToReadCronHistory)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]CronRun, 0, len(records))
+	for _, rec := range records {
+		runs = append(runs, CronRun{
+			Started:   rec.started,
+			Running:   rec.running,
+			Execution: commandExecutionFromStreams("sh", []string{"-c", c.command}, rec.exitCode, rec.stdout, rec.stderr, TerminationReason{}),
+		})
+	}
+	return runs, nil
+}
+
+// guestRun is a run recorded by a guest-side scheduler under runs/<name> in its directory: its
+// start time in "start", its output in "out" and "err", its exit code in "exit" once it finished,
+// and optionally the files that triggered it in "files".
+type guestRun struct {
+	name           string
+	started        time.Time
+	running        bool
+	exitCode       int
+	stdout, stderr []byte
+	files          []byte
+}
+
+// readGuestRuns reads the runs recorded by proc, in the order of their names. Failures wrap
+// errKind.
+func readGuestRuns(ctx context.Context, proc *guestProcess, errKind error) ([]guestRun, error) {
+	script := `cd ` + shellQuote(proc.dir()+"/runs") + ` 2>/dev/null || exit 0
+for r in *; do
+  [ -f "$r/start" ] || continue
+  echo "R $r $(cat "$r/start") $(cat "$r/exit" 2>/dev/null)"
+  echo "O $(base64 <"$r/out" 2>/dev/null | tr -d '\n')"
+  echo "E $(base64 <"$r/err" 2>/dev/null | tr -d '\n')"
+  echo "F $(base64 <"$r/files" 2>/dev/null | tr -d '\n')"
+done`
+	exec, err := proc.shell(ctx, script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errKind, err)
+	}
+	out, _ := exec.GetOutput()
+	if !exec.IsSuccess() {
+		return nil, fmt.Errorf("%w: %s", errKind, commandStderr(exec))
+	}
+
+	var runs []guestRun
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		tag, value, _ := strings.Cut(sc.Text(), " ")
+		if tag == "R" {
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				continue
+			}
+			secs, _ := strconv.ParseInt(fields[1], 10, 64)
+			run := guestRun{name: fields[0], started: time.Unix(secs, 0), exitCode: -1, running: true}
+			if len(fields) > 2 {
+				if code, err := strconv.Atoi(fields[2]); err == nil {
+					run.exitCode, run.running = code, false
+				}
+			}
+			runs = append(runs, run)
+			continue
+		}
+		if len(runs) == 0 {
+			continue
+		}
+		run := &runs[len(runs)-1]
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+		}
+		switch tag {
+		case "O":
+			run.stdout = data
+		case "E":
+			run.stderr = data
+		case "F":
+			run.files = data
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errKind, err)
+	}
+	return runs, nil
+}
+
+// Stop unschedules the job, kills any run in progress and discards the job's history, deleting
+// the job.
+func (c *CronJob) Stop(ctx context.Context) error {
+	if err := c.proc.signal(ctx, "KILL"); err != nil {
+		return err
+	}
+	return c.proc.remove(ctx)
+}
+
+// cronField is the range of values of a field of a cron expression, with the names its values
+// may be given by, starting at min.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+	maxIsMin bool // max is an alias of min, as 7 is of Sunday
+}
+
+var cronFields = [5]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}, maxIsMin: true},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCronSchedule expands each field of a cron expression into the space-separated list of
+// values it matches, or "*" if it matches all of them. As in cron, a day matches if either the
+// day of month or the day of week does when both are restricted, which dayOr reports.
+func parseCronSchedule(schedule string) (fields [5]string, dayOr bool, err error) {
+	expr := strings.TrimSpace(schedule)
+	if d, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return fields, false, fmt.Errorf("%w: %q: want 5 fields", ErrInvalidCronSchedule, schedule)
+	}
+	for i, part := range parts {
+		values, err := cronFields[i].parse(part)
+		if err != nil {
+			return fields, false, fmt.Errorf("%w: %q: %w", ErrInvalidCronSchedule, schedule, err)
+		}
+		fields[i] = values
+	}
+	dayOr = !strings.HasPrefix(parts[2], "*") && !strings.HasPrefix(parts[4], "*")
+	return fields, dayOr, nil
+}
+
+func (f cronField) parse(s string) (string, error) {
+	if s == "*" {
+		return "*", nil
+	}
+	set := make(map[int]bool)
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return "", fmt.Errorf("invalid step %q in %s", stepStr, f.name)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(loStr); err != nil {
+				return "", err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiStr); err != nil {
+					return "", err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if hi < lo {
+				return "", fmt.Errorf("invalid range %q in %s", rng, f.name)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			if v == f.max && f.maxIsMin {
+				set[f.min] = true
+				continue
+			}
+			set[v] = true
+		}
+	}
+
+	values := make([]int, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, " "), nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if i := slices.Index(f.names, strings.ToLower(s)); i >= 0 {
+		return f.min + i, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	return v, nil
+}
+
+// Cron-related errors
+var (
+	ErrInvalidCronSchedule     = errors.New("invalid cron schedule")
+	ErrFailedToScheduleCron    = errors.New("failed to schedule cron job")
+	ErrFailedToReadCronHistory = errors.New("failed to read cron history")
+	ErrFailedToListCronJobs    = errors.New("failed to list cron jobs")
+)
@@ -0,0 +1,73 @@
+package msb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		fields   [5]string
+		dayOr    bool
+	}{
+		{"* * * * *", [5]string{"*", "*", "*", "*", "*"}, false},
+		{"*/15 * * * *", [5]string{"0 15 30 45", "*", "*", "*", "*"}, false},
+		{"5/20 * * * *", [5]string{"5 25 45", "*", "*", "*", "*"}, false},
+		{"0-30/10 9-17/4 * * *", [5]string{"0 10 20 30", "9 13 17", "*", "*", "*"}, false},
+		{"0,30 8,12-13 * * *", [5]string{"0 30", "8 12 13", "*", "*", "*"}, false},
+		{"0 0 1 jan,Jul *", [5]string{"0", "0", "1", "1 7", "*"}, false},
+		{"0 0 * MAR-may *", [5]string{"0", "0", "*", "3 4 5", "*"}, false},
+		{"0 0 * * mon-fri", [5]string{"0", "0", "*", "*", "1 2 3 4 5"}, false},
+		{"0 0 * * 7", [5]string{"0", "0", "*", "*", "0"}, false},
+		{"0 0 * * 5-7", [5]string{"0", "0", "*", "*", "0 5 6"}, false},
+		{"0 0 * * */2", [5]string{"0", "0", "*", "*", "0 2 4 6"}, false},
+		{"  @daily ", [5]string{"0", "0", "*", "*", "*"}, false},
+		{"@WEEKLY", [5]string{"0", "0", "*", "*", "0"}, false},
+		{"@yearly", [5]string{"0", "0", "1", "1", "*"}, false},
+		{"@hourly", [5]string{"0", "*", "*", "*", "*"}, false},
+		// Restricting both days matches either of them
+		{"0 0 1,15 * fri", [5]string{"0", "0", "1 15", "*", "5"}, true},
+		{"0 0 */2 * fri", [5]string{"0", "0", "1 3 5 7 9 11 13 15 17 19 21 23 25 27 29 31", "*", "5"}, false},
+		{"0 0 13 * *", [5]string{"0", "0", "13", "*", "*"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			fields, dayOr, err := parseCronSchedule(tt.schedule)
+			if err != nil {
+				t.Fatalf("parseCronSchedule: %v", err)
+			}
+			if fields != tt.fields || dayOr != tt.dayOr {
+				t.Errorf("parseCronSchedule = %q, %v; want %q, %v", fields, dayOr, tt.fields, tt.dayOr)
+			}
+		})
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	for _, schedule := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@reboot",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"10-5 * * * *",
+		"1-x * * * *",
+		"* * * foo *",
+		"* * * * sun-sat-mon",
+		"* * * * mon-sun",
+		"1,,2 * * * *",
+	} {
+		t.Run(schedule, func(t *testing.T) {
+			if _, _, err := parseCronSchedule(schedule); !errors.Is(err, ErrInvalidCronSchedule) {
+				t.Errorf("parseCronSchedule error = %v, want ErrInvalidCronSchedule", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package msb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// dedupeGroup collapses identical code executions submitted within a time window into a single
+// execution whose result is shared by every caller.
+type dedupeGroup struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*dedupeEntry
+}
+
+type dedupeEntry struct {
+	done    chan struct{} // closed once exec and err are set
+	exec    CodeExecution
+	err     error
+	expires time.Time // zero while in flight
+}
+
+func newDedupeGroup(window time.Duration) *dedupeGroup {
+	return &dedupeGroup{window: window, entries: map[string]*dedupeEntry{}}
+}
+
+// dedupeKey identifies an execution by everything that affects its result.
+func dedupeKey(lang Language, code string, rc runConfig) string {
+	h := sha256.New()
+	h.Write([]byte(lang))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(int64(rc.timeout), 10)))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(int64(rc.watchdog), 10)))
+	h.Write([]byte{0})
+	h.Write([]byte(rc.workdir))
+	h.Write([]byte{0})
+	for _, name := range slices.Sorted(maps.Keys(rc.env)) {
+		h.Write([]byte(name + "=" + rc.env[name]))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(code))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// do runs fn unless an execution with the same key is in flight or finished successfully within
+// the window, in which case it waits for and returns that execution's result instead. Failed
+// executions are forgotten as soon as they finish, so retries after an error run again.
+func (g *dedupeGroup) do(ctx context.Context, key string, fn func() (CodeExecution, error)) (CodeExecution, error) {
+	now := time.Now()
+	g.mu.Lock()
+	for k, e := range g.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(g.entries, k)
+		}
+	}
+	if e, ok := g.entries[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return CodeExecution{}, ctx.Err()
+		}
+		exec := e.exec
+		exec.deduped = true
+		return exec, e.err
+	}
+	e := &dedupeEntry{done: make(chan struct{})}
+	g.entries[key] = e
+	g.mu.Unlock()
+
+	e.exec, e.err = fn()
+
+	g.mu.Lock()
+	if e.err != nil {
+		delete(g.entries, key)
+	} else {
+		e.expires = time.Now().Add(g.window)
+	}
+	g.mu.Unlock()
+	close(e.done)
+	return e.exec, e.err
+}
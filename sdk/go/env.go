@@ -0,0 +1,151 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// EnvVar declares an environment variable of the guest.
+type EnvVar struct {
+	Name string
+	// Value is a text/template rendered with [EnvSpec.Data], e.g. "{{.Region}}", or a plain string.
+	Value string
+	// Default is used when Value renders to an empty string.
+	Default string
+	// Required makes Start fail when the variable ends up empty.
+	Required bool
+}
+
+// EnvSpec describes the guest's environment. It is rendered and validated before Start contacts
+// the server, so a misconfigured environment fails on the client with [ErrInvalidEnv] rather than
+// surfacing as a confusing failure inside the guest.
+//
+//	msb.WithEnv(msb.EnvSpec{
+//		Vars: []msb.EnvVar{
+//			{Name: "REGION", Value: "{{.Region}}", Required: true},
+//			{Name: "LOG_LEVEL", Default: "info"},
+//		},
+//		Data: deployment,
+//	})
+type EnvSpec struct {
+	Vars []EnvVar
+	// Data is passed to every Value template. Referencing a missing map key or field is an error.
+	Data any
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Render returns the environment as "NAME=value" pairs, reporting every invalid variable at once.
+func (s EnvSpec) Render() ([]string, error) {
+	var (
+		envs []string
+		errs []error
+		seen = map[string]bool{}
+	)
+	for _, v := range s.Vars {
+		if !envNamePattern.MatchString(v.Name) {
+			errs = append(errs, fmt.Errorf("%q: invalid name", v.Name))
+			continue
+		}
+		if seen[v.Name] {
+			errs = append(errs, fmt.Errorf("%s: declared more than once", v.Name))
+			continue
+		}
+		seen[v.Name] = true
+
+		value, err := renderEnvValue(v, s.Data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.Name, err))
+			continue
+		}
+		if value == "" {
+			value = v.Default
+		}
+		if value == "" && v.Required {
+			errs = append(errs, fmt.Errorf("%s: required but empty", v.Name))
+			continue
+		}
+		envs = append(envs, v.Name+"="+value)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEnv, errors.Join(errs...))
+	}
+	return envs, nil
+}
+
+func renderEnvValue(v EnvVar, data any) (string, error) {
+	if !strings.Contains(v.Value, "{{") {
+		return v.Value, nil
+	}
+	tmpl, err := template.New(v.Name).Option("missingkey=error").Parse(v.Value)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// startEnv renders the environment passed to the server at Start: the EnvSpec set with WithEnv,
+// overridden by the plain variables set with WithEnvVars.
+func (cfg *config) startEnv() ([]string, error) {
+	envs, err := cfg.env.Render()
+	if err != nil || len(cfg.envVars) == 0 {
+		return envs, err
+	}
+	if err := validateEnvNames(cfg.envVars); err != nil {
+		return nil, err
+	}
+	merged := envs[:0:0]
+	for _, kv := range envs {
+		if name, _, _ := strings.Cut(kv, "="); !hasKey(cfg.envVars, name) {
+			merged = append(merged, kv)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.envVars)) {
+		merged = append(merged, name+"="+cfg.envVars[name])
+	}
+	return merged, nil
+}
+
+// envExports returns shell statements exporting env, to prefix a guest script with.
+func envExports(env map[string]string) (string, error) {
+	if err := validateEnvNames(env); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		sb.WriteString("export " + name + "=" + shellQuote(env[name]) + "\n")
+	}
+	return sb.String(), nil
+}
+
+func validateEnvNames(env map[string]string) error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		if !envNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("%q: invalid name", name))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidEnv, errors.Join(errs...))
+	}
+	return nil
+}
+
+func hasKey(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// Environment errors
+var (
+	ErrInvalidEnv = errors.New("invalid sandbox environment")
+)
@@ -0,0 +1,228 @@
+// Package eval runs large batches of executions against a sandbox and streams a summary of each
+// one to a [ResultWriter] as soon as it finishes, so evaluation runs with millions of cases never
+// hold their results in memory.
+//
+//	f, _ := os.Create("results.csv")
+//	w := eval.NewCSVWriter(f)
+//	summary, err := eval.Run(ctx, sandbox, cases, w, eval.Options{Parallelism: 8})
+//	if flushErr := w.Flush(); err == nil {
+//		err = flushErr
+//	}
+//
+// Cases come from an iterator, so they can be read lazily from a file or generated on the fly.
+// Only CSV output ships with the package; other formats such as Parquet plug in by implementing
+// ResultWriter on top of the encoder of choice.
+package eval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"sync"
+	"time"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// Case is a single execution of an evaluation. When Command is set it runs through the sandbox's
+// CommandRunner, otherwise Code runs through its CodeRunner.
+type Case struct {
+	// ID identifies the case in its result.
+	ID      string
+	Code    string
+	Command string
+	Args    []string
+	// Options are passed to Run, e.g. msb.WithLanguage or msb.WithExecTimeout.
+	Options []msb.RunOption
+}
+
+// Status values reported in a Result.
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+	StatusTimeout = "timeout"
+)
+
+// Result summarizes a finished case.
+type Result struct {
+	ID       string
+	Status   string // StatusSuccess, StatusError or StatusTimeout
+	Duration time.Duration
+	// ExitCode is the command's exit code. For code executions it is 1 if the code raised an
+	// error and 0 otherwise.
+	ExitCode int
+	// OutputHash is the hex-encoded SHA-256 of stdout, to compare outputs across runs cheaply.
+	OutputHash string
+	// Error describes why the execution failed to run; empty when it ran, even unsuccessfully.
+	Error string
+}
+
+// ResultWriter consumes results as they are produced. Run never calls WriteResult concurrently.
+type ResultWriter interface {
+	WriteResult(Result) error
+}
+
+// Options tune an evaluation run.
+type Options struct {
+	// Parallelism bounds how many cases run at once. Defaults to 1.
+	Parallelism int
+	// StopOnWriteError aborts the run when the writer fails. By default the error is returned
+	// once all cases have run.
+	StopOnWriteError bool
+}
+
+// Summary aggregates an evaluation run.
+type Summary struct {
+	Total     int
+	Succeeded int
+	Failed    int
+	TimedOut  int
+	Duration  time.Duration
+}
+
+// Run executes every case against sandbox and writes one result per case to w, in completion
+// order. It stops early when ctx is done; the summary then covers the cases written so far.
+func Run(ctx context.Context, sandbox msb.LangSandBox, cases iter.Seq[Case], w ResultWriter, opts Options) (Summary, error) {
+	parallelism := max(opts.Parallelism, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		summary  Summary
+		writeErr error
+		wg       sync.WaitGroup
+	)
+	started := time.Now()
+	sem := make(chan struct{}, parallelism)
+	for c := range cases {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res := runCase(ctx, sandbox, c)
+			mu.Lock()
+			defer mu.Unlock()
+			summary.Total++
+			switch res.Status {
+			case StatusSuccess:
+				summary.Succeeded++
+			case StatusTimeout:
+				summary.TimedOut++
+			default:
+				summary.Failed++
+			}
+			if err := w.WriteResult(res); err != nil && writeErr == nil {
+				writeErr = fmt.Errorf("%w: case %q: %w", ErrWriteFailed, res.ID, err)
+				if opts.StopOnWriteError {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	summary.Duration = time.Since(started)
+
+	if writeErr != nil {
+		return summary, writeErr
+	}
+	return summary, ctx.Err()
+}
+
+func runCase(ctx context.Context, sandbox msb.LangSandBox, c Case) Result {
+	res := Result{ID: c.ID}
+	start := time.Now()
+	var (
+		stdout      string
+		termination msb.TerminationReason
+		err         error
+	)
+	if c.Command != "" {
+		var exec msb.CommandExecution
+		exec, err = sandbox.Command().Run(ctx, c.Command, c.Args, c.Options...)
+		res.ExitCode = exec.GetExitCode()
+		stdout, _ = exec.GetOutput()
+		termination = exec.GetTerminationReason()
+		if err == nil && !exec.IsSuccess() {
+			res.Status = StatusError
+		}
+	} else {
+		var exec msb.CodeExecution
+		exec, err = sandbox.Code().Run(ctx, c.Code, c.Options...)
+		if exec.HasError() {
+			res.ExitCode = 1
+			res.Status = StatusError
+		}
+		stdout, _ = exec.GetOutput()
+		termination = exec.GetTerminationReason()
+	}
+	res.Duration = time.Since(start)
+
+	sum := sha256.Sum256([]byte(stdout))
+	res.OutputHash = hex.EncodeToString(sum[:])
+	switch {
+	case termination.Kind == msb.TerminationTimeout:
+		res.Status = StatusTimeout
+	case err != nil:
+		res.Status = StatusError
+		res.Error = err.Error()
+	case res.Status == "":
+		res.Status = StatusSuccess
+	}
+	return res
+}
+
+// CSVWriter writes results as CSV rows under a header line. Call Flush once the run is over.
+type CSVWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSVWriter returns a CSVWriter writing to w.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// WriteResult writes res as a single row; durations are in milliseconds.
+func (cw *CSVWriter) WriteResult(res Result) error {
+	if !cw.wroteHeader {
+		if err := cw.w.Write([]string{"id", "status", "duration_ms", "exit_code", "output_sha256", "error"}); err != nil {
+			return err
+		}
+		cw.wroteHeader = true
+	}
+	return cw.w.Write([]string{
+		res.ID,
+		res.Status,
+		strconv.FormatFloat(float64(res.Duration)/float64(time.Millisecond), 'f', 3, 64),
+		strconv.Itoa(res.ExitCode),
+		res.OutputHash,
+		res.Error,
+	})
+}
+
+// Flush writes any buffered rows to the underlying writer.
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Evaluation errors
+var (
+	ErrWriteFailed = errors.New("failed to write evaluation result")
+)
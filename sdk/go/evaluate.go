@@ -0,0 +1,172 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Evaluator evaluates single expressions.
+type Evaluator interface {
+	// Eval evaluates expr in the sandbox's interpreter and returns its value, e.g. "len(rows)" or
+	// "sum(x * x for x in range(10))". Names defined by earlier executions are in scope. It is
+	// much lighter than [CodeRunner.Run] for such tiny evaluations: the expression runs in the
+	// REPL without streaming, deduplication or journaling, and only its value comes back.
+	// Values with no JSON representation come back as their repr string. An expression that
+	// raises fails with [ErrEvalFailed]; [WithLanguage] evaluates in another enabled language.
+	Eval(ctx context.Context, expr string, opts ...RunOption) (Value, error)
+}
+
+// valuePrefix marks the stdout line carrying the JSON-encoded value of an evaluation.
+const valuePrefix = "__msb_value__:"
+
+// Value is the value of an evaluated expression, held as JSON.
+type Value struct {
+	raw json.RawMessage
+}
+
+// ValueKind is the JSON type of a [Value].
+type ValueKind int
+
+// Value kinds
+const (
+	ValueNull ValueKind = iota
+	ValueBool
+	ValueNumber
+	ValueString
+	ValueArray
+	ValueObject
+)
+
+// Kind returns the JSON type of the value.
+func (v Value) Kind() ValueKind {
+	if len(v.raw) == 0 {
+		return ValueNull
+	}
+	switch v.raw[0] {
+	case 't', 'f':
+		return ValueBool
+	case '"':
+		return ValueString
+	case '[':
+		return ValueArray
+	case '{':
+		return ValueObject
+	case 'n':
+		return ValueNull
+	default:
+		return ValueNumber
+	}
+}
+
+// Raw returns the value as JSON.
+func (v Value) Raw() json.RawMessage {
+	return v.raw
+}
+
+// Decode unmarshals the value into dst, like [json.Unmarshal].
+func (v Value) Decode(dst any) error {
+	return json.Unmarshal(v.raw, dst)
+}
+
+// Bool returns the value of a boolean.
+func (v Value) Bool() (bool, error) {
+	var b bool
+	return b, v.decodeKind(ValueBool, &b)
+}
+
+// Int returns the value of an integral number.
+func (v Value) Int() (int64, error) {
+	var n int64
+	return n, v.decodeKind(ValueNumber, &n)
+}
+
+// Float returns the value of a number.
+func (v Value) Float() (float64, error) {
+	var f float64
+	return f, v.decodeKind(ValueNumber, &f)
+}
+
+// Text returns the value of a string.
+func (v Value) Text() (string, error) {
+	var s string
+	return s, v.decodeKind(ValueString, &s)
+}
+
+// String returns strings as they are and any other value as JSON.
+func (v Value) String() string {
+	if s, err := v.Text(); err == nil {
+		return s
+	}
+	if len(v.raw) == 0 {
+		return "null"
+	}
+	return string(v.raw)
+}
+
+func (v Value) decodeKind(kind ValueKind, dst any) error {
+	if v.Kind() != kind {
+		return fmt.Errorf("%w: %s", ErrValueType, v)
+	}
+	if err := json.Unmarshal(v.raw, dst); err != nil {
+		return fmt.Errorf("%w: %w", ErrValueType, err)
+	}
+	return nil
+}
+
+type evaluator struct {
+	b *baseMicroSandbox
+	l Language
+}
+
+func (ev evaluator) Eval(ctx context.Context, expr string, opts ...RunOption) (Value, error) {
+	if ev.b.state.Load() != started {
+		return Value{}, ErrSandboxNotStarted
+	}
+	rt, err := ev.b.resolveLanguage(ctx, ev.l, newRunConfig(opts).language)
+	if err != nil {
+		return Value{}, err
+	}
+	if rt.eval == "" {
+		return Value{}, fmt.Errorf("%w: %q", ErrEvalUnsupported, rt.Name)
+	}
+	// The expression travels as a string literal, so it cannot break out of the wrapper
+	literal, err := json.Marshal(expr)
+	if err != nil {
+		return Value{}, err
+	}
+	code := strings.ReplaceAll(rt.eval, "{expr}", string(literal))
+
+	release, err := ev.b.waitExecSlot(ctx)
+	if err != nil {
+		return Value{}, err
+	}
+	defer release()
+	exec, err := withExecHooks(ctx, ev.b, ExecEvent{Language: rt.Name, Code: code}, func() (CodeExecution, error) {
+		return codeRunner{ev.b, ev.l}.run(ctx, rt, code)
+	})
+	if err != nil {
+		return Value{}, err
+	}
+	for _, line := range exec.parsed.OutputLines {
+		if line.Stream == "stdout" && strings.HasPrefix(line.Text, valuePrefix) {
+			raw := json.RawMessage(strings.TrimPrefix(line.Text, valuePrefix))
+			if !json.Valid(raw) {
+				return Value{}, fmt.Errorf("%w: unparseable value %q", ErrEvalFailed, raw)
+			}
+			return Value{raw: raw}, nil
+		}
+	}
+	stderr, _ := exec.GetError()
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	return Value{}, fmt.Errorf("%w: %s", ErrEvalFailed, strings.TrimSpace(lines[len(lines)-1]))
+}
+
+// Evaluation-related errors
+var (
+	ErrEvalUnsupported = errors.New("language does not support expression evaluation")
+	ErrEvalFailed      = errors.New("expression evaluation failed")
+	ErrValueType       = errors.New("value is not of the requested type")
+)
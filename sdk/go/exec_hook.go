@@ -0,0 +1,131 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExecHook is called around every Code().Run and Command().Run; see [WithPreExec] and [WithPostExec].
+type ExecHook func(ctx context.Context, ev ExecEvent) error
+
+// ExecEvent describes the execution an [ExecHook] is wrapped around.
+type ExecEvent struct {
+	Language Language // Language of a code execution; empty for commands
+	Code     string   // Code being run; empty for commands
+	Command  string   // Command being run; empty for code executions
+	Args     []string // Command arguments
+	Tags     []string // Tags set with WithTags
+	Err      error    // Post hooks only: the error returned by the execution, if any
+}
+
+// WithPreExec registers a client-side hook called before every execution.
+// If a pre-exec hook fails, the execution is skipped and the error wraps [ErrPreExecHookFailed].
+// Hooks run in the order they are registered.
+func WithPreExec(hook ExecHook) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.preExec = append(msb.cfg.preExec, hook)
+	}
+}
+
+// WithPostExec registers a client-side hook called after every execution, including failed ones.
+// Hook failures are reported alongside the execution result, wrapping [ErrPostExecHookFailed].
+func WithPostExec(hook ExecHook) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.postExec = append(msb.cfg.postExec, hook)
+	}
+}
+
+// WithPreExecScript registers a shell script run inside the sandbox before every execution,
+// e.g. to prepare a working directory. The script runs in its own shell, so exported variables
+// do not carry over; a non-zero exit fails the execution like [WithPreExec].
+func WithPreExecScript(script string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.preExec = append(msb.cfg.preExec, scriptHook(msb, script))
+	}
+}
+
+// WithPostExecScript registers a shell script run inside the sandbox after every execution,
+// e.g. to collect artifacts. A non-zero exit is reported like [WithPostExec].
+func WithPostExecScript(script string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.postExec = append(msb.cfg.postExec, scriptHook(msb, script))
+	}
+}
+
+func scriptHook(b *baseMicroSandbox, script string) ExecHook {
+	return func(ctx context.Context, _ ExecEvent) error {
+		exec, err := b.runShell(ctx, script)
+		if err != nil {
+			return err
+		}
+		if !exec.IsSuccess() {
+			stderr, _ := exec.GetError()
+			return fmt.Errorf("script exited with code %d: %s", exec.GetExitCode(), strings.TrimSpace(stderr))
+		}
+		return nil
+	}
+}
+
+// withExecHooks runs the configured pre-exec hooks, then run, then the post-exec hooks.
+func withExecHooks[E parsedExecution](ctx context.Context, b *baseMicroSandbox, ev ExecEvent, run func() (E, error)) (E, error) {
+	cfg := b.config()
+	for _, hook := range cfg.preExec {
+		if err := hook(ctx, ev); err != nil {
+			var zero E
+			return zero, fmt.Errorf("%w: %w", ErrPreExecHookFailed, err)
+		}
+	}
+
+	var started LifecycleEvent
+	if len(cfg.publishers) > 0 {
+		id, _ := newGuestJobID()
+		started = LifecycleEvent{
+			Type:        EventExecutionStarted,
+			ExecutionID: id,
+			Language:    ev.Language,
+			Code:        ev.Code,
+			Command:     ev.Command,
+			Args:        ev.Args,
+			Tags:        ev.Tags,
+		}
+		b.publish(ctx, started)
+	}
+	begin := time.Now()
+
+	exec, err := run()
+
+	ev.Err = err
+	var hookErrs []error
+	for _, hook := range cfg.postExec {
+		if herr := hook(ctx, ev); herr != nil {
+			hookErrs = append(hookErrs, herr)
+		}
+	}
+	if len(hookErrs) > 0 {
+		err = errors.Join(err, fmt.Errorf("%w: %w", ErrPostExecHookFailed, errors.Join(hookErrs...)))
+	}
+	if len(cfg.publishers) > 0 {
+		b.publish(ctx, finishedEvent(started, exec, err, time.Since(begin)))
+	}
+	if len(cfg.onExecution) > 0 {
+		b.notifyExecution(ctx, ExecutionEvent{
+			Language: ev.Language,
+			Code:     ev.Code,
+			Command:  ev.Command,
+			Args:     ev.Args,
+			Started:  begin,
+			Duration: time.Since(begin),
+			Err:      err,
+		})
+	}
+	return exec, err
+}
+
+// Execution hook errors
+var (
+	ErrPreExecHookFailed  = errors.New("pre-exec hook failed")
+	ErrPostExecHookFailed = errors.New("post-exec hook failed")
+)
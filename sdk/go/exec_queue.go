@@ -0,0 +1,119 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// QueueReporter reports on executions waiting for a slot under [WithMaxConcurrentExecutions].
+type QueueReporter interface {
+	// QueueStats returns how many executions are running and waiting, and the limits.
+	QueueStats() QueueStats
+}
+
+// QueueStats is a snapshot of a sandbox's execution queue.
+type QueueStats struct {
+	Running       int // Executions holding a slot
+	Queued        int // Executions waiting for a slot
+	MaxConcurrent int // Slots; 0 when unlimited
+	MaxQueued     int // Executions allowed to wait; 0 when unbounded
+}
+
+// WithMaxConcurrentExecutions limits the executions running at once on this sandbox, across
+// Code().Run, Command().Run and the other ways of running code or commands, to n. Calls beyond
+// the limit wait in line for a slot instead of all hitting the server at once, which would
+// otherwise answer some of them with 429 Too Many Requests; see [WithMaxQueuedExecutions] to
+// bound the line. n <= 0 means unlimited.
+func WithMaxConcurrentExecutions(n int) Option {
+	return func(msb *baseMicroSandbox) {
+		queued := 0
+		if msb.execQueue != nil {
+			queued = msb.execQueue.maxQueued
+		}
+		msb.execQueue = newExecQueue(n, queued)
+	}
+}
+
+// WithMaxQueuedExecutions bounds the executions waiting for a slot under
+// [WithMaxConcurrentExecutions] to n; further calls fail at once with [ErrQueueFull], so that
+// callers can shed load instead of piling up. n <= 0 means unbounded.
+func WithMaxQueuedExecutions(n int) Option {
+	return func(msb *baseMicroSandbox) {
+		limit := 0
+		if msb.execQueue != nil {
+			limit = cap(msb.execQueue.slots)
+		}
+		msb.execQueue = newExecQueue(limit, n)
+	}
+}
+
+// execQueue is a semaphore with a possibly bounded line of waiters.
+type execQueue struct {
+	slots     chan struct{} // nil when unlimited
+	maxQueued int
+	queued    atomic.Int64
+}
+
+func newExecQueue(limit, maxQueued int) *execQueue {
+	q := &execQueue{maxQueued: max(maxQueued, 0)}
+	if limit > 0 {
+		q.slots = make(chan struct{}, limit)
+	}
+	return q
+}
+
+// acquire takes a slot, waiting in line if none is free, and returns the function releasing it.
+// The line's length is recorded in metrics, if not nil.
+func (q *execQueue) acquire(ctx context.Context, metrics MetricsRecorder) (func(), error) {
+	if q.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case q.slots <- struct{}{}:
+		return q.release, nil
+	default:
+	}
+	if n := q.queued.Add(1); q.maxQueued > 0 && n > int64(q.maxQueued) {
+		q.queued.Add(-1)
+		return nil, ErrQueueFull
+	}
+	if metrics != nil {
+		metrics.AddGauge(MetricQueuedExecutions, nil, 1)
+		defer metrics.AddGauge(MetricQueuedExecutions, nil, -1)
+	}
+	defer q.queued.Add(-1)
+	select {
+	case q.slots <- struct{}{}:
+		return q.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *execQueue) release() {
+	<-q.slots
+}
+
+func (q *execQueue) stats() QueueStats {
+	if q == nil || q.slots == nil {
+		return QueueStats{}
+	}
+	return QueueStats{
+		Running:       len(q.slots),
+		Queued:        int(q.queued.Load()),
+		MaxConcurrent: cap(q.slots),
+		MaxQueued:     q.maxQueued,
+	}
+}
+
+type queueReporter struct {
+	b *baseMicroSandbox
+}
+
+func (qr queueReporter) QueueStats() QueueStats {
+	return qr.b.execQueue.stats()
+}
+
+// Execution queue-related errors
+var ErrQueueFull = errors.New("execution queue full")
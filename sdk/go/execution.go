@@ -0,0 +1,233 @@
+package msb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Execution is implemented by both [CodeExecution] and [CommandExecution], so results can be
+// processed, stored and re-hydrated by the same code whatever produced them. Statuses and exit
+// codes are mapped across: a command reports "success" or "error" as its status, and code
+// reports 0 or 1 as its exit code.
+type Execution interface {
+	// GetOutput returns the standard output, or ErrExecutionNotParsed.
+	GetOutput() (string, error)
+	// GetError returns the standard error, or ErrExecutionNotParsed.
+	GetError() (string, error)
+	// GetOutputOrEmpty returns the standard output, or "" if unparsed.
+	GetOutputOrEmpty() string
+	// GetErrorOrEmpty returns the standard error, or "" if unparsed.
+	GetErrorOrEmpty() string
+	// GetOutputLines returns the output lines in order, or ErrExecutionNotParsed.
+	GetOutputLines() ([]OutputLine, error)
+	// GetStatus returns "success", "error", "exception" for code, or "unknown" if unparsed.
+	GetStatus() string
+	// GetExitCode returns the exit code, or -1 if there is none.
+	GetExitCode() int
+	// HasError reports whether the execution failed.
+	HasError() bool
+	// IsSuccess reports whether the execution succeeded; false if unparsed.
+	IsSuccess() bool
+	// GetTerminationReason reports why the execution was terminated, if it was.
+	GetTerminationReason() TerminationReason
+	// Truncation reports how the output was cut to a cap, if it was.
+	Truncation() OutputTruncation
+	// GetResourceUsage returns what the execution consumed, if it was measured.
+	GetResourceUsage() (ResourceUsage, bool)
+	// RawOutput returns the raw JSON response from the server, the result's Output field.
+	RawOutput() json.RawMessage
+	// ToJSON encodes the result in the canonical JSON encoding; see [ResultJSONVersion].
+	ToJSON() ([]byte, error)
+}
+
+var (
+	_ Execution = CodeExecution{}
+	_ Execution = CommandExecution{}
+)
+
+// NewCodeExecution builds a code execution from the raw JSON response of the server, as kept in
+// the Output field of an earlier result. Like a result returned by Run, it reports
+// ErrExecutionNotParsed from its getters if output cannot be parsed.
+func NewCodeExecution(output json.RawMessage) CodeExecution {
+	exec := CodeExecution{Output: output}
+	exec.parsedOK = json.Unmarshal(output, &exec.parsed) == nil
+	return exec
+}
+
+// NewCommandExecution is the command counterpart of [NewCodeExecution].
+func NewCommandExecution(output json.RawMessage) CommandExecution {
+	exec := CommandExecution{Output: output}
+	if err := json.Unmarshal(output, &exec.parsed); err == nil {
+		exec.parsedOK = true
+		exec.termination = terminationFromExitCode(exec.parsed.ExitCode)
+	}
+	return exec
+}
+
+// ParseExecution decodes a result encoded by ToJSON, dispatching on its kind.
+func ParseExecution(data []byte) (Execution, error) {
+	var header resultHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResultJSON, err)
+	}
+	switch header.Kind {
+	case resultKindCodeExecution:
+		var exec CodeExecution
+		if err := exec.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		return exec, nil
+	case resultKindCommandExecution:
+		var exec CommandExecution
+		if err := exec.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		return exec, nil
+	default:
+		return nil, fmt.Errorf("%w: kind %q is not an execution", ErrInvalidResultJSON, header.Kind)
+	}
+}
+
+// RawOutput returns the raw JSON response from the server.
+func (ce CodeExecution) RawOutput() json.RawMessage {
+	return ce.Output
+}
+
+// RawOutput returns the raw JSON response from the server.
+func (ce CommandExecution) RawOutput() json.RawMessage {
+	return ce.Output
+}
+
+// MarshalJSON encodes the execution with ToJSON, so results embedded in other values are stored
+// in the canonical encoding.
+func (ce CodeExecution) MarshalJSON() ([]byte, error) {
+	return ce.ToJSON()
+}
+
+// MarshalJSON encodes the execution with ToJSON, so results embedded in other values are stored
+// in the canonical encoding.
+func (ce CommandExecution) MarshalJSON() ([]byte, error) {
+	return ce.ToJSON()
+}
+
+// UnmarshalJSON decodes a result encoded by ToJSON or, lacking a kind, a raw server response as
+// [NewCodeExecution] does. What the encoding does not carry, such as UploadedAsFile or
+// Truncation, is not restored; an unparsed result keeps the document as its Output.
+func (ce *CodeExecution) UnmarshalJSON(data []byte) error {
+	var doc struct {
+		resultHeader
+		Language    string             `json:"language"`
+		Status      string             `json:"status"`
+		Output      []OutputLine       `json:"output"`
+		Termination *terminationJSON   `json:"termination"`
+		Spec        *SandboxSpec       `json:"spec"`
+		Usage       *resourceUsageJSON `json:"resource_usage"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResultJSON, err)
+	}
+	if doc.Kind == "" {
+		*ce = NewCodeExecution(append(json.RawMessage(nil), data...))
+		return nil
+	}
+	if err := doc.check(resultKindCodeExecution); err != nil {
+		return err
+	}
+	termination, err := doc.Termination.reason()
+	if err != nil {
+		return err
+	}
+	*ce = CodeExecution{termination: termination, spec: doc.Spec, usage: doc.Usage.usage()}
+	if doc.Status == "unknown" {
+		ce.Output = append(json.RawMessage(nil), data...)
+		return nil
+	}
+	ce.parsed = executionData{OutputLines: doc.Output, Status: doc.Status, Language: doc.Language}
+	ce.parsedOK = true
+	ce.Output, _ = json.Marshal(ce.parsed)
+	return nil
+}
+
+// UnmarshalJSON decodes a result encoded by ToJSON or, lacking a kind, a raw server response as
+// [NewCommandExecution] does. What the encoding does not carry, such as Truncation, is not
+// restored; an unparsed result keeps the document as its Output.
+func (ce *CommandExecution) UnmarshalJSON(data []byte) error {
+	var doc struct {
+		resultHeader
+		Command     string             `json:"command"`
+		Args        []string           `json:"args"`
+		ExitCode    int                `json:"exit_code"`
+		ExitStatus  string             `json:"exit_status"`
+		Success     bool               `json:"success"`
+		DurationMS  int64              `json:"duration_ms"`
+		Output      []OutputLine       `json:"output"`
+		Termination *terminationJSON   `json:"termination"`
+		Spec        *SandboxSpec       `json:"spec"`
+		Usage       *resourceUsageJSON `json:"resource_usage"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResultJSON, err)
+	}
+	if doc.Kind == "" {
+		*ce = NewCommandExecution(append(json.RawMessage(nil), data...))
+		return nil
+	}
+	if err := doc.check(resultKindCommandExecution); err != nil {
+		return err
+	}
+	termination, err := doc.Termination.reason()
+	if err != nil {
+		return err
+	}
+	*ce = CommandExecution{
+		termination: termination,
+		spec:        doc.Spec,
+		duration:    time.Duration(doc.DurationMS) * time.Millisecond,
+		usage:       doc.Usage.usage(),
+	}
+	switch doc.ExitStatus {
+	case ExitNotRun.String():
+		return nil
+	case ExitUnparsed.String():
+		ce.Output = append(json.RawMessage(nil), data...)
+		return nil
+	}
+	ce.parsed = commandData{
+		OutputLines: doc.Output,
+		Command:     doc.Command,
+		Args:        doc.Args,
+		ExitCode:    doc.ExitCode,
+		Success:     doc.Success,
+	}
+	ce.parsedOK = true
+	ce.Output, _ = json.Marshal(ce.parsed)
+	return nil
+}
+
+// check verifies that a document is of the given kind and of a version this SDK reads.
+func (h resultHeader) check(kind string) error {
+	if h.Kind != kind {
+		return fmt.Errorf("%w: kind %q, want %q", ErrInvalidResultJSON, h.Kind, kind)
+	}
+	if h.Version < 1 || h.Version > ResultJSONVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidResultJSON, h.Version)
+	}
+	return nil
+}
+
+// reason is the inverse of newTerminationJSON.
+func (t *terminationJSON) reason() (TerminationReason, error) {
+	if t == nil {
+		return TerminationReason{}, nil
+	}
+	kind, ok := parseTerminationKind(t.Kind)
+	if !ok {
+		return TerminationReason{}, fmt.Errorf("%w: unknown termination kind %q", ErrInvalidResultJSON, t.Kind)
+	}
+	return TerminationReason{Kind: kind, Signal: t.Signal, SignalName: t.SignalName, Detail: t.Detail}, nil
+}
+
+// Execution-related errors
+var ErrInvalidResultJSON = errors.New("invalid result JSON")
@@ -0,0 +1,67 @@
+package msb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithExitError makes Command().Run return a [*ExitError] along with the result when the command
+// ran but did not succeed, as os/exec's Run does, so that a single err check covers both failures
+// to run and non-zero exits:
+//
+//	exec, err := sandbox.Command().Run(ctx, "make", []string{"test"}, msb.WithExitError())
+//	var exitErr *msb.ExitError
+//	if errors.As(err, &exitErr) {
+//		log.Printf("tests failed with code %d:\n%s", exitErr.ExitCode, exitErr.Stderr)
+//	}
+func WithExitError() RunOption {
+	return func(rc *runConfig) {
+		rc.exitError = true
+	}
+}
+
+// ExitError reports a command that ran to its end, or until it was killed, without succeeding,
+// with every secret registered through [WithSecrets] masked. It matches [ErrCommandFailed].
+type ExitError struct {
+	Command     string            // The command line, with secrets masked
+	ExitCode    int               // The exit code; -1 if the command was killed by the client, e.g. by WithExecTimeout
+	Stderr      string            // The captured standard error, with secrets masked
+	Termination TerminationReason // Why the command was terminated, if it was
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("command %s", e.Command)
+	switch {
+	case e.Termination.Kind == TerminationSignal && e.Termination.SignalName != "":
+		msg += " was killed by " + e.Termination.SignalName
+	case e.ExitCode >= 0:
+		msg += fmt.Sprintf(" exited with code %d", e.ExitCode)
+	case e.Termination.Detail != "":
+		msg += " was terminated: " + e.Termination.Detail
+	default:
+		msg += " was terminated"
+	}
+	lines := strings.Split(strings.TrimSpace(e.Stderr), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		msg += ": " + last
+	}
+	return msg
+}
+
+func (e *ExitError) Unwrap() error {
+	return ErrCommandFailed
+}
+
+// exitError returns nil if the command succeeded, and otherwise the [*ExitError] reporting it.
+func (ce CommandExecution) exitError() error {
+	if ce.IsSuccess() {
+		return nil
+	}
+	stderr, _ := ce.GetError()
+	return &ExitError{
+		Command:     ce.redactor.redact(commandScript(ce.GetCommand(), ce.GetArgs())),
+		ExitCode:    ce.GetExitCode(),
+		Stderr:      ce.redactor.redact(stderr),
+		Termination: ce.GetTerminationReason(),
+	}
+}
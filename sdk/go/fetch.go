@@ -0,0 +1,114 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Fetcher downloads files from inside the sandbox.
+type Fetcher interface {
+	// Fetch downloads url to dest inside the sandbox using the guest's curl or wget, so the
+	// download goes through the sandbox's own network and egress restrictions rather than the
+	// client's. The file only appears at dest once size and checksum checks have passed.
+	Fetch(ctx context.Context, url string, dest string, opts ...FetchOption) (FetchResult, error)
+}
+
+// FetchOption configures a single Fetch.
+type FetchOption func(*fetchConfig)
+
+type fetchConfig struct {
+	maxBytes int64
+	sha256   string
+}
+
+// WithFetchMaxBytes fails the download with [ErrFetchTooLarge] if it exceeds n bytes.
+func WithFetchMaxBytes(n int64) FetchOption {
+	return func(fc *fetchConfig) {
+		fc.maxBytes = n
+	}
+}
+
+// WithFetchSHA256 fails the download with [ErrChecksumMismatch] unless its SHA-256 digest
+// matches the given hex string.
+func WithFetchSHA256(hexDigest string) FetchOption {
+	return func(fc *fetchConfig) {
+		fc.sha256 = strings.ToLower(strings.TrimSpace(hexDigest))
+	}
+}
+
+// FetchResult describes a completed download.
+type FetchResult struct {
+	Path   string
+	Bytes  int64
+	SHA256 string
+}
+
+type fetcher struct {
+	b *baseMicroSandbox
+}
+
+// Exit codes of fetchScript that map to dedicated errors
+const (
+	fetchExitTooLarge = 12
+	fetchExitChecksum = 13
+)
+
+// fetchScript downloads $1 into a temporary file next to $2, verifies it against the size
+// limit $3 (0 = none) and digest $4 (empty = none), then moves it into place.
+const fetchScript = `url=$1 dest=$2 max=$3 want=$4 tmp="$2.msb-part"
+mkdir -p "$(dirname "$dest")" || exit 1
+if command -v curl >/dev/null 2>&1; then
+  lim=; [ "$max" -gt 0 ] && lim="--max-filesize $max"
+  curl -fsSL $lim -o "$tmp" "$url" || { s=$?; rm -f "$tmp"; [ $s -eq 63 ] && exit 12; exit 10; }
+elif command -v wget >/dev/null 2>&1; then
+  wget -q -O "$tmp" "$url" || { rm -f "$tmp"; exit 10; }
+else
+  echo "neither curl nor wget is installed" >&2; exit 11
+fi
+size=$(wc -c <"$tmp" | tr -d ' ')
+if [ "$max" -gt 0 ] && [ "$size" -gt "$max" ]; then rm -f "$tmp"; exit 12; fi
+sum=$(sha256sum "$tmp" | cut -d' ' -f1)
+if [ -n "$want" ] && [ "$sum" != "$want" ]; then rm -f "$tmp"; echo "got $sum" >&2; exit 13; fi
+mv "$tmp" "$dest" && echo "$size $sum"`
+
+func (f fetcher) Fetch(ctx context.Context, url string, dest string, opts ...FetchOption) (FetchResult, error) {
+	var fc fetchConfig
+	for _, opt := range opts {
+		opt(&fc)
+	}
+	script := `set -- ` + shellQuote(url) + ` ` + shellQuote(dest) + ` ` + strconv.FormatInt(max(fc.maxBytes, 0), 10) + ` ` + shellQuote(fc.sha256) + `
+` + fetchScript
+	exec, err := f.b.runShell(ctx, script)
+	if err != nil {
+		return FetchResult{}, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	stderr, _ := exec.GetError()
+	stderr = strings.TrimSpace(stderr)
+	switch exec.GetExitCode() {
+	case 0:
+	case fetchExitTooLarge:
+		return FetchResult{}, fmt.Errorf("%w: limit is %d bytes", ErrFetchTooLarge, fc.maxBytes)
+	case fetchExitChecksum:
+		return FetchResult{}, fmt.Errorf("%w: want %s, %s", ErrChecksumMismatch, fc.sha256, stderr)
+	default:
+		return FetchResult{}, fmt.Errorf("%w: exit code %d: %s", ErrFetchFailed, exec.GetExitCode(), stderr)
+	}
+
+	out, _ := exec.GetOutput()
+	sizeField, sum, _ := strings.Cut(strings.TrimSpace(out), " ")
+	size, err := strconv.ParseInt(sizeField, 10, 64)
+	if err != nil {
+		return FetchResult{}, fmt.Errorf("%w: unexpected output %q", ErrFetchFailed, out)
+	}
+	return FetchResult{Path: dest, Bytes: size, SHA256: sum}, nil
+}
+
+// Fetch-related errors
+var (
+	ErrFetchFailed      = errors.New("failed to fetch")
+	ErrFetchTooLarge    = errors.New("download exceeds size limit")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
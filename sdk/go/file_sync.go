@@ -0,0 +1,152 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sharedWrites records the files written through the shared directory whose arrival in the
+// guest is not confirmed yet. The client sees its own writes at once, but the guest's view of
+// the mount may lag behind; see [FileSystem.Sync].
+type sharedWrites struct {
+	mu      sync.Mutex
+	sizes   map[string]int64 // size written, by guest path
+	missing []string         // guest paths found missing by a barrier, until Sync reports them
+}
+
+func (sw *sharedWrites) add(name string, size int64) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.sizes == nil {
+		sw.sizes = map[string]int64{}
+	}
+	sw.sizes[name] = size
+}
+
+// take returns the unconfirmed writes and forgets them.
+func (sw *sharedWrites) take() map[string]int64 {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sizes := sw.sizes
+	sw.sizes = nil
+	return sizes
+}
+
+// fail records files that did not arrive in time, for the next Sync to report.
+func (sw *sharedWrites) fail(names []string) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.missing = slices.Compact(slices.Sorted(slices.Values(append(sw.missing, names...))))
+}
+
+// takeMissing returns the error reporting the files that did not arrive in time, if any, and
+// forgets them.
+func (sw *sharedWrites) takeMissing() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if len(sw.missing) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%w: %s", errNotVisible, strings.Join(sw.missing, ", "))
+	sw.missing = nil
+	return err
+}
+
+// sharedWriteScript waits up to 5 seconds until the files given as "path size" argument pairs
+// have their size in the guest, and prints those that do not in time.
+const sharedWriteScript = `arrived() {
+	missing=
+	while [ $# -gt 0 ]; do
+		[ "$(wc -c <"$1" 2>/dev/null)" -eq "$2" ] 2>/dev/null || missing="$missing$1
+"
+		shift 2
+	done
+	[ -z "$missing" ]
+}
+i=0
+until arrived "$@"; do
+	i=$((i + 1))
+	[ $i -lt 250 ] || { printf '%s' "$missing"; exit 1; }
+	sleep 0.02
+done`
+
+// confirmSharedWrites waits until the files written through the shared directory have arrived in
+// the guest, so that executions see them. Files that do not arrive in time are reported by the
+// returned error and recorded for the next Sync, rather than delaying every later execution.
+func (b *baseMicroSandbox) confirmSharedWrites(ctx context.Context) error {
+	sizes := b.sharedWrites.take()
+	if len(sizes) == 0 {
+		return nil
+	}
+	script := `set --`
+	for _, name := range slices.Sorted(maps.Keys(sizes)) {
+		script += ` ` + shellQuote(name) + ` ` + strconv.FormatInt(sizes[name], 10)
+	}
+	exec, err := b.runShell(ctx, script+"\n"+sharedWriteScript)
+	if err != nil {
+		// Look for them again on the next barrier
+		for name, size := range sizes {
+			b.sharedWrites.add(name, size)
+		}
+		return err
+	}
+	if !exec.IsSuccess() {
+		out, _ := exec.GetOutput()
+		missing := strings.Fields(out)
+		b.sharedWrites.fail(missing)
+		return fmt.Errorf("%w: %s", errNotVisible, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (f fileSystem) Sync(ctx context.Context) error {
+	if f.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	done := make(chan struct{})
+	go func() {
+		f.b.fileWrites.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrFailedToSync, ctx.Err())
+	}
+	err := f.b.confirmSharedWrites(ctx)
+	// Report the files found missing since the last Sync, by executions too
+	if missing := f.b.sharedWrites.takeMissing(); missing != nil {
+		if err == nil || errors.Is(err, errNotVisible) {
+			err = missing
+		} else {
+			err = errors.Join(missing, err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToSync, err)
+	}
+	if f.b.sharedDirState.Load() != sharedDirAvailable {
+		return nil
+	}
+	// Flush what the guest wrote to the mount, for ReadFile to read it locally
+	exec, err := f.b.runShell(ctx, `sync`)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToSync, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToSync, commandStderr(exec))
+	}
+	return nil
+}
+
+// File sync-related errors
+var ErrFailedToSync = errors.New("failed to sync files with the sandbox")
+
+// errNotVisible reports shared directory writes that did not arrive in the guest in time.
+var errNotVisible = errors.New("not visible in the sandbox")
@@ -0,0 +1,108 @@
+package msb_test
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+	"github.com/keithang/microsandbox/sdk/go/msbtest"
+)
+
+// newSharedDirSandbox returns a started fake sandbox sharing a temporary directory as /exchange,
+// whose shell scripts run locally against that directory unless lagging reports true for them.
+func newSharedDirSandbox(t *testing.T, lagging func(script string) bool) *msbtest.FakeSandbox {
+	t.Helper()
+	dir := t.TempDir()
+	sandbox := msbtest.NewFakeSandbox(msb.LanguagePython, msb.WithSharedDir(dir, "/exchange"))
+	sandbox.HandleCommandFunc(func(command string, args []string) (msbtest.Result, bool) {
+		if command != "sh" || len(args) != 2 {
+			return msbtest.Result{}, false
+		}
+		if lagging(args[1]) {
+			return msbtest.Result{Stdout: "/exchange/data.txt\n", ExitCode: 1}, true
+		}
+		out, err := exec.Command("sh", "-c", strings.ReplaceAll(args[1], "/exchange", dir)).Output()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return msbtest.Result{Stdout: string(out), Stderr: string(exitErr.Stderr), ExitCode: exitErr.ExitCode()}, true
+		}
+		return msbtest.Result{Stdout: string(out)}, true
+	})
+	if err := sandbox.Start(context.Background(), "img", 0, 0); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return sandbox
+}
+
+func TestSharedWritesConfirmedBeforeExecution(t *testing.T) {
+	ctx := context.Background()
+	sandbox := newSharedDirSandbox(t, func(string) bool { return false })
+	sandbox.HandleCommand("cat", msbtest.Result{Stdout: "hello"})
+
+	if err := sandbox.Files().WriteFile(ctx, "/exchange/data.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := sandbox.Command().Run(ctx, "cat", []string{"/exchange/data.txt"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	commands := sandbox.SubmittedCommands()
+	if len(commands) < 2 || commands[len(commands)-1].Name != "cat" {
+		t.Fatalf("commands = %v, want the confirmation before cat", commands)
+	}
+	confirm := commands[len(commands)-2]
+	if confirm.Name != "sh" || !strings.Contains(confirm.Args[1], "'/exchange/data.txt' 5") {
+		t.Errorf("command before cat = %v, want the confirmation of /exchange/data.txt", confirm)
+	}
+	if err := sandbox.Files().Sync(ctx); err != nil {
+		t.Errorf("Sync: %v", err)
+	}
+}
+
+func TestSyncReportsLaggingSharedWrites(t *testing.T) {
+	ctx := context.Background()
+	sandbox := newSharedDirSandbox(t, func(script string) bool { return strings.Contains(script, "arrived") })
+
+	if err := sandbox.Files().WriteFile(ctx, "/exchange/data.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	err := sandbox.Files().Sync(ctx)
+	if !errors.Is(err, msb.ErrFailedToSync) || !strings.Contains(err.Error(), "/exchange/data.txt") {
+		t.Fatalf("Sync error = %v, want ErrFailedToSync naming /exchange/data.txt", err)
+	}
+	// A file that did not arrive is reported once
+	if err := sandbox.Files().Sync(ctx); err != nil {
+		t.Errorf("second Sync: %v", err)
+	}
+}
+
+func TestSyncReportsWritesMissedByExecution(t *testing.T) {
+	ctx := context.Background()
+	sandbox := newSharedDirSandbox(t, func(script string) bool { return strings.Contains(script, "arrived") })
+	sandbox.HandleCommand("cat", msbtest.Result{ExitCode: 1})
+
+	if err := sandbox.Files().WriteFile(ctx, "/exchange/data.txt", []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	// The barrier before the execution finds the file missing, which only logs
+	if _, err := sandbox.Command().Run(ctx, "cat", []string{"/exchange/data.txt"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	err := sandbox.Files().Sync(ctx)
+	if !errors.Is(err, msb.ErrFailedToSync) || !strings.Contains(err.Error(), "/exchange/data.txt") {
+		t.Fatalf("Sync error = %v, want ErrFailedToSync naming /exchange/data.txt", err)
+	}
+	if err := sandbox.Files().Sync(ctx); err != nil {
+		t.Errorf("second Sync: %v", err)
+	}
+}
+
+func TestSyncRequiresStartedSandbox(t *testing.T) {
+	sandbox := msbtest.NewFakeSandbox(msb.LanguagePython)
+	if err := sandbox.Files().Sync(context.Background()); !errors.Is(err, msb.ErrSandboxNotStarted) {
+		t.Errorf("Sync error = %v, want ErrSandboxNotStarted", err)
+	}
+}
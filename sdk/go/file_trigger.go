@@ -0,0 +1,213 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileWatcher installs commands inside the sandbox that run when files change.
+type FileWatcher interface {
+	// OnFileChange runs the shell command inside the sandbox whenever files matching pattern are
+	// created, modified or deleted, e.g. to rerun a build or tests, until the returned
+	// FileTrigger is stopped or the sandbox goes away. pattern is a glob in which '*' also
+	// matches '/', as with `find -path`, e.g. "/src/*.py"; a pattern without wildcards watches
+	// every file under that path. Changes are detected within a second, using inotifywait when
+	// the image provides it. Runs never overlap: changes during a run trigger the next one. The
+	// trigger runs inside the guest, so the client need not stay connected; see
+	// [FileTrigger.Follow] to receive the runs as they finish.
+	OnFileChange(ctx context.Context, pattern, command string) (*FileTrigger, error)
+}
+
+// FileTrigger is a handle to a command installed with OnFileChange.
+type FileTrigger struct {
+	proc    *guestProcess
+	pattern string
+	command string
+}
+
+// FileTriggerRun is one run of a [FileTrigger].
+type FileTriggerRun struct {
+	Started time.Time
+	Running bool
+	// Changed lists the files whose changes triggered the run.
+	Changed []string
+	// Execution holds the run's output and exit code; while the run is in progress, it holds
+	// the output so far and an exit code of -1.
+	Execution CommandExecution
+
+	name string
+}
+
+// fileTriggerHistoryLimit is how many finished runs the guest keeps for History.
+const fileTriggerHistoryLimit = 100
+
+// fileTriggerPollInterval is how often Follow checks for finished runs.
+const fileTriggerPollInterval = time.Second
+
+// fileTriggerScript is the trigger. Every second, or as soon as inotifywait reports an event, it
+// lists the matching files and those modified since the previous check, and runs the command if
+// either changed. The timestamp of a check is taken before listing, so changes made while
+// listing or running are caught by the next check. Each run records its output and the changed
+// files under runs/<sequence number>, and the oldest finished runs are pruned.
+const fileTriggerScript = `snap() { find "$MSB_TRIG_ROOT" -path "$MSB_TRIG_DIR" -prune -o -type f -path "$MSB_TRIG_PAT" -print 2>/dev/null | sort; }
+mkdir -p "$MSB_TRIG_DIR/runs"
+w=; command -v inotifywait >/dev/null 2>&1 && w=1
+touch "$MSB_TRIG_DIR/stamp"; prev=$(snap); n=0
+while :; do
+  if [ -n "$w" ]; then
+    inotifywait -qq -r -t 1 -e close_write,create,delete,move "$MSB_TRIG_ROOT" >/dev/null 2>&1
+    [ $? -eq 1 ] && sleep 1
+  else
+    sleep 1
+  fi
+  touch "$MSB_TRIG_DIR/next"
+  cur=$(snap)
+  new=$(find "$MSB_TRIG_ROOT" -path "$MSB_TRIG_DIR" -prune -o -type f -path "$MSB_TRIG_PAT" -newer "$MSB_TRIG_DIR/stamp" -print 2>/dev/null)
+  mv "$MSB_TRIG_DIR/next" "$MSB_TRIG_DIR/stamp"
+  [ -z "$new" ] && [ "$cur" = "$prev" ] && continue
+  n=$((n + 1)); r="$MSB_TRIG_DIR/runs/$(printf %010d $n)"; mkdir -p "$r"; date +%s >"$r/start"
+  { printf '%s\n%s\n' "$prev" "$cur" | sort | uniq -u; printf '%s\n' "$new"; } | sed '/^$/d' | sort -u >"$r/files"
+  prev=$cur
+  (eval "$MSB_TRIG_CMD") >"$r/out" 2>"$r/err" </dev/null; echo $? >"$r/exit.tmp"; mv "$r/exit.tmp" "$r/exit"
+  c=$(ls "$MSB_TRIG_DIR/runs" | wc -l)
+  [ "$c" -gt ` + "{limit}" + ` ] && ls "$MSB_TRIG_DIR/runs" | head -n $((c - ` + "{limit}" + `)) | while read -r x; do
+    rm -rf "$MSB_TRIG_DIR/runs/$x"
+  done
+done`
+
+type fileWatcher struct {
+	b *baseMicroSandbox
+}
+
+func (fw fileWatcher) OnFileChange(ctx context.Context, pattern, command string) (*FileTrigger, error) {
+	if fw.b.state.Load() != started {
+		return nil, ErrSandboxNotStarted
+	}
+	if command == "" {
+		return nil, fmt.Errorf("%w: empty command", ErrFailedToInstallFileTrigger)
+	}
+	root, glob, err := splitWatchPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToInstallFileTrigger, err)
+	}
+	vars := []string{
+		"MSB_TRIG_DIR=" + shellQuote(guestJobsDir+"/"+id),
+		"MSB_TRIG_CMD=" + shellQuote(command),
+		"MSB_TRIG_ROOT=" + shellQuote(root),
+		"MSB_TRIG_PAT=" + shellQuote(glob),
+	}
+	script := strings.Join(vars, "; ") + "\n" + strings.ReplaceAll(fileTriggerScript, "{limit}", strconv.Itoa(fileTriggerHistoryLimit))
+	proc, err := fw.b.spawnWithID(ctx, id, script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToInstallFileTrigger, err)
+	}
+	fw.b.logger().Info("File trigger installed", "sandbox", fw.b.name(), "pattern", pattern, "command", command, "id", id)
+	return &FileTrigger{proc: proc, pattern: pattern, command: command}, nil
+}
+
+// splitWatchPattern returns the directory to search for files matching pattern, along with the
+// glob that `find -path` matches them with.
+func splitWatchPattern(pattern string) (root, glob string, err error) {
+	if strings.TrimSpace(pattern) == "" {
+		return "", "", fmt.Errorf("%w: empty pattern", ErrInvalidWatchPattern)
+	}
+	i := strings.IndexAny(pattern, "*?[")
+	if i < 0 {
+		return pattern, "*", nil
+	}
+	root = path.Dir(pattern[:i] + "x")
+	if root == "." && !strings.HasPrefix(pattern, "./") {
+		// find prints paths under "." with a "./" prefix
+		glob = "./" + pattern
+	} else {
+		glob = pattern
+	}
+	return root, glob, nil
+}
+
+// Pattern returns the trigger's pattern, as passed to OnFileChange.
+func (t *FileTrigger) Pattern() string {
+	return t.pattern
+}
+
+// History returns the trigger's runs, oldest first. The sandbox keeps the last 100 runs.
+func (t *FileTrigger) History(ctx context.Context) ([]FileTriggerRun, error) {
+	records, err := readGuestRuns(ctx, t.proc, ErrFailedToReadFileTriggerHistory)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]FileTriggerRun, 0, len(records))
+	for _, rec := range records {
+		var changed []string
+		if files := strings.TrimSpace(string(rec.files)); files != "" {
+			changed = strings.Split(files, "\n")
+		}
+		runs = append(runs, FileTriggerRun{
+			Started:   rec.started,
+			Running:   rec.running,
+			Changed:   changed,
+			Execution: commandExecutionFromStreams("sh", []string{"-c", t.command}, rec.exitCode, rec.stdout, rec.stderr, TerminationReason{}),
+			name:      rec.name,
+		})
+	}
+	return runs, nil
+}
+
+// Follow calls fn with every finished run of the trigger, oldest first, starting with those
+// still in its history, and then with each further run as it finishes, until ctx is done or
+// reading the history fails.
+//
+//	trigger, err := sandbox.OnFileChange(ctx, "/src/*.go", "go test ./...")
+//	go trigger.Follow(ctx, func(run msb.FileTriggerRun) {
+//		fmt.Printf("%v changed, tests exited with %d\n", run.Changed, run.Execution.GetExitCode())
+//	})
+func (t *FileTrigger) Follow(ctx context.Context, fn func(FileTriggerRun)) error {
+	last := ""
+	for {
+		runs, err := t.History(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		for _, run := range runs {
+			if run.Running {
+				break
+			}
+			if run.name > last {
+				last = run.name
+				fn(run)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(fileTriggerPollInterval):
+		}
+	}
+}
+
+// Stop uninstalls the trigger, kills any run in progress and discards the trigger's history.
+func (t *FileTrigger) Stop(ctx context.Context) error {
+	if err := t.proc.signal(ctx, "KILL"); err != nil {
+		return err
+	}
+	return t.proc.remove(ctx)
+}
+
+// File trigger-related errors
+var (
+	ErrInvalidWatchPattern            = errors.New("invalid watch pattern")
+	ErrFailedToInstallFileTrigger     = errors.New("failed to install file trigger")
+	ErrFailedToReadFileTriggerHistory = errors.New("failed to read file trigger history")
+)
@@ -0,0 +1,448 @@
+package msb
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileSystem transfers files between the client and the sandbox. Content travels base64-encoded
+// in chunks, so binary data and large files are handled safely. Transfers are compressed as
+// chosen with [WithCompression] or [WithDefaultCompression].
+type FileSystem interface {
+	// WriteFile writes data to name, creating parent directories as needed. The file is
+	// written to a temporary name first and renamed into place, so readers never see it partially written.
+	WriteFile(ctx context.Context, name string, data []byte, perm fs.FileMode, opts ...TransferOption) error
+	// ReadFile returns the contents of name. A missing file yields an error matching fs.ErrNotExist.
+	// [VerifyAgainst] and [WithDigest] check the contents as they arrive.
+	ReadFile(ctx context.Context, name string, opts ...TransferOption) ([]byte, error)
+	// ListDir lists the entries of dir, excluding "." and "..".
+	ListDir(ctx context.Context, dir string) ([]FileInfo, error)
+	// RemoveFile removes name, which must not be a non-empty directory.
+	RemoveFile(ctx context.Context, name string) error
+	// UploadDir copies the local directory localDir into remoteDir as a tar archive.
+	UploadDir(ctx context.Context, localDir string, remoteDir string, opts ...TransferOption) error
+	// DownloadDir copies remoteDir into the local directory localDir as a tar archive.
+	// [VerifyAgainst] and [WithDigest] apply to the archive.
+	DownloadDir(ctx context.Context, remoteDir string, localDir string, opts ...TransferOption) error
+	// Sync is a barrier between this client's file transfers and the sandbox: it waits for the
+	// writes in flight to complete and for the files written through [WithSharedDir] to be
+	// visible in the guest, and flushes the guest's writes to the shared directory for
+	// ReadFile to see them. Writes that completed before an execution starts are visible to it
+	// without calling Sync, since executions wait for the shared writes themselves; files they
+	// found missing are reported by the next Sync.
+	Sync(ctx context.Context) error
+}
+
+// FileInfo describes a directory entry in the sandbox.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+// IsDir reports whether the entry is a directory.
+func (fi FileInfo) IsDir() bool {
+	return fi.Mode.IsDir()
+}
+
+type fileSystem struct {
+	b *baseMicroSandbox
+}
+
+// fileReadChunkBytes bounds how much of a file is returned by a single command.
+const fileReadChunkBytes = 1 << 20
+
+func (f fileSystem) WriteFile(ctx context.Context, name string, data []byte, perm fs.FileMode, opts ...TransferOption) error {
+	f.b.fileWrites.Add(1)
+	defer f.b.fileWrites.Done()
+	if local, ok := f.b.sharedPath(ctx, name); ok {
+		if err := writeSharedFile(local, data, perm); err != nil {
+			return fmt.Errorf("%w: %w", ErrFailedToWriteFile, err)
+		}
+		f.b.sharedWrites.add(name, int64(len(data)))
+		return nil
+	}
+	tc, err := f.transferConfig(opts)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToWriteFile, err)
+	}
+	tmp := name + ".msb-part"
+	if err := f.upload(ctx, tc, tmp, data); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToWriteFile, err)
+	}
+	exec, err := f.b.runShell(ctx, `chmod `+strconv.FormatUint(uint64(perm.Perm()), 8)+` `+shellQuote(tmp)+` && mv -f `+shellQuote(tmp)+` `+shellQuote(name))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToWriteFile, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToWriteFile, commandStderr(exec))
+	}
+	return nil
+}
+
+func (f fileSystem) ReadFile(ctx context.Context, name string, opts ...TransferOption) ([]byte, error) {
+	tc, err := f.transferConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+	}
+	h := tc.newDigest()
+	if local, ok := f.b.sharedPath(ctx, name); ok {
+		data, err := readSharedFile(local, h)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+		}
+		return f.verified(tc, h, data)
+	}
+	if !tc.compression.enabled() {
+		data, err := f.readFile(ctx, name, h)
+		if err != nil {
+			return nil, err
+		}
+		return f.verified(tc, h, data)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+	}
+	q := shellQuote(name)
+	archive := guestPayloadsDir + "/" + id + ".z"
+	exec, err := f.b.runShell(ctx, `[ -f `+q+` ] || exit 2
+mkdir -p `+shellQuote(guestPayloadsDir)+` && `+tc.compression.GuestCompress+` <`+q+` >`+shellQuote(archive))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+	}
+	if exec.GetExitCode() == 2 {
+		return nil, fmt.Errorf("%w: %w: %s", ErrFailedToReadFile, fs.ErrNotExist, name)
+	}
+	if !exec.IsSuccess() {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToReadFile, commandStderr(exec))
+	}
+	data, err := f.readFile(ctx, archive, nil)
+	_ = f.RemoveFile(context.WithoutCancel(ctx), archive)
+	if err != nil {
+		return nil, err
+	}
+	data, err = tc.decompress(data, h)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+	}
+	return f.verified(tc, h, data)
+}
+
+// verified returns data if its digest h passes the checks of tc.
+func (f fileSystem) verified(tc transferConfig, h hash.Hash, data []byte) ([]byte, error) {
+	if err := tc.checkDigest(h); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+	}
+	return data, nil
+}
+
+// readFile reads name as is, a chunk per command, feeding the chunks to h as well if it is not
+// nil.
+func (f fileSystem) readFile(ctx context.Context, name string, h hash.Hash) ([]byte, error) {
+	q := shellQuote(name)
+	exec, err := f.b.runShell(ctx, `[ -f `+q+` ] || exit 2; wc -c <`+q)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+	}
+	if exec.GetExitCode() == 2 {
+		return nil, fmt.Errorf("%w: %w: %s", ErrFailedToReadFile, fs.ErrNotExist, name)
+	}
+	out, _ := exec.GetOutput()
+	size, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToReadFile, commandStderr(exec))
+	}
+
+	data := make([]byte, 0, size)
+	for off := int64(0); off < size; off += fileReadChunkBytes {
+		exec, err := f.b.runShell(ctx, `tail -c +`+strconv.FormatInt(off+1, 10)+` `+q+` | head -c `+strconv.Itoa(fileReadChunkBytes)+` | base64 | tr -d '\n'`)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+		}
+		out, _ := exec.GetOutput()
+		chunk, err := base64.StdEncoding.DecodeString(strings.TrimSpace(out))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToReadFile, err)
+		}
+		if len(chunk) == 0 {
+			break // the file shrank while being read
+		}
+		if h != nil {
+			h.Write(chunk)
+		}
+		data = append(data, chunk...)
+	}
+	return data, nil
+}
+
+func (f fileSystem) ListDir(ctx context.Context, dir string) ([]FileInfo, error) {
+	exec, err := f.b.runShell(ctx, `cd `+shellQuote(dir)+` || exit 2
+for f in * .*; do
+  case $f in .|..) continue;; esac
+  [ -e "$f" ] || [ -L "$f" ] || continue
+  stat -c '%s %f %Y %n' -- "$f"
+done`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToListDir, err)
+	}
+	if exec.GetExitCode() == 2 {
+		return nil, fmt.Errorf("%w: %w: %s", ErrFailedToListDir, fs.ErrNotExist, dir)
+	}
+	if !exec.IsSuccess() {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToListDir, commandStderr(exec))
+	}
+	out, _ := exec.GetOutput()
+	var entries []FileInfo
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[0], 10, 64)
+		rawMode, _ := strconv.ParseUint(fields[1], 16, 32)
+		mtime, _ := strconv.ParseInt(fields[2], 10, 64)
+		entries = append(entries, FileInfo{
+			Name:    fields[3],
+			Size:    size,
+			Mode:    unixFileMode(uint32(rawMode)),
+			ModTime: time.Unix(mtime, 0),
+		})
+	}
+	return entries, nil
+}
+
+func (f fileSystem) RemoveFile(ctx context.Context, name string) error {
+	q := shellQuote(name)
+	exec, err := f.b.runShell(ctx, `[ -e `+q+` ] || [ -L `+q+` ] || exit 2
+if [ -d `+q+` ] && [ ! -L `+q+` ]; then rmdir `+q+`; else rm -f `+q+`; fi`)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToRemoveFile, err)
+	}
+	if exec.GetExitCode() == 2 {
+		return fmt.Errorf("%w: %w: %s", ErrFailedToRemoveFile, fs.ErrNotExist, name)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToRemoveFile, commandStderr(exec))
+	}
+	return nil
+}
+
+func (f fileSystem) UploadDir(ctx context.Context, localDir string, remoteDir string, opts ...TransferOption) error {
+	f.b.fileWrites.Add(1)
+	defer f.b.fileWrites.Done()
+	tc, err := f.transferConfig(opts)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToUploadDir, err)
+	}
+	var buf bytes.Buffer
+	if err := tarDir(&buf, localDir); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToUploadDir, err)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToUploadDir, err)
+	}
+	archive := guestPayloadsDir + "/" + id + ".tar"
+	if err := f.upload(ctx, tc, archive, buf.Bytes()); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToUploadDir, err)
+	}
+	a := shellQuote(archive)
+	exec, err := f.b.runShell(ctx, `mkdir -p `+shellQuote(remoteDir)+` && tar -xf `+a+` -C `+shellQuote(remoteDir)+`; s=$?; rm -f `+a+`; exit $s`)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToUploadDir, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToUploadDir, commandStderr(exec))
+	}
+	return nil
+}
+
+func (f fileSystem) DownloadDir(ctx context.Context, remoteDir string, localDir string, opts ...TransferOption) error {
+	if _, err := f.transferConfig(opts); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToDownloadDir, err)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToDownloadDir, err)
+	}
+	archive := guestPayloadsDir + "/" + id + ".tar"
+	exec, err := f.b.runShell(ctx, `[ -d `+shellQuote(remoteDir)+` ] || exit 2
+mkdir -p `+shellQuote(guestPayloadsDir)+` && tar -cf `+shellQuote(archive)+` -C `+shellQuote(remoteDir)+` .`)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToDownloadDir, err)
+	}
+	if exec.GetExitCode() == 2 {
+		return fmt.Errorf("%w: %w: %s", ErrFailedToDownloadDir, fs.ErrNotExist, remoteDir)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToDownloadDir, commandStderr(exec))
+	}
+	// The archive is verified as it is read, before anything is extracted
+	data, err := f.ReadFile(ctx, archive, opts...)
+	_ = f.RemoveFile(context.WithoutCancel(ctx), archive)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToDownloadDir, err)
+	}
+	if err := untarDir(bytes.NewReader(data), localDir); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToDownloadDir, err)
+	}
+	return nil
+}
+
+// upload writes data to dest inside the guest, compressing it on the way if tc asks for it.
+func (f fileSystem) upload(ctx context.Context, tc transferConfig, dest string, data []byte) error {
+	if !tc.compression.enabled() {
+		return f.b.uploadPayload(ctx, dest, data)
+	}
+	compressed, err := tc.compress(data)
+	if err != nil {
+		return err
+	}
+	archive := dest + ".z"
+	if err := f.b.uploadPayload(ctx, archive, compressed); err != nil {
+		return err
+	}
+	a := shellQuote(archive)
+	exec, err := f.b.runShell(ctx, tc.compression.GuestDecompress+` <`+a+` >`+shellQuote(dest)+`; s=$?; rm -f `+a+`; exit $s`)
+	if err != nil {
+		return err
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrCompressionFailed, commandStderr(exec))
+	}
+	return nil
+}
+
+// unixFileMode converts a raw st_mode to an fs.FileMode.
+func unixFileMode(raw uint32) fs.FileMode {
+	mode := fs.FileMode(raw & 0o777)
+	switch raw & 0o170000 {
+	case 0o040000:
+		mode |= fs.ModeDir
+	case 0o120000:
+		mode |= fs.ModeSymlink
+	case 0o010000:
+		mode |= fs.ModeNamedPipe
+	case 0o140000:
+		mode |= fs.ModeSocket
+	case 0o020000:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0o060000:
+		mode |= fs.ModeDevice
+	}
+	return mode
+}
+
+func commandStderr(exec CommandExecution) string {
+	stderr, _ := exec.GetError()
+	return strings.TrimSpace(stderr)
+}
+
+// tarDir writes the regular files and directories under dir to w.
+func tarDir(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil // skip symlinks, devices and the like
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// untarDir extracts the regular files and directories in r under dir, rejecting entries
+// that would escape it.
+func untarDir(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel := filepath.FromSlash(path.Clean("/" + hdr.Name))[1:]
+		if rel == "" {
+			continue
+		}
+		target := filepath.Join(dir, rel)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// File-related errors
+var (
+	ErrFailedToWriteFile   = errors.New("failed to write file")
+	ErrFailedToReadFile    = errors.New("failed to read file")
+	ErrFailedToListDir     = errors.New("failed to list directory")
+	ErrFailedToRemoveFile  = errors.New("failed to remove file")
+	ErrFailedToUploadDir   = errors.New("failed to upload directory")
+	ErrFailedToDownloadDir = errors.New("failed to download directory")
+)
@@ -0,0 +1,121 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Forker branches a running sandbox into a new one.
+type Forker interface {
+	// Fork starts a new sandbox named newName with the options this one was created with and
+	// the image, memory and CPUs it was started with, and copies into it the files created or
+	// changed since this sandbox booted, so that risky code can run in the fork while this
+	// sandbox stays pristine. opts apply on top of the original options, e.g. to set a
+	// different namespace.
+	//
+	// The copy is of the filesystem only: the server cannot snapshot a VM's memory, so the fork
+	// has fresh interpreters and no running processes, and files deleted since boot are present
+	// again in the fork. /proc, /sys, /dev, /run and the SDK's own files are not copied. The
+	// caller must Stop the fork.
+	//
+	//	fork, err := sandbox.Fork(ctx, "attempt-2")
+	//	defer fork.Stop(context.Background())
+	//	_, err = fork.Command().Run(ctx, "rm", []string{"-rf", "/data/cache"})
+	Fork(ctx context.Context, newName string, opts ...Option) (LangSandBox, error)
+}
+
+// startArgs are the arguments of the last successful Start, defaults applied.
+type startArgs struct {
+	image    string
+	memoryMB int
+	cpus     int
+}
+
+// forkScript archives the files whose status changed since the guest booted, in $a.
+const forkScript = `since=$(( $(date +%s) - $(cut -d. -f1 /proc/uptime) ))
+mkdir -p ` + guestPayloadsDir + ` || exit 1
+find / -xdev \( -path /proc -o -path /sys -o -path /dev -o -path /run -o -path /tmp/.msb \) -prune \
+	-o -newerct "@$since" ! -path / -print0 |
+	tar --null --no-recursion -T - -cf "$a" || [ $? -eq 1 ] # 1: a file changed while read`
+
+type forker struct {
+	b    *baseMicroSandbox
+	lang Language
+}
+
+func (f forker) Fork(ctx context.Context, newName string, opts ...Option) (LangSandBox, error) {
+	if f.b.state.Load() != started {
+		return nil, ErrSandboxNotStarted
+	}
+	args := f.b.lastStart.Load()
+	if args == nil {
+		return nil, fmt.Errorf("%w: the image of an attached sandbox is unknown", ErrFailedToFork)
+	}
+	if newName == "" || newName == f.b.name() {
+		return nil, fmt.Errorf("%w: the fork needs a name of its own", ErrFailedToFork)
+	}
+
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFork, err)
+	}
+	archive := guestPayloadsDir + "/" + id + ".tar"
+	fsys := fileSystem{f.b}
+	exec, err := f.b.runShell(ctx, `a=`+shellQuote(archive)+"\n"+forkScript)
+	if err == nil && !exec.IsSuccess() {
+		err = errors.New(commandStderr(exec))
+	}
+	var data []byte
+	if err == nil {
+		data, err = fsys.ReadFile(ctx, archive)
+	}
+	_ = fsys.RemoveFile(context.WithoutCancel(ctx), archive)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFork, err)
+	}
+
+	options := append(slices.Clone(f.b.options), WithName(newName))
+	fork := newLangSandbox(f.lang, append(options, opts...)...)
+	if err := fork.Start(ctx, args.image, args.memoryMB, args.cpus); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFork, err)
+	}
+	if err := fork.restore(ctx, data); err != nil {
+		if stopErr := fork.Stop(context.WithoutCancel(ctx)); stopErr != nil {
+			err = errors.Join(err, stopErr)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrFailedToFork, err)
+	}
+	f.b.logger().Debug("Forked sandbox", "sandbox", f.b.name(), "fork", newName, "bytes", len(data))
+	return fork, nil
+}
+
+// restore unpacks an archive made by forkScript over the sandbox's root.
+func (ls *langSandbox) restore(ctx context.Context, data []byte) error {
+	fsys := fileSystem{ls.b}
+	tc, err := fsys.transferConfig(nil)
+	if err != nil {
+		return err
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return err
+	}
+	archive := guestPayloadsDir + "/" + id + ".tar"
+	if err := fsys.upload(ctx, tc, archive, data); err != nil {
+		return err
+	}
+	a := shellQuote(archive)
+	exec, err := ls.b.runShell(ctx, `tar -xpf `+a+` -C /; s=$?; rm -f `+a+`; exit $s`)
+	if err != nil {
+		return err
+	}
+	if !exec.IsSuccess() {
+		return errors.New(commandStderr(exec))
+	}
+	return nil
+}
+
+// Fork-related errors
+var ErrFailedToFork = errors.New("failed to fork sandbox")
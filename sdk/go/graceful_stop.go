@@ -0,0 +1,84 @@
+package msb
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// StopResult reports how a sandbox was stopped by [Stopper.StopGraceful] or [Stopper.ForceStop].
+type StopResult struct {
+	// Graceful reports whether the guest's processes were sent SIGTERM before the sandbox was
+	// torn down.
+	Graceful bool
+	// InFlight is the number of executions of this client still running when stopping began.
+	// Those still running at teardown fail with the server's error.
+	InFlight int
+	// Terminated is the number of guest processes that exited within the grace period, and
+	// Killed the number still running at its end, killed with the sandbox.
+	Terminated int
+	Killed     int
+	// Waited is how long the processes were given to exit.
+	Waited time.Duration
+}
+
+// gracefulStopPollInterval is how often StopGraceful checks whether the processes have exited.
+const gracefulStopPollInterval = 250 * time.Millisecond
+
+// terminateScript sends SIGTERM to every process of the guest except init, kernel threads and
+// the shell and its ancestors, which serve the request, and prints their PIDs.
+const terminateScript = `keep=" 1 2 $$ "
+p=$$
+while [ "$p" -gt 1 ]; do
+	read -r st </proc/$p/stat || break
+	set -- ${st##*)}
+	p=$2; keep="$keep$p "
+done
+for d in /proc/[0-9]*; do
+	p=${d#/proc/}
+	case $keep in *" $p "*) continue ;; esac
+	read -r st <"$d/stat" 2>/dev/null || continue
+	set -- ${st##*)}
+	[ "$2" = 2 ] && continue
+	kill -TERM "$p" 2>/dev/null && printf '%s ' "$p"
+done`
+
+// terminate sends SIGTERM to the guest's processes and waits up to grace for them and for the
+// client's in-flight executions to end. The sandbox is stopping, so the script runs whatever
+// the state.
+func (s stopper) terminate(ctx context.Context, grace time.Duration, res *StopResult) {
+	logger := s.b.logger()
+	result, err := s.b.rpcClient.runCommand(ctx, s.b.config(), "sh", []string{"-c", terminateScript})
+	if err != nil {
+		logger.Error("Failed to terminate guest processes, stopping at once", "sandbox", s.b.name(), "error", err)
+		return
+	}
+	res.Graceful = true
+	out, _ := newCommandExecution(result).GetOutput()
+	pids := strings.Fields(out)
+	total, alive := len(pids), len(pids)
+	begin := time.Now()
+	deadline := begin.Add(grace)
+	for (alive > 0 || s.b.inFlight.Load() > 0) && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			deadline = time.Now()
+			continue
+		case <-time.After(min(gracefulStopPollInterval, time.Until(deadline))):
+		}
+		if alive == 0 {
+			continue
+		}
+		result, err := s.b.rpcClient.runCommand(ctx, s.b.config(), "sh", []string{"-c",
+			`for p in ` + strings.Join(pids, " ") + `; do kill -0 "$p" 2>/dev/null && printf '%s ' "$p"; done; :`})
+		if err != nil {
+			continue
+		}
+		out, _ := newCommandExecution(result).GetOutput()
+		pids = strings.Fields(out)
+		alive = len(pids)
+	}
+	res.Waited = time.Since(begin)
+	res.Terminated, res.Killed = total-alive, alive
+	logger.Debug("Terminated guest processes", "sandbox", s.b.name(), "killed", alive, "waited", res.Waited)
+}
@@ -0,0 +1,229 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Group ties the lifetimes of several sandboxes together, so that a multi-sandbox job is torn down
+// as a whole: stopping the group, or cancelling the context it was created with, stops every
+// sandbox in it and in its subgroups. Work can be fanned out across its sandboxes with
+// [Group.RunAll] and [Group.Distribute]. It is safe for concurrent use.
+//
+//	group := msb.NewGroup(ctx)
+//	defer group.Stop(context.Background())
+//	db := msb.NewPythonSandbox(msb.WithName("db"))
+//	if err := group.Start(ctx, db, "", 512, 1); err != nil {
+//		return err
+//	}
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	sandboxes []LangSandBox
+	children  []*Group
+	stopped   bool
+	stopErr   error
+	stopDone  chan struct{}
+}
+
+// NewGroup creates a group that is stopped when ctx is cancelled.
+func NewGroup(ctx context.Context) *Group {
+	g := &Group{stopDone: make(chan struct{})}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	context.AfterFunc(g.ctx, func() { _ = g.Stop(context.WithoutCancel(ctx)) })
+	return g
+}
+
+// Context returns a context that is cancelled once the group starts stopping, for work that
+// should not outlive the group's sandboxes.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// NewSubgroup creates a group whose sandboxes are stopped along with g's. Stopping the subgroup
+// leaves g untouched.
+func (g *Group) NewSubgroup() (*Group, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		return nil, ErrGroupStopped
+	}
+	child := NewGroup(g.ctx)
+	g.children = append(g.children, child)
+	return child, nil
+}
+
+// Start starts sandbox and adds it to the group. If the group stops while Start is in flight,
+// the sandbox is stopped as soon as it is up.
+func (g *Group) Start(ctx context.Context, sandbox LangSandBox, image string, memoryMB int, cpus int) error {
+	if g.ctx.Err() != nil {
+		return ErrGroupStopped
+	}
+	if err := sandbox.Start(ctx, image, memoryMB, cpus); err != nil {
+		return err
+	}
+	return g.Add(sandbox)
+}
+
+// Add hands an already started sandbox over to the group. If the group has already stopped,
+// the sandbox is stopped right away and Add fails with [ErrGroupStopped].
+func (g *Group) Add(sandbox LangSandBox) error {
+	g.mu.Lock()
+	if !g.stopped {
+		g.sandboxes = append(g.sandboxes, sandbox)
+		g.mu.Unlock()
+		return nil
+	}
+	g.mu.Unlock()
+	if err := sandbox.Stop(context.WithoutCancel(g.ctx)); err != nil {
+		return errors.Join(ErrGroupStopped, err)
+	}
+	return ErrGroupStopped
+}
+
+// Create creates and starts n sandboxes as described by spec, like [CreateSandboxes], and adds
+// those that started to the group. It returns the handles at the sandboxes' indices, nil for
+// those that failed, along with a [*MultiError] of the failures.
+//
+//	workers, err := group.Create(ctx, msb.BatchSpec{Name: "grader", Parallelism: 8}, 32)
+func (g *Group) Create(ctx context.Context, spec BatchSpec, n int) ([]LangSandBox, error) {
+	if g.ctx.Err() != nil {
+		return make([]LangSandBox, n), ErrGroupStopped
+	}
+	sandboxes, errs := CreateSandboxes(ctx, spec, n)
+	for i, sb := range sandboxes {
+		if sb == nil {
+			continue
+		}
+		if err := g.Add(sb); err != nil {
+			sandboxes[i], errs[i] = nil, err
+		}
+	}
+	return sandboxes, joinIndexed(errs)
+}
+
+// Sandboxes returns the sandboxes of the group, not including those of its subgroups, in the
+// order they were added.
+func (g *Group) Sandboxes() []LangSandBox {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.sandboxes)
+}
+
+// RunAll runs code on every sandbox of the group concurrently, with at most parallelism runs in
+// flight (parallelism <= 0 means unbounded). It returns the executions at the sandboxes' indices
+// in [Group.Sandboxes], along with a [*MultiError] of the runs that failed.
+func (g *Group) RunAll(ctx context.Context, code string, parallelism int, opts ...RunOption) ([]CodeExecution, error) {
+	ctx, cancel := g.bind(ctx)
+	defer cancel()
+	sandboxes := g.Sandboxes()
+	execs := make([]CodeExecution, len(sandboxes))
+	errs := forEachBounded(ctx, len(sandboxes), parallelism, func(i int) error {
+		var err error
+		execs[i], err = sandboxes[i].Code().Run(ctx, code, opts...)
+		return err
+	})
+	return execs, joinIndexed(errs)
+}
+
+// Distribute works through n items as a queue across the sandboxes of the group: fn is called
+// for every index in [0, n) with a sandbox that runs no other item meanwhile, with at most
+// parallelism items in flight (parallelism <= 0 means one per sandbox). The returned error is a
+// [*MultiError] of the items that failed, by item index.
+//
+//	grades := make([]msb.CodeExecution, len(submissions))
+//	err := group.Distribute(ctx, len(submissions), 0, func(ctx context.Context, sb msb.LangSandBox, i int) error {
+//		var err error
+//		grades[i], err = sb.Code().Run(ctx, submissions[i])
+//		return err
+//	})
+func (g *Group) Distribute(ctx context.Context, n int, parallelism int, fn func(ctx context.Context, sandbox LangSandBox, i int) error) error {
+	ctx, cancel := g.bind(ctx)
+	defer cancel()
+	sandboxes := g.Sandboxes()
+	if len(sandboxes) == 0 {
+		if n == 0 {
+			return nil
+		}
+		return ErrGroupEmpty
+	}
+	if parallelism <= 0 || parallelism > len(sandboxes) {
+		parallelism = len(sandboxes)
+	}
+	// Every item in flight holds a sandbox, so there is always a free one for the next
+	free := make(chan LangSandBox, len(sandboxes))
+	for _, sb := range sandboxes {
+		free <- sb
+	}
+	errs := forEachBounded(ctx, n, parallelism, func(i int) error {
+		sb := <-free
+		defer func() { free <- sb }()
+		return fn(ctx, sb, i)
+	})
+	if !slices.ContainsFunc(errs, func(err error) bool { return err != nil }) {
+		return nil
+	}
+	return &MultiError{errs: errs, item: "item"}
+}
+
+// bind returns a context that is also cancelled once the group starts stopping.
+func (g *Group) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(g.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Stop stops every subgroup, then every sandbox of the group concurrently. It is idempotent:
+// later calls wait for the first one to finish and return its result.
+func (g *Group) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	if g.stopped {
+		g.mu.Unlock()
+		select {
+		case <-g.stopDone:
+			return g.stopErr
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	g.stopped = true
+	children, sandboxes := g.children, g.sandboxes
+	g.mu.Unlock()
+	g.cancel()
+
+	var errs []error
+	for _, child := range children {
+		if err := child.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	stopErrs := forEachBounded(ctx, len(sandboxes), 0, func(i int) error {
+		if err := sandboxes[i].Stop(ctx); err != nil && !errors.Is(err, ErrSandboxNotStarted) {
+			return err
+		}
+		return nil
+	})
+	if err := joinIndexed(stopErrs); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		g.stopErr = fmt.Errorf("%w: %w", ErrFailedToStopGroup, errors.Join(errs...))
+	}
+	close(g.stopDone)
+	return g.stopErr
+}
+
+// Group-related errors
+var (
+	ErrGroupStopped      = errors.New("sandbox group stopped")
+	ErrGroupEmpty        = errors.New("sandbox group has no sandboxes")
+	ErrFailedToStopGroup = errors.New("failed to stop sandbox group")
+)
@@ -0,0 +1,225 @@
+package msb
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runShell executes a POSIX shell script inside the sandbox through the command RPC.
+// It underpins the guest-side helpers, which are all built on top of plain command execution.
+func (b *baseMicroSandbox) runShell(ctx context.Context, script string) (CommandExecution, error) {
+	if b.state.Load() != started {
+		return CommandExecution{}, ErrSandboxNotStarted
+	}
+	result, err := b.rpcClient.runCommand(ctx, b.config(), "sh", []string{"-c", script})
+	if err != nil {
+		return CommandExecution{}, err
+	}
+	return newCommandExecution(result), nil
+}
+
+// shellQuote quotes s as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// --- background guest processes ---
+
+// guestJobsDir is where background processes keep their bookkeeping inside the guest.
+const guestJobsDir = "/tmp/.msb/jobs"
+
+// guestProcess is a detached process running inside the sandbox. The command RPC only returns
+// once a command exits, so long-running work is launched in its own session with stdout, stderr
+// and the final exit code redirected to files, which are then read back incrementally.
+type guestProcess struct {
+	b   *baseMicroSandbox
+	id  string
+	pid int
+
+	foreign bool // the sandbox may have been started by another client; skip the local state check
+}
+
+// guestProcessStatus is a snapshot of a guest process and the output produced since the
+// offsets passed to poll.
+type guestProcessStatus struct {
+	running  bool
+	exitCode int // only meaningful when !running; -1 if the process vanished without an exit code
+	stdout   []byte
+	stderr   []byte
+}
+
+// spawn launches script in the background and returns as soon as it is running.
+func (b *baseMicroSandbox) spawn(ctx context.Context, script string) (*guestProcess, error) {
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, err
+	}
+	return b.spawnWithID(ctx, id, script)
+}
+
+func (b *baseMicroSandbox) spawnWithID(ctx context.Context, id string, script string) (*guestProcess, error) {
+	p := &guestProcess{b: b, id: id}
+	dir := shellQuote(p.dir())
+	launcher := `d=` + dir + `; mkdir -p "$d" || exit 1
+s=; command -v setsid >/dev/null 2>&1 && s=setsid
+$s sh -c '(eval "$1") >"$2/out" 2>"$2/err" </dev/null; echo $? >"$2/exit.tmp"; mv "$2/exit.tmp" "$2/exit"' sh ` + shellQuote(script) + ` "$d" >/dev/null 2>&1 &
+echo $! >"$d/pid"; echo $!`
+	exec, err := b.runShell(ctx, launcher)
+	if err != nil {
+		return nil, err
+	}
+	out, _ := exec.GetOutput()
+	if p.pid, err = strconv.Atoi(strings.TrimSpace(out)); err != nil || !exec.IsSuccess() {
+		stderr, _ := exec.GetError()
+		return nil, fmt.Errorf("%w: %s", ErrGuestProcessSpawnFailed, strings.TrimSpace(stderr))
+	}
+	return p, nil
+}
+
+// attachGuestProcess re-binds to a process previously spawned with the given id,
+// possibly by another client. A foreign process is reached even if this client did not start
+// the sandbox.
+func (b *baseMicroSandbox) attachGuestProcess(ctx context.Context, id string, foreign bool) (*guestProcess, error) {
+	if !validGuestJobID(id) {
+		return nil, fmt.Errorf("%w: %q", ErrGuestProcessNotFound, id)
+	}
+	p := &guestProcess{b: b, id: id, foreign: foreign}
+	exec, err := p.shell(ctx, `cat `+shellQuote(p.dir()+"/pid"))
+	if err != nil {
+		return nil, err
+	}
+	out, _ := exec.GetOutput()
+	if p.pid, err = strconv.Atoi(strings.TrimSpace(out)); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrGuestProcessNotFound, id)
+	}
+	return p, nil
+}
+
+func (p *guestProcess) dir() string {
+	return guestJobsDir + "/" + p.id
+}
+
+// poll reports whether the process is still running and returns the stdout/stderr bytes written
+// past the given offsets; a negative offset skips that stream. Output travels base64-encoded,
+// since the command RPC is line-oriented.
+func (p *guestProcess) poll(ctx context.Context, stdoutOffset, stderrOffset int64) (guestProcessStatus, error) {
+	return p.longPoll(ctx, stdoutOffset, stderrOffset, 0)
+}
+
+// longPoll is poll, except that the guest holds the request for up to wait until the process
+// writes past the given offsets or exits, so that output arrives as soon as it is written with
+// a single request rather than repeated polls.
+func (p *guestProcess) longPoll(ctx context.Context, stdoutOffset, stderrOffset int64, wait time.Duration) (guestProcessStatus, error) {
+	script := `d=` + shellQuote(p.dir())
+	if ticks := int(wait / longPollTick); ticks > 0 {
+		script += `
+size() { s=$(wc -c <"$1" 2>/dev/null) && echo "$s" || echo 0; }
+i=0; while [ $i -lt ` + strconv.Itoa(ticks) + ` ] && [ ! -f "$d/exit" ] && kill -0 "$(cat "$d/pid")" 2>/dev/null` +
+			` && [ "$(size "$d/out")" -le ` + strconv.FormatInt(stdoutOffset, 10) + ` ]` +
+			` && [ "$(size "$d/err")" -le ` + strconv.FormatInt(stderrOffset, 10) + ` ]; do sleep 0.1; i=$((i+1)); done`
+	}
+	script += `
+if [ -f "$d/exit" ]; then echo "X $(cat "$d/exit")"; elif kill -0 "$(cat "$d/pid")" 2>/dev/null; then echo R; else echo "X -1"; fi`
+	if stdoutOffset >= 0 {
+		script += `
+echo "O $(tail -c +` + strconv.FormatInt(stdoutOffset+1, 10) + ` "$d/out" 2>/dev/null | base64 | tr -d '\n')"`
+	}
+	if stderrOffset >= 0 {
+		script += `
+echo "E $(tail -c +` + strconv.FormatInt(stderrOffset+1, 10) + ` "$d/err" 2>/dev/null | base64 | tr -d '\n')"`
+	}
+
+	exec, err := p.shell(ctx, script)
+	if err != nil {
+		return guestProcessStatus{}, err
+	}
+	out, err := exec.GetOutput()
+	if err != nil {
+		return guestProcessStatus{}, err
+	}
+
+	var st guestProcessStatus
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		tag, value, _ := strings.Cut(sc.Text(), " ")
+		switch tag {
+		case "R":
+			st.running = true
+		case "X":
+			if st.exitCode, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				st.exitCode = -1
+			}
+		case "O":
+			if st.stdout, err = base64.StdEncoding.DecodeString(value); err != nil {
+				return st, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+			}
+		case "E":
+			if st.stderr, err = base64.StdEncoding.DecodeString(value); err != nil {
+				return st, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+			}
+		}
+	}
+	return st, sc.Err()
+}
+
+// longPollTick is how often a long poll checks the process in the guest.
+const longPollTick = 100 * time.Millisecond
+
+// signal sends sig (e.g. "TERM", "KILL") to the process and its whole session. dash's kill
+// takes no "--", but reads a negative pid after the signal as a process group.
+func (p *guestProcess) signal(ctx context.Context, sig string) error {
+	pid := strconv.Itoa(p.pid)
+	_, err := p.shell(ctx, `kill -`+sig+` -`+pid+` 2>/dev/null || kill -`+sig+` `+pid+` 2>/dev/null; true`)
+	return err
+}
+
+// remove deletes the process bookkeeping from the guest.
+func (p *guestProcess) remove(ctx context.Context) error {
+	_, err := p.shell(ctx, `rm -rf `+shellQuote(p.dir()))
+	return err
+}
+
+func (p *guestProcess) shell(ctx context.Context, script string) (CommandExecution, error) {
+	if !p.foreign {
+		return p.b.runShell(ctx, script)
+	}
+	result, err := p.b.rpcClient.runCommand(ctx, p.b.config(), "sh", []string{"-c", script})
+	if err != nil {
+		return CommandExecution{}, err
+	}
+	return newCommandExecution(result), nil
+}
+
+func newGuestJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrGuestProcessSpawnFailed, err)
+	}
+	return fmt.Sprintf("%x", b), nil
+}
+
+func validGuestJobID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Guest process errors
+var (
+	ErrGuestProcessSpawnFailed = errors.New("failed to spawn guest process")
+	ErrGuestProcessNotFound    = errors.New("guest process not found")
+)
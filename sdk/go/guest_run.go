@@ -0,0 +1,221 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// guestRunPollInterval is how often a command run as a guest process is polled.
+const guestRunPollInterval = 250 * time.Millisecond
+
+// commandScript renders cmd and args as a shell command line.
+func commandScript(cmd string, args []string) string {
+	words := []string{shellQuote(cmd)}
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// runGuestCommand runs script as a guest process and polls it until it exits, instead of
+// blocking on a single command RPC. This lets output reach rc.onOutput line by line, progress
+// reports rc.progress, and rc.stdout and rc.stderr as is, as it is produced, lets rc.onPrompt
+// answer prompts, and lets rc.timeout and rc.watchdog kill the process while keeping the output
+// captured so far (the server's own timeout discards it). cmd and args only label the result.
+func (b *baseMicroSandbox) runGuestCommand(ctx context.Context, script string, cmd string, args []string, rc runConfig) (CommandExecution, error) {
+	spawn := b.spawn
+	if rc.prompts() {
+		spawn = b.spawnPrompted
+	}
+	proc, err := spawn(ctx, script)
+	if err != nil {
+		return CommandExecution{}, err
+	}
+	defer func() { _ = proc.remove(context.WithoutCancel(ctx)) }()
+
+	var (
+		stdoutOff, stderrOff int64
+		lines                []OutputLine
+	)
+	outLines := lineSplitter{stream: "stdout"}
+	errLines := lineSplitter{stream: "stderr"}
+	sampler := outputSampler{OutputSampling: rc.sampling}
+	handle := func(line OutputLine, ok bool) {
+		if ok {
+			rc.onOutput(line)
+		}
+	}
+	deliver := func(batch []OutputLine) {
+		batch = slices.DeleteFunc(batch, func(line OutputLine) bool {
+			return rc.reportProgress(ctx, line)
+		})
+		for _, line := range batch {
+			if rc.buffered(line.Stream) {
+				lines = append(lines, line)
+			}
+		}
+		if rc.onOutput != nil {
+			now := time.Now()
+			for _, line := range batch {
+				handle(sampler.sample(line, now))
+			}
+			handle(sampler.due(now))
+		}
+	}
+	// finish delivers the output left once the process is gone, the last line included
+	finish := func() {
+		deliver(append(outLines.flush(), errLines.flush()...))
+		if rc.onOutput != nil {
+			handle(sampler.flush(time.Now()))
+		}
+	}
+	collect := func(st guestProcessStatus) {
+		stdoutOff += int64(len(st.stdout))
+		stderrOff += int64(len(st.stderr))
+		writeIfSet(rc.stdout, st.stdout)
+		writeIfSet(rc.stderr, st.stderr)
+		// Output that is neither kept nor handled line by line need not be split
+		if rc.onOutput != nil || rc.progress != nil || rc.buffered("stdout") {
+			deliver(outLines.write(st.stdout))
+		}
+		if rc.onOutput != nil || rc.buffered("stderr") {
+			deliver(errLines.write(st.stderr))
+		}
+	}
+
+	var deadline time.Time
+	if rc.timeout > 0 {
+		deadline = time.Now().Add(rc.timeout)
+	}
+	var reason TerminationReason
+	lastActivity := time.Now()
+	prompted := int64(-1) // output offsets summed when the last prompt was reported
+	for {
+		st, err := proc.poll(ctx, stdoutOff, stderrOff)
+		if err != nil {
+			if ctx.Err() != nil {
+				_ = proc.signal(context.WithoutCancel(ctx), "KILL")
+			}
+			return CommandExecution{}, err
+		}
+		collect(st)
+		if !st.running {
+			finish()
+			return commandExecutionFromLines(cmd, args, st.exitCode, lines, TerminationReason{}), nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			reason = TerminationReason{
+				Kind:   TerminationTimeout,
+				Detail: fmt.Sprintf("command timed out after %s", rc.timeout),
+			}
+			break
+		}
+		if len(st.stdout) > 0 || len(st.stderr) > 0 {
+			lastActivity = time.Now()
+		}
+		if rc.watchdog > 0 && time.Since(lastActivity) >= rc.watchdog {
+			if b.sandboxBusy(ctx) {
+				lastActivity = time.Now()
+			} else {
+				reason = TerminationReason{
+					Kind:   TerminationStalled,
+					Detail: fmt.Sprintf("no output or CPU activity for %s", rc.watchdog),
+				}
+				break
+			}
+		}
+		if rc.prompts() && time.Since(lastActivity) >= promptQuiet && stdoutOff+stderrOff != prompted {
+			if prompt, ok := pendingPrompt(&outLines, &errLines); ok {
+				prompted = stdoutOff + stderrOff
+				resp := rc.onPrompt(prompt)
+				if resp.Abort {
+					reason = TerminationReason{
+						Kind:   TerminationPrompt,
+						Detail: fmt.Sprintf("aborted at prompt %q", prompt.Text),
+					}
+					break
+				}
+				if resp.Input != "" {
+					if err := proc.answer(ctx, resp.Input); err != nil {
+						_ = proc.signal(context.WithoutCancel(ctx), "KILL")
+						return CommandExecution{}, err
+					}
+					lastActivity = time.Now()
+				}
+			}
+		}
+
+		wait := guestRunPollInterval
+		if !deadline.IsZero() {
+			wait = min(wait, time.Until(deadline))
+		}
+		select {
+		case <-ctx.Done():
+			_ = proc.signal(context.WithoutCancel(ctx), "KILL")
+			return CommandExecution{}, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	if err := proc.signal(ctx, "KILL"); err != nil {
+		return CommandExecution{}, err
+	}
+	// Pick up whatever was written between the last poll and the kill
+	if st, err := proc.poll(ctx, stdoutOff, stderrOff); err == nil {
+		collect(st)
+	}
+	finish()
+	return commandExecutionFromLines(cmd, args, -1, lines, reason), nil
+}
+
+// watchdogBusyCPU is the sandbox CPU usage, in percent, above which a silent execution is
+// considered to still be working.
+const watchdogBusyCPU = 1.0
+
+// sandboxBusy reports whether the sandbox is using CPU. Errors and unmeasured usage count as
+// busy, so that a metrics hiccup never gets an execution killed.
+func (b *baseMicroSandbox) sandboxBusy(ctx context.Context) bool {
+	metrics, err := b.rpcClient.getMetrics(ctx, b.config())
+	return err != nil || metrics.CPUUsage == nil || *metrics.CPUUsage >= watchdogBusyCPU
+}
+
+// commandExecutionFromStreams builds a CommandExecution from raw output streams.
+// Stdout lines are listed before stderr lines, since their interleaving is not recorded.
+func commandExecutionFromStreams(cmd string, args []string, exitCode int, stdout, stderr []byte, reason TerminationReason) CommandExecution {
+	lines := appendOutputLines(nil, "stdout", stdout)
+	lines = appendOutputLines(lines, "stderr", stderr)
+	return commandExecutionFromLines(cmd, args, exitCode, lines, reason)
+}
+
+// commandExecutionFromLines builds a CommandExecution from output lines in the order they were
+// received.
+func commandExecutionFromLines(cmd string, args []string, exitCode int, lines []OutputLine, reason TerminationReason) CommandExecution {
+	data := commandData{
+		Command:     cmd,
+		Args:        args,
+		ExitCode:    exitCode,
+		Success:     exitCode == 0 && reason.Kind == TerminationNone,
+		OutputLines: lines,
+	}
+	if reason.Kind == TerminationNone {
+		reason = terminationFromExitCode(exitCode)
+	}
+
+	exec := CommandExecution{parsed: data, parsedOK: true, termination: reason}
+	exec.Output, _ = json.Marshal(data)
+	return exec
+}
+
+func appendOutputLines(lines []OutputLine, stream string, p []byte) []OutputLine {
+	if len(p) == 0 {
+		return lines
+	}
+	for _, text := range strings.Split(strings.TrimSuffix(string(p), "\n"), "\n") {
+		lines = append(lines, OutputLine{Stream: stream, Text: text})
+	}
+	return lines
+}
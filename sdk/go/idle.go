@@ -0,0 +1,155 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Toucher extends the lease of a sandbox started with [WithIdleTimeout].
+type Toucher interface {
+	// Touch counts as activity, postponing the idle stop, e.g. while a long background job
+	// runs or a user watches a shell.
+	Touch(ctx context.Context) error
+}
+
+// WithIdleTimeout stops the sandbox once it has run no code or command for d; see [Toucher] to
+// extend the lease otherwise. Since a client that crashed cannot stop its sandboxes, the lease is
+// also kept inside the guest, where a watchdog powers the VM off if the client stops renewing
+// it, freeing the sandbox's memory; the server may still list such a sandbox until it is
+// stopped.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.idleTimeout = d
+	}
+}
+
+// guestLeaseFile is renewed by the client while the sandbox is in use; the guest watchdog
+// powers the VM off once it is older than the lease.
+const guestLeaseFile = "/tmp/.msb/lease"
+
+// idleWatchdogScript powers the VM off once the lease file has not been renewed for {lease}
+// seconds.
+const idleWatchdogScript = `f=` + guestLeaseFile + `; mkdir -p "${f%/*}" && touch "$f" || exit 1
+while :; do
+  sleep 5
+  [ $(( $(date +%s) - $(date -r "$f" +%s 2>/dev/null || echo 0) )) -gt {lease} ] && break
+done
+sync; poweroff -f 2>/dev/null || halt -f 2>/dev/null || echo o >/proc/sysrq-trigger`
+
+// idleWatcher stops a started sandbox once it has been idle for the configured timeout, and
+// renews the guest's lease while it is not.
+type idleWatcher struct {
+	b       *baseMicroSandbox
+	timeout time.Duration
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// startIdleWatch launches the idle watcher if WithIdleTimeout is configured; it is called once
+// the sandbox has started.
+func (b *baseMicroSandbox) startIdleWatch() {
+	timeout := b.config().idleTimeout
+	if timeout <= 0 {
+		return
+	}
+	b.lastActive.Store(time.Now().UnixNano())
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &idleWatcher{b: b, timeout: timeout, cancel: cancel, done: make(chan struct{})}
+	if old := b.idleWatcher.Swap(w); old != nil {
+		old.stop()
+	}
+	go w.run(ctx)
+}
+
+// stopIdleWatch stops the idle watcher; it is called when the sandbox stops.
+func (b *baseMicroSandbox) stopIdleWatch() {
+	if w := b.idleWatcher.Swap(nil); w != nil {
+		w.stop()
+	}
+}
+
+func (w *idleWatcher) stop() {
+	w.cancel()
+	<-w.done
+}
+
+// tick is how often the watcher checks for idleness and renews the lease.
+func (w *idleWatcher) tick() time.Duration {
+	return max(w.timeout/4, time.Second)
+}
+
+func (w *idleWatcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	// The guest allows for a missed renewal before giving up on the client
+	lease := int((w.timeout + 2*w.tick()).Seconds())
+	if _, err := w.b.spawn(ctx, strings.ReplaceAll(idleWatchdogScript, "{lease}", strconv.Itoa(lease))); err != nil {
+		w.b.logger().Error("Failed to start idle watchdog in sandbox", "sandbox", w.b.name(), "error", err)
+	}
+
+	renewed := time.Now()
+	ticker := time.NewTicker(w.tick())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		lastActive := time.Unix(0, w.b.lastActive.Load())
+		if idle := time.Since(lastActive); idle >= w.timeout {
+			w.b.logger().Info("Stopping idle sandbox", "sandbox", w.b.name(), "idle", idle.Round(time.Second))
+			// Stop waits for this watcher to exit, so it runs on its own
+			go func() {
+				if err := (stopper{w.b}).Stop(context.Background()); err != nil && !errors.Is(err, ErrSandboxNotStarted) {
+					w.b.logger().Error("Failed to stop idle sandbox", "sandbox", w.b.name(), "error", err)
+				}
+			}()
+			return
+		}
+		if lastActive.After(renewed) {
+			if err := w.b.renewLease(ctx); err != nil {
+				w.b.logger().Error("Failed to renew sandbox lease", "sandbox", w.b.name(), "error", err)
+				continue
+			}
+			renewed = time.Now()
+		}
+	}
+}
+
+// renewLease renews the guest's lease.
+func (b *baseMicroSandbox) renewLease(ctx context.Context) error {
+	exec, err := b.runShell(ctx, `touch `+guestLeaseFile)
+	if err != nil {
+		return err
+	}
+	if !exec.IsSuccess() {
+		return errors.New(commandStderr(exec))
+	}
+	return nil
+}
+
+type toucher struct {
+	b *baseMicroSandbox
+}
+
+func (t toucher) Touch(ctx context.Context) error {
+	if t.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	t.b.lastActive.Store(time.Now().UnixNano())
+	if t.b.idleWatcher.Load() == nil {
+		return nil
+	}
+	if err := t.b.renewLease(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToRenewLease, err)
+	}
+	return nil
+}
+
+// Idle-related errors
+var ErrFailedToRenewLease = errors.New("failed to renew sandbox lease")
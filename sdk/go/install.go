@@ -0,0 +1,109 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Installer installs packages with the package manager of the sandbox's language.
+type Installer interface {
+	// Install installs packages one after the other with the language's package manager, e.g.
+	// pip for Python and npm for Node.js, so that a broken package does not prevent the others
+	// from being installed. [WithLanguage] selects another enabled language's package manager;
+	// [WithOutputHandler] streams the package manager's progress. If any package fails to
+	// install, the result is returned along with [ErrPackageInstallFailed].
+	Install(ctx context.Context, packages []string, opts ...RunOption) (InstallResult, error)
+}
+
+// InstallResult reports the outcome of an Install call, one entry per package in the order given.
+type InstallResult struct {
+	Packages []PackageResult
+}
+
+// PackageResult is the outcome of installing a single package.
+type PackageResult struct {
+	Name      string
+	Execution CommandExecution // The package manager's run
+	Err       error            // Why the package was not installed; nil on success
+}
+
+// Installed returns the names of the packages that were installed.
+func (r InstallResult) Installed() []string {
+	var names []string
+	for _, p := range r.Packages {
+		if p.Err == nil {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// Failed returns the names of the packages that could not be installed.
+func (r InstallResult) Failed() []string {
+	var names []string
+	for _, p := range r.Packages {
+		if p.Err != nil {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+type installer struct {
+	b *baseMicroSandbox
+	l Language
+}
+
+func (in installer) Install(ctx context.Context, packages []string, opts ...RunOption) (InstallResult, error) {
+	if in.b.state.Load() != started {
+		return InstallResult{}, ErrSandboxNotStarted
+	}
+	rt, err := in.b.resolveLanguage(ctx, in.l, newRunConfig(opts).language)
+	if err != nil {
+		return InstallResult{}, err
+	}
+	if rt.Install == "" {
+		return InstallResult{}, fmt.Errorf("%w: %q", ErrNoPackageManager, rt.Name)
+	}
+	for _, pkg := range packages {
+		// A leading dash would be taken for an option of the package manager
+		if pkg == "" || strings.HasPrefix(pkg, "-") {
+			return InstallResult{}, fmt.Errorf("%w: %q", ErrInvalidPackageName, pkg)
+		}
+	}
+
+	var result InstallResult
+	for _, pkg := range packages {
+		in.b.logger().Info("Installing package", "sandbox", in.b.name(), "language", rt.Name, "package", pkg)
+		script := strings.ReplaceAll(rt.Install, "{package}", shellQuote(pkg))
+		exec, err := commandRunner{in.b}.Run(ctx, "sh", []string{"-c", script}, opts...)
+		if err == nil && !exec.IsSuccess() {
+			err = fmt.Errorf("exit code %d: %s", exec.GetExitCode(), lastErrorLine(exec))
+		}
+		result.Packages = append(result.Packages, PackageResult{Name: pkg, Execution: exec, Err: err})
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+	}
+	if failed := result.Failed(); len(failed) > 0 {
+		return result, fmt.Errorf("%w: %s", ErrPackageInstallFailed, strings.Join(failed, ", "))
+	}
+	return result, nil
+}
+
+// lastErrorLine returns the last non-empty line of stderr, where package managers put the reason
+// they failed.
+func lastErrorLine(exec CommandExecution) string {
+	stderr, _ := exec.GetError()
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// Package installation errors
+var (
+	ErrNoPackageManager     = errors.New("language has no package manager")
+	ErrInvalidPackageName   = errors.New("invalid package name")
+	ErrPackageInstallFailed = errors.New("failed to install packages")
+)
@@ -0,0 +1,97 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// RPCFunc sends a single JSON-RPC request to the server.
+type RPCFunc func(ctx context.Context, req *RPCRequest) (*RPCResponse, error)
+
+// Interceptor is middleware around every RPC the SDK sends to the server, e.g. to record a tracing
+// span per call, refresh an auth token, log or meter requests, or redact what they carry. It
+// returns an [RPCFunc] that calls next, possibly after changing the request or its context, and
+// possibly more than once. next sends the RPC with the retries configured by [WithRetry], so an
+// interceptor sees each RPC once, however many attempts it takes:
+//
+//	traceRPCs := func(next msb.RPCFunc) msb.RPCFunc {
+//		return func(ctx context.Context, req *msb.RPCRequest) (*msb.RPCResponse, error) {
+//			ctx, span := tracer.Start(ctx, req.Method, trace.WithAttributes(attribute.String("sandbox", req.Sandbox)))
+//			defer span.End()
+//			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+//			resp, err := next(ctx, req)
+//			if err != nil {
+//				span.SetStatus(codes.Error, err.Error())
+//			}
+//			return resp, err
+//		}
+//	}
+//
+//	refreshAuth := func(next msb.RPCFunc) msb.RPCFunc {
+//		return func(ctx context.Context, req *msb.RPCRequest) (*msb.RPCResponse, error) {
+//			req.Header.Set("Authorization", "Bearer "+tokens.Current())
+//			resp, err := next(ctx, req)
+//			var rpcErr *msb.RPCError
+//			if errors.As(err, &rpcErr) && rpcErr.StatusCode == http.StatusUnauthorized {
+//				req.Header.Set("Authorization", "Bearer "+tokens.Refresh())
+//				resp, err = next(ctx, req)
+//			}
+//			return resp, err
+//		}
+//	}
+type Interceptor func(next RPCFunc) RPCFunc
+
+// RPCRequest is a JSON-RPC request on its way to the server.
+type RPCRequest struct {
+	Method    string // The JSON-RPC method, e.g. "sandbox.repl.run"
+	Sandbox   string
+	Namespace string
+	Params    json.RawMessage
+	// Header is sent along with the request, e.g. to propagate the trace context. An
+	// Authorization header set here takes precedence over the API key.
+	Header http.Header
+}
+
+// RPCResponse is the server's response to an [RPCRequest].
+type RPCResponse struct {
+	Result json.RawMessage
+	Header http.Header // The HTTP response header
+}
+
+// WithInterceptors adds middleware around every RPC. Interceptors are nested in the order they
+// are added, the first one outermost.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.interceptors = append(msb.cfg.interceptors, interceptors...)
+	}
+}
+
+// makeJSONRPCRequest sends the request through the registered interceptors.
+func (d *jsonRPCHTTPClient) makeJSONRPCRequest(ctx context.Context, cfg *config, method rpcMethod, params any) (jsonRPCResponse, error) {
+	if len(cfg.interceptors) == 0 {
+		return d.sendWithRetry(ctx, cfg, method, params, nil)
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return jsonRPCResponse{}, fmt.Errorf("%w: %w", ErrMarshalReqFailed, err)
+	}
+	req := &RPCRequest{Method: string(method), Sandbox: cfg.name, Namespace: cfg.namespace, Params: raw, Header: http.Header{}}
+	send := func(ctx context.Context, req *RPCRequest) (*RPCResponse, error) {
+		resp, err := d.sendWithRetry(ctx, cfg, rpcMethod(req.Method), req.Params, req.Header)
+		if err != nil {
+			return nil, err
+		}
+		return &RPCResponse{Result: resp.Result, Header: resp.header}, nil
+	}
+	for i := len(cfg.interceptors) - 1; i >= 0; i-- {
+		send = cfg.interceptors[i](send)
+	}
+	resp, err := send(ctx, req)
+	if err != nil || resp == nil {
+		return jsonRPCResponse{}, err
+	}
+	date, _ := http.ParseTime(resp.Header.Get("Date"))
+	return jsonRPCResponse{JSONRPC: "2.0", Result: resp.Result, date: date, header: resp.Header}, nil
+}
@@ -0,0 +1,64 @@
+package msb_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+func TestInterceptorsWrapRetriedRPC(t *testing.T) {
+	ctx := context.Background()
+	var failNext atomic.Bool
+	var traced []string // trace headers of the requests received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traced = append(traced, r.Header.Get("X-Trace"))
+		if failNext.CompareAndSwap(true, false) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":"1","result":{"sandboxes":[{"name":"box","namespace":"default","running":true}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	var calls []string
+	record := func(name string) msb.Interceptor {
+		return func(next msb.RPCFunc) msb.RPCFunc {
+			return func(ctx context.Context, req *msb.RPCRequest) (*msb.RPCResponse, error) {
+				calls = append(calls, name+" "+req.Method)
+				req.Header.Set("X-Trace", "span-1")
+				resp, err := next(ctx, req)
+				calls = append(calls, name+" done")
+				return resp, err
+			}
+		}
+	}
+	sandbox := msb.NewPythonSandbox(msb.WithServerUrl(srv.URL), msb.WithApiKey("key"),
+		msb.WithRetry(2, func(int) time.Duration { return 0 }),
+		msb.WithInterceptors(record("outer"), record("inner")))
+	if err := sandbox.Attach(ctx, "box"); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+
+	calls, traced = nil, nil
+	failNext.Store(true)
+	if _, err := sandbox.Metrics().IsRunning(ctx); err != nil {
+		t.Fatalf("IsRunning: %v", err)
+	}
+	want := []string{"outer sandbox.metrics.get", "inner sandbox.metrics.get", "inner done", "outer done"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+	if len(traced) != 2 || traced[0] != "span-1" || traced[1] != "span-1" {
+		t.Errorf("trace headers received = %q, want both attempts to carry span-1", traced)
+	}
+}
@@ -0,0 +1,172 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Janitor keeps track of the sandboxes started with [WithJanitor] or [WithAutoCleanup] and
+// stops those still running when the program shuts down, so that it does not leave microVMs
+// running on the server. Cleanup can be triggered by a signal, the cancellation of a context, a
+// panic, or explicitly; it is best-effort and synchronous, bounded by the janitor's timeout.
+//
+//	defer msb.DefaultJanitor.Recover()
+//	stop := msb.DefaultJanitor.CleanupOnSignal(os.Interrupt, syscall.SIGTERM)
+//	defer stop()
+//	sandbox := msb.NewPythonSandbox(msb.WithAutoCleanup())
+//
+// Nothing can run when the process is killed outright (SIGKILL, OOM killer); combine it with
+// [WithIdleTimeout], which the guest enforces by itself. A Janitor is safe for concurrent use.
+type Janitor struct {
+	timeout time.Duration
+
+	mu        sync.Mutex
+	sandboxes map[*baseMicroSandbox]struct{}
+}
+
+// DefaultJanitor is the janitor sandboxes are registered with by [WithAutoCleanup].
+var DefaultJanitor = NewJanitor(0)
+
+// defaultCleanupTimeout bounds a cleanup when the janitor's timeout is not set.
+const defaultCleanupTimeout = 10 * time.Second
+
+// NewJanitor returns a janitor whose cleanups triggered by a signal, a context or a panic take
+// at most timeout, or 10 seconds if timeout <= 0.
+func NewJanitor(timeout time.Duration) *Janitor {
+	if timeout <= 0 {
+		timeout = defaultCleanupTimeout
+	}
+	return &Janitor{timeout: timeout, sandboxes: map[*baseMicroSandbox]struct{}{}}
+}
+
+// WithJanitor registers the sandbox with j while it runs: j tracks it from a successful Start to
+// a successful Stop.
+func WithJanitor(j *Janitor) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.onStart = append(msb.cfg.onStart, func(_ context.Context, ev StartEvent) {
+			if ev.Err == nil {
+				j.track(msb)
+			}
+		})
+		msb.cfg.onStop = append(msb.cfg.onStop, func(_ context.Context, ev StopEvent) {
+			if ev.Err == nil {
+				j.untrack(msb)
+			}
+		})
+	}
+}
+
+// WithAutoCleanup registers the sandbox with [DefaultJanitor].
+func WithAutoCleanup() Option {
+	return WithJanitor(DefaultJanitor)
+}
+
+func (j *Janitor) track(b *baseMicroSandbox) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.sandboxes[b] = struct{}{}
+}
+
+func (j *Janitor) untrack(b *baseMicroSandbox) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.sandboxes, b)
+}
+
+// Len returns the number of sandboxes the janitor would stop.
+func (j *Janitor) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.sandboxes)
+}
+
+// Cleanup stops every tracked sandbox concurrently and waits for them, or for ctx to be done.
+// Sandboxes that fail to stop remain tracked, so a later Cleanup retries them.
+func (j *Janitor) Cleanup(ctx context.Context) error {
+	j.mu.Lock()
+	sandboxes := make([]*baseMicroSandbox, 0, len(j.sandboxes))
+	for b := range j.sandboxes {
+		sandboxes = append(sandboxes, b)
+	}
+	j.mu.Unlock()
+	errs := forEachBounded(ctx, len(sandboxes), 0, func(i int) error {
+		err := stopper{sandboxes[i]}.Stop(ctx)
+		if errors.Is(err, ErrSandboxNotStarted) {
+			j.untrack(sandboxes[i])
+			return nil
+		}
+		if err != nil {
+			sandboxes[i].logger().Info("Failed to stop sandbox on cleanup", "sandbox", sandboxes[i].name(), "error", err)
+			return fmt.Errorf("%s: %w", sandboxes[i].name(), err)
+		}
+		return nil
+	})
+	if slices.ContainsFunc(errs, func(err error) bool { return err != nil }) {
+		return fmt.Errorf("%w: %w", ErrFailedToCleanUp, errors.Join(errs...))
+	}
+	return nil
+}
+
+// cleanupNow runs a Cleanup bounded by the janitor's timeout. Failures are logged by the
+// sandboxes that failed to stop.
+func (j *Janitor) cleanupNow() {
+	ctx, cancel := context.WithTimeout(context.Background(), j.timeout)
+	defer cancel()
+	_ = j.Cleanup(ctx)
+}
+
+// CleanupOnCancel cleans up once ctx is cancelled, e.g. the context of a server's lifetime. The
+// returned function unregisters the cleanup.
+func (j *Janitor) CleanupOnCancel(ctx context.Context) (stop func() bool) {
+	return context.AfterFunc(ctx, func() { j.cleanupNow() })
+}
+
+// CleanupOnSignal cleans up when the process receives one of sigs, by default os.Interrupt,
+// then delivers the signal again with its default behavior restored, which normally ends the
+// process. The returned function stops listening.
+func (j *Janitor) CleanupOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		select {
+		case sig := <-ch:
+			j.cleanupNow()
+			signal.Reset(sigs...)
+			if p, err := os.FindProcess(os.Getpid()); err != nil || p.Signal(sig) != nil {
+				os.Exit(1)
+			}
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Recover cleans up if the goroutine is panicking, then lets the panic go on. It must be
+// deferred directly, typically first thing in main:
+//
+//	defer msb.DefaultJanitor.Recover()
+func (j *Janitor) Recover() {
+	if r := recover(); r != nil {
+		j.cleanupNow()
+		panic(r)
+	}
+}
+
+// Janitor-related errors
+var ErrFailedToCleanUp = errors.New("failed to clean up sandboxes")
@@ -0,0 +1,180 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job is a handle to code running in the background inside the sandbox, started with
+// Code().RunAsync. No request stays open while it runs: the code keeps running if the client
+// disconnects or exits, and its output is kept in the sandbox until the result is collected.
+// Store its ID to collect the result later with Code().Attach, from this process or another one.
+type Job struct {
+	b    *baseMicroSandbox
+	proc *guestProcess
+	lang Language
+
+	// next serializes WaitNext and guards the offsets of the output it has returned
+	next      sync.Mutex
+	stdoutOff int64
+	stderrOff int64
+}
+
+// JobUpdate is the output a job wrote since the previous [Job.WaitNext].
+type JobUpdate struct {
+	Stdout   []byte
+	Stderr   []byte
+	Done     bool // The job has finished and all of its output has been returned
+	ExitCode int  // Set once Done
+}
+
+const (
+	// jobPollInterval is how often Wait checks whether a job has finished.
+	jobPollInterval = time.Second
+	// jobLongPollWait is how long a single WaitNext request is held in the sandbox.
+	jobLongPollWait = 20 * time.Second
+)
+
+func (cr codeRunner) RunAsync(ctx context.Context, code string, opts ...RunOption) (*Job, error) {
+	if cr.b.state.Load() != started {
+		return nil, ErrSandboxNotStarted
+	}
+	rc := newRunConfig(opts)
+	rt, err := cr.b.resolveLanguage(ctx, cr.l, rc.language)
+	if err != nil {
+		return nil, err
+	}
+	prelude, err := cr.b.shellPrelude(rc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartJob, err)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartJob, err)
+	}
+
+	script := prelude + code
+	if !rt.shell {
+		// The source lives in the job's directory, so collecting the result cleans it up too
+		path := guestJobsDir + "/" + id + "/code"
+		if err := cr.b.uploadPayload(ctx, path, []byte(code)); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToStartJob, err)
+		}
+		script = prelude + rt.command(path)
+	}
+	proc, err := cr.b.spawnWithID(ctx, id, script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartJob, err)
+	}
+	cr.b.logger().Debug("Job started", "sandbox", cr.b.name(), "language", rt.Name, "id", id)
+	return &Job{b: cr.b, proc: proc, lang: rt.Name}, nil
+}
+
+func (cr codeRunner) Attach(ctx context.Context, jobID string) (*Job, error) {
+	proc, err := cr.b.attachGuestProcess(ctx, jobID, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Job{b: cr.b, proc: proc, lang: cr.l}, nil
+}
+
+// ID identifies the job within the sandbox, for Code().Attach and [Canceler].
+func (j *Job) ID() string {
+	return j.proc.id
+}
+
+// Poll reports whether the job has finished, without waiting.
+func (j *Job) Poll(ctx context.Context) (bool, error) {
+	st, err := j.proc.poll(ctx, -1, -1)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrFailedToPollJob, err)
+	}
+	return !st.running, nil
+}
+
+// WaitNext blocks until the job writes output or finishes, and returns the output written since
+// the previous WaitNext. The sandbox holds each request open until there is something to return,
+// so calling WaitNext in a loop follows the job in near real time over plain request/response
+// HTTP, where streaming is not available. The output is still kept for Wait, which must be
+// called to collect the result and remove the job.
+func (j *Job) WaitNext(ctx context.Context) (JobUpdate, error) {
+	j.next.Lock()
+	defer j.next.Unlock()
+
+	for {
+		st, err := j.proc.longPoll(ctx, j.stdoutOff, j.stderrOff, j.longPollWait(ctx))
+		if err != nil {
+			return JobUpdate{}, fmt.Errorf("%w: %w", ErrFailedToPollJob, err)
+		}
+		j.stdoutOff += int64(len(st.stdout))
+		j.stderrOff += int64(len(st.stderr))
+		u := JobUpdate{Stdout: st.stdout, Stderr: st.stderr, Done: !st.running}
+		if u.Done {
+			u.ExitCode = st.exitCode
+		}
+		if u.Done || len(u.Stdout) > 0 || len(u.Stderr) > 0 {
+			return u, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return JobUpdate{}, err
+		}
+	}
+}
+
+// longPollWait is how long the sandbox may hold a WaitNext request, leaving the request time to
+// return within the deadlines of ctx and of the sandbox's RPCs.
+func (j *Job) longPollWait(ctx context.Context) time.Duration {
+	wait := jobLongPollWait
+	if timeout := j.b.config().rpcTimeout(methodSandboxCommandRun); timeout > 0 {
+		wait = min(wait, timeout/2)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		wait = min(wait, time.Until(deadline)/2)
+	}
+	return wait
+}
+
+// Wait blocks until the job finishes or ctx is done, and returns its result. Collecting the
+// result removes the job from the sandbox, so it can only be collected once. Stdout lines are
+// listed before stderr lines, since their interleaving is not recorded.
+func (j *Job) Wait(ctx context.Context) (CodeExecution, error) {
+	for {
+		st, err := j.proc.poll(ctx, -1, -1)
+		if err != nil {
+			return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToPollJob, err)
+		}
+		if !st.running {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return CodeExecution{}, ctx.Err()
+		case <-time.After(jobPollInterval):
+		}
+	}
+
+	st, err := j.proc.poll(ctx, 0, 0)
+	if err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToPollJob, err)
+	}
+	exec := codeExecutionFromCommand(commandExecutionFromStreams(string(j.lang), nil, st.exitCode, st.stdout, st.stderr, TerminationReason{}), j.lang)
+	if err := j.proc.remove(context.WithoutCancel(ctx)); err != nil {
+		j.b.logger().Error("Failed to remove finished job", "sandbox", j.b.name(), "id", j.proc.id, "error", err)
+	}
+	return exec, nil
+}
+
+// Cancel kills the job and every process it started. Its partial output can still be
+// collected with Wait.
+func (j *Job) Cancel(ctx context.Context) error {
+	return canceler{j.b}.Cancel(ctx, j.proc.id)
+}
+
+// Job-related errors
+var (
+	ErrFailedToStartJob = errors.New("failed to start job")
+	ErrFailedToPollJob  = errors.New("failed to poll job")
+)
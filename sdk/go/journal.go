@@ -0,0 +1,249 @@
+package msb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Journal is an append-only log on disk of the executions submitted with [WithJournalKey] and of
+// their results. A client that crashes mid-batch reopens the journal and submits the batch again
+// with the same keys: executions that already finished return their recorded result without
+// running, and background processes started with Command().Start that were still running are
+// re-attached instead of started twice. Executions that failed, or whose result was never
+// recorded, run again. It is safe for concurrent use.
+//
+//	journal, err := msb.OpenJournal("batch.journal")
+//	defer journal.Close()
+//	sandbox := msb.NewPythonSandbox(msb.WithJournal(journal))
+//	exec, err := sandbox.Code().Run(ctx, code, msb.WithJournalKey("case-42"))
+type Journal struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries map[string]*journalRecord // latest record per key
+}
+
+// journalRecord is one line of the journal.
+type journalRecord struct {
+	Op          string            `json:"op"`   // "submit" or "result"
+	Key         string            `json:"key"`  // caller-chosen key
+	Kind        string            `json:"kind"` // "code", "command" or "process"
+	ProcessID   string            `json:"process_id,omitempty"`
+	Output      json.RawMessage   `json:"output,omitempty"`
+	Termination TerminationReason `json:"termination,omitzero"`
+}
+
+const (
+	journalSubmit = "submit"
+	journalResult = "result"
+
+	journalCode    = "code"
+	journalCommand = "command"
+	journalProcess = "process"
+)
+
+// OpenJournal opens the journal at path, creating it if needed, and loads the records of
+// earlier runs. A truncated last line, left by a crash mid-write, is ignored.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrJournalFailed, err)
+	}
+	j := &Journal{f: f, entries: map[string]*journalRecord{}}
+	reader := bufio.NewReader(f)
+	terminated := true
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			terminated = line[len(line)-1] == '\n'
+			var rec journalRecord
+			if json.Unmarshal(line, &rec) == nil && rec.Key != "" {
+				j.entries[rec.Key] = &rec
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w: %w", ErrJournalFailed, err)
+		}
+	}
+	if !terminated {
+		// Start on a fresh line, so the next record is not glued to the truncated one.
+		if _, err := f.Write([]byte{'\n'}); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w: %w", ErrJournalFailed, err)
+		}
+	}
+	return j, nil
+}
+
+// Done reports whether the execution with the given key has a recorded result.
+func (j *Journal) Done(key string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rec, ok := j.entries[key]
+	return ok && rec.Op == journalResult
+}
+
+// Pending returns the sorted keys of executions that were submitted but have no recorded result.
+// After a crash, these are the executions that were in flight.
+func (j *Journal) Pending() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var keys []string
+	for key, rec := range j.entries {
+		if rec.Op == journalSubmit {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+// lookup returns the latest record for key. Reusing a key for a different kind of execution is
+// an error, since its recorded result could not be returned.
+func (j *Journal) lookup(key, kind string) (journalRecord, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rec, ok := j.entries[key]
+	if !ok {
+		return journalRecord{}, false, nil
+	}
+	if rec.Kind != kind {
+		return journalRecord{}, false, fmt.Errorf("%w: key %q was recorded for a %s, not a %s", ErrJournalKeyConflict, key, rec.Kind, kind)
+	}
+	return *rec, true, nil
+}
+
+// append writes rec and syncs it to disk before returning, so that it survives a crash.
+func (j *Journal) append(rec journalRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrJournalFailed, err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("%w: %w", ErrJournalFailed, err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return fmt.Errorf("%w: %w", ErrJournalFailed, err)
+	}
+	j.entries[rec.Key] = &rec
+	return nil
+}
+
+// journaled runs a synchronous execution under key: a recorded result is replayed, anything else
+// runs again. Only successful runs are recorded, so failures are retried on resume.
+func journaled[E CodeExecution | CommandExecution](b *baseMicroSandbox, key, kind string, run func() (E, error), replay func(journalRecord) E, record func(E) journalRecord) (E, error) {
+	j := b.config().journal
+	rec, ok, err := j.lookup(key, kind)
+	if err != nil {
+		var zero E
+		return zero, err
+	}
+	if ok && rec.Op == journalResult {
+		b.logger().Debug("Replaying journaled execution", "sandbox", b.name(), "key", key)
+		return replay(rec), nil
+	}
+	if err := j.append(journalRecord{Op: journalSubmit, Key: key, Kind: kind}); err != nil {
+		var zero E
+		return zero, err
+	}
+	exec, err := run()
+	if err != nil {
+		return exec, err
+	}
+	rec = record(exec)
+	rec.Op, rec.Key, rec.Kind = journalResult, key, kind
+	if err := j.append(rec); err != nil {
+		b.logger().Error("Failed to journal execution result", "sandbox", b.name(), "key", key, "error", err)
+	}
+	return exec, nil
+}
+
+func codeExecutionFromJournal(rec journalRecord) CodeExecution {
+	exec := CodeExecution{Output: rec.Output, termination: rec.Termination, replayed: true}
+	exec.parsedOK = json.Unmarshal(rec.Output, &exec.parsed) == nil
+	return exec
+}
+
+func commandExecutionFromJournal(rec journalRecord) CommandExecution {
+	exec := CommandExecution{Output: rec.Output, termination: rec.Termination, replayed: true}
+	exec.parsedOK = json.Unmarshal(rec.Output, &exec.parsed) == nil
+	return exec
+}
+
+func journalCodeResult(exec CodeExecution) journalRecord {
+	return journalRecord{Output: exec.Output, Termination: exec.termination}
+}
+
+func journalCommandResult(exec CommandExecution) journalRecord {
+	return journalRecord{Output: exec.Output, Termination: exec.termination}
+}
+
+// resumeProcess starts a journaled background process, or re-attaches to it if an earlier run
+// started it under the same key. The job id is recorded before the process is spawned, so a
+// crash in between cannot leave an untracked process behind.
+func (b *baseMicroSandbox) resumeProcess(ctx context.Context, key string, cmd string, args []string, rc runConfig) (*Process, error) {
+	j := b.config().journal
+	rec, ok, err := j.lookup(key, journalProcess)
+	if err != nil {
+		return nil, err
+	}
+	if ok && rec.Op == journalResult {
+		p := &Process{b: b, proc: &guestProcess{b: b, id: rec.ProcessID}, cmd: cmd, args: args, done: make(chan struct{})}
+		p.result = commandExecutionFromJournal(rec)
+		close(p.done)
+		return p, nil
+	}
+	if ok && rec.ProcessID != "" {
+		proc, err := b.attachGuestProcess(ctx, rec.ProcessID, false)
+		if err == nil {
+			b.logger().Debug("Process re-attached", "sandbox", b.name(), "command", cmd, "id", proc.id)
+			return b.watchProcess(proc, cmd, args, rc, key), nil
+		}
+		if !errors.Is(err, ErrGuestProcessNotFound) {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToStartProcess, err)
+		}
+	}
+
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartProcess, err)
+	}
+	if err := j.append(journalRecord{Op: journalSubmit, Key: key, Kind: journalProcess, ProcessID: id}); err != nil {
+		return nil, err
+	}
+	script, err := b.guestCommandScript(ctx, cmd, args, rc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartProcess, err)
+	}
+	proc, err := b.spawnWithID(ctx, id, script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartProcess, err)
+	}
+	b.logger().Debug("Process started", "sandbox", b.name(), "command", cmd, "id", proc.id)
+	return b.watchProcess(proc, cmd, args, rc, key), nil
+}
+
+// Journal-related errors
+var (
+	ErrJournalFailed      = errors.New("journal failed")
+	ErrJournalKeyConflict = errors.New("journal key reused for a different kind of execution")
+)
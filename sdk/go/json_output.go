@@ -0,0 +1,77 @@
+package msb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DecodeJSON unmarshals into v the last JSON object or array the code printed to stdout, e.g.
+// the result of a final print(json.dumps(result)). The document must start a line and end one,
+// and may span several, as with json.dumps(result, indent=2); any other output before or after
+// it, such as logs or progress messages, is ignored, even lines like "[1/3] done". It fails with
+// ErrNoJSONOutput if stdout holds no such document, and with ErrFailedToDecodeJSON if the
+// document does not fit v.
+//
+//	execution, err := sandbox.Code().Run(ctx, "import json\nprint(json.dumps({'total': 42}))")
+//	var result struct{ Total int }
+//	err = execution.DecodeJSON(&result)
+func (ce CodeExecution) DecodeJSON(v any) error {
+	stdout, err := ce.GetOutput()
+	if err != nil {
+		return err
+	}
+	doc, ok := lastJSONDocument([]byte(stdout))
+	if !ok {
+		return ErrNoJSONOutput
+	}
+	if err := json.Unmarshal(doc, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToDecodeJSON, err)
+	}
+	return nil
+}
+
+// lastJSONDocument returns the last top-level JSON object or array in out that starts and ends a
+// line. It scans forward, skipping over each document it finds, so that an object nested in a
+// later document is never mistaken for the last one. Each attempt resumes where the previous one
+// stopped, so every byte is decoded at most once.
+func lastJSONDocument(out []byte) (json.RawMessage, bool) {
+	var last json.RawMessage
+	for i := 0; i < len(out); i = nextLine(out, i) {
+		if out[i] != '{' && out[i] != '[' {
+			continue
+		}
+		var doc json.RawMessage
+		dec := json.NewDecoder(bytes.NewReader(out[i:]))
+		err := dec.Decode(&doc)
+		var syntaxErr *json.SyntaxError
+		switch {
+		case err == nil:
+			i += int(dec.InputOffset())
+			if rest, _, _ := bytes.Cut(out[i:], []byte("\n")); len(bytes.TrimSpace(rest)) == 0 {
+				last = doc
+			}
+		case errors.As(err, &syntaxErr):
+			i += int(syntaxErr.Offset)
+		default:
+			// The output ends inside a document, so none follows
+			return last, last != nil
+		}
+	}
+	return last, last != nil
+}
+
+// nextLine returns the offset of the line after the one holding out[i], or len(out).
+func nextLine(out []byte, i int) int {
+	if j := bytes.IndexByte(out[i:], '\n'); j >= 0 {
+		return i + j + 1
+	}
+	return len(out)
+}
+
+// JSON output-related errors
+var (
+	ErrNoJSONOutput       = errors.New("no JSON document in output")
+	ErrFailedToDecodeJSON = errors.New("failed to decode JSON output")
+)
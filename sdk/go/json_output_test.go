@@ -0,0 +1,84 @@
+package msb_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+	"github.com/keithang/microsandbox/sdk/go/msbtest"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		stdout  string
+		want    string // the document decoded, re-encoded compactly
+		wantErr error
+	}{
+		{"document alone", `{"total": 42}`, `{"total":42}`, nil},
+		{"trailing log line", "{\"total\": 42}\nwrote results.json", `{"total":42}`, nil},
+		{"leading logs", "loading\nscoring\n[1, 2, 3]", `[1,2,3]`, nil},
+		{"bracketed log text", "{\"total\": 42}\n[1/3] done\n{} items skipped\n[INFO] finished", `{"total":42}`, nil},
+		{"bracketed log text only", "[1] started\n{} items\n[INFO] done", "", msb.ErrNoJSONOutput},
+		{"pretty-printed", "result:\n{\n  \"total\": 42,\n  \"items\": [\n    {\"id\": 1}\n  ]\n}\nbye", `{"total":42,"items":[{"id":1}]}`, nil},
+		{"last of several", "{\"step\": 1}\n{\"step\": 2}\n", `{"step":2}`, nil},
+		{"nested object on its own line", "[\n{\"id\": 1}\n]", `[{"id":1}]`, nil},
+		{"truncated", "{\"step\": 1}\n{\"step\": 2, \"items\": [1, 2", `{"step":1}`, nil},
+		{"truncated only", "{\"items\": [1, 2", "", msb.ErrNoJSONOutput},
+		{"invalid then valid", "{not json}\n{\"ok\": true}", `{"ok":true}`, nil},
+		{"empty", "", "", msb.ErrNoJSONOutput},
+		{"whitespace", " \n\t\n", "", msb.ErrNoJSONOutput},
+	}
+	ctx := context.Background()
+	sandbox := msbtest.NewFakeSandbox(msb.LanguagePython)
+	if err := sandbox.Start(ctx, "img", 0, 0); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sandbox.HandleCode(tt.name, msbtest.Result{Stdout: tt.stdout})
+			exec, err := sandbox.Code().Run(ctx, tt.name)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			var got json.RawMessage
+			err = exec.DecodeJSON(&got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeJSON error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			compact, _ := json.Marshal(got)
+			if string(compact) != tt.want {
+				t.Errorf("DecodeJSON = %s, want %s", compact, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONLinear(t *testing.T) {
+	// Every line opens an array that is never closed, or closed too late; decoding from each line
+	// start anew would take quadratic time
+	for _, stdout := range []string{
+		strings.Repeat("[\n", 200000),
+		strings.Repeat("[\n", 200000) + "x\n{\"ok\": true}",
+		strings.Repeat("[1,\n", 200000) + "x",
+	} {
+		ctx := context.Background()
+		sandbox := msbtest.NewFakeSandbox(msb.LanguagePython)
+		if err := sandbox.Start(ctx, "img", 0, 0); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		sandbox.HandleCode("run", msbtest.Result{Stdout: stdout})
+		exec, err := sandbox.Code().Run(ctx, "run")
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		var v any
+		_ = exec.DecodeJSON(&v)
+	}
+}
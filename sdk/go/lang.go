@@ -1,20 +1,39 @@
 package msb
 
-import "errors"
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
 
 // LangSandBox provides a complete sandbox interface for a specific programming language.
 // It combines lifecycle management (Start/Stop) with execution capabilities (Code/Command)
 // and monitoring (Metrics) in a single, easy-to-use interface.
 //
+// Further features are separate interfaces, such as [Forker], [ShellOpener] or [CronScheduler],
+// which every sandbox created by this package implements. Code holding a LangSandBox reaches
+// them by type assertion:
+//
+//	if opener, ok := sandbox.(msb.ShellOpener); ok {
+//		shell, err := opener.OpenShell(ctx)
+//	}
+//
 // Example usage:
 //
 //	sandbox := msb.NewPythonSandbox(msb.WithName("my-sandbox"))
-//	if err := sandbox.Start("", 512, 1); err != nil {
+//	ctx := context.Background()
+//	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 //		log.Fatal(err)
 //	}
-//	defer sandbox.Stop()
+//	defer sandbox.Stop(ctx)
 //
-//	execution, err := sandbox.Code().Run("print('Hello World')")
+//	execution, err := sandbox.Code().Run(ctx, "print('Hello World')")
 //	if err != nil {
 //		log.Fatal(err)
 //	}
@@ -23,14 +42,17 @@ type LangSandBox interface {
 	Stopper
 	Code() CodeRunner
 	Command() CommandRunner
+	Files() FileSystem
 	Metrics() MetricsReader
+	Tests() TestRunner
+	Services() ServiceManager
 }
 
 var _ LangSandBox = (*langSandbox)(nil)
 
 type langSandbox struct {
 	b *baseMicroSandbox
-	l progLang
+	l Language
 }
 
 // NewPythonSandbox creates a new Python sandbox instance with the specified configuration options.
@@ -43,7 +65,7 @@ type langSandbox struct {
 //		msb.WithServerUrl("http://localhost:5555"),
 //	)
 func NewPythonSandbox(options ...Option) *langSandbox {
-	return newLangSandbox(langPython, options...)
+	return newLangSandbox(LanguagePython, options...)
 }
 
 // NewNodeSandbox creates a new Node.js sandbox instance with the specified configuration options.
@@ -56,10 +78,26 @@ func NewPythonSandbox(options ...Option) *langSandbox {
 //		msb.WithApiKey("your-api-key"),
 //	)
 func NewNodeSandbox(options ...Option) *langSandbox {
-	return newLangSandbox(langNodeJs, options...)
+	return newLangSandbox(LanguageNodeJS, options...)
+}
+
+// NewSandboxWithLanguage creates a sandbox whose code runs in lang, which may be any built-in
+// language, alias or language declared with [RegisterLanguage]. It fails with
+// [ErrUnknownLanguage] if lang is not registered.
+// The sandbox must be started with Start() before executing code or commands.
+//
+// Example:
+//
+//	sandbox, err := msb.NewSandboxWithLanguage("javascript", msb.WithName("my-js-sandbox"))
+func NewSandboxWithLanguage(lang Language, options ...Option) (LangSandBox, error) {
+	rt, err := lookupLanguage(string(lang))
+	if err != nil {
+		return nil, err
+	}
+	return newLangSandbox(rt.Name, options...), nil
 }
 
-func newLangSandbox(lang progLang, options ...Option) *langSandbox {
+func newLangSandbox(lang Language, options ...Option) *langSandbox {
 	b := newBaseWithOptions(options...)
 	n := &langSandbox{
 		b: b,
@@ -68,15 +106,187 @@ func newLangSandbox(lang progLang, options ...Option) *langSandbox {
 	return n
 }
 
-func (ls *langSandbox) Start(image string, memoryMB int, cpus int) error {
+func (ls *langSandbox) Start(ctx context.Context, image string, memoryMB int, cpus int) error {
+	image = cmp.Or(image, ls.b.config().image)
 	if image == "" {
-		image = ls.l.DefaultImage()
+		if rt, err := lookupLanguage(string(ls.l)); err == nil {
+			image = rt.Image
+		}
+		if image == "" {
+			return fmt.Errorf("%w: %q", ErrNoDefaultImage, ls.l)
+		}
 	}
-	return starter{ls.b}.Start(image, memoryMB, cpus)
+	return starter{ls.b}.Start(ctx, image, memoryMB, cpus)
+}
+
+func (ls *langSandbox) Stop(ctx context.Context) error {
+	return stopper{ls.b}.Stop(ctx)
+}
+
+func (ls *langSandbox) StopGraceful(ctx context.Context, grace time.Duration) (StopResult, error) {
+	return stopper{ls.b}.StopGraceful(ctx, grace)
+}
+
+func (ls *langSandbox) ForceStop(ctx context.Context) (StopResult, error) {
+	return stopper{ls.b}.ForceStop(ctx)
+}
+
+func (ls *langSandbox) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = NoOpLogger{}
+	}
+	ls.b.updateConfig(func(cfg *config) { cfg.logger = logger })
+}
+
+func (ls *langSandbox) SetTimeout(timeout time.Duration) {
+	ls.b.updateConfig(func(cfg *config) { cfg.timeout = timeout })
+}
+
+func (ls *langSandbox) SetCredentials(apiKey string) {
+	ls.b.updateConfig(func(cfg *config) {
+		cfg.apiKey = apiKey
+		cfg.tokenProvider = nil
+	})
+}
+
+func (ls *langSandbox) CrashReport(ctx context.Context, exec CommandExecution) (CrashReport, error) {
+	return crashReporter{ls.b}.CrashReport(ctx, exec)
+}
+
+func (ls *langSandbox) OpenMessageChannel(ctx context.Context, command string) (*MessageChannel, error) {
+	return messageChannelOpener{ls.b}.OpenMessageChannel(ctx, command)
+}
+
+func (ls *langSandbox) Lint(ctx context.Context, code string, opts ...RunOption) ([]Diagnostic, error) {
+	return codeChecker{ls.b, ls.l}.Lint(ctx, code, opts...)
+}
+
+func (ls *langSandbox) Format(ctx context.Context, code string, opts ...RunOption) (string, error) {
+	return codeChecker{ls.b, ls.l}.Format(ctx, code, opts...)
+}
+
+func (ls *langSandbox) ValidateCode(ctx context.Context, code string, opts ...RunOption) ([]Diagnostic, error) {
+	return codeChecker{ls.b, ls.l}.ValidateCode(ctx, code, opts...)
+}
+
+func (ls *langSandbox) Spec() (SandboxSpec, bool) {
+	spec := ls.b.spec.Load()
+	if spec == nil {
+		return SandboxSpec{}, false
+	}
+	return *spec, true
+}
+
+func (ls *langSandbox) Fetch(ctx context.Context, url string, dest string, opts ...FetchOption) (FetchResult, error) {
+	return fetcher{ls.b}.Fetch(ctx, url, dest, opts...)
+}
+
+func (ls *langSandbox) Cancel(ctx context.Context, executionID string) error {
+	return canceler{ls.b}.Cancel(ctx, executionID)
+}
+
+func (ls *langSandbox) Install(ctx context.Context, packages []string, opts ...RunOption) (InstallResult, error) {
+	return installer{ls.b, ls.l}.Install(ctx, packages, opts...)
+}
+
+func (ls *langSandbox) Health() HealthState {
+	return healthReporter{ls.b, ls.l}.Health()
+}
+
+func (ls *langSandbox) HealthCheck(ctx context.Context) error {
+	return healthReporter{ls.b, ls.l}.HealthCheck(ctx)
+}
+
+func (ls *langSandbox) WaitReady(ctx context.Context, opts ...WaitOption) error {
+	return healthReporter{ls.b, ls.l}.WaitReady(ctx, opts...)
+}
+
+func (ls *langSandbox) Eval(ctx context.Context, expr string, opts ...RunOption) (Value, error) {
+	return evaluator{ls.b, ls.l}.Eval(ctx, expr, opts...)
+}
+
+func (ls *langSandbox) ExposePort(ctx context.Context, sandboxPort int) (*url.URL, error) {
+	return portExposer{ls.b}.ExposePort(ctx, sandboxPort)
+}
+
+func (ls *langSandbox) ClockSkew(ctx context.Context) (ClockSkew, error) {
+	return clockReporter{ls.b}.ClockSkew(ctx)
+}
+
+func (ls *langSandbox) OpenShell(ctx context.Context) (*Shell, error) {
+	return shellOpener{ls.b, ls.l}.OpenShell(ctx)
+}
+
+func (ls *langSandbox) NewSession() (*Session, error) {
+	return sessionOpener{ls.b, ls.l}.NewSession()
+}
+
+func (ls *langSandbox) Cron(ctx context.Context, schedule, command string) (*CronJob, error) {
+	return cronScheduler{ls.b, ls.l}.Cron(ctx, schedule, command)
+}
+
+func (ls *langSandbox) Schedule(ctx context.Context, schedule, code string, opts ...RunOption) (*CronJob, error) {
+	return cronScheduler{ls.b, ls.l}.Schedule(ctx, schedule, code, opts...)
+}
+
+func (ls *langSandbox) CronJobs(ctx context.Context) ([]*CronJob, error) {
+	return cronScheduler{ls.b, ls.l}.CronJobs(ctx)
+}
+
+func (ls *langSandbox) Touch(ctx context.Context) error {
+	return toucher{ls.b}.Touch(ctx)
+}
+
+func (ls *langSandbox) OnFileChange(ctx context.Context, pattern, command string) (*FileTrigger, error) {
+	return fileWatcher{ls.b}.OnFileChange(ctx, pattern, command)
 }
 
-func (ls *langSandbox) Stop() error {
-	return stopper{ls.b}.Stop()
+func (ls *langSandbox) VerifyCleanState(ctx context.Context, rules CleanStateRules) (CleanStateReport, error) {
+	return cleanStateVerifier{ls.b}.VerifyCleanState(ctx, rules)
+}
+
+func (ls *langSandbox) GetArtifacts(ctx context.Context, paths ...string) (*Artifacts, error) {
+	return artifactCollector{ls.b}.GetArtifacts(ctx, paths...)
+}
+
+func (ls *langSandbox) QueueStats() QueueStats {
+	return queueReporter{ls.b}.QueueStats()
+}
+
+func (ls *langSandbox) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	return serverInfoReporter{ls.b}.ServerInfo(ctx)
+}
+
+func (ls *langSandbox) Fork(ctx context.Context, newName string, opts ...Option) (LangSandBox, error) {
+	return forker{ls.b, ls.l}.Fork(ctx, newName, opts...)
+}
+
+func (ls *langSandbox) CopyTo(ctx context.Context, dst LangSandBox, srcPath, dstPath string, opts ...TransferOption) error {
+	return copier{ls.b}.CopyTo(ctx, dst, srcPath, dstPath, opts...)
+}
+
+func (ls *langSandbox) Attach(ctx context.Context, name string) error {
+	return attacher{ls.b}.Attach(ctx, name)
+}
+
+func (ls *langSandbox) Detach() error {
+	return attacher{ls.b}.Detach()
+}
+
+func (ls *langSandbox) TailLogs(ctx context.Context, opts LogTailOptions, fn func(LogLine)) error {
+	return logTailer{ls.b}.TailLogs(ctx, opts, fn)
+}
+
+func (ls *langSandbox) ReadFullOutput(ctx context.Context, t OutputTruncation) ([]byte, []byte, error) {
+	return fullOutputReader{ls.b}.ReadFullOutput(ctx, t)
+}
+
+func (ls *langSandbox) RemoveFullOutput(ctx context.Context, t OutputTruncation) error {
+	return fullOutputReader{ls.b}.RemoveFullOutput(ctx, t)
+}
+
+func (ls *langSandbox) Capabilities(ctx context.Context) (Capabilities, error) {
+	return capabilityReporter{ls.b, ls.l}.Capabilities(ctx)
 }
 
 func (ls *langSandbox) Code() CodeRunner {
@@ -87,42 +297,268 @@ func (ls *langSandbox) Command() CommandRunner {
 	return commandRunner{ls.b}
 }
 
+func (ls *langSandbox) Files() FileSystem {
+	return fileSystem{ls.b}
+}
+
 func (ls *langSandbox) Metrics() MetricsReader {
 	return metricsReader{ls.b}
 }
 
-type progLang int
+func (ls *langSandbox) Tests() TestRunner {
+	return testRunner{ls.b}
+}
+
+func (ls *langSandbox) Services() ServiceManager {
+	return serviceManager{ls.b}
+}
+
+// Language names a runtime that code can be executed in. The built-in languages are
+// [LanguagePython], [LanguageNodeJS] and [LanguageBash]; others can be declared with
+// [RegisterLanguage]. Wherever a Language is accepted, any registered alias works too.
+type Language string
+
+// Built-in languages
+const (
+	LanguagePython Language = "python"
+	LanguageNodeJS Language = "nodejs"
+	LanguageBash   Language = "bash"
+)
+
+func (l Language) String() string {
+	return string(l)
+}
+
+// Runtime declares how code in a language is executed.
+type Runtime struct {
+	// Name is the canonical language name, e.g. "ruby".
+	Name Language
+	// Aliases are alternative spellings accepted wherever the language is named, e.g. "rb".
+	Aliases []string
+	// Extensions are the file extensions of source files in the language, e.g. ".rb", by which
+	// [CodeRunner.RunScriptFile] recognizes them.
+	Extensions []string
+	// Image is the default image for sandboxes of this language.
+	Image string
+	// Exec is the shell command that runs a source file; every "{file}" is replaced with the
+	// quoted path of the file holding the code, e.g. "ruby {file}".
+	Exec string
+	// Install is the shell command that installs a package for [Installer]; every "{package}" is
+	// replaced with the quoted package name, e.g. "gem install {package}". Empty if the language
+	// has no package manager.
+	Install string
+	// Check is a shell command exiting with 0 if the language's runtime is installed in the
+	// guest, e.g. "command -v ruby", by which [CapabilityReporter] lists the languages a sandbox
+	// can run. Empty if the runtime is assumed to be installed.
+	Check string
+}
+
+// languageRuntime is a registered runtime along with how the SDK dispatches to it.
+type languageRuntime struct {
+	Runtime
+	repl  bool // runs through the REPL RPC; Exec is only used for streamed executions
+	shell bool // runs the code as a shell script through the command RPC
 
+	// interrupt is a shell script that interrupts the code running in the REPL, leaving the
+	// interpreter usable; empty if the REPL cannot be interrupted without killing it.
+	interrupt string
+	// eval is a single line of code printing the JSON value of the expression whose string
+	// literal replaces "{expr}", after valuePrefix; empty if the language has no [Evaluator].
+	eval string
+	// sessionRun and sessionReset run code in and discard a [Session]'s namespace; empty if the
+	// language has no sessions.
+	sessionRun   string
+	sessionReset string
+	// chdir is a single line of code changing the REPL's working directory to the directory
+	// whose string literal replaces "{dir}"; empty if the language has no REPL.
+	chdir string
+}
+
+// command renders the runtime's Exec template for the source file at path.
+func (rt *languageRuntime) command(path string) string {
+	return strings.ReplaceAll(rt.Exec, "{file}", shellQuote(path))
+}
+
+// pythonReplInterrupt sends SIGINT to the portal's Python REPL, which raises KeyboardInterrupt in
+// the running code and returns to the prompt. The REPL is found by its command line; checking
+// the program name first keeps this script, which contains the same text, from matching itself.
+const pythonReplInterrupt = `for d in /proc/[0-9]*; do
+  c=$(tr '\0' ' ' <"$d/cmdline" 2>/dev/null) || continue
+  case "${c%% *}" in *python*) ;; *) continue ;; esac
+  case "$c" in *" -q -u -i -c import sys; sys.ps1=sys.ps2="*) kill -INT "${d#/proc/}" 2>/dev/null ;; esac
+done; true`
+
+// pythonEval and nodeEval print the value of an expression for [Evaluator].
 const (
-	langUnspecified progLang = iota
-	langPython
-	langNodeJs
+	pythonEval = `print("` + valuePrefix + `" + __import__("json").dumps(eval({expr}), default=repr))`
+	nodeEval   = `console.log("` + valuePrefix + `" + (JSON.stringify(eval({expr})) ?? "null"))`
 )
 
-// String should be the language's corresponding RPC parameter.
-func (p progLang) String() string {
-	switch p {
-	case langPython:
-		return "python"
-	case langNodeJs:
-		return "nodejs"
-	default:
-		panic(ErrUnknownLanguage)
+var languageRegistry = struct {
+	sync.RWMutex
+	byName      map[string]*languageRuntime
+	byExtension map[string]*languageRuntime
+}{byName: map[string]*languageRuntime{}, byExtension: map[string]*languageRuntime{}}
+
+func init() {
+	for _, rt := range []*languageRuntime{
+		{Runtime: Runtime{
+			Name:       LanguagePython,
+			Aliases:    []string{"python3", "py"},
+			Extensions: []string{".py"},
+			Image:      "microsandbox/python",
+			Exec:       `exec "$(command -v python3 || command -v python)" -u {file}`,
+			Install:    `"$(command -v python3 || command -v python)" -m pip install --disable-pip-version-check --no-input --progress-bar off {package}`,
+			Check:      `command -v python3 || command -v python`,
+		}, repl: true, interrupt: pythonReplInterrupt, eval: pythonEval,
+			sessionRun: pythonSessionRun, sessionReset: pythonSessionReset,
+			chdir: `__import__("os").chdir({dir})`},
+		{Runtime: Runtime{
+			Name:       LanguageNodeJS,
+			Aliases:    []string{"node", "javascript", "js"},
+			Extensions: []string{".js", ".cjs"},
+			Image:      "microsandbox/node",
+			Exec:       `exec node {file}`,
+			Install:    `npm install --no-audit --no-fund --no-progress {package}`,
+			Check:      `command -v node`,
+		}, repl: true, eval: nodeEval,
+			sessionRun: nodeSessionRun, sessionReset: nodeSessionReset,
+			chdir: `process.chdir({dir})`},
+		{Runtime: Runtime{
+			Name:       LanguageBash,
+			Aliases:    []string{"sh", "shell"},
+			Extensions: []string{".sh", ".bash"},
+			Exec:       `. {file}`,
+		}, shell: true},
+	} {
+		for _, name := range append([]string{string(rt.Name)}, rt.Aliases...) {
+			languageRegistry.byName[name] = rt
+		}
+		for _, ext := range rt.Extensions {
+			languageRegistry.byExtension[ext] = rt
+		}
+	}
+}
+
+// RegisterLanguage declares a custom runtime, so that sandboxes can run code in languages the
+// SDK has no built-in support for. Code is uploaded to a file in the guest and run with rt.Exec.
+// Names are case-insensitive. Register languages before creating sandboxes that use them.
+func RegisterLanguage(rt Runtime) error {
+	rt.Name = Language(normalizeLanguageName(string(rt.Name)))
+	if rt.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidRuntime)
+	}
+	if !strings.Contains(rt.Exec, "{file}") {
+		return fmt.Errorf("%w: %q: exec template must reference {file}", ErrInvalidRuntime, rt.Name)
+	}
+	names := []string{string(rt.Name)}
+	for _, alias := range rt.Aliases {
+		alias = normalizeLanguageName(alias)
+		if alias == "" {
+			return fmt.Errorf("%w: %q: empty alias", ErrInvalidRuntime, rt.Name)
+		}
+		names = append(names, alias)
+	}
+	rt.Aliases = names[1:]
+	rt.Extensions = slices.Clone(rt.Extensions)
+	for i, ext := range rt.Extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			return fmt.Errorf("%w: %q: empty extension", ErrInvalidRuntime, rt.Name)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		rt.Extensions[i] = ext
+	}
+
+	languageRegistry.Lock()
+	defer languageRegistry.Unlock()
+	for _, name := range names {
+		if _, ok := languageRegistry.byName[name]; ok {
+			return fmt.Errorf("%w: %q", ErrLanguageAlreadyRegistered, name)
+		}
+	}
+	for _, ext := range rt.Extensions {
+		if _, ok := languageRegistry.byExtension[ext]; ok {
+			return fmt.Errorf("%w: extension %q", ErrLanguageAlreadyRegistered, ext)
+		}
+	}
+	entry := &languageRuntime{Runtime: rt}
+	for _, name := range names {
+		languageRegistry.byName[name] = entry
+	}
+	for _, ext := range rt.Extensions {
+		languageRegistry.byExtension[ext] = entry
+	}
+	return nil
+}
+
+// LookupLanguage returns the runtime registered under name or one of its aliases.
+func LookupLanguage(name string) (Runtime, bool) {
+	rt, err := lookupLanguage(name)
+	if err != nil {
+		return Runtime{}, false
 	}
+	runtime := rt.Runtime
+	runtime.Aliases = slices.Clone(runtime.Aliases)
+	runtime.Extensions = slices.Clone(runtime.Extensions)
+	return runtime, true
 }
 
-func (p progLang) DefaultImage() string {
-	switch p {
-	case langPython:
-		return "microsandbox/python"
-	case langNodeJs:
-		return "microsandbox/node"
-	default:
-		panic(ErrUnknownLanguage)
+func lookupLanguage(name string) (*languageRuntime, error) {
+	languageRegistry.RLock()
+	rt, ok := languageRegistry.byName[normalizeLanguageName(name)]
+	languageRegistry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownLanguage, name)
+	}
+	return rt, nil
+}
+
+func normalizeLanguageName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// canonicalLanguage normalizes the accepted spellings of a language name.
+func canonicalLanguage(name Language) (Language, error) {
+	rt, err := lookupLanguage(string(name))
+	if err != nil {
+		return "", err
+	}
+	return rt.Name, nil
+}
+
+// resolveLanguage picks the runtime for a single execution. An empty requested language
+// selects the sandbox's own language; anything else must be enabled via WithLanguages, and
+// installed in the guest as far as the sandbox's capabilities tell.
+func (b *baseMicroSandbox) resolveLanguage(ctx context.Context, primary Language, requested Language) (*languageRuntime, error) {
+	if requested == "" {
+		requested = primary
+	}
+	rt, err := lookupLanguage(string(requested))
+	if err != nil {
+		return nil, err
+	}
+	if rt.Name == primary {
+		return rt, nil
+	}
+	if !slices.Contains(b.config().languages, rt.Name) {
+		return nil, fmt.Errorf("%w: %q", ErrLanguageNotEnabled, requested)
+	}
+	// The sandbox's own language is not checked, its image being chosen for it. If detection
+	// fails, the execution reports the missing runtime itself
+	if caps, err := (capabilityReporter{b, primary}).Capabilities(ctx); err == nil && !slices.Contains(caps.Languages, rt.Name) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedLanguage, rt.Name)
 	}
+	return rt, nil
 }
 
 // Language-related errors
 var (
-	ErrUnknownLanguage = errors.New("unknown language")
+	ErrUnknownLanguage           = errors.New("unknown language")
+	ErrLanguageNotEnabled        = errors.New("language not enabled for this sandbox")
+	ErrInvalidRuntime            = errors.New("invalid language runtime")
+	ErrLanguageAlreadyRegistered = errors.New("language already registered")
+	ErrNoDefaultImage            = errors.New("no default image for language; pass one to Start or use WithImage")
 )
@@ -0,0 +1,50 @@
+package msb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+	"github.com/keithang/microsandbox/sdk/go/msbtest"
+)
+
+func TestNewSandboxWithLanguage(t *testing.T) {
+	tests := []struct {
+		lang msb.Language
+		want msb.Language // the language code runs in
+	}{
+		{msb.LanguagePython, msb.LanguagePython},
+		{msb.LanguageNodeJS, msb.LanguageNodeJS},
+		{msb.LanguageBash, msb.LanguageBash},
+		{"javascript", msb.LanguageNodeJS},
+		{"Py", msb.LanguagePython},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(string(tt.lang), func(t *testing.T) {
+			// The fake creates its sandbox with NewSandboxWithLanguage
+			sandbox := msbtest.NewFakeSandbox(tt.lang)
+			if err := sandbox.Start(ctx, "img", 0, 0); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			exec, err := sandbox.Code().Run(ctx, "1")
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := exec.GetLanguage(); got != string(tt.want) {
+				t.Errorf("code ran in %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		sandbox, err := msb.NewSandboxWithLanguage("cobol", msb.WithApiKey("key"))
+		if !errors.Is(err, msb.ErrUnknownLanguage) {
+			t.Errorf("error = %v, want ErrUnknownLanguage", err)
+		}
+		if sandbox != nil {
+			t.Errorf("sandbox = %v, want nil", sandbox)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package msb
+
+import (
+	"context"
+	"time"
+)
+
+// StartEvent describes a call to Start, for the hooks registered with [WithOnStart].
+type StartEvent struct {
+	Sandbox   string
+	Namespace string
+	Image     string        // Image requested, defaults applied
+	MemoryMB  int           // Memory requested, defaults applied
+	CPUs      int           // CPUs requested, defaults applied
+	Duration  time.Duration // Time Start took
+	Err       error         // Error returned by Start, if any
+}
+
+// StopEvent describes a call to Stop, for the hooks registered with [WithOnStop].
+type StopEvent struct {
+	Sandbox   string
+	Namespace string
+	Uptime    time.Duration // Time since the sandbox started; zero if it was not started
+	Duration  time.Duration // Time Stop took
+	Err       error         // Error returned by Stop, if any
+}
+
+// ExecutionEvent describes a finished execution, for the hooks registered with [WithOnExecution].
+type ExecutionEvent struct {
+	Sandbox   string
+	Namespace string
+	Language  Language // Language of a code execution; empty for commands
+	Code      string   // Code run; empty for commands
+	Command   string   // Command run; empty for code executions
+	Args      []string // Command arguments
+	Started   time.Time
+	Duration  time.Duration
+	Err       error // Error returned by the execution, if any
+}
+
+// WithOnStart registers a hook called after every Start, successful or not, e.g. to write an
+// audit log. Hooks are called synchronously, in the order they are registered, and should
+// return quickly.
+func WithOnStart(hook func(ctx context.Context, ev StartEvent)) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.onStart = append(msb.cfg.onStart, hook)
+	}
+}
+
+// WithOnStop registers a hook called after every Stop, successful or not, like [WithOnStart].
+func WithOnStop(hook func(ctx context.Context, ev StopEvent)) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.onStop = append(msb.cfg.onStop, hook)
+	}
+}
+
+// WithOnExecution registers a hook called after every Code().Run and Command().Run, including
+// failed ones, e.g. to record billing per execution without wrapping every call site. Unlike
+// [WithPostExec] hooks, it cannot fail the execution.
+func WithOnExecution(hook func(ctx context.Context, ev ExecutionEvent)) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.onExecution = append(msb.cfg.onExecution, hook)
+	}
+}
+
+// notifyStart calls the WithOnStart hooks.
+func (b *baseMicroSandbox) notifyStart(ctx context.Context, ev StartEvent) {
+	cfg := b.config()
+	ev.Sandbox, ev.Namespace = cfg.name, cfg.namespace
+	for _, hook := range cfg.onStart {
+		hook(ctx, ev)
+	}
+}
+
+// notifyStop calls the WithOnStop hooks.
+func (b *baseMicroSandbox) notifyStop(ctx context.Context, ev StopEvent) {
+	cfg := b.config()
+	ev.Sandbox, ev.Namespace = cfg.name, cfg.namespace
+	for _, hook := range cfg.onStop {
+		hook(ctx, ev)
+	}
+}
+
+// notifyExecution calls the WithOnExecution hooks.
+func (b *baseMicroSandbox) notifyExecution(ctx context.Context, ev ExecutionEvent) {
+	cfg := b.config()
+	ev.Sandbox, ev.Namespace = cfg.name, cfg.namespace
+	for _, hook := range cfg.onExecution {
+		hook(ctx, ev)
+	}
+}
@@ -0,0 +1,334 @@
+package msb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CodeChecker validates and formats code with the language's standard tooling inside the sandbox,
+// so code can be checked before it is executed.
+type CodeChecker interface {
+	// Lint runs the language's linter over code and returns its diagnostics: ruff for Python,
+	// eslint for Node.js and shellcheck for bash. By default code is treated as the sandbox's
+	// language; see [WithLanguage]. The linter must be installed in the image.
+	Lint(ctx context.Context, code string, opts ...RunOption) ([]Diagnostic, error)
+	// Format returns code formatted with ruff (Python), prettier (Node.js) or shfmt (bash).
+	Format(ctx context.Context, code string, opts ...RunOption) (string, error)
+	// ValidateCode checks that code parses, without executing it, and returns the syntax errors
+	// found, if any, as diagnostics of severity "error". It relies on the interpreter alone
+	// (compile for Python, node --check for Node.js and bash -n for bash), so it is cheaper than
+	// a run and needs no linter in the image. Python and node stop at the first error; bash
+	// reports no columns.
+	ValidateCode(ctx context.Context, code string, opts ...RunOption) ([]Diagnostic, error)
+}
+
+// Diagnostic is a single finding reported by a linter.
+type Diagnostic struct {
+	Line     int
+	Column   int
+	Severity string // "error" or "warning"
+	Rule     string // Linter rule identifier, e.g. "F401"; empty for syntax errors
+	Message  string
+}
+
+type codeChecker struct {
+	b *baseMicroSandbox
+	l Language
+}
+
+func (cc codeChecker) Lint(ctx context.Context, code string, opts ...RunOption) ([]Diagnostic, error) {
+	rt, err := cc.b.resolveLanguage(ctx, cc.l, newRunConfig(opts).language)
+	if err != nil {
+		return nil, err
+	}
+	lang := rt.Name
+	var tool string
+	switch lang {
+	case LanguagePython:
+		tool = `ruff check --no-cache --exit-zero --output-format=json --stdin-filename snippet.py -`
+	case LanguageNodeJS:
+		tool = `npx --no-install eslint --format json --stdin --stdin-filename snippet.js`
+	case LanguageBash:
+		tool = `shellcheck --format=json -`
+	default:
+		return nil, fmt.Errorf("%w: no linter for %q", ErrFailedToLint, lang)
+	}
+	exec, err := cc.b.runShell(ctx, pipeCode(code, tool))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToLint, err)
+	}
+	out, err := exec.GetOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToLint, err)
+	}
+	// Linters exit 1 when they report findings; anything else without a report is a tool failure
+	if strings.TrimSpace(out) == "" || exec.GetExitCode() > 1 {
+		stderr, _ := exec.GetError()
+		return nil, fmt.Errorf("%w: exit code %d: %s", ErrFailedToLint, exec.GetExitCode(), strings.TrimSpace(stderr))
+	}
+
+	var diags []Diagnostic
+	switch lang {
+	case LanguagePython:
+		diags, err = parseRuffDiagnostics(out)
+	case LanguageNodeJS:
+		diags, err = parseESLintDiagnostics(out)
+	case LanguageBash:
+		diags, err = parseShellCheckDiagnostics(out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToLint, err)
+	}
+	return diags, nil
+}
+
+func (cc codeChecker) Format(ctx context.Context, code string, opts ...RunOption) (string, error) {
+	rt, err := cc.b.resolveLanguage(ctx, cc.l, newRunConfig(opts).language)
+	if err != nil {
+		return "", err
+	}
+	lang := rt.Name
+	var tool string
+	switch lang {
+	case LanguagePython:
+		tool = `ruff format --no-cache --stdin-filename snippet.py -`
+	case LanguageNodeJS:
+		tool = `npx --no-install prettier --stdin-filepath snippet.js`
+	case LanguageBash:
+		tool = `shfmt -`
+	default:
+		return "", fmt.Errorf("%w: no formatter for %q", ErrFailedToFormat, lang)
+	}
+	// Formatted code travels base64-encoded so that whitespace survives the line-oriented RPC
+	exec, err := cc.b.runShell(ctx, `f=$(mktemp) || exit 1
+if `+pipeCode(code, tool)+` >"$f"; then base64 <"$f" | tr -d '\n'; rm -f "$f"; else s=$?; rm -f "$f"; exit $s; fi`)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrFailedToFormat, err)
+	}
+	if !exec.IsSuccess() {
+		stderr, _ := exec.GetError()
+		return "", fmt.Errorf("%w: %s", ErrFailedToFormat, strings.TrimSpace(stderr))
+	}
+	out, err := exec.GetOutput()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrFailedToFormat, err)
+	}
+	formatted, err := base64.StdEncoding.DecodeString(strings.TrimSpace(out))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrFailedToFormat, err)
+	}
+	return string(formatted), nil
+}
+
+// pythonValidateScript compiles the code on stdin and prints its syntax error as JSON.
+const pythonValidateScript = `import json, sys
+try:
+    compile(sys.stdin.read(), "snippet.py", "exec")
+except SyntaxError as e:
+    print(json.dumps({"line": e.lineno or 0, "column": e.offset or 0, "message": e.msg}))
+    sys.exit(1)`
+
+func (cc codeChecker) ValidateCode(ctx context.Context, code string, opts ...RunOption) ([]Diagnostic, error) {
+	rt, err := cc.b.resolveLanguage(ctx, cc.l, newRunConfig(opts).language)
+	if err != nil {
+		return nil, err
+	}
+	lang := rt.Name
+	// Interpreters exit with syntaxExit after reporting syntax errors
+	var (
+		script     string
+		syntaxExit = 1
+	)
+	switch lang {
+	case LanguagePython:
+		script = pipeCode(code, `"$(command -v python3 || command -v python)" -c `+shellQuote(pythonValidateScript))
+	case LanguageNodeJS:
+		// node only checks files, whose name prefixes its error reports
+		script = `d=$(mktemp -d) || exit 3
+` + pipeCode(code, `cat >"$d/snippet.js"`) + `
+node --check "$d/snippet.js"; s=$?; rm -rf "$d"; exit $s`
+	case LanguageBash:
+		script = pipeCode(code, `bash -n`)
+		syntaxExit = 2
+	default:
+		return nil, fmt.Errorf("%w: no validator for %q", ErrFailedToValidate, lang)
+	}
+	exec, err := cc.b.runShell(ctx, script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToValidate, err)
+	}
+	if exec.IsSuccess() {
+		return []Diagnostic{}, nil
+	}
+	out, _ := exec.GetOutput()
+	stderr, _ := exec.GetError()
+	// Any other exit, or one without a report, is a tool failure
+	var diags []Diagnostic
+	if exec.GetExitCode() == syntaxExit {
+		switch lang {
+		case LanguagePython:
+			diags = parsePythonSyntaxError(out)
+		case LanguageNodeJS:
+			diags = parseNodeSyntaxError(stderr)
+		case LanguageBash:
+			diags = parseBashSyntaxErrors(stderr)
+		}
+	}
+	if len(diags) == 0 {
+		return nil, fmt.Errorf("%w: exit code %d: %s", ErrFailedToValidate, exec.GetExitCode(), strings.TrimSpace(stderr))
+	}
+	return diags, nil
+}
+
+// pipeCode builds a script that feeds code to tool's stdin.
+func pipeCode(code, tool string) string {
+	return `printf '%s' ` + base64.StdEncoding.EncodeToString([]byte(code)) + ` | base64 -d | ` + tool
+}
+
+// --- diagnostic parsers ---
+
+func parseRuffDiagnostics(raw string) ([]Diagnostic, error) {
+	var findings []struct {
+		Code     *string `json:"code"`
+		Message  string  `json:"message"`
+		Location struct {
+			Row    int `json:"row"`
+			Column int `json:"column"`
+		} `json:"location"`
+	}
+	if err := json.Unmarshal([]byte(raw), &findings); err != nil {
+		return nil, err
+	}
+	diags := make([]Diagnostic, 0, len(findings))
+	for _, f := range findings {
+		d := Diagnostic{Line: f.Location.Row, Column: f.Location.Column, Severity: "warning", Message: f.Message}
+		if f.Code == nil {
+			d.Severity = "error" // ruff reports syntax errors without a rule code
+		} else {
+			d.Rule = *f.Code
+		}
+		diags = append(diags, d)
+	}
+	return diags, nil
+}
+
+func parseESLintDiagnostics(raw string) ([]Diagnostic, error) {
+	var files []struct {
+		Messages []struct {
+			RuleID   *string `json:"ruleId"`
+			Severity int     `json:"severity"`
+			Message  string  `json:"message"`
+			Line     int     `json:"line"`
+			Column   int     `json:"column"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(raw), &files); err != nil {
+		return nil, err
+	}
+	var diags []Diagnostic
+	for _, f := range files {
+		for _, m := range f.Messages {
+			d := Diagnostic{Line: m.Line, Column: m.Column, Severity: "warning", Message: m.Message}
+			if m.Severity == 2 {
+				d.Severity = "error"
+			}
+			if m.RuleID != nil {
+				d.Rule = *m.RuleID
+			}
+			diags = append(diags, d)
+		}
+	}
+	return diags, nil
+}
+
+func parseShellCheckDiagnostics(raw string) ([]Diagnostic, error) {
+	var findings []struct {
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Level   string `json:"level"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &findings); err != nil {
+		return nil, err
+	}
+	diags := make([]Diagnostic, 0, len(findings))
+	for _, f := range findings {
+		d := Diagnostic{Line: f.Line, Column: f.Column, Severity: "warning", Rule: "SC" + strconv.Itoa(f.Code), Message: f.Message}
+		if f.Level == "error" {
+			d.Severity = "error"
+		}
+		diags = append(diags, d)
+	}
+	return diags, nil
+}
+
+func parsePythonSyntaxError(raw string) []Diagnostic {
+	var e struct {
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &e); err != nil {
+		return nil
+	}
+	return []Diagnostic{{Line: e.Line, Column: e.Column, Severity: "error", Message: e.Message}}
+}
+
+// parseNodeSyntaxError parses node's report of a syntax error:
+//
+//	/tmp/tmp.x/snippet.js:3
+//	foo(1, 2
+//	    ^
+//
+//	SyntaxError: missing ) after argument list
+func parseNodeSyntaxError(raw string) []Diagnostic {
+	lines := strings.Split(raw, "\n")
+	d := Diagnostic{Severity: "error"}
+	for i, line := range lines {
+		if _, n, ok := strings.Cut(line, "snippet.js:"); ok && d.Line == 0 {
+			d.Line, _ = strconv.Atoi(strings.TrimSpace(n))
+			if i+2 < len(lines) {
+				if col := strings.IndexByte(lines[i+2], '^'); col >= 0 {
+					d.Column = col + 1
+				}
+			}
+		}
+		if msg, ok := strings.CutPrefix(line, "SyntaxError: "); ok {
+			d.Message = msg
+			return []Diagnostic{d}
+		}
+	}
+	return nil
+}
+
+// parseBashSyntaxErrors parses the errors of bash -n, "bash: line 3: syntax error near
+// unexpected token `fi'", each followed by the offending line quoted, which is skipped.
+func parseBashSyntaxErrors(raw string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(raw, "\n") {
+		_, rest, ok := strings.Cut(line, "line ")
+		if !ok {
+			continue
+		}
+		n, msg, ok := strings.Cut(rest, ": ")
+		lineNo, err := strconv.Atoi(n)
+		if !ok || err != nil || strings.HasPrefix(msg, "`") {
+			continue
+		}
+		diags = append(diags, Diagnostic{Line: lineNo, Severity: "error", Message: msg})
+	}
+	return diags
+}
+
+// Lint-related errors
+var (
+	ErrFailedToLint     = errors.New("failed to lint code")
+	ErrFailedToFormat   = errors.New("failed to format code")
+	ErrFailedToValidate = errors.New("failed to validate code")
+)
@@ -0,0 +1,163 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogTailer reads the sandbox's system log.
+type LogTailer interface {
+	// TailLogs calls fn with the last lines of the guest's kernel log, oldest first, and, if
+	// opts.Follow is set, with each further line as it is logged, until ctx is done. The kernel
+	// log holds the VM's boot messages, OOM killer reports and other kernel errors, which explain
+	// executions failing with [ErrFailedToRunCode] or a killed process:
+	//
+	//	err := sandbox.TailLogs(ctx, msb.LogTailOptions{Lines: 20}, func(line msb.LogLine) {
+	//		fmt.Printf("[%8.3f] %s\n", line.Time.Seconds(), line.Text)
+	//	})
+	//
+	// The logs of the microVM's supervisor stay on the server's host, which has no API to read
+	// them. Following polls the guest every second.
+	TailLogs(ctx context.Context, opts LogTailOptions, fn func(LogLine)) error
+}
+
+// LogTailOptions configures [LogTailer.TailLogs].
+type LogTailOptions struct {
+	Lines  int  // Lines of history to deliver first; 0 means 100, negative means none
+	Follow bool // Keep delivering new lines until ctx is done
+}
+
+// LogLine is a line of the guest's kernel log.
+type LogLine struct {
+	Time  time.Duration // Time since the guest booted; zero if the kernel does not record it
+	Level int           // Syslog severity, from 0 (emergency) to 7 (debug); -1 if not reported
+	Text  string
+}
+
+// defaultLogTailLines is how many lines of history TailLogs delivers when opts.Lines is 0.
+const defaultLogTailLines = 100
+
+// logFollowLines bounds the lines read by each poll while following, which is how many lines
+// the guest may log within an interval without any being missed.
+const logFollowLines = 1000
+
+// logTailPollInterval is how often a followed log is polled.
+const logTailPollInterval = time.Second
+
+type logTailer struct {
+	b *baseMicroSandbox
+}
+
+func (t logTailer) TailLogs(ctx context.Context, opts LogTailOptions, fn func(LogLine)) error {
+	if t.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	lines := opts.Lines
+	if lines == 0 {
+		lines = defaultLogTailLines
+	}
+	history, err := t.read(ctx, max(lines, 1))
+	if err != nil {
+		return err
+	}
+	// Lines are told apart by their time, and by their rank among the lines logged at that time
+	var (
+		last time.Duration
+		seen int
+	)
+	if len(history) > 0 {
+		last = history[len(history)-1].Time
+	}
+	for _, line := range history {
+		if line.Time == last {
+			seen++
+		}
+		if opts.Lines >= 0 {
+			fn(line)
+		}
+	}
+	if !opts.Follow {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(logTailPollInterval):
+		}
+		batch, err := t.read(ctx, logFollowLines)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		rank := 0
+		for _, line := range batch {
+			switch {
+			case line.Time < last:
+				continue
+			case line.Time == last:
+				if rank++; rank <= seen {
+					continue
+				}
+				seen++
+			default:
+				last, seen, rank = line.Time, 1, 1
+			}
+			fn(line)
+		}
+	}
+}
+
+// read returns the last n lines of the kernel log. dmesg -r keeps the priority of each line;
+// builds of dmesg without it print the lines plainly.
+func (t logTailer) read(ctx context.Context, n int) ([]LogLine, error) {
+	exec, err := t.b.runShell(ctx, `{ dmesg -r 2>/dev/null || dmesg; } | tail -n `+strconv.Itoa(n))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToTailLogs, err)
+	}
+	if !exec.IsSuccess() {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToTailLogs, commandStderr(exec))
+	}
+	out, _ := exec.GetOutput()
+	var lines []LogLine
+	for _, text := range strings.Split(out, "\n") {
+		if text != "" {
+			lines = append(lines, parseLogLine(text))
+		}
+	}
+	return lines, nil
+}
+
+// parseLogLine parses a line printed by dmesg, "<6>[    1.234567] text", whose priority and
+// time are both optional.
+func parseLogLine(text string) LogLine {
+	line := LogLine{Level: -1}
+	if rest, ok := strings.CutPrefix(text, "<"); ok {
+		if pri, after, ok := strings.Cut(rest, ">"); ok {
+			if n, err := strconv.Atoi(pri); err == nil {
+				line.Level = n & 7 // The facility is in the upper bits
+				text = after
+			}
+		}
+	}
+	if rest, ok := strings.CutPrefix(text, "["); ok {
+		if stamp, after, ok := strings.Cut(rest, "]"); ok {
+			if secs, err := strconv.ParseFloat(strings.TrimSpace(stamp), 64); err == nil {
+				line.Time = time.Duration(secs * float64(time.Second)).Round(time.Microsecond)
+				text = strings.TrimPrefix(after, " ")
+			}
+		}
+	}
+	line.Text = text
+	return line
+}
+
+// Log-related errors
+var ErrFailedToTailLogs = errors.New("failed to tail logs")
@@ -0,0 +1,66 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaintenanceError reports that the server is draining or down for maintenance, signalled by
+// HTTP 503 Service Unavailable. It matches [ErrServerMaintenance] and the underlying [*RPCError]
+// via errors.Is and errors.As, so callers can move work to another server instead of retrying.
+type MaintenanceError struct {
+	Method     string
+	RetryAfter time.Duration // From the Retry-After header; zero if the server gave no estimate
+	Err        *RPCError
+}
+
+func (e *MaintenanceError) Error() string {
+	msg := fmt.Sprintf("%s: %s", ErrServerMaintenance, e.Method)
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(": retry after %s", e.RetryAfter)
+	}
+	if e.Err != nil && e.Err.Message != "" {
+		msg += ": " + e.Err.Message
+	}
+	return msg
+}
+
+func (e *MaintenanceError) Unwrap() []error {
+	return []error{ErrServerMaintenance, e.Err}
+}
+
+// MaintenanceHandler is notified whenever an RPC hits a server in maintenance, before the error
+// is returned to the caller. It is called synchronously, so it should return quickly.
+type MaintenanceHandler func(sandbox string, err *MaintenanceError)
+
+// newMaintenanceError builds the error for a 503 response, or returns nil for any other status.
+func newMaintenanceError(method rpcMethod, resp *http.Response, rpcErr *RPCError) *MaintenanceError {
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		return nil
+	}
+	return &MaintenanceError{
+		Method:     string(method),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Err:        rpcErr,
+	}
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header: delay-seconds and an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// ErrServerMaintenance matches errors caused by the server draining or being under maintenance.
+var ErrServerMaintenance = errors.New("server in maintenance")
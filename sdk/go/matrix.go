@@ -0,0 +1,162 @@
+package msb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MatrixEntry is one runtime of a [RunMatrix], e.g. a Python version, given by its image.
+type MatrixEntry struct {
+	// Name labels the entry in the report. Defaults to the image.
+	Name string
+	// NewSandbox creates the sandbox. Defaults to [NewPythonSandbox].
+	NewSandbox func(options ...Option) LangSandBox
+	// Options configure the sandbox.
+	Options []Option
+	// Image, MemoryMB and CPUs are passed to Start.
+	Image    string
+	MemoryMB int
+	CPUs     int
+}
+
+// MatrixResult is the outcome of running the code on one entry of a matrix.
+type MatrixResult struct {
+	Entry string
+	// Execution is the result of the run; it is zero if the sandbox failed to start.
+	Execution CodeExecution
+	// Err is why the sandbox failed to start or the code failed to run.
+	Err      error
+	Duration time.Duration
+}
+
+// outcome summarizes the result for comparison with the other entries.
+func (r MatrixResult) outcome() string {
+	if r.Err != nil {
+		return "error: " + r.Err.Error()
+	}
+	stdout, _ := r.Execution.GetOutput()
+	stderr, _ := r.Execution.GetError()
+	return fmt.Sprintf("%s\x00%t\x00%s\x00%s", r.Execution.GetStatus(), r.Execution.HasError(), stdout, stderr)
+}
+
+// MatrixReport compares the results of a [RunMatrix], in the order of its entries.
+type MatrixReport struct {
+	Results []MatrixResult
+}
+
+// Consistent reports whether every entry ran the code successfully with the same output.
+func (r *MatrixReport) Consistent() bool {
+	for _, res := range r.Results {
+		if res.Err != nil || res.Execution.HasError() || res.outcome() != r.Results[0].outcome() {
+			return false
+		}
+	}
+	return true
+}
+
+// Groups partitions the entries by outcome: entries in the same group succeeded or failed alike,
+// with the same output. Groups are ordered by their first entry.
+func (r *MatrixReport) Groups() [][]string {
+	var groups [][]string
+	index := make(map[string]int)
+	for _, res := range r.Results {
+		outcome := res.outcome()
+		i, ok := index[outcome]
+		if !ok {
+			i = len(groups)
+			index[outcome] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], res.Entry)
+	}
+	return groups
+}
+
+// String renders the report as a table with one line per entry, giving its status, its duration
+// and the first line of its output, followed by the groups of entries with the same outcome.
+func (r *MatrixReport) String() string {
+	width := 0
+	for _, res := range r.Results {
+		width = max(width, len(res.Entry))
+	}
+	var out strings.Builder
+	for _, res := range r.Results {
+		status, detail := "error", ""
+		switch {
+		case res.Err != nil:
+			detail = res.Err.Error()
+		case res.Execution.HasError():
+			detail, _ = res.Execution.GetError()
+		default:
+			status = "ok"
+			detail, _ = res.Execution.GetOutput()
+		}
+		detail, _, _ = strings.Cut(strings.TrimSpace(detail), "\n")
+		fmt.Fprintf(&out, "%-*s  %-5s  %8s  %s\n", width, res.Entry, status, res.Duration.Round(time.Millisecond), detail)
+	}
+	if groups := r.Groups(); len(groups) > 1 {
+		out.WriteString("\noutcomes differ:\n")
+		for _, group := range groups {
+			out.WriteString("  " + strings.Join(group, ", ") + "\n")
+		}
+	}
+	return out.String()
+}
+
+// RunMatrix runs code on a fresh sandbox for every entry, with at most parallelism entries in
+// flight (parallelism <= 0 means unbounded), and reports how the results compare, e.g. to check
+// that generated code behaves the same across Python or Node.js versions. Sandboxes are stopped
+// once the code ran. Failures are reported per entry in the report rather than as an error.
+//
+//	report := msb.RunMatrix(ctx, []msb.MatrixEntry{
+//		{Name: "py3.10", Image: "python:3.10"},
+//		{Name: "py3.12", Image: "python:3.12"},
+//	}, code, 0)
+//	if !report.Consistent() {
+//		fmt.Print(report)
+//	}
+func RunMatrix(ctx context.Context, entries []MatrixEntry, code string, parallelism int, opts ...RunOption) *MatrixReport {
+	report := &MatrixReport{Results: make([]MatrixResult, len(entries))}
+	errs := forEachBounded(ctx, len(entries), parallelism, func(i int) error {
+		report.Results[i] = runMatrixEntry(ctx, entries[i], code, opts)
+		return nil
+	})
+	for i, err := range errs {
+		res := &report.Results[i]
+		if res.Entry == "" {
+			res.Entry = entries[i].label()
+		}
+		if err != nil {
+			res.Err = err
+		}
+	}
+	return report
+}
+
+func (e MatrixEntry) label() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return e.Image
+}
+
+func runMatrixEntry(ctx context.Context, entry MatrixEntry, code string, opts []RunOption) MatrixResult {
+	res := MatrixResult{Entry: entry.label()}
+	newSandbox := entry.NewSandbox
+	if newSandbox == nil {
+		newSandbox = func(options ...Option) LangSandBox { return NewPythonSandbox(options...) }
+	}
+	sb := newSandbox(entry.Options...)
+	if res.Err = sb.Start(ctx, entry.Image, entry.MemoryMB, entry.CPUs); res.Err != nil {
+		return res
+	}
+	defer func() {
+		_ = sb.Stop(context.WithoutCancel(ctx))
+	}()
+	start := time.Now()
+	res.Execution, res.Err = sb.Code().Run(ctx, code, opts...)
+	res.Duration = time.Since(start)
+	return res
+}
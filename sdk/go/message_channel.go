@@ -0,0 +1,134 @@
+package msb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MessageChannelOpener starts processes that exchange framed messages with the client.
+type MessageChannelOpener interface {
+	// OpenMessageChannel starts command in the background and connects its stdin and stdout
+	// to a [MessageChannel]. The process reads one JSON document per line from stdin and
+	// writes one JSON document per line to stdout.
+	OpenMessageChannel(ctx context.Context, command string) (*MessageChannel, error)
+}
+
+// MessageChannel is a bidirectional JSON Lines conversation with a long-running process in the
+// sandbox. Send and Receive may be used concurrently with each other, but each is serialized.
+type MessageChannel struct {
+	proc *guestProcess
+
+	sendMu sync.Mutex
+
+	recvMu    sync.Mutex
+	outOffset int64
+	pending   []byte // received stdout bytes not yet consumed as complete frames
+
+	// PollInterval is how often Receive checks for new output. Defaults to 200ms.
+	PollInterval time.Duration
+}
+
+type messageChannelOpener struct {
+	b *baseMicroSandbox
+}
+
+func (o messageChannelOpener) OpenMessageChannel(ctx context.Context, command string) (*MessageChannel, error) {
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, err
+	}
+	fifo := shellQuote(guestJobsDir + "/" + id + "/in")
+	// A sleeping writer keeps the FIFO open so the process never sees EOF between messages.
+	script := `mkfifo ` + fifo + ` && { sleep 2147483647 >` + fifo + ` & } && exec <` + fifo + `
+` + command
+	proc, err := o.b.spawnWithID(ctx, id, script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToOpenMessageChannel, err)
+	}
+	return &MessageChannel{proc: proc, PollInterval: 200 * time.Millisecond}, nil
+}
+
+// Send marshals v as JSON and writes it as a single line to the process's stdin.
+func (mc *MessageChannel) Send(ctx context.Context, v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMessageChannelSend, err)
+	}
+	msg = append(msg, '\n')
+
+	mc.sendMu.Lock()
+	defer mc.sendMu.Unlock()
+	d := shellQuote(mc.proc.dir())
+	script := `[ -f ` + d + `/exit ] && exit 3
+printf '%s' ` + base64.StdEncoding.EncodeToString(msg) + ` | base64 -d >` + d + `/in`
+	exec, err := mc.proc.b.runShell(ctx, script)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMessageChannelSend, err)
+	}
+	if !exec.IsSuccess() {
+		return ErrMessageChannelClosed
+	}
+	return nil
+}
+
+// Receive blocks until the process writes a complete line to stdout and unmarshals it into v.
+// It returns [ErrMessageChannelClosed] once the process has exited and all output is consumed.
+func (mc *MessageChannel) Receive(ctx context.Context, v any) error {
+	mc.recvMu.Lock()
+	defer mc.recvMu.Unlock()
+
+	for {
+		if i := bytes.IndexByte(mc.pending, '\n'); i >= 0 {
+			frame := mc.pending[:i]
+			mc.pending = mc.pending[i+1:]
+			if len(bytes.TrimSpace(frame)) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(frame, v); err != nil {
+				return fmt.Errorf("%w: %w", ErrMessageChannelReceive, err)
+			}
+			return nil
+		}
+
+		st, err := mc.proc.poll(ctx, mc.outOffset, -1)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrMessageChannelReceive, err)
+		}
+		mc.outOffset += int64(len(st.stdout))
+		mc.pending = append(mc.pending, st.stdout...)
+		if len(st.stdout) > 0 {
+			continue
+		}
+		if !st.running {
+			return ErrMessageChannelClosed
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(mc.PollInterval):
+		}
+	}
+}
+
+// Close terminates the process and removes its bookkeeping from the sandbox.
+func (mc *MessageChannel) Close(ctx context.Context) error {
+	if err := mc.proc.signal(ctx, "KILL"); err != nil {
+		return err
+	}
+	return mc.proc.remove(ctx)
+}
+
+// Message channel errors
+var (
+	ErrFailedToOpenMessageChannel = errors.New("failed to open message channel")
+	ErrMessageChannelSend         = errors.New("failed to send message")
+	ErrMessageChannelReceive      = errors.New("failed to receive message")
+	ErrMessageChannelClosed       = errors.New("message channel closed")
+)
@@ -0,0 +1,41 @@
+package msb_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// newMetricsServer serves sandbox.metrics.get with the given sandbox report.
+func newMetricsServer(t *testing.T, sandbox string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":"1","result":{"sandboxes":[` + sandbox + `]}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestIsRunningWithoutUsage(t *testing.T) {
+	ctx := context.Background()
+	srv := newMetricsServer(t, `{"name":"box","namespace":"default","running":true,"cpu_usage":null,"memory_usage":null,"disk_usage":null}`)
+	sandbox := msb.NewPythonSandbox(msb.WithServerUrl(srv.URL), msb.WithApiKey("key"))
+	if err := sandbox.Attach(ctx, "box"); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+
+	running, err := sandbox.Metrics().IsRunning(ctx)
+	if err != nil {
+		t.Fatalf("IsRunning: %v", err)
+	}
+	if !running {
+		t.Error("IsRunning = false, want true")
+	}
+	if _, err := sandbox.Metrics().All(ctx); !errors.Is(err, msb.ErrCapabilityUnavailable) {
+		t.Errorf("All error = %v, want ErrCapabilityUnavailable", err)
+	}
+}
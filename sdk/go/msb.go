@@ -2,20 +2,22 @@
 //
 // This SDK provides thread-safe access to running microsandbox environments for code execution,
 // command running, and resource monitoring, without imposing any particular concurrency paradigm.
+// Every operation takes a context.Context, so callers can enforce deadlines and cancellation.
 //
 // # Quick Start
 //
 // Create a Python sandbox:
 //
+//	ctx := context.Background()
 //	sandbox := msb.NewPythonSandbox(msb.WithName("my-sandbox"))
-//	if err := sandbox.Start("", 512, 1); err != nil {
+//	if err := sandbox.Start(ctx, "", 512, 1); err != nil {
 //		log.Fatal(err)
 //	}
-//	defer sandbox.Stop()
+//	defer sandbox.Stop(ctx)
 //
 // Execute Python code:
 //
-//	execution, err := sandbox.Code().Run("print('Hello World')")
+//	execution, err := sandbox.Code().Run(ctx, "print('Hello World')")
 //	if err != nil {
 //		log.Fatal(err)
 //	}
@@ -24,14 +26,14 @@
 //
 // Run shell commands:
 //
-//	cmdExec, err := sandbox.Command().Run("ls", []string{"-la"})
+//	cmdExec, err := sandbox.Command().Run(ctx, "ls", []string{"-la"})
 //	if err != nil {
 //		log.Fatal(err)
 //	}
 //
 // Monitor resource usage:
 //
-//	metrics, err := sandbox.Metrics().All()
+//	metrics, err := sandbox.Metrics().All(ctx)
 //	if err != nil {
 //		log.Fatal(err)
 //	}
@@ -39,9 +41,11 @@
 package msb
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 )
 
 // Core sandbox interfaces
@@ -49,53 +53,132 @@ type (
 	// Starter manages sandbox lifecycle startup.
 	Starter interface {
 		// Start initializes the sandbox with the specified configuration.
-		// If image is empty, uses the default image for the configured language.
-		// If memoryMB <= 0, defaults to 512. If cpus <= 0, defaults to 1.
-		Start(image string, memoryMB int, cpus int) error
+		// If image is empty, uses [WithImage] or else the default image for the configured language.
+		// If memoryMB <= 0, uses [WithMemoryMB] or else 512. If cpus <= 0, uses [WithCPUs] or else 1.
+		// More than 255 vCPUs fail with [ErrInvalidCPUs].
+		// If ctx is done before the server answers, Start returns ctx's error and the sandbox
+		// stays stopped on the client side, although the server may still bring it up.
+		Start(ctx context.Context, image string, memoryMB int, cpus int) error
 	}
 
 	// Stopper manages sandbox lifecycle shutdown.
 	Stopper interface {
-		// Stop terminates the sandbox and releases its resources.
-		Stop() error
+		// Stop terminates the sandbox and releases its resources, like ForceStop.
+		Stop(ctx context.Context) error
+		// StopGraceful sends SIGTERM to the guest's processes, in-flight executions included, and
+		// waits up to grace for them to exit and for this client's executions to return before
+		// tearing the sandbox down, which kills whatever is still running. New executions fail
+		// with [ErrSandboxNotStarted] as soon as it is called. If the processes cannot be
+		// signalled, the sandbox is stopped at once and the result is not Graceful.
+		StopGraceful(ctx context.Context, grace time.Duration) (StopResult, error)
+		// ForceStop tears the sandbox down at once, killing its processes; in-flight executions
+		// fail with the server's error.
+		ForceStop(ctx context.Context) (StopResult, error)
+	}
+
+	// Reconfigurer mutates sandbox settings at runtime. It is safe to call concurrently with
+	// in-flight operations, which keep the settings they started with.
+	Reconfigurer interface {
+		// SetLogger replaces the logger. A nil logger discards all output.
+		SetLogger(logger Logger)
+		// SetTimeout sets the deadline applied to each RPC. Zero disables it.
+		SetTimeout(timeout time.Duration)
+		// SetCredentials replaces the API key used to authenticate with the server, and any
+		// token provider.
+		SetCredentials(apiKey string)
 	}
 
 	// CodeRunner executes code in the sandbox's REPL environment.
 	CodeRunner interface {
 		// Run executes the provided code and returns detailed execution results.
 		// The sandbox must be started before calling this method.
-		Run(code string) (CodeExecution, error)
+		// By default the code runs in the sandbox's language; see [WithLanguage].
+		// Consecutive runs share the interpreter's global state, so variables defined by one
+		// run are visible to the next, except for runs in their own process, as with
+		// [WithOutputHandler]; use a [Session] for state isolated from other runs.
+		// When the server times the execution out, the error comes with a result whose
+		// GetTerminationReason reports [TerminationTimeout]. Code exceeding the server's request
+		// size limit is transparently uploaded and run from a file; see UploadedAsFile.
+		// If ctx is done before the code finishes, the code is stopped in the sandbox rather
+		// than left running: Python code in the REPL is interrupted with KeyboardInterrupt, and
+		// code running in its own process, as with [WithOutputHandler], is killed. Node.js code
+		// in the REPL cannot be interrupted without killing the REPL, and runs to completion.
+		Run(ctx context.Context, code string, opts ...RunOption) (CodeExecution, error)
+		// RunScriptFile reads the local script at path and runs it like Run, in the language
+		// given by its extension or else its #! line, e.g. "./analysis.py". The language must be
+		// the sandbox's own or enabled with [WithLanguages]; see [Runtime.Extensions] for custom
+		// languages. It fails with [ErrUnknownScriptLanguage] if no language matches.
+		RunScriptFile(ctx context.Context, path string, opts ...RunOption) (CodeExecution, error)
+		// RunAsync starts the code in the background and returns a handle to it right away,
+		// for long runs that should not hold a request open. The code runs in a fresh
+		// interpreter process, like with [WithOutputHandler]; [WithLanguage], [WithExecEnv] and
+		// [WithExecWorkdir] apply.
+		RunAsync(ctx context.Context, code string, opts ...RunOption) (*Job, error)
+		// RunWithProgress runs the code like Run, sending the progress it reports to progress as
+		// it runs, e.g. to drive a progress bar, and closes progress when it returns. The code
+		// reports progress by printing lines of JSON with an optional percent, from 0 to 100,
+		// and message, after the "__msb_progress__:" prefix:
+		//
+		//	print('__msb_progress__:{"percent": 40, "message": "loading rows"}', flush=True)
+		//
+		// Those lines are left out of the result and of [WithOutputHandler], but reach
+		// [WithStdout] writers as is. The code runs in a fresh interpreter process, as with
+		// [WithOutputHandler]. Sending blocks until the report is received, so progress must be
+		// read concurrently or be buffered.
+		RunWithProgress(ctx context.Context, code string, progress chan<- Progress, opts ...RunOption) (CodeExecution, error)
+		// Attach returns a handle to a job started earlier with RunAsync, given its ID. It works
+		// from any client configured with the sandbox's name, namespace and credentials; the
+		// sandbox does not need to have been started by this client. It fails with
+		// [ErrGuestProcessNotFound] if no such job exists or its result was already collected.
+		Attach(ctx context.Context, jobID string) (*Job, error)
 	}
 
 	// CommandRunner executes shell commands in the sandbox.
 	CommandRunner interface {
 		// Run executes a shell command with the given arguments.
 		// The sandbox must be started before calling this method.
-		Run(cmd string, args []string) (CommandExecution, error)
+		// When the server times the command out, the error comes with a result whose
+		// GetTerminationReason reports [TerminationTimeout]; see [WithExecTimeout] to keep
+		// the partial output instead. A command that exits non-zero is no error unless
+		// [WithExitError] is given.
+		Run(ctx context.Context, cmd string, args []string, opts ...RunOption) (CommandExecution, error)
+		// Start launches a command in the background and returns a handle to it without
+		// waiting for it to finish, so several commands can run in the sandbox concurrently.
+		Start(ctx context.Context, cmd string, args []string, opts ...RunOption) (*Process, error)
+		// RunCommands executes cmds one after another in a single round trip to the sandbox and
+		// returns the result of each command that ran. With [StopOnError], it halts at the first
+		// command exiting non-zero, like `set -e`, and reports it with [ErrBatchStopped].
+		RunCommands(ctx context.Context, cmds []CommandSpec, mode BatchMode) ([]CommandExecution, error)
+		// Cmd starts building cmd with the given arguments, which can be extended and piped into
+		// further commands without quoting them by hand; see [CommandBuilder].
+		Cmd(cmd string, args ...string) *CommandBuilder
 	}
 
 	// MetricsReader provides access to sandbox resource metrics.
 	MetricsReader interface {
 		// All returns comprehensive metrics for the sandbox.
-		All() (Metrics, error)
-		// CPU returns current CPU usage as a percentage (0-100).
-		CPU() (float64, error)
+		All(ctx context.Context) (Metrics, error)
+		// CPU returns current CPU usage as a percentage (0-100). CPU, MemoryMiB and DiskBytes
+		// fail with [ErrCapabilityUnavailable] if the server cannot measure the value.
+		CPU(ctx context.Context) (float64, error)
 		// MemoryMiB returns current memory usage in mebibytes.
-		MemoryMiB() (int, error)
+		MemoryMiB(ctx context.Context) (int, error)
 		// DiskBytes returns current disk usage in bytes.
-		DiskBytes() (int, error)
-		// IsRunning reports whether the sandbox is currently running.
-		IsRunning() (bool, error)
+		DiskBytes(ctx context.Context) (int, error)
+		// IsRunning reports whether the sandbox is currently running. Unlike All, it does not
+		// fail with [ErrCapabilityUnavailable] when the server measures no usage.
+		IsRunning(ctx context.Context) (bool, error)
 	}
 
 	// Metrics contains resource usage information for a sandbox.
 	Metrics struct {
-		Name      string  // Sandbox name
-		Namespace string  // Sandbox namespace
-		IsRunning bool    // Whether the sandbox is currently running
-		CPU       float64 // CPU usage percentage (0-100)
-		MemoryMiB int     // Memory usage in mebibytes
-		DiskBytes int     // Disk usage in bytes
+		Name      string        // Sandbox name
+		Namespace string        // Sandbox namespace
+		IsRunning bool          // Whether the sandbox is currently running
+		CPU       float64       // CPU usage percentage (0-100)
+		MemoryMiB int           // Memory usage in mebibytes
+		DiskBytes int           // Disk usage in bytes
+		Uptime    time.Duration // Time since this client started the sandbox; the server does not report it
 	}
 )
 
@@ -105,21 +188,93 @@ type starter struct {
 	b *baseMicroSandbox
 }
 
-func (s starter) Start(image string, memoryMB int, cpus int) error {
-	if s.b.state.Load() == started {
-		return ErrSandboxAlreadyStarted
-	}
+func (s starter) Start(ctx context.Context, image string, memoryMB int, cpus int) error {
+	image = cmp.Or(image, s.b.config().image)
 	if memoryMB <= 0 {
-		memoryMB = 512
+		memoryMB = cmp.Or(s.b.config().memoryMB, 512)
 	}
 	if cpus <= 0 {
-		cpus = 1
+		cpus = cmp.Or(s.b.config().cpus, 1)
+	}
+	begin := time.Now()
+	err := s.start(ctx, image, memoryMB, cpus)
+	if err == nil {
+		s.b.lastStart.Store(&startArgs{image: image, memoryMB: memoryMB, cpus: cpus})
+	}
+	s.b.notifyStart(ctx, StartEvent{Image: image, MemoryMB: memoryMB, CPUs: cpus, Duration: time.Since(begin), Err: err})
+	return err
+}
+
+func (s starter) start(ctx context.Context, image string, memoryMB int, cpus int) error {
+	if cpus < 1 || cpus > maxCPUs {
+		return fmt.Errorf("%w: %d", ErrInvalidCPUs, cpus)
+	}
+	envs, err := s.b.config().startEnv()
+	if err != nil {
+		return err
+	}
+	ports, err := s.b.config().publishedPorts()
+	if err != nil {
+		return err
+	}
+	if err := s.b.config().validateVolumes(); err != nil {
+		return err
+	}
+	for _, probe := range []*Probe{s.b.config().readinessProbe, s.b.config().livenessProbe} {
+		if probe != nil {
+			if err := probe.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	if !s.b.state.CompareAndSwap(off, starting) {
+		if s.b.state.Load() == started {
+			return ErrSandboxAlreadyStarted
+		}
+		return ErrSandboxTransitioning
+	}
+	if s.b.config().serverVersionCheck {
+		if err := s.b.checkServerVersion(ctx); err != nil {
+			s.b.state.Store(off)
+			return fmt.Errorf("%w: %w", ErrFailedToStartSandbox, err)
+		}
 	}
-	err := s.b.rpcClient.startSandbox(context.Background(), &s.b.cfg, image, memoryMB, cpus)
+	progress := newStartProgressReporter(s.b.config().startProgress)
+	progress.report(StartPhaseRequested, "", nil)
+	message, err := s.b.rpcClient.startSandbox(ctx, s.b.config(), image, memoryMB, cpus, envs, ports)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrFailedToStartSandbox, err)
+		s.b.state.Store(off)
+		err = fmt.Errorf("%w: %w", ErrFailedToStartSandbox, volumeError(s.b.config(), s.b.startTimeoutError(ctx, err)))
+		progress.report(StartPhaseFailed, "", err)
+		return err
 	}
 	s.b.state.Store(started)
+	s.b.startedAt.Store(time.Now().UnixNano())
+	s.b.recordActive(1)
+	progress.report(StartPhaseRunning, message, nil)
+	if err := s.b.waitReady(ctx); err != nil {
+		if _, stopErr := (stopper{s.b}).stop(context.WithoutCancel(ctx), 0); stopErr != nil {
+			err = errors.Join(err, stopErr)
+		}
+		err = fmt.Errorf("%w: %w", ErrFailedToStartSandbox, err)
+		progress.report(StartPhaseFailed, "", err)
+		return err
+	}
+	if err := s.b.protectReadOnlyVolumes(ctx); err != nil {
+		// Writable mounts must not be handed out as read-only ones
+		if _, stopErr := (stopper{s.b}).stop(context.WithoutCancel(ctx), 0); stopErr != nil {
+			err = errors.Join(err, stopErr)
+		}
+		err = fmt.Errorf("%w: %w", ErrFailedToStartSandbox, err)
+		progress.report(StartPhaseFailed, "", err)
+		return err
+	}
+	s.b.startProbes()
+	s.b.startIdleWatch()
+	if s.b.config().specSnapshots {
+		s.b.recordSpec(ctx, image, memoryMB, cpus)
+	}
+	progress.report(StartPhaseReady, "", nil)
 	return nil
 }
 
@@ -127,119 +282,346 @@ type stopper struct {
 	b *baseMicroSandbox
 }
 
-func (s stopper) Stop() error {
-	if s.b.state.Load() == off {
-		return ErrSandboxNotStarted
+func (s stopper) Stop(ctx context.Context) error {
+	_, err := s.ForceStop(ctx)
+	return err
+}
+
+func (s stopper) StopGraceful(ctx context.Context, grace time.Duration) (StopResult, error) {
+	return s.notified(ctx, grace)
+}
+
+func (s stopper) ForceStop(ctx context.Context) (StopResult, error) {
+	return s.notified(ctx, 0)
+}
+
+// notified stops the sandbox, giving its processes grace to exit, and calls the stop hooks.
+func (s stopper) notified(ctx context.Context, grace time.Duration) (StopResult, error) {
+	var uptime time.Duration
+	if at := s.b.startedAt.Load(); at != 0 {
+		uptime = time.Since(time.Unix(0, at))
+	}
+	begin := time.Now()
+	res, err := s.stop(ctx, grace)
+	s.b.notifyStop(ctx, StopEvent{Uptime: uptime, Duration: time.Since(begin), Err: err})
+	return res, err
+}
+
+func (s stopper) stop(ctx context.Context, grace time.Duration) (StopResult, error) {
+	if !s.b.state.CompareAndSwap(started, stopping) {
+		if s.b.state.Load() == off {
+			return StopResult{}, ErrSandboxNotStarted
+		}
+		return StopResult{}, ErrSandboxTransitioning
+	}
+	res := StopResult{InFlight: int(s.b.inFlight.Load())}
+	s.b.stopProbes()
+	s.b.stopIdleWatch()
+	if grace > 0 {
+		s.terminate(ctx, grace, &res)
 	}
-	ctx := context.Background()
-	err := s.b.rpcClient.stopSandbox(ctx, &s.b.cfg)
+	err := s.b.rpcClient.stopSandbox(ctx, s.b.config())
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrFailedToStopSandbox, err)
+		s.b.state.Store(started)
+		s.b.startProbes()
+		s.b.startIdleWatch()
+		return res, fmt.Errorf("%w: %w", ErrFailedToStopSandbox, err)
 	}
-	s.b.state.Store(off)
-	return nil
+	s.b.release()
+	return res, nil
+}
+
+// release forgets the state of the sandbox this client was bound to, once it stopped or was
+// detached.
+func (b *baseMicroSandbox) release() {
+	b.state.Store(off)
+	b.recordActive(-1)
+	b.spec.Store(nil)
+	b.startedAt.Store(0)
+	b.capabilities.Store(nil)
+	b.serverInfo.Store(nil)
+	b.replWorkdirs.Clear()
+	b.sharedDirState.Store(sharedDirUnknown)
 }
 
 type codeRunner struct {
 	b *baseMicroSandbox
-	l progLang
+	l Language
 }
 
-func (cr codeRunner) Run(code string) (CodeExecution, error) {
+func (cr codeRunner) Run(ctx context.Context, code string, opts ...RunOption) (CodeExecution, error) {
 	if cr.b.state.Load() != started {
 		return CodeExecution{}, ErrSandboxNotStarted
 	}
-	ctx := context.Background()
-	result, err := cr.b.rpcClient.runRepl(ctx, &cr.b.cfg, cr.l, code)
+	rc := newRunConfig(opts)
+	rt, err := cr.b.resolveLanguage(ctx, cr.l, rc.language)
 	if err != nil {
-		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+		return CodeExecution{}, err
+	}
+	execute := func() (CodeExecution, error) {
+		release, err := cr.b.waitExecSlot(ctx)
+		if err != nil {
+			return CodeExecution{}, err
+		}
+		defer release()
+		return withExecHooks(ctx, cr.b, ExecEvent{Language: rt.Name, Code: code, Tags: rc.tags}, func() (CodeExecution, error) {
+			limit := cr.b.maxOutputBytes(rc)
+			if rc.polled() || len(rc.env) > 0 || rc.workdir != "" || rc.resourceUsage || (limit > 0 && !rt.repl) {
+				return cr.runStreamed(ctx, rt, code, rc)
+			}
+			exec, err := cr.run(ctx, rt, code)
+			if isPayloadTooLarge(err) {
+				cr.b.logger().Info("Code too large to send inline, uploading it", "sandbox", cr.b.name(), "bytes", len(code))
+				exec, err = cr.runFromFile(ctx, rt, code)
+			}
+			return exec.withCappedOutput(limit), err
+		})
+	}
+	if key := rc.journalKey; key != "" && cr.b.config().journal != nil {
+		run := execute
+		execute = func() (CodeExecution, error) {
+			return journaled(cr.b, key, journalCode, run, codeExecutionFromJournal, journalCodeResult)
+		}
+	}
+	var exec CodeExecution
+	if cr.b.dedupe != nil && rc.onOutput == nil && rc.stdout == nil && rc.stderr == nil && rc.progress == nil {
+		exec, err = cr.b.dedupe.do(ctx, dedupeKey(rt.Name, code, rc), execute)
+	} else {
+		exec, err = execute()
+	}
+	exec.spec = cr.b.spec.Load()
+	if !exec.deduped && !exec.replayed {
+		cr.b.emitExecutionOutput(ctx, exec.parsed.OutputLines)
+	}
+	return exec, err
+}
+
+func (cr codeRunner) run(ctx context.Context, rt *languageRuntime, code string) (CodeExecution, error) {
+	if rt.shell {
+		cmdExec, err := cr.b.runShell(ctx, workdirPrelude(cr.b.config().workdir)+code)
+		if err != nil {
+			return terminatedCodeExecution(rt.Name, err), fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+		}
+		return checkParsed(cr.b, codeExecutionFromCommand(cmdExec, rt.Name), ErrFailedToRunCode)
+	}
+	if !rt.repl {
+		return cr.runExec(ctx, rt, code, runConfig{})
 	}
 
-	exec := CodeExecution{Output: result.output}
-	// Parse the output for convenience methods
-	if err := json.Unmarshal(result.output, &exec.parsed); err == nil {
-		exec.parsedOK = true
+	if err := cr.b.moveReplToWorkdir(ctx, rt); err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	if rt.interrupt != "" {
+		stop := context.AfterFunc(ctx, func() { cr.interrupt(context.WithoutCancel(ctx), rt) })
+		defer stop()
+	}
+	result, err := cr.b.rpcClient.runRepl(ctx, cr.b.config(), rt.Name, code)
+	if err != nil {
+		return terminatedCodeExecution(rt.Name, err), fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
 	}
 
-	return exec, nil
+	return checkParsed(cr.b, NewCodeExecution(result.output), ErrFailedToRunCode)
+}
+
+// replInterruptTimeout bounds the request interrupting the REPL after a run is abandoned.
+const replInterruptTimeout = 10 * time.Second
+
+// interrupt stops the code still running in the REPL after its caller gave up on it, so the
+// interpreter is not left busy.
+func (cr codeRunner) interrupt(ctx context.Context, rt *languageRuntime) {
+	ctx, cancel := context.WithTimeout(ctx, replInterruptTimeout)
+	defer cancel()
+	if _, err := cr.b.runShell(ctx, rt.interrupt); err != nil {
+		cr.b.logger().Error("Failed to interrupt abandoned execution", "sandbox", cr.b.name(), "language", rt.Name, "error", err)
+		return
+	}
+	cr.b.logger().Debug("Interrupted abandoned execution", "sandbox", cr.b.name(), "language", rt.Name)
 }
 
 type commandRunner struct {
 	b *baseMicroSandbox
 }
 
-func (cr commandRunner) Run(cmd string, args []string) (CommandExecution, error) {
+func (cr commandRunner) Run(ctx context.Context, cmd string, args []string, opts ...RunOption) (CommandExecution, error) {
 	if cr.b.state.Load() != started {
 		return CommandExecution{}, ErrSandboxNotStarted
 	}
-	ctx := context.Background()
-	result, err := cr.b.rpcClient.runCommand(ctx, &cr.b.cfg, cmd, args)
-	if err != nil {
-		return CommandExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+	rc := newRunConfig(opts)
+	var (
+		exec CommandExecution
+		err  error
+	)
+	if key := rc.journalKey; key != "" && cr.b.config().journal != nil {
+		exec, err = journaled(cr.b, key, journalCommand, func() (CommandExecution, error) {
+			return cr.run(ctx, cmd, args, rc)
+		}, commandExecutionFromJournal, journalCommandResult)
+		exec.redactor = newRedactor(cr.b.config().maskedValues())
+	} else {
+		exec, err = cr.run(ctx, cmd, args, rc)
 	}
+	if err == nil && rc.exitError {
+		err = exec.exitError()
+	}
+	return exec, err
+}
 
-	exec := CommandExecution{Output: result.output}
-	// Parse the output for convenience methods
-	if err := json.Unmarshal(result.output, &exec.parsed); err == nil {
-		exec.parsedOK = true
+func (cr commandRunner) run(ctx context.Context, cmd string, args []string, rc runConfig) (CommandExecution, error) {
+	release, err := cr.b.waitExecSlot(ctx)
+	if err != nil {
+		return CommandExecution{}, err
 	}
+	defer release()
+	begin := time.Now()
+	exec, err := withExecHooks(ctx, cr.b, ExecEvent{Command: cmd, Args: args, Tags: rc.tags}, func() (CommandExecution, error) {
+		script, err := cr.b.guestCommandScript(ctx, cmd, args, rc)
+		if err != nil {
+			return CommandExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+		}
+		if rc.polled() {
+			exec, err := cr.b.runGuestCommand(ctx, script, cmd, args, rc)
+			if err != nil {
+				return CommandExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+			}
+			return exec, nil
+		}
+		if limit := cr.b.maxOutputBytes(rc); limit > 0 || rc.stdin != nil || len(rc.env) > 0 || rc.workdir != "" || cr.b.config().workdir != "" || rc.resourceUsage {
+			exec, err := cr.b.runShellCapped(ctx, script, limit, rc.resourceUsage)
+			if err != nil {
+				return terminatedCommandExecution(cmd, args, err), fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+			}
+			return checkParsed(cr.b, exec.withCommand(cmd, args), ErrFailedToRunCommand)
+		}
+		result, err := cr.b.rpcClient.runCommand(ctx, cr.b.config(), cmd, args)
+		if err != nil {
+			return terminatedCommandExecution(cmd, args, err), fmt.Errorf("%w: %w", ErrFailedToRunCommand, err)
+		}
+		return checkParsed(cr.b, newCommandExecution(result), ErrFailedToRunCommand)
+	})
+	exec.duration = time.Since(begin)
+	exec.spec = cr.b.spec.Load()
+	cr.b.emitExecutionOutput(ctx, exec.parsed.OutputLines)
+	exec.redactor = newRedactor(cr.b.config().maskedValues())
+	return exec, exec.redactor.commandError(cmd, args, err)
+}
 
-	return exec, nil
+func (cr commandRunner) Start(ctx context.Context, cmd string, args []string, opts ...RunOption) (*Process, error) {
+	if cr.b.state.Load() != started {
+		return nil, ErrSandboxNotStarted
+	}
+	release, err := cr.b.waitExecSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return cr.b.startProcess(ctx, cmd, args, newRunConfig(opts))
 }
 
 type metricsReader struct {
 	b *baseMicroSandbox
 }
 
-func (mr metricsReader) All() (Metrics, error) {
+// All fails with [ErrCapabilityUnavailable] if the server measures none of the running
+// sandbox's usage; values it cannot measure are otherwise reported as zero.
+func (mr metricsReader) All(ctx context.Context) (Metrics, error) {
+	m, raw, err := mr.fetch(ctx)
+	if err != nil {
+		return Metrics{}, err
+	}
+	if raw.Running && raw.CPUUsage == nil && raw.MemoryUsage == nil && raw.DiskUsage == nil {
+		return Metrics{}, unavailable("metrics")
+	}
+	return m, nil
+}
+
+// fetch returns the sandbox's metrics along with the server's raw report.
+func (mr metricsReader) fetch(ctx context.Context) (Metrics, sandboxMetrics, error) {
 	if mr.b.state.Load() != started {
-		return Metrics{}, ErrSandboxNotStarted
+		return Metrics{}, sandboxMetrics{}, ErrSandboxNotStarted
 	}
 
-	ctx := context.Background()
-	metrics, err := mr.b.rpcClient.getMetrics(ctx, &mr.b.cfg)
+	metrics, err := mr.b.rpcClient.getMetrics(ctx, mr.b.config())
 	if err != nil {
-		return Metrics{}, fmt.Errorf("%w: %w", ErrFailedToGetMetrics, err)
+		return Metrics{}, sandboxMetrics{}, fmt.Errorf("%w: %w", ErrFailedToGetMetrics, err)
+	}
+
+	m := metricsFrom(*metrics)
+	if at := mr.b.startedAt.Load(); at != 0 {
+		m.Uptime = time.Since(time.Unix(0, at))
 	}
+	return m, *metrics, nil
+}
 
-	return Metrics{
+func metricsFrom(metrics sandboxMetrics) Metrics {
+	m := Metrics{
 		Name:      metrics.Name,
 		Namespace: metrics.Namespace,
 		IsRunning: metrics.Running,
-		CPU:       metrics.CPUUsage,
-		MemoryMiB: metrics.MemoryUsage,
-		DiskBytes: metrics.DiskUsage,
-	}, nil
+	}
+	if metrics.CPUUsage != nil {
+		m.CPU = *metrics.CPUUsage
+	}
+	if metrics.MemoryUsage != nil {
+		m.MemoryMiB = *metrics.MemoryUsage
+	}
+	if metrics.DiskUsage != nil {
+		m.DiskBytes = *metrics.DiskUsage
+	}
+	return m
 }
 
-func (mr metricsReader) CPU() (float64, error) {
-	metrics, err := mr.All()
+// MemoryBytes returns memory usage in bytes.
+func (m Metrics) MemoryBytes() int64 {
+	return int64(m.MemoryMiB) << 20
+}
+
+// MemoryMB returns memory usage in (decimal) megabytes.
+func (m Metrics) MemoryMB() float64 {
+	return float64(m.MemoryBytes()) / 1e6
+}
+
+// DiskMiB returns disk usage in mebibytes.
+func (m Metrics) DiskMiB() float64 {
+	return float64(m.DiskBytes) / (1 << 20)
+}
+
+func (mr metricsReader) CPU(ctx context.Context) (float64, error) {
+	metrics, raw, err := mr.fetch(ctx)
 	if err != nil {
 		return 0, err
 	}
+	if raw.Running && raw.CPUUsage == nil {
+		return 0, unavailable(capabilityCPUMetrics)
+	}
 	return metrics.CPU, nil
 }
 
-func (mr metricsReader) MemoryMiB() (int, error) {
-	metrics, err := mr.All()
+func (mr metricsReader) MemoryMiB(ctx context.Context) (int, error) {
+	metrics, raw, err := mr.fetch(ctx)
 	if err != nil {
 		return 0, err
 	}
+	if raw.Running && raw.MemoryUsage == nil {
+		return 0, unavailable(capabilityMemoryMetrics)
+	}
 	return metrics.MemoryMiB, nil
 }
 
-func (mr metricsReader) DiskBytes() (int, error) {
-	metrics, err := mr.All()
+func (mr metricsReader) DiskBytes(ctx context.Context) (int, error) {
+	metrics, raw, err := mr.fetch(ctx)
 	if err != nil {
 		return 0, err
 	}
+	if raw.Running && raw.DiskUsage == nil {
+		return 0, unavailable(capabilityDiskMetrics)
+	}
 	return metrics.DiskBytes, nil
 }
 
-func (mr metricsReader) IsRunning() (bool, error) {
-	metrics, err := mr.All()
+func (mr metricsReader) IsRunning(ctx context.Context) (bool, error) {
+	// Servers that measure no usage still report whether the sandbox runs
+	_, raw, err := mr.fetch(ctx)
 	if err != nil {
 		return false, err
 	}
-	return metrics.IsRunning, nil
+	return raw.Running, nil
 }
@@ -0,0 +1,277 @@
+// Package msbmcp exposes microsandbox as a code-execution tool to agent frameworks through the
+// Model Context Protocol. Every MCP session gets its own sandbox, started on the session's first
+// tool call and stopped when the session ends.
+//
+// Over stdio, e.g. as a subprocess of a desktop assistant:
+//
+//	srv := msbmcp.NewServer(msbmcp.Config{Options: []msb.Option{msb.WithApiKey(key)}})
+//	err := srv.ServeStdio(ctx, os.Stdin, os.Stdout)
+//
+// Over HTTP with server-sent events, serving any number of sessions:
+//
+//	http.Handle("/mcp/", http.StripPrefix("/mcp", srv.SSEHandler()))
+//
+// The tools are execute_code, execute_command, read_file, write_file and list_directory.
+package msbmcp
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// Config configures a [Server].
+type Config struct {
+	// Name and Version identify the server to clients. Default to "microsandbox" and "1.0.0".
+	Name    string
+	Version string
+	// NewSandbox creates the sandbox of a session. Defaults to [msb.NewPythonSandbox].
+	NewSandbox func(opts ...msb.Option) msb.LangSandBox
+	// Options configure every session's sandbox. They must not include msb.WithName, since
+	// every session needs a sandbox of its own.
+	Options []msb.Option
+	// Image, MemoryMB and CPUs are passed to Start.
+	Image    string
+	MemoryMB int
+	CPUs     int
+	// ExecTimeout kills executions running longer, unless the tool call asks for another
+	// timeout. Zero means no limit.
+	ExecTimeout time.Duration
+	// MaxOutputTokens condenses tool output to roughly this many LLM tokens with
+	// [msb.Summarize]. Zero means no limit.
+	MaxOutputTokens int
+}
+
+// Server serves the sandbox tools over MCP. It is safe for concurrent use.
+type Server struct {
+	cfg Config
+
+	mu       sync.Mutex
+	sessions map[string]*session // SSE sessions by ID
+}
+
+// NewServer returns a server with the given configuration.
+func NewServer(cfg Config) *Server {
+	if cfg.Name == "" {
+		cfg.Name = "microsandbox"
+	}
+	if cfg.Version == "" {
+		cfg.Version = "1.0.0"
+	}
+	if cfg.NewSandbox == nil {
+		cfg.NewSandbox = func(opts ...msb.Option) msb.LangSandBox { return msb.NewPythonSandbox(opts...) }
+	}
+	return &Server{cfg: cfg, sessions: map[string]*session{}}
+}
+
+// ServeStdio serves a single session, reading newline-delimited JSON-RPC messages from r and
+// writing responses to w, until r is exhausted or ctx is done. The session's sandbox is stopped
+// before it returns.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	var mu sync.Mutex
+	sess := s.newSession(func(msg []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := w.Write(append(msg, '\n'))
+		return err
+	})
+	defer sess.close()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, maxMessageBytes)
+		for sc.Scan() {
+			select {
+			case lines <- append([]byte(nil), sc.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- sc.Err()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case line := <-lines:
+			if len(strings.TrimSpace(string(line))) == 0 {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sess.handle(ctx, line)
+			}()
+		}
+	}
+}
+
+// maxMessageBytes bounds a single incoming message, e.g. a write_file call with a large file.
+const maxMessageBytes = 64 << 20
+
+// protocolVersion is the MCP revision spoken when the client does not ask for another one.
+const protocolVersion = "2024-11-05"
+
+// session is an MCP session and the sandbox that serves it.
+type session struct {
+	srv  *Server
+	send func(msg []byte) error
+	ctx  context.Context // SSE sessions only: done when the event stream closes
+
+	mu      sync.Mutex
+	sandbox msb.LangSandBox // nil until the first tool call
+	closed  bool
+}
+
+func (s *Server) newSession(send func([]byte) error) *session {
+	return &session{srv: s, send: send}
+}
+
+// startedSandbox returns the session's sandbox, starting it on first use.
+func (sess *session) startedSandbox(ctx context.Context) (msb.LangSandBox, error) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.closed {
+		return nil, errSessionClosed
+	}
+	if sess.sandbox != nil {
+		return sess.sandbox, nil
+	}
+	cfg := sess.srv.cfg
+	sb := cfg.NewSandbox(cfg.Options...)
+	if err := sb.Start(ctx, cfg.Image, cfg.MemoryMB, cfg.CPUs); err != nil {
+		return nil, err
+	}
+	sess.sandbox = sb
+	return sb, nil
+}
+
+// close stops the session's sandbox, if it was started.
+func (sess *session) close() {
+	sess.mu.Lock()
+	sb := sess.sandbox
+	sess.closed = true
+	sess.sandbox = nil
+	sess.mu.Unlock()
+	if sb != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_ = sb.Stop(ctx)
+	}
+}
+
+// JSON-RPC envelopes
+type (
+	request struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id,omitempty"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params,omitempty"`
+	}
+
+	response struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result,omitempty"`
+		Error   *rpcError       `json:"error,omitempty"`
+	}
+
+	rpcError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// handle processes one incoming message and sends the response, if it needs one.
+func (sess *session) handle(ctx context.Context, msg []byte) {
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		sess.reply(json.RawMessage("null"), nil, &rpcError{Code: codeParseError, Message: err.Error()})
+		return
+	}
+	result, rpcErr := sess.dispatch(ctx, req)
+	if len(req.ID) == 0 {
+		return // notification
+	}
+	sess.reply(req.ID, result, rpcErr)
+}
+
+func (sess *session) reply(id json.RawMessage, result any, rpcErr *rpcError) {
+	if result == nil && rpcErr == nil {
+		result = struct{}{}
+	}
+	msg, err := json.Marshal(response{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr})
+	if err == nil {
+		_ = sess.send(msg)
+	}
+}
+
+func (sess *session) dispatch(ctx context.Context, req request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		return map[string]any{
+			"protocolVersion": cmp.Or(params.ProtocolVersion, protocolVersion),
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": sess.srv.cfg.Name, "version": sess.srv.cfg.Version},
+		}, nil
+	case "ping", "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "tools/list":
+		return map[string]any{"tools": toolList}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		tool, ok := tools[params.Name]
+		if !ok {
+			return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+		}
+		text, err := tool.call(ctx, sess, params.Arguments)
+		if err != nil {
+			return toolResult(err.Error(), true), nil
+		}
+		return toolResult(text, false), nil
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+	}
+}
+
+func toolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+var errSessionClosed = errors.New("session closed")
@@ -0,0 +1,104 @@
+package msbmcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SSEHandler serves sessions over MCP's HTTP with server-sent events transport. A client opens
+// a session with a GET request to /sse, which streams the responses, and posts its messages to
+// the /message endpoint announced on that stream, as a URL relative to the stream's. The
+// session, and its sandbox, end when the client disconnects from the stream.
+func (s *Server) SSEHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sse", s.serveSSE)
+	mux.HandleFunc("POST /message", s.serveMessage)
+	return mux
+}
+
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sessionID := hex.EncodeToString(id)
+
+	// Messages are queued so that a slow stream never blocks a tool call holding the session
+	events := make(chan []byte, 64)
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	sess := s.newSession(func(msg []byte) error {
+		select {
+		case events <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	sess.ctx = ctx
+	s.mu.Lock()
+	s.sessions[sessionID] = sess
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sessionID)
+		s.mu.Unlock()
+		sess.close()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Relative to the stream's URL, so that the endpoint stays under any prefix the handler is
+	// mounted at, which http.StripPrefix hides from r.URL
+	endpoint := "message?sessionId=" + sessionID
+	if err := writeEvent(w, "endpoint", []byte(endpoint)); err != nil {
+		return
+	}
+	flusher.Flush()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-events:
+			if err := writeEvent(w, "message", msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) serveMessage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sess, ok := s.sessions[r.URL.Query().Get("sessionId")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	// The response travels over the stream, so the call outlives this request
+	go sess.handle(sess.ctx, body)
+}
+
+// writeEvent writes a single server-sent event. Messages are JSON without raw newlines, so they
+// fit in a single data line.
+func writeEvent(w io.Writer, event string, data []byte) error {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
@@ -0,0 +1,94 @@
+package msbmcp_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/keithang/microsandbox/sdk/go/msbmcp"
+)
+
+// readEvent reads the next server-sent event from r.
+func readEvent(t *testing.T, r *bufio.Reader) (event, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestSSEHandshakeUnderPrefix(t *testing.T) {
+	srv := msbmcp.NewServer(msbmcp.Config{})
+	mux := http.NewServeMux()
+	mux.Handle("/mcp/", http.StripPrefix("/mcp", srv.SSEHandler()))
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/mcp/sse", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /mcp/sse: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	event, data := readEvent(t, stream)
+	if event != "endpoint" {
+		t.Fatalf("first event = %q, want endpoint", event)
+	}
+	ref, err := url.Parse(data)
+	if err != nil {
+		t.Fatalf("endpoint %q: %v", data, err)
+	}
+	endpoint := req.URL.ResolveReference(ref)
+	if endpoint.Path != "/mcp/message" || endpoint.Query().Get("sessionId") == "" {
+		t.Fatalf("endpoint = %s, want /mcp/message with a session ID", endpoint)
+	}
+
+	post, err := http.Post(endpoint.String(), "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`))
+	if err != nil {
+		t.Fatalf("POST %s: %v", endpoint, err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST status = %d, want %d", post.StatusCode, http.StatusAccepted)
+	}
+	event, data = readEvent(t, stream)
+	if event != "message" || !strings.Contains(data, `"id":1`) || !strings.Contains(data, `"serverInfo"`) {
+		t.Errorf("response event = %s %s, want the initialize result", event, data)
+	}
+}
+
+func TestSSEUnknownSession(t *testing.T) {
+	ts := httptest.NewServer(msbmcp.NewServer(msbmcp.Config{}).SSEHandler())
+	t.Cleanup(ts.Close)
+	resp, err := http.Post(ts.URL+"/message?sessionId=nope", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
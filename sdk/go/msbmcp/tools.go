@@ -0,0 +1,198 @@
+package msbmcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// tool is an MCP tool backed by the session's sandbox.
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	call func(ctx context.Context, sess *session, args json.RawMessage) (string, error)
+}
+
+var (
+	toolList = []*tool{
+		{
+			Name:        "execute_code",
+			Description: "Run code in the session's sandbox and return its output. Interpreter state persists across calls.",
+			InputSchema: schema(map[string]any{
+				"code":     prop("string", "The code to run"),
+				"language": prop("string", "The language to run the code in; defaults to the sandbox's language"),
+				"timeout":  prop("number", "Seconds after which the execution is killed"),
+			}, "code"),
+			call: executeCode,
+		},
+		{
+			Name:        "execute_command",
+			Description: "Run a shell command line in the session's sandbox and return its output and exit code.",
+			InputSchema: schema(map[string]any{
+				"command": prop("string", "The shell command line to run"),
+				"timeout": prop("number", "Seconds after which the command is killed"),
+			}, "command"),
+			call: executeCommand,
+		},
+		{
+			Name:        "read_file",
+			Description: "Read a text file from the session's sandbox.",
+			InputSchema: schema(map[string]any{
+				"path": prop("string", "Absolute path of the file"),
+			}, "path"),
+			call: readFile,
+		},
+		{
+			Name:        "write_file",
+			Description: "Write a text file in the session's sandbox, replacing it if it exists.",
+			InputSchema: schema(map[string]any{
+				"path":    prop("string", "Absolute path of the file"),
+				"content": prop("string", "The file's new content"),
+			}, "path", "content"),
+			call: writeFile,
+		},
+		{
+			Name:        "list_directory",
+			Description: "List the entries of a directory in the session's sandbox.",
+			InputSchema: schema(map[string]any{
+				"path": prop("string", "Absolute path of the directory"),
+			}, "path"),
+			call: listDirectory,
+		},
+	}
+
+	tools = func() map[string]*tool {
+		m := make(map[string]*tool, len(toolList))
+		for _, t := range toolList {
+			m[t.Name] = t
+		}
+		return m
+	}()
+)
+
+func schema(props map[string]any, required ...string) map[string]any {
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+func prop(typ, description string) map[string]any {
+	return map[string]any{"type": typ, "description": description}
+}
+
+// execOptions translates the timeout argument, in seconds, into run options.
+func (sess *session) execOptions(timeoutSecs float64) []msb.RunOption {
+	timeout := sess.srv.cfg.ExecTimeout
+	if timeoutSecs > 0 {
+		timeout = time.Duration(timeoutSecs * float64(time.Second))
+	}
+	if timeout <= 0 {
+		return nil
+	}
+	return []msb.RunOption{msb.WithExecTimeout(timeout)}
+}
+
+func executeCode(ctx context.Context, sess *session, raw json.RawMessage) (string, error) {
+	var args struct {
+		Code     string  `json:"code"`
+		Language string  `json:"language"`
+		Timeout  float64 `json:"timeout"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	sb, err := sess.startedSandbox(ctx)
+	if err != nil {
+		return "", err
+	}
+	opts := sess.execOptions(args.Timeout)
+	if args.Language != "" {
+		opts = append(opts, msb.WithLanguage(msb.Language(args.Language)))
+	}
+	exec, err := sb.Code().Run(ctx, args.Code, opts...)
+	if err != nil && exec.GetTerminationReason().Kind != msb.TerminationTimeout {
+		return "", err
+	}
+	return msb.Summarize(exec, sess.srv.cfg.MaxOutputTokens), nil
+}
+
+func executeCommand(ctx context.Context, sess *session, raw json.RawMessage) (string, error) {
+	var args struct {
+		Command string  `json:"command"`
+		Timeout float64 `json:"timeout"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	sb, err := sess.startedSandbox(ctx)
+	if err != nil {
+		return "", err
+	}
+	exec, err := sb.Command().Run(ctx, "sh", []string{"-c", args.Command}, sess.execOptions(args.Timeout)...)
+	if err != nil && !exec.TimedOut() {
+		return "", err
+	}
+	return msb.Summarize(exec, sess.srv.cfg.MaxOutputTokens), nil
+}
+
+func readFile(ctx context.Context, sess *session, raw json.RawMessage) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	sb, err := sess.startedSandbox(ctx)
+	if err != nil {
+		return "", err
+	}
+	data, err := sb.Files().ReadFile(ctx, args.Path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func writeFile(ctx context.Context, sess *session, raw json.RawMessage) (string, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	sb, err := sess.startedSandbox(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := sb.Files().WriteFile(ctx, args.Path, []byte(args.Content), 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(args.Content), args.Path), nil
+}
+
+func listDirectory(ctx context.Context, sess *session, raw json.RawMessage) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	sb, err := sess.startedSandbox(ctx)
+	if err != nil {
+		return "", err
+	}
+	entries, err := sb.Files().ListDir(ctx, args.Path)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&out, "%s %10d %s %s\n", e.Mode, e.Size, e.ModTime.UTC().Format(time.RFC3339), e.Name)
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
@@ -0,0 +1,201 @@
+// Package msbprom exports the SDK's client metrics in the Prometheus text exposition format,
+// without depending on the Prometheus client library. A Registry records the metrics of any
+// number of sandboxes and pools, and serves them for scraping:
+//
+//	reg := msbprom.NewRegistry()
+//	sandbox := msb.NewPythonSandbox(msb.WithMetrics(reg))
+//	pool, err := msb.NewPool(ctx, msb.PoolConfig{Size: 4, Metrics: reg, Options: []msb.Option{msb.WithMetrics(reg)}})
+//	http.Handle("/metrics", reg)
+//
+// To register the metrics with a prometheus.Registerer instead, implement [msb.MetricsRecorder]
+// on top of its collectors.
+package msbprom
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// DefaultBuckets are the upper bounds of histogram buckets, in seconds, suited to RPC
+// latencies from a few milliseconds to several minutes.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// help describes the SDK's metrics.
+var help = map[string]string{
+	msb.MetricRPCDuration:      "Duration of RPCs to the microsandbox server, retries included.",
+	msb.MetricRPCErrors:        "RPCs to the microsandbox server that failed.",
+	msb.MetricActiveSandboxes:  "Sandboxes started and not yet stopped.",
+	msb.MetricQueuedExecutions: "Executions waiting for a concurrency slot.",
+	msb.MetricPoolSandboxes:    "Sandboxes of a pool, by state.",
+	msb.MetricPoolUtilization:  "Fraction of a pool's capacity in use.",
+}
+
+// Registry is an [msb.MetricsRecorder] that keeps metrics in memory and serves them over HTTP
+// in the Prometheus text format. It is safe for concurrent use.
+type Registry struct {
+	buckets []float64
+
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// family is a metric and its series, by rendered labels.
+type family struct {
+	kind   string // "counter", "gauge" or "histogram"
+	series map[string]*series
+}
+
+type series struct {
+	value  float64  // counter or gauge value; histogram sum
+	count  uint64   // histogram observations
+	counts []uint64 // histogram observations per bucket, not cumulative
+}
+
+// NewRegistry returns an empty registry whose histograms use buckets, or DefaultBuckets if none
+// are given.
+func NewRegistry(buckets ...float64) *Registry {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &Registry{buckets: slices.Compact(buckets), families: map[string]*family{}}
+}
+
+var _ msb.MetricsRecorder = (*Registry)(nil)
+
+// AddCounter adds delta to a counter.
+func (r *Registry) AddCounter(name string, labels map[string]string, delta float64) {
+	r.update(name, "counter", labels, func(s *series) { s.value += delta })
+}
+
+// AddGauge adds delta to a gauge.
+func (r *Registry) AddGauge(name string, labels map[string]string, delta float64) {
+	r.update(name, "gauge", labels, func(s *series) { s.value += delta })
+}
+
+// SetGauge sets a gauge to value.
+func (r *Registry) SetGauge(name string, labels map[string]string, value float64) {
+	r.update(name, "gauge", labels, func(s *series) { s.value = value })
+}
+
+// ObserveHistogram records value in a histogram.
+func (r *Registry) ObserveHistogram(name string, labels map[string]string, value float64) {
+	r.update(name, "histogram", labels, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(r.buckets))
+		}
+		if i, _ := slices.BinarySearch(r.buckets, value); i < len(r.buckets) {
+			s.counts[i]++
+		}
+		s.count++
+		s.value += value
+	})
+}
+
+// update applies fn to the series of name with labels, creating it as needed. A series keeps the
+// kind of its first update.
+func (r *Registry) update(name, kind string, labels map[string]string, fn func(*series)) {
+	key := renderLabels(labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.families[name]
+	if f == nil {
+		f = &family{kind: kind, series: map[string]*series{}}
+		r.families[name] = f
+	}
+	if f.kind != kind {
+		return
+	}
+	s := f.series[key]
+	if s == nil {
+		s = &series{}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.Write(w)
+}
+
+// Write writes the metrics to w in the Prometheus text format, sorted by name and labels.
+func (r *Registry) Write(w io.Writer) error {
+	var b strings.Builder
+	r.mu.Lock()
+	for _, name := range slices.Sorted(maps.Keys(r.families)) {
+		f := r.families[name]
+		if text, ok := help[name]; ok {
+			fmt.Fprintf(&b, "# HELP %s %s\n", name, text)
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.kind)
+		for _, key := range slices.Sorted(maps.Keys(f.series)) {
+			s := f.series[key]
+			if f.kind != "histogram" {
+				fmt.Fprintf(&b, "%s%s %s\n", name, braced(key), formatFloat(s.value))
+				continue
+			}
+			var cumulative uint64
+			for i, le := range r.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, braced(joinLabels(key, `le="`+formatFloat(le)+`"`)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, braced(joinLabels(key, `le="+Inf"`)), s.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, braced(key), formatFloat(s.value))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, braced(key), s.count)
+		}
+	}
+	r.mu.Unlock()
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// renderLabels renders labels as `name="value"` pairs sorted by name, without braces.
+func renderLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labels[name])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(key, pair string) string {
+	if key == "" {
+		return pair
+	}
+	return key + "," + pair
+}
+
+func braced(key string) string {
+	if key == "" {
+		return ""
+	}
+	return "{" + key + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
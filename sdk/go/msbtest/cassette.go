@@ -0,0 +1,231 @@
+package msbtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// A cassette is a file of recorded interactions with a server, one JSON object per line, in the
+// order they happened. Only the JSON-RPC method and parameters of requests are recorded, so API
+// keys do not end up on disk, and the sandbox's secrets are masked; see [msb.RedactSecrets].
+type interaction struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   string          `json:"body"`
+}
+
+// Recorder is an [http.RoundTripper] that passes requests on to a real server and records the
+// interactions to a cassette, for a [Replayer] to serve back:
+//
+//	rec, err := msbtest.NewRecorder("testdata/run.jsonl", nil)
+//	defer rec.Close()
+//	sandbox := msb.NewPythonSandbox(msb.WithName("recorded"), msb.WithTransport(rec))
+//
+// Sandboxes being recorded should be given a fixed name, since the name is part of every
+// request.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	err error // the first error writing the cassette
+}
+
+// NewRecorder creates the cassette at path, replacing any existing one, and records the
+// interactions of requests sent through next, or [http.DefaultTransport] if next is nil.
+func NewRecorder(path string, next http.RoundTripper) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCassette, err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(reqBody))
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var rpc rpcRequest
+	_ = json.Unmarshal([]byte(msb.RedactSecrets(req.Context(), string(reqBody))), &rpc)
+	body := msb.RedactSecrets(req.Context(), string(respBody))
+	line, err := json.Marshal(interaction{Method: rpc.Method, Params: rpc.Params, Status: resp.StatusCode, Header: resp.Header, Body: body})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil && r.err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return resp, nil
+}
+
+// Close flushes the cassette to disk, and reports any error recording it.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := errors.Join(r.err, r.w.Flush(), r.f.Close())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCassette, err)
+	}
+	return nil
+}
+
+// Replayer is an [http.RoundTripper] that serves the interactions of a cassette recorded by a
+// [Recorder], without a server:
+//
+//	rep, err := msbtest.NewReplayer("testdata/run.jsonl")
+//	sandbox := msb.NewPythonSandbox(msb.WithName("recorded"), msb.WithApiKey("unused"), msb.WithTransport(rep))
+//
+// Each request is served the first unused interaction with the same method and parameters. Since
+// some requests carry random identifiers, e.g. of background jobs, a request with no such match
+// is served the first unused interaction with the same method, so replays stay deterministic as
+// long as the code issues its requests in the recorded order.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []interaction
+	used         []bool
+}
+
+// NewReplayer loads the cassette at path.
+func NewReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCassette, err)
+	}
+	defer f.Close()
+
+	r := &Replayer{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var in interaction
+		if err := json.Unmarshal(sc.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrCassette, path, err)
+		}
+		r.interactions = append(r.interactions, in)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrCassette, path, err)
+	}
+	r.used = make([]bool, len(r.interactions))
+	return r, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	var rpc rpcRequest
+	if err := json.Unmarshal([]byte(msb.RedactSecrets(req.Context(), string(body))), &rpc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoInteraction, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.find(rpc, true)
+	if i < 0 {
+		i = r.find(rpc, false)
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoInteraction, rpc.Method)
+	}
+	r.used[i] = true
+	in := r.interactions[i]
+	return &http.Response{
+		StatusCode:    in.Status,
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		Header:        in.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader([]byte(in.Body))),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}, nil
+}
+
+// find returns the index of the first unused interaction matching the request, or -1.
+func (r *Replayer) find(rpc rpcRequest, matchParams bool) int {
+	for i, in := range r.interactions {
+		if r.used[i] || in.Method != rpc.Method {
+			continue
+		}
+		if matchParams && !equalJSON(in.Params, rpc.Params) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// Unused returns how many recorded interactions have not been served, e.g. to check that a test
+// issued every request it was recorded with.
+func (r *Replayer) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// readBody reads and closes the body of req.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
+
+// equalJSON reports whether a and b encode the same value, regardless of formatting and key
+// order.
+func equalJSON(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
+
+// Cassette-related errors
+var (
+	ErrCassette      = errors.New("cassette error")
+	ErrNoInteraction = errors.New("no recorded interaction for request")
+)
@@ -0,0 +1,285 @@
+// Package msbtest provides a fake sandbox for unit testing code that depends on the SDK, without
+// a running microsandbox server.
+//
+// A FakeSandbox is a real [msb.LangSandBox] whose requests are served in memory, so the code
+// under test exercises the SDK as it would against a server. Its results are scripted, and the
+// code and commands it received can be inspected afterwards:
+//
+//	fake := msbtest.NewFakeSandbox(msb.LanguagePython)
+//	fake.HandleCode("print(1 + 1)", msbtest.Result{Stdout: "2"})
+//	fake.HandleCommand("ls", msbtest.Result{ExitCode: 2, Stderr: "ls: cannot access"})
+//
+//	err := runReport(ctx, fake) // the code under test takes an msb.LangSandBox
+//
+//	if got := fake.SubmittedCode(); len(got) != 1 {
+//		t.Errorf("submitted %d snippets, want 1", len(got))
+//	}
+//
+// Features beyond [msb.LangSandBox], such as [msb.Forker], are reached on the embedded sandbox,
+// e.g. fake.LangSandBox.(msb.Forker).
+//
+// Code and commands without a matching handler succeed with no output. Helpers built on guest
+// shell scripts, such as file transfers and background jobs, receive the same default, so tests
+// relying on them need handlers that reproduce the guest's output.
+//
+// Alternatively, a [Recorder] captures the interactions with a real server once, and a
+// [Replayer] serves them back on every later run.
+package msbtest
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// FakeSandbox is a sandbox backed by an in-memory fake server. Its methods are safe for
+// concurrent use.
+type FakeSandbox struct {
+	msb.LangSandBox
+
+	mu              sync.Mutex
+	codeHandlers    []func(code string) (Result, bool)
+	commandHandlers []func(command string, args []string) (Result, bool)
+	code            []string
+	commands        []Command
+	running         bool
+	serverVersion   string
+}
+
+var _ msb.LangSandBox = (*FakeSandbox)(nil)
+
+// Result is the scripted outcome of code or a command.
+type Result struct {
+	Stdout string
+	Stderr string
+	// ExitCode is the exit code of a command; code fails if it is non-zero.
+	ExitCode int
+	// RPCError, if set, makes the server reject the request with this JSON-RPC error message,
+	// which the SDK reports as an [*msb.RPCError].
+	RPCError string
+}
+
+// Command is a command received by a FakeSandbox.
+type Command struct {
+	Name string
+	Args []string
+}
+
+// NewFakeSandbox returns a fake sandbox running code in lang. Options apply as to a real
+// sandbox, except for the transport, which the fake provides; no API key is needed. It panics
+// if lang is not registered.
+func NewFakeSandbox(lang msb.Language, opts ...msb.Option) *FakeSandbox {
+	f := &FakeSandbox{}
+	opts = append([]msb.Option{msb.WithApiKey("msbtest")}, opts...)
+	sandbox, err := msb.NewSandboxWithLanguage(lang, append(opts, msb.WithTransport(roundTripper{f}))...)
+	if err != nil {
+		panic(err)
+	}
+	f.LangSandBox = sandbox
+	return f
+}
+
+// HandleCode scripts the result of running exactly code.
+func (f *FakeSandbox) HandleCode(code string, r Result) {
+	f.HandleCodeFunc(func(c string) (Result, bool) { return r, c == code })
+}
+
+// HandleCodeFunc scripts the result of running code for which fn reports true. Handlers
+// registered later take precedence. Handlers must not call the FakeSandbox's methods.
+func (f *FakeSandbox) HandleCodeFunc(fn func(code string) (Result, bool)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.codeHandlers = append(f.codeHandlers, fn)
+}
+
+// HandleCommand scripts the result of running command with any arguments.
+func (f *FakeSandbox) HandleCommand(command string, r Result) {
+	f.HandleCommandFunc(func(c string, _ []string) (Result, bool) { return r, c == command })
+}
+
+// HandleCommandFunc scripts the result of running a command for which fn reports true.
+// Handlers registered later take precedence.
+func (f *FakeSandbox) HandleCommandFunc(fn func(command string, args []string) (Result, bool)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commandHandlers = append(f.commandHandlers, fn)
+}
+
+// DefaultServerVersion is the version a FakeSandbox reports for its server.
+const DefaultServerVersion = "0.2.6"
+
+// SetServerVersion sets the version the fake server reports, e.g. to test how code handles
+// [msb.ErrIncompatibleServer].
+func (f *FakeSandbox) SetServerVersion(version string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.serverVersion = version
+}
+
+// SubmittedCode returns the code the sandbox received, in order.
+func (f *FakeSandbox) SubmittedCode() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.code)
+}
+
+// SubmittedCommands returns the commands the sandbox received, in order.
+func (f *FakeSandbox) SubmittedCommands() []Command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.commands)
+}
+
+// Running reports whether the sandbox has been started and not stopped.
+func (f *FakeSandbox) Running() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.running
+}
+
+// Reset forgets the submitted code and commands, keeping the handlers.
+func (f *FakeSandbox) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.code, f.commands = nil, nil
+}
+
+type rpcRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	ID     any             `json:"id"`
+}
+
+type outputLine struct {
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+// serve answers a JSON-RPC request with its result, or the message of a JSON-RPC error.
+func (f *FakeSandbox) serve(req rpcRequest) (any, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": "2024-11-05",
+			"capabilities":    map[string]any{"toolCalling": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "microsandbox-server", "version": cmp.Or(f.serverVersion, DefaultServerVersion)},
+		}, ""
+	case "sandbox.start":
+		f.running = true
+		return "Sandbox started", ""
+	case "sandbox.stop":
+		f.running = false
+		return "Sandbox stopped", ""
+	case "sandbox.metrics.get":
+		var p struct {
+			Namespace string `json:"namespace"`
+			Sandbox   string `json:"sandbox"`
+		}
+		_ = json.Unmarshal(req.Params, &p)
+		sandbox := map[string]any{"name": p.Sandbox, "namespace": p.Namespace, "running": f.running}
+		if f.running {
+			sandbox["cpu_usage"], sandbox["memory_usage"], sandbox["disk_usage"] = 0, 0, 0
+		}
+		return map[string]any{"sandboxes": []map[string]any{sandbox}}, ""
+	case "sandbox.repl.run":
+		var p struct {
+			Language string `json:"language"`
+			Code     string `json:"code"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err.Error()
+		}
+		f.code = append(f.code, p.Code)
+		r := match(f.codeHandlers, func(h func(string) (Result, bool)) (Result, bool) { return h(p.Code) })
+		if r.RPCError != "" {
+			return nil, r.RPCError
+		}
+		status := "success"
+		if r.ExitCode != 0 {
+			status = "error"
+		}
+		return map[string]any{"output": r.lines(), "status": status, "language": p.Language}, ""
+	case "sandbox.command.run":
+		var p struct {
+			Command string   `json:"command"`
+			Args    []string `json:"args"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, err.Error()
+		}
+		f.commands = append(f.commands, Command{Name: p.Command, Args: p.Args})
+		r := match(f.commandHandlers, func(h func(string, []string) (Result, bool)) (Result, bool) { return h(p.Command, p.Args) })
+		if r.RPCError != "" {
+			return nil, r.RPCError
+		}
+		return map[string]any{"output": r.lines(), "command": p.Command, "args": p.Args, "exit_code": r.ExitCode, "success": r.ExitCode == 0}, ""
+	}
+	return nil, "method not found: " + req.Method
+}
+
+// match returns the result of the latest handler that matches, or an empty result.
+func match[H any](handlers []H, call func(H) (Result, bool)) Result {
+	for _, h := range slices.Backward(handlers) {
+		if r, ok := call(h); ok {
+			return r
+		}
+	}
+	return Result{}
+}
+
+func (r Result) lines() []outputLine {
+	lines := []outputLine{}
+	for _, stream := range []struct{ name, text string }{{"stdout", r.Stdout}, {"stderr", r.Stderr}} {
+		if stream.text == "" {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(stream.text, "\n"), "\n") {
+			lines = append(lines, outputLine{Stream: stream.name, Text: line})
+		}
+	}
+	return lines
+}
+
+// roundTripper serves the SDK's HTTP requests from the fake.
+type roundTripper struct {
+	f *FakeSandbox
+}
+
+func (rt roundTripper) RoundTrip(httpReq *http.Request) (*http.Response, error) {
+	defer httpReq.Body.Close()
+	var req rpcRequest
+	if err := json.NewDecoder(httpReq.Body).Decode(&req); err != nil {
+		return respond(httpReq, http.StatusBadRequest, map[string]any{"jsonrpc": "2.0", "error": map[string]any{"code": -32700, "message": err.Error()}})
+	}
+	result, rpcErr := rt.f.serve(req)
+	if rpcErr != "" {
+		return respond(httpReq, http.StatusOK, map[string]any{"jsonrpc": "2.0", "error": map[string]any{"code": -32000, "message": rpcErr}, "id": req.ID})
+	}
+	return respond(httpReq, http.StatusOK, map[string]any{"jsonrpc": "2.0", "result": result, "id": req.ID})
+}
+
+func respond(req *http.Request, status int, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package msb
+
+import "context"
+
+// The Must helpers panic instead of returning an error, for scripts, examples and tests where a
+// failure should simply abort. Services should call the error-returning methods instead.
+
+// MustStart starts sandbox like [Starter.Start], and panics if it fails.
+func MustStart(ctx context.Context, sandbox LangSandBox, image string, memoryMB int, cpus int) {
+	if err := sandbox.Start(ctx, image, memoryMB, cpus); err != nil {
+		panic(err)
+	}
+}
+
+// MustRun runs code in sandbox like [CodeRunner.Run], and panics if it fails. Code that raised
+// an exception is not a failure: its result reports it.
+//
+//	fmt.Println(msb.MustRun(ctx, sandbox, "print(6 * 7)").GetOutputOrEmpty())
+func MustRun(ctx context.Context, sandbox LangSandBox, code string, opts ...RunOption) CodeExecution {
+	exec, err := sandbox.Code().Run(ctx, code, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return exec
+}
+
+// MustRunCommand runs a command in sandbox like [CommandRunner.Run], and panics if it fails. A
+// non-zero exit code is not a failure: its result reports it.
+func MustRunCommand(ctx context.Context, sandbox LangSandBox, cmd string, args []string, opts ...RunOption) CommandExecution {
+	exec, err := sandbox.Command().Run(ctx, cmd, args, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return exec
+}
+
+// MustStop stops sandbox like [Stopper.Stop], and panics if it fails.
+func MustStop(ctx context.Context, sandbox LangSandBox) {
+	if err := sandbox.Stop(ctx); err != nil {
+		panic(err)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"os"
+	"time"
 )
 
 // Option configures a sandbox during creation.
@@ -20,6 +22,15 @@ func WithServerUrl(serverUrl string) Option {
 	}
 }
 
+// WithServerOverride pins this sandbox to a specific server URL, taking precedence over
+// WithServerUrl and MSB_SERVER_URL regardless of option order. This lets a shared option set
+// be reused while a router picks the nearest regional server per sandbox.
+func WithServerOverride(serverUrl string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.serverOverride = serverUrl
+	}
+}
+
 // WithNamespace configures the sandbox namespace for isolation.
 // If not specified, uses the default namespace.
 func WithNamespace(namespace string) Option {
@@ -44,6 +55,15 @@ func WithApiKey(apiKey string) Option {
 	}
 }
 
+// WithTimeout configures a deadline applied to each RPC made by the sandbox.
+// If not specified, RPCs are bounded only by the HTTP client's own timeout.
+// It can be changed later with SetTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.timeout = timeout
+	}
+}
+
 // WithLogger configures a custom logger for the sandbox.
 // If not specified, uses a no-op logger that discards all log output.
 func WithLogger(logger Logger) Option {
@@ -62,16 +82,277 @@ func WithReqIdProducer(reqIdPrd ReqIdProducer) Option {
 
 // WithHTTPClient configures a custom HTTP client for server communication.
 // Useful for setting timeouts, proxies, or other HTTP-level configuration.
+// A nil client restores the default one.
 func WithHTTPClient(c *http.Client) Option {
 	return func(msb *baseMicroSandbox) {
-		msb.rpcClient = newJsonRPCHTTPClient(c)
+		msb.rpcClient = nil
+		if c != nil {
+			msb.rpcClient = newJsonRPCHTTPClient(c)
+		}
+	}
+}
+
+// WithTransport sends requests to the server through rt, e.g. to add custom TLS settings,
+// connection pooling or request instrumentation, while keeping the default client otherwise.
+// Use [WithHTTPClient] to replace the client as a whole.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.rpcClient = newJsonRPCHTTPClient(&http.Client{Transport: rt})
+	}
+}
+
+// WithRetry retries RPCs that failed transiently, making up to maxAttempts attempts in total and
+// waiting backoff(n) after the n-th failure (or longer, if the server asks for it with
+// Retry-After). Any call the server rejected with 429 Too Many Requests or 503 Service
+// Unavailable is retried, since it was not acted upon; metrics reads, being idempotent, are also
+// retried after network and gateway errors. Executions are never retried once they may have run.
+// If backoff is nil, [ExponentialBackoff] from 100ms up to 5s is used.
+func WithRetry(maxAttempts int, backoff Backoff) Option {
+	return func(msb *baseMicroSandbox) {
+		if backoff == nil {
+			backoff = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+		}
+		msb.cfg.retry = retryPolicy{maxAttempts: maxAttempts, backoff: backoff}
+	}
+}
+
+// WithImage sets the image, e.g. "registry.example.com/team/python-data:1.2", that Start boots
+// the sandbox from when called with an empty image. Baking dependencies into an image avoids
+// installing them on every run.
+func WithImage(image string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.image = image
+	}
+}
+
+// WithJournal records executions run with [WithJournalKey] in j, so a batch can resume after
+// the client crashes.
+func WithJournal(j *Journal) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.journal = j
+	}
+}
+
+// WithReadinessProbe checks periodically whether the started sandbox is ready to serve, e.g.
+// whether a server it runs listens on its port; see [HealthReporter].
+func WithReadinessProbe(probe Probe) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.readinessProbe = &probe
+	}
+}
+
+// WithLivenessProbe checks periodically whether the started sandbox still works, e.g. whether
+// its interpreter still answers; see [HealthReporter]. A failing liveness probe is only
+// reported: the sandbox is not restarted.
+func WithLivenessProbe(probe Probe) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.livenessProbe = &probe
+	}
+}
+
+// WithHealthHandler calls h whenever a readiness or liveness probe starts or stops passing.
+func WithHealthHandler(h HealthHandler) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.healthHandler = h
+	}
+}
+
+// WithSecrets registers values, such as API tokens passed as command arguments, that are masked
+// in the errors of failed commands, see [CommandError], in errors and logs echoing what the
+// server received, and in the bodies seen through [RedactSecrets] by recording transports.
+func WithSecrets(secrets ...string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.secrets = append(msb.cfg.secrets, secrets...)
+	}
+}
+
+// WithSecret hands value to everything running in the sandbox as the environment variable name,
+// set when the sandbox starts, and masks it like [WithSecrets]. Code should read the variable,
+// e.g. os.environ["OPENAI_API_KEY"], rather than embed the value, which would otherwise travel
+// with every execution.
+func WithSecret(name, value string) Option {
+	return func(msb *baseMicroSandbox) {
+		if msb.cfg.envVars == nil {
+			msb.cfg.envVars = map[string]string{}
+		}
+		msb.cfg.envVars[name] = value
+		msb.cfg.secrets = append(msb.cfg.secrets, value)
+	}
+}
+
+// WithSecretEnvRedaction masks, like the values registered with [WithSecrets], the values of the
+// environment variables set with [WithEnvVars] whose names suggest a credential, i.e. contain
+// KEY, TOKEN, SECRET, PASSWORD, PASSWD or CREDENTIAL, such as OPENAI_API_KEY.
+func WithSecretEnvRedaction() Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.redactSecretEnv = true
+	}
+}
+
+// WithMemoryMB sets the memory, in MiB, that Start gives the sandbox when called with
+// memoryMB <= 0, so differently sized sandboxes can be configured where they are created.
+func WithMemoryMB(memoryMB int) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.memoryMB = max(memoryMB, 0)
+	}
+}
+
+// WithCPUs sets the number of vCPUs that Start gives the sandbox when called with cpus <= 0.
+// The server accepts 1 to 255 vCPUs; Start fails with [ErrInvalidCPUs] for other counts, and 0
+// leaves the default of 1.
+func WithCPUs(cpus int) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.cpus = cpus
+	}
+}
+
+// maxCPUs is the most vCPUs the server accepts, which it reads as an unsigned byte.
+const maxCPUs = 255
+
+// WithPublisher sends an event to p when each execution starts and when it finishes, the latter
+// carrying the result. Publishing happens synchronously; failures are logged and do not affect
+// the execution. Multiple publishers may be registered.
+func WithPublisher(p Publisher) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.publishers = append(msb.cfg.publishers, p)
+	}
+}
+
+// WithStartProgress registers a callback that receives phased progress while Start runs,
+// so callers can show what a slow cold start is doing.
+func WithStartProgress(fn StartProgressFunc) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.startProgress = fn
+	}
+}
+
+// WithMaxResponseBytes bounds the size of RPC response bodies the client will read.
+// Larger responses fail with a [*ResponseTooLargeError] instead of exhausting client memory.
+// If not specified (or n <= 0), response size is unbounded.
+func WithMaxResponseBytes(n int64) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.maxResponseBytes = n
+	}
+}
+
+// WithLanguages enables additional languages in the sandbox, built-in or declared with
+// [RegisterLanguage], so individual executions can pick one via [WithLanguage] instead of
+// requiring separate sandboxes. The image must provide the corresponding runtimes.
+func WithLanguages(languages ...Language) Option {
+	return func(msb *baseMicroSandbox) {
+		for _, l := range languages {
+			lang, err := canonicalLanguage(l)
+			if err != nil {
+				panic(err)
+			}
+			msb.cfg.languages = append(msb.cfg.languages, lang)
+		}
+	}
+}
+
+// WithStrictParsing makes Code().Run and Command().Run fail with [ErrExecutionNotParsed] when the
+// server's response cannot be parsed, instead of returning a result whose Get* methods degrade.
+func WithStrictParsing() Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.strictParsing = true
+	}
+}
+
+// WithMaintenanceHandler registers a callback invoked when the server reports that it is draining
+// or under maintenance (HTTP 503), so applications can migrate workloads pre-emptively.
+// The failing call still returns a [*MaintenanceError].
+func WithMaintenanceHandler(fn MaintenanceHandler) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.maintenanceHandler = fn
+	}
+}
+
+// WithOutputSink ships the output of every execution to sink, labelled with the sandbox and
+// execution, e.g. to centralize logs in Loki with [NewLokiSink]. Multiple sinks may be registered.
+func WithOutputSink(sink OutputSink) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.outputSinks = append(msb.cfg.outputSinks, sink)
 	}
 }
 
+// WithSpecSnapshots records the sandbox's effective spec (image, limits, mounts and a hash of the
+// guest environment) when it starts and attaches it to every execution result, so results can be
+// traced to the environment that produced them. It costs one extra command at startup.
+func WithSpecSnapshots() Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.specSnapshots = true
+	}
+}
+
+// WithMaxExecutionsPerMinute limits Code().Run and Command().Run on this sandbox to n calls per
+// minute, allowing bursts of up to n. Calls beyond the limit block until a slot frees up, which
+// keeps a runaway caller from hammering a stateful REPL session. n <= 0 means unlimited.
+func WithMaxExecutionsPerMinute(n int) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.execLimiter = nil
+		if n > 0 {
+			msb.execLimiter = newTokenBucket(n, time.Minute)
+		}
+	}
+}
+
+// WithDedupeWindow makes Code().Run share results between identical executions: while code is
+// running, and for window after it finished successfully, running the same code in the same
+// language returns that execution's result instead of running it again. This absorbs accidental
+// double-submits from UI retries or at-least-once queues. Executions with output handlers or
+// writers always run. window <= 0 disables deduplication.
+func WithDedupeWindow(window time.Duration) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.dedupe = nil
+		if window > 0 {
+			msb.dedupe = newDedupeGroup(window)
+		}
+	}
+}
+
+// WithEnv sets the guest's environment variables. The spec is rendered and validated on every
+// Start; see [EnvSpec].
+func WithEnv(spec EnvSpec) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.env = spec
+	}
+}
+
+// WithEnvVars sets plain environment variables for everything running in the sandbox, e.g. API
+// keys that should not be embedded in code. They override same-named variables from [WithEnv],
+// and are themselves overridden per execution by [WithExecEnv].
+func WithEnvVars(env map[string]string) Option {
+	return func(msb *baseMicroSandbox) {
+		if msb.cfg.envVars == nil {
+			msb.cfg.envVars = map[string]string{}
+		}
+		maps.Copy(msb.cfg.envVars, env)
+	}
+}
+
+// WithPackageCache mounts persistent pip and npm caches into the sandbox. The caches live in the
+// namespace's directory on the server, so every sandbox in the namespace shares them and
+// repeated dependency installs in fresh sandboxes are served from the cache.
+func WithPackageCache() Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.volumes = append(msb.cfg.volumes, packageCacheVolumes...)
+	}
+}
+
+// packageCacheVolumes are relative on the host side, which the server resolves against the
+// namespace directory.
+var packageCacheVolumes = []string{
+	".msb-cache/pip:/root/.cache/pip",
+	".msb-cache/npm:/root/.npm",
+}
+
 // --- internal constructor operations ---
 
 func fillDefaultConfigs() Option {
 	return func(msb *baseMicroSandbox) {
+		if msb.cfg.serverOverride != "" {
+			msb.cfg.serverUrl = msb.cfg.serverOverride
+		}
 		if msb.cfg.serverUrl == "" {
 			if envUrl := os.Getenv("MSB_SERVER_URL"); envUrl != "" {
 				msb.cfg.serverUrl = envUrl
@@ -89,7 +370,7 @@ func fillDefaultConfigs() Option {
 			}
 			msb.cfg.name = fmt.Sprintf(defaultNameTemplate, b)
 		}
-		if msb.cfg.apiKey == "" {
+		if msb.cfg.apiKey == "" && msb.cfg.tokenProvider == nil {
 			if envApiKey := os.Getenv("MSB_API_KEY"); envApiKey != "" {
 				msb.cfg.apiKey = envApiKey
 			} else {
@@ -119,5 +400,6 @@ func fillDefaultRPCClient() Option {
 var (
 	ErrLanguageMustBeSpecified    = errors.New("language must be specified")
 	ErrFailedToGenerateRandomName = errors.New("failed to generate random name")
-	ErrAPIKeyMustBeSpecified      = errors.New("API key must be specified either via WithApiKey() or MSB_API_KEY environment variable")
+	ErrInvalidCPUs                = errors.New("number of vCPUs must be between 1 and 255")
+	ErrAPIKeyMustBeSpecified      = errors.New("API key must be specified via WithApiKey(), WithTokenProvider() or MSB_API_KEY environment variable")
 )
@@ -0,0 +1,221 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// WithMaxOutputBytes caps the standard output and the standard error kept in the result of
+// every Code().Run and Command().Run to n bytes each; see [WithExecMaxOutputBytes]. n <= 0 means
+// no cap, the default.
+func WithMaxOutputBytes(n int64) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.maxOutputBytes = n
+	}
+}
+
+// WithExecMaxOutputBytes caps the standard output and the standard error kept in the result of
+// one execution to n bytes each, overriding [WithMaxOutputBytes]; n < 0 lifts the sandbox's cap.
+// The result's Truncation reports what was cut. Commands and code not run by a REPL spill their
+// full output to a directory in the sandbox instead, from which [FullOutputReader] fetches it on
+// demand; code run by a REPL is cut on the client, once received. The cap does not apply to
+// executions streamed with [WithOutputHandler], [WithStdout] or [WithStderr], whose output is
+// delivered in full as it is produced.
+func WithExecMaxOutputBytes(n int64) RunOption {
+	return func(rc *runConfig) {
+		rc.maxOutputBytes = n
+	}
+}
+
+// OutputTruncation reports how an execution's output was cut to the cap set with
+// [WithMaxOutputBytes] or [WithExecMaxOutputBytes].
+type OutputTruncation struct {
+	StdoutBytes    int64 // Size of the full standard output
+	StderrBytes    int64 // Size of the full standard error
+	TruncatedBytes int64 // Bytes left out of the result, over both streams
+	// Overflow is the directory inside the sandbox holding the full output, for
+	// [FullOutputReader]; empty if nothing was cut or the full output was not kept.
+	Overflow string
+}
+
+// Truncated reports whether output was left out of the result.
+func (t OutputTruncation) Truncated() bool {
+	return t.TruncatedBytes > 0
+}
+
+// FullOutputReader fetches the output that an execution capped with [WithExecMaxOutputBytes]
+// spilled in the sandbox.
+type FullOutputReader interface {
+	// ReadFullOutput returns the full standard output and standard error of the execution whose
+	// result reported t. It fails with [ErrNoFullOutput] if t has no Overflow directory, and with
+	// an error matching fs.ErrNotExist once the directory is removed.
+	ReadFullOutput(ctx context.Context, t OutputTruncation) (stdout, stderr []byte, err error)
+	// RemoveFullOutput deletes the Overflow directory of t. Spilled outputs otherwise stay until
+	// the sandbox stops.
+	RemoveFullOutput(ctx context.Context, t OutputTruncation) error
+}
+
+// guestOutputsDir holds the full outputs of capped executions, a directory each.
+const guestOutputsDir = "/tmp/.msb/outputs"
+
+// outputSizesTag starts the line a capped execution reports its full output sizes on.
+const outputSizesTag = "\x1emsb-output-sizes"
+
+// maxOutputBytes returns the cap on the output of an execution run with rc; 0 means no cap.
+func (b *baseMicroSandbox) maxOutputBytes(rc runConfig) int64 {
+	switch {
+	case rc.maxOutputBytes > 0:
+		return rc.maxOutputBytes
+	case rc.maxOutputBytes < 0:
+		return 0
+	}
+	return max(b.config().maxOutputBytes, 0)
+}
+
+// runShellCapped runs script like runShell, keeping at most limit bytes of each output stream in
+// the result and the full streams in a directory of guestOutputsDir, removed unless cut. The
+// streams' interleaving is not kept: stdout comes first. If measure is set, the result reports
+// the script's resource usage.
+func (b *baseMicroSandbox) runShellCapped(ctx context.Context, script string, limit int64, measure bool) (CommandExecution, error) {
+	if limit <= 0 && !measure {
+		return b.runShell(ctx, script)
+	}
+	if limit <= 0 {
+		exec, err := b.runShell(ctx, usageScript("(\n"+script+"\n)")+"\nexit $s")
+		return exec.withResourceUsage(), err
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return CommandExecution{}, err
+	}
+	dir := guestOutputsDir + "/" + id
+	n := strconv.FormatInt(limit, 10)
+	run := "(\n" + script + "\n) >\"$d/stdout\" 2>\"$d/stderr\""
+	if measure {
+		run = usageScript(run)
+	} else {
+		run += "; s=$?"
+	}
+	exec, err := b.runShell(ctx, `d=`+shellQuote(dir)+`; mkdir -p "$d" || exit 1
+`+run+`
+o=$(wc -c <"$d/stdout"); e=$(wc -c <"$d/stderr")
+printf '%s %s %s\n' '`+outputSizesTag+`' $o $e
+head -c `+n+` "$d/stdout"; head -c `+n+` "$d/stderr" >&2
+[ $o -gt `+n+` ] || [ $e -gt `+n+` ] || rm -rf "$d"
+exit $s`)
+	if err != nil {
+		return exec, err
+	}
+	return exec.withResourceUsage().withTruncation(dir, limit), nil
+}
+
+// withTruncation takes the sizes line written by runShellCapped out of the output, and records
+// the truncation it reports.
+func (ce CommandExecution) withTruncation(dir string, limit int64) CommandExecution {
+	lines := ce.parsed.OutputLines
+	i := slices.IndexFunc(lines, func(line OutputLine) bool {
+		return line.Stream == "stdout" && strings.HasPrefix(line.Text, outputSizesTag+" ")
+	})
+	if i < 0 {
+		return ce
+	}
+	var t OutputTruncation
+	if _, err := fmt.Sscan(strings.TrimPrefix(lines[i].Text, outputSizesTag), &t.StdoutBytes, &t.StderrBytes); err != nil {
+		return ce
+	}
+	t.TruncatedBytes = max(t.StdoutBytes-limit, 0) + max(t.StderrBytes-limit, 0)
+	if t.Truncated() {
+		t.Overflow = dir
+	}
+	ce.parsed.OutputLines = append(lines[:i:i], lines[i+1:]...)
+	ce.Output, _ = json.Marshal(ce.parsed)
+	ce.truncation = t
+	return ce
+}
+
+// capOutputLines keeps at most limit bytes of each stream of lines, counting a newline after
+// every line, and reports what was left out.
+func capOutputLines(lines []OutputLine, limit int64) ([]OutputLine, OutputTruncation) {
+	var (
+		t    OutputTruncation
+		kept []OutputLine
+	)
+	for _, line := range lines {
+		size := int64(len(line.Text)) + 1
+		total := &t.StdoutBytes
+		if line.Stream == "stderr" {
+			total = &t.StderrBytes
+		}
+		room := limit - *total
+		*total += size
+		switch {
+		case room >= size:
+			kept = append(kept, line)
+		case room > 1:
+			kept = append(kept, OutputLine{Stream: line.Stream, Text: strings.ToValidUTF8(line.Text[:room-1], "")})
+			t.TruncatedBytes += size - room
+		default:
+			t.TruncatedBytes += size - max(room, 0)
+		}
+	}
+	return kept, t
+}
+
+// withCappedOutput cuts the output of a code execution received in full to limit bytes per
+// stream.
+func (ce CodeExecution) withCappedOutput(limit int64) CodeExecution {
+	if limit <= 0 || !ce.parsedOK {
+		return ce
+	}
+	lines, t := capOutputLines(ce.parsed.OutputLines, limit)
+	if !t.Truncated() {
+		return ce
+	}
+	ce.parsed.OutputLines = lines
+	ce.Output, _ = json.Marshal(ce.parsed)
+	ce.truncation = t
+	return ce
+}
+
+type fullOutputReader struct {
+	b *baseMicroSandbox
+}
+
+func (r fullOutputReader) ReadFullOutput(ctx context.Context, t OutputTruncation) (stdout, stderr []byte, err error) {
+	if t.Overflow == "" {
+		return nil, nil, ErrNoFullOutput
+	}
+	fsys := fileSystem{r.b}
+	if stdout, err = fsys.ReadFile(ctx, t.Overflow+"/stdout"); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrFailedToReadFullOutput, err)
+	}
+	if stderr, err = fsys.ReadFile(ctx, t.Overflow+"/stderr"); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrFailedToReadFullOutput, err)
+	}
+	return stdout, stderr, nil
+}
+
+func (r fullOutputReader) RemoveFullOutput(ctx context.Context, t OutputTruncation) error {
+	if t.Overflow == "" {
+		return ErrNoFullOutput
+	}
+	exec, err := r.b.runShell(ctx, `rm -rf `+shellQuote(t.Overflow))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToRemoveFile, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToRemoveFile, commandStderr(exec))
+	}
+	return nil
+}
+
+// Output cap-related errors
+var (
+	ErrNoFullOutput           = errors.New("no full output kept for this execution")
+	ErrFailedToReadFullOutput = errors.New("failed to read full output")
+)
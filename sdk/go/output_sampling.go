@@ -0,0 +1,73 @@
+package msb
+
+import "time"
+
+// OutputSampling thins out the lines [WithOutputHandler] receives from executions printing at
+// high rates, so that a UI or a connection relaying them keeps up. Every line dropped is
+// counted in the Skipped field of the next line delivered from the same execution, and the last
+// line is always delivered, so the handler sees how the output ends and how much it missed.
+// The execution's result keeps every line.
+type OutputSampling struct {
+	// Every delivers one line in Every, starting with the first; 0 or 1 delivers all of them.
+	Every int
+	// Interval delivers at most one line per Interval; the latest line dropped is delivered
+	// once the Interval has passed, even if the execution prints nothing more. 0 means no limit.
+	Interval time.Duration
+}
+
+// WithOutputSampling samples the lines passed to [WithOutputHandler] as s describes:
+//
+//	// One line per second at most, however fast the build logs
+//	exec, err := sandbox.Command().Run(ctx, "make", nil,
+//		msb.WithOutputHandler(show), msb.WithOutputSampling(msb.OutputSampling{Interval: time.Second}))
+func WithOutputSampling(s OutputSampling) RunOption {
+	return func(rc *runConfig) {
+		rc.sampling = s
+	}
+}
+
+// outputSampler applies an OutputSampling to the lines of one execution.
+type outputSampler struct {
+	OutputSampling
+	seen      int
+	skipped   int
+	delivered time.Time   // when the last line was delivered; zero before the first
+	held      *OutputLine // the latest line dropped, delivered by flush
+}
+
+// sample reports whether line is delivered, with the count of lines dropped before it.
+func (s *outputSampler) sample(line OutputLine, now time.Time) (OutputLine, bool) {
+	s.seen++
+	if s.Every > 1 && (s.seen-1)%s.Every != 0 ||
+		s.Interval > 0 && !s.delivered.IsZero() && now.Sub(s.delivered) < s.Interval {
+		s.skipped++
+		s.held = &line
+		return OutputLine{}, false
+	}
+	return s.deliver(line, now), true
+}
+
+// due returns the line held back if Interval has passed since the last delivery.
+func (s *outputSampler) due(now time.Time) (OutputLine, bool) {
+	if s.held == nil || s.Interval <= 0 || now.Sub(s.delivered) < s.Interval {
+		return OutputLine{}, false
+	}
+	return s.flush(now)
+}
+
+// flush returns the line held back, once the execution is done or due.
+func (s *outputSampler) flush(now time.Time) (OutputLine, bool) {
+	if s.held == nil {
+		return OutputLine{}, false
+	}
+	s.skipped--
+	return s.deliver(*s.held, now), true
+}
+
+func (s *outputSampler) deliver(line OutputLine, now time.Time) OutputLine {
+	line.Skipped = s.skipped
+	s.skipped = 0
+	s.delivered = now
+	s.held = nil
+	return line
+}
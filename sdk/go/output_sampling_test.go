@@ -0,0 +1,51 @@
+package msb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+func TestOutputSampling(t *testing.T) {
+	tests := []struct {
+		name     string
+		sampling msb.OutputSampling
+		want     []string // lines delivered
+	}{
+		{"all", msb.OutputSampling{}, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}},
+		{"every third", msb.OutputSampling{Every: 3}, []string{"1", "4", "7", "10"}},
+		{"every fourth keeps the last", msb.OutputSampling{Every: 4}, []string{"1", "5", "9", "10"}},
+		{"interval", msb.OutputSampling{Interval: time.Hour}, []string{"1", "10"}},
+	}
+	ctx := context.Background()
+	sandbox := newLocalSandbox(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []msb.OutputLine
+			exec, err := sandbox.Command().Run(ctx, "seq", []string{"10"},
+				msb.WithOutputHandler(func(line msb.OutputLine) { got = append(got, line) }),
+				msb.WithOutputSampling(tt.sampling))
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("delivered %v, want %v", got, tt.want)
+			}
+			total := 0
+			for i, line := range got {
+				if line.Text != tt.want[i] {
+					t.Errorf("line %d = %q, want %q", i, line.Text, tt.want[i])
+				}
+				total += 1 + line.Skipped
+			}
+			if total != 10 {
+				t.Errorf("delivered and skipped lines add up to %d, want 10", total)
+			}
+			if out, _ := exec.GetOutput(); out != "1\n2\n3\n4\n5\n6\n7\n8\n9\n10" {
+				t.Errorf("result output = %q, want every line", out)
+			}
+		})
+	}
+}
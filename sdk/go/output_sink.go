@@ -0,0 +1,173 @@
+package msb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutputSink receives execution output for centralized logging. Sinks are registered with
+// [WithOutputSink] and are called synchronously as output becomes available: once per
+// Code().Run or Command().Run, and on every poll of a background [Process]. Implementations
+// that ship to a remote service should buffer if latency matters. Errors are logged, never
+// returned to the execution's caller.
+type OutputSink interface {
+	WriteOutput(ctx context.Context, records []OutputRecord) error
+}
+
+// OutputRecord is a single line of execution output with its labels.
+type OutputRecord struct {
+	Time      time.Time
+	Namespace string
+	Sandbox   string
+	Execution string // Identifies the execution within the sandbox
+	Stream    string // "stdout" or "stderr"
+	Text      string
+}
+
+// emitOutput sends lines produced by an execution to the configured sinks.
+func (b *baseMicroSandbox) emitOutput(ctx context.Context, execID string, lines []OutputLine) {
+	cfg := b.config()
+	if len(cfg.outputSinks) == 0 || len(lines) == 0 {
+		return
+	}
+	now := time.Now()
+	records := make([]OutputRecord, len(lines))
+	for i, line := range lines {
+		records[i] = OutputRecord{
+			Time:      now,
+			Namespace: cfg.namespace,
+			Sandbox:   cfg.name,
+			Execution: execID,
+			Stream:    line.Stream,
+			Text:      line.Text,
+		}
+	}
+	for _, sink := range cfg.outputSinks {
+		if err := sink.WriteOutput(ctx, records); err != nil {
+			cfg.logger.Error("Output sink failed", "sandbox", cfg.name, "execution", execID, "error", err)
+		}
+	}
+}
+
+// emitExecutionOutput sends the output of a completed execution to the configured sinks
+// under a freshly generated execution ID.
+func (b *baseMicroSandbox) emitExecutionOutput(ctx context.Context, lines []OutputLine) {
+	if len(b.config().outputSinks) == 0 || len(lines) == 0 {
+		return
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return
+	}
+	b.emitOutput(ctx, id, lines)
+}
+
+// lineSplitter turns a byte stream into complete lines, holding back a trailing partial line.
+type lineSplitter struct {
+	stream  string
+	pending []byte
+}
+
+func (ls *lineSplitter) write(p []byte) []OutputLine {
+	ls.pending = append(ls.pending, p...)
+	var lines []OutputLine
+	for {
+		i := bytes.IndexByte(ls.pending, '\n')
+		if i < 0 {
+			return lines
+		}
+		lines = append(lines, OutputLine{Stream: ls.stream, Text: string(ls.pending[:i]), Time: time.Now()})
+		ls.pending = ls.pending[i+1:]
+	}
+}
+
+func (ls *lineSplitter) flush() []OutputLine {
+	if len(ls.pending) == 0 {
+		return nil
+	}
+	line := OutputLine{Stream: ls.stream, Text: string(ls.pending), Time: time.Now()}
+	ls.pending = nil
+	return []OutputLine{line}
+}
+
+// --- Loki ---
+
+// LokiSink pushes execution output to Grafana Loki's push API. Each record becomes a log line in
+// the stream labelled with its namespace, sandbox, execution and stream, plus any static Labels.
+type LokiSink struct {
+	URL      string            // Base URL of the Loki server, e.g. "http://loki:3100"
+	TenantID string            // Sent as X-Scope-OrgID when set, for multi-tenant Loki
+	Labels   map[string]string // Static labels added to every stream, e.g. {"app": "agent"}
+	Client   *http.Client      // Defaults to http.DefaultClient
+}
+
+// NewLokiSink creates a sink pushing to the Loki server at url.
+func NewLokiSink(url string) *LokiSink {
+	return &LokiSink{URL: url}
+}
+
+func (s *LokiSink) WriteOutput(ctx context.Context, records []OutputRecord) error {
+	type lokiStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var streams []*lokiStream
+	index := map[string]*lokiStream{}
+	for _, r := range records {
+		key := r.Namespace + "\x00" + r.Sandbox + "\x00" + r.Execution + "\x00" + r.Stream
+		st, ok := index[key]
+		if !ok {
+			labels := map[string]string{
+				"namespace": r.Namespace,
+				"sandbox":   r.Sandbox,
+				"execution": r.Execution,
+				"stream":    r.Stream,
+			}
+			for k, v := range s.Labels {
+				labels[k] = v
+			}
+			st = &lokiStream{Stream: labels}
+			index[key] = st
+			streams = append(streams, st)
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(r.Time.UnixNano(), 10), r.Text})
+	}
+
+	body, err := json.Marshal(map[string]any{"streams": streams})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrOutputSinkFailed, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.URL, "/")+"/loki/api/v1/push", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrOutputSinkFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.TenantID)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrOutputSinkFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%w: loki returned status %d: %s", ErrOutputSinkFailed, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// ErrOutputSinkFailed is returned by sinks that could not deliver output.
+var ErrOutputSinkFailed = errors.New("output sink failed")
@@ -0,0 +1,130 @@
+package msb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+const (
+	// guestPayloadsDir holds code uploaded because it was too large to send inline.
+	guestPayloadsDir = "/tmp/.msb/payloads"
+	// payloadChunkBytes keeps each upload command well below the kernel's 128 KiB limit on a
+	// single argument, once base64-encoded.
+	payloadChunkBytes = 64 * 1024
+)
+
+// isPayloadTooLarge reports whether err is the server rejecting a request body as too large.
+func isPayloadTooLarge(err error) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) && rpcErr.StatusCode == http.StatusRequestEntityTooLarge
+}
+
+// runFromFile uploads code to the guest in chunks and runs a small loader that executes the file
+// in the REPL's global scope, so the result is the same as running the code inline.
+func (cr codeRunner) runFromFile(ctx context.Context, rt *languageRuntime, code string) (CodeExecution, error) {
+	id, err := newGuestJobID()
+	if err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	path := guestPayloadsDir + "/" + id
+	if err := cr.b.uploadPayload(ctx, path, []byte(code)); err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	defer func() { _, _ = cr.b.runShell(context.WithoutCancel(ctx), `rm -f `+shellQuote(path)) }()
+
+	var loader string
+	switch rt.Name {
+	case LanguagePython:
+		loader = `exec(compile(open(` + strconv.Quote(path) + `).read(), ` + strconv.Quote(path) + `, "exec"))`
+	case LanguageNodeJS:
+		loader = `(0, eval)(require("fs").readFileSync(` + strconv.Quote(path) + `, "utf8"))`
+	default:
+		loader = `. ` + shellQuote(path)
+	}
+	exec, err := cr.run(ctx, rt, loader)
+	exec.uploaded = true
+	return exec, err
+}
+
+// runStreamed runs code as a fresh interpreter process, polled in the background when its output
+// is streamed or timed; see [WithOutputHandler] and [WithExecEnv].
+func (cr codeRunner) runStreamed(ctx context.Context, rt *languageRuntime, code string, rc runConfig) (CodeExecution, error) {
+	if !rt.shell {
+		return cr.runExec(ctx, rt, code, rc)
+	}
+	prelude, err := cr.b.shellPrelude(rc)
+	if err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	if !rc.polled() {
+		exec, err := cr.b.runShellCapped(ctx, prelude+code, cr.b.maxOutputBytes(rc), rc.resourceUsage)
+		if err != nil {
+			return terminatedCodeExecution(rt.Name, err), fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+		}
+		return checkParsed(cr.b, codeExecutionFromCommand(exec, rt.Name), ErrFailedToRunCode)
+	}
+	exec, err := cr.b.runGuestCommand(ctx, prelude+code, string(rt.Name), nil, rc)
+	if err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	return codeExecutionFromCommand(exec, rt.Name), nil
+}
+
+// runExec uploads code to the guest and runs it with the runtime's Exec template. This is how
+// registered languages always run, and how REPL languages run when their output is streamed.
+func (cr codeRunner) runExec(ctx context.Context, rt *languageRuntime, code string, rc runConfig) (CodeExecution, error) {
+	prelude, err := cr.b.shellPrelude(rc)
+	if err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	path := guestPayloadsDir + "/" + id
+	if err := cr.b.uploadPayload(ctx, path, []byte(code)); err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	defer func() { _, _ = cr.b.runShell(context.WithoutCancel(ctx), `rm -f `+shellQuote(path)) }()
+
+	if rc.polled() {
+		exec, err := cr.b.runGuestCommand(ctx, prelude+rt.command(path), string(rt.Name), nil, rc)
+		if err != nil {
+			return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+		}
+		return codeExecutionFromCommand(exec, rt.Name), nil
+	}
+	exec, err := cr.b.runShellCapped(ctx, prelude+rt.command(path), cr.b.maxOutputBytes(rc), rc.resourceUsage)
+	if err != nil {
+		return terminatedCodeExecution(rt.Name, err), fmt.Errorf("%w: %w", ErrFailedToRunCode, err)
+	}
+	return checkParsed(cr.b, codeExecutionFromCommand(exec, rt.Name), ErrFailedToRunCode)
+}
+
+// uploadPayload writes data to dest inside the guest, a chunk per command.
+func (b *baseMicroSandbox) uploadPayload(ctx context.Context, dest string, data []byte) error {
+	p := shellQuote(dest)
+	if _, err := b.runShell(ctx, `mkdir -p `+shellQuote(path.Dir(dest))+` && : >`+p); err != nil {
+		return err
+	}
+	for off := 0; off < len(data); off += payloadChunkBytes {
+		chunk := data[off:min(off+payloadChunkBytes, len(data))]
+		exec, err := b.runShell(ctx, `printf '%s' `+base64.StdEncoding.EncodeToString(chunk)+` | base64 -d >>`+p)
+		if err != nil {
+			return err
+		}
+		if !exec.IsSuccess() {
+			stderr, _ := exec.GetError()
+			return fmt.Errorf("%w: %s", ErrPayloadUploadFailed, stderr)
+		}
+	}
+	return nil
+}
+
+// ErrPayloadUploadFailed is returned when oversized code cannot be uploaded to the sandbox.
+var ErrPayloadUploadFailed = errors.New("failed to upload code payload")
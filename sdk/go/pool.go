@@ -0,0 +1,437 @@
+package msb
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PoolConfig configures a [Pool].
+type PoolConfig struct {
+	// Size is the number of sandboxes started up front, and the most the pool keeps alive. With
+	// Autoscale, it is only the initial size, and defaults to Autoscale.Min.
+	Size int
+	// Name prefixes the names of the pooled sandboxes. Defaults to a random "pool-" name.
+	Name string
+	// NewSandbox creates a pooled sandbox. Defaults to [NewPythonSandbox].
+	NewSandbox func(options ...Option) LangSandBox
+	// Options configure every pooled sandbox. The pool adds a WithName option of its own.
+	Options []Option
+	// Image, MemoryMB and CPUs are passed to Start.
+	Image    string
+	MemoryMB int
+	CPUs     int
+	// MaxIdle stops sandboxes left idle for longer, shrinking the pool until demand returns.
+	// Zero keeps idle sandboxes forever.
+	MaxIdle time.Duration
+	// MaxUses replaces a sandbox with a fresh one after it has been acquired this many times.
+	// 1 gives every caller a pristine sandbox; zero reuses sandboxes indefinitely.
+	MaxUses int
+	// Reset is called on Release to clean a sandbox up for its next user, e.g. by deleting
+	// scratch files. A sandbox whose reset fails is replaced.
+	Reset func(ctx context.Context, sandbox LangSandBox) error
+	// HealthCheck is called on Acquire before handing out an idle sandbox; unhealthy sandboxes
+	// are replaced. Defaults to checking that the server reports the sandbox as running.
+	HealthCheck func(ctx context.Context, sandbox LangSandBox) error
+	// Autoscale, if set, grows and shrinks the pool with demand; see [PoolAutoscale].
+	Autoscale *PoolAutoscale
+	// Metrics, if set, records the pool's occupancy whenever it changes, as
+	// [MetricPoolSandboxes] and [MetricPoolUtilization]. Pass [WithMetrics] in Options to record
+	// the pooled sandboxes' RPCs too.
+	Metrics MetricsRecorder
+}
+
+// PoolStats is a snapshot of a pool's occupancy.
+type PoolStats struct {
+	Capacity int // Sandboxes the pool currently keeps alive at most
+	Live     int // Sandboxes started and not yet stopped, including ones starting up
+	Idle     int // Sandboxes ready to be acquired
+	InUse    int // Sandboxes acquired and not yet released
+}
+
+// Pool keeps a set of started sandboxes warm, so that latency-sensitive callers do not pay for a
+// cold start on every request. It is safe for concurrent use.
+//
+//	pool, err := msb.NewPool(ctx, msb.PoolConfig{Size: 4, MaxUses: 1})
+//	sandbox, err := pool.Acquire(ctx)
+//	defer pool.Release(ctx, sandbox)
+type Pool struct {
+	cfg PoolConfig
+
+	mu       sync.Mutex
+	idle     []*pooledSandbox // most recently released last
+	inUse    map[LangSandBox]*pooledSandbox
+	live     int
+	capacity int // Size, or the autoscaled size
+	seq      int
+	closed   bool
+	demand   poolDemand
+
+	changed  chan struct{}             // closed and replaced whenever a sandbox may have become available
+	done     chan struct{}             // closed by Close to stop the idle reaper
+	affinity map[string]*pooledSandbox // sandbox last acquired with each affinity key
+}
+
+type pooledSandbox struct {
+	sandbox   LangSandBox
+	uses      int
+	idleSince time.Time
+	affinity  string // key the sandbox was last acquired with
+}
+
+// AcquireOption configures [Pool.Acquire].
+type AcquireOption func(*acquireConfig)
+
+type acquireConfig struct {
+	affinity string
+}
+
+// WithAffinity makes Acquire prefer the sandbox last acquired with key, so that the executions of
+// a logical session, e.g. a user's, land on the same sandbox and find the state and caches they
+// left. If that sandbox is in use, unhealthy or gone, e.g. replaced after MaxUses, any other
+// sandbox is handed out as usual, and becomes the one preferred for key.
+func WithAffinity(key string) AcquireOption {
+	return func(ac *acquireConfig) {
+		ac.affinity = key
+	}
+}
+
+// NewPool starts cfg.Size sandboxes and returns a pool handing them out. If any of them fails to
+// start, the others are stopped again and the error is returned.
+func NewPool(ctx context.Context, cfg PoolConfig) (*Pool, error) {
+	if cfg.Autoscale != nil {
+		as := *cfg.Autoscale
+		cfg.Autoscale = &as
+		if err := as.validate(); err != nil {
+			return nil, err
+		}
+		if cfg.Size <= 0 {
+			cfg.Size = as.Min
+		}
+		cfg.Size = min(max(cfg.Size, as.Min), as.Max)
+	}
+	if cfg.Size <= 0 {
+		return nil, fmt.Errorf("%w: size must be positive", ErrInvalidPoolConfig)
+	}
+	if cfg.Name == "" {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPoolConfig, err)
+		}
+		cfg.Name = fmt.Sprintf("pool-%x", suffix)
+	}
+	if cfg.NewSandbox == nil {
+		cfg.NewSandbox = func(options ...Option) LangSandBox { return NewPythonSandbox(options...) }
+	}
+	if cfg.HealthCheck == nil {
+		cfg.HealthCheck = func(ctx context.Context, sandbox LangSandBox) error {
+			running, err := sandbox.Metrics().IsRunning(ctx)
+			if err == nil && !running {
+				err = ErrSandboxNotRunning
+			}
+			return err
+		}
+	}
+	p := &Pool{
+		cfg:      cfg,
+		inUse:    map[LangSandBox]*pooledSandbox{},
+		affinity: map[string]*pooledSandbox{},
+		live:     cfg.Size,
+		capacity: cfg.Size,
+		changed:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	sandboxes := make([]LangSandBox, cfg.Size)
+	for i := range sandboxes {
+		sandboxes[i] = p.newSandbox()
+	}
+	if err := StartAll(ctx, sandboxes, 0, cfg.Image, cfg.MemoryMB, cfg.CPUs); err != nil {
+		_ = StopAll(context.WithoutCancel(ctx), sandboxes, 0)
+		return nil, err
+	}
+	now := time.Now()
+	for _, sb := range sandboxes {
+		p.idle = append(p.idle, &pooledSandbox{sandbox: sb, idleSince: now})
+	}
+	if cfg.MaxIdle > 0 {
+		go p.reapIdle()
+	}
+	if cfg.Autoscale != nil {
+		go p.autoscale()
+	}
+	p.recordStats()
+	return p, nil
+}
+
+// Acquire hands out an idle sandbox, starting a new one if the pool has shrunk below its size,
+// and otherwise waits until another caller releases one or ctx is done; see [WithAffinity] to
+// prefer a given sandbox. Every acquired sandbox must be handed back with Release.
+func (p *Pool) Acquire(ctx context.Context, opts ...AcquireOption) (LangSandBox, error) {
+	var ac acquireConfig
+	for _, opt := range opts {
+		opt(&ac)
+	}
+	var waited time.Duration
+	defer func() { p.recordWait(waited) }()
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if e := p.takeIdle(ac.affinity); e != nil {
+			p.mu.Unlock()
+			if err := p.cfg.HealthCheck(ctx, e.sandbox); err != nil {
+				if ctx.Err() != nil {
+					p.putIdle(e)
+					return nil, ctx.Err()
+				}
+				_ = p.discard(ctx, e)
+				continue
+			}
+			return p.hand(e, ac.affinity), nil
+		}
+		if p.live < p.capacity {
+			p.live++
+			p.mu.Unlock()
+			begin := time.Now()
+			e, err := p.start(ctx)
+			waited += time.Since(begin)
+			if err != nil {
+				return nil, err
+			}
+			return p.hand(e, ac.affinity), nil
+		}
+		changed := p.changed
+		p.demand.waiting++
+		p.mu.Unlock()
+
+		begin := time.Now()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+		}
+		waited += time.Since(begin)
+		p.mu.Lock()
+		p.demand.waiting--
+		p.mu.Unlock()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Release hands a sandbox obtained from Acquire back to the pool. The sandbox is reset, or
+// replaced with a fresh one once it reaches MaxUses; after Close, it is stopped instead.
+func (p *Pool) Release(ctx context.Context, sandbox LangSandBox) error {
+	p.mu.Lock()
+	e, ok := p.inUse[sandbox]
+	delete(p.inUse, sandbox)
+	closed := p.closed
+	p.mu.Unlock()
+	if !ok {
+		return ErrNotFromPool
+	}
+
+	switch {
+	case closed:
+		return p.discard(ctx, e)
+	case p.cfg.MaxUses > 0 && e.uses >= p.cfg.MaxUses:
+		p.replace(ctx, e)
+		return nil
+	case p.cfg.Reset != nil:
+		if err := p.cfg.Reset(ctx, sandbox); err != nil {
+			p.replace(ctx, e)
+			return fmt.Errorf("%w: %w", ErrPoolResetFailed, err)
+		}
+	}
+	p.putIdle(e)
+	return nil
+}
+
+// Stats returns the pool's current occupancy.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{Capacity: p.capacity, Live: p.live, Idle: len(p.idle), InUse: len(p.inUse)}
+}
+
+// Close stops every idle sandbox. Sandboxes still in use are stopped as they are released,
+// and Acquire fails with [ErrPoolClosed] from now on.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	close(p.done)
+	p.notify()
+
+	errs := make([]error, len(idle))
+	for i, e := range idle {
+		errs[i] = p.discard(ctx, e)
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pool) newSandbox() LangSandBox {
+	p.mu.Lock()
+	p.seq++
+	name := fmt.Sprintf("%s-%d", p.cfg.Name, p.seq)
+	p.mu.Unlock()
+	return p.cfg.NewSandbox(append(p.cfg.Options[:len(p.cfg.Options):len(p.cfg.Options)], WithName(name))...)
+}
+
+// start brings up a sandbox for a slot already counted in live.
+func (p *Pool) start(ctx context.Context) (*pooledSandbox, error) {
+	sb := p.newSandbox()
+	if err := sb.Start(ctx, p.cfg.Image, p.cfg.MemoryMB, p.cfg.CPUs); err != nil {
+		p.mu.Lock()
+		p.live--
+		p.mu.Unlock()
+		p.notify()
+		return nil, err
+	}
+	return &pooledSandbox{sandbox: sb}, nil
+}
+
+// takeIdle removes an idle sandbox from the pool and returns it: the one last acquired with
+// affinity if it is idle, and otherwise the most recently released one. It returns nil if no
+// sandbox is idle. p.mu must be held.
+func (p *Pool) takeIdle(affinity string) *pooledSandbox {
+	n := len(p.idle)
+	if n == 0 {
+		return nil
+	}
+	i := n - 1
+	if preferred, ok := p.affinity[affinity]; ok && affinity != "" {
+		if j := slices.Index(p.idle, preferred); j >= 0 {
+			i = j
+		}
+	}
+	e := p.idle[i]
+	p.idle = slices.Delete(p.idle, i, i+1)
+	return e
+}
+
+// hand marks a sandbox as acquired, with affinity if not empty.
+func (p *Pool) hand(e *pooledSandbox, affinity string) LangSandBox {
+	e.uses++
+	p.mu.Lock()
+	if affinity != "" {
+		p.unbind(e)
+		e.affinity = affinity
+		p.affinity[affinity] = e
+	}
+	p.inUse[e.sandbox] = e
+	p.demand.peakInUse = max(p.demand.peakInUse, len(p.inUse))
+	p.mu.Unlock()
+	p.recordStats()
+	return e.sandbox
+}
+
+func (p *Pool) putIdle(e *pooledSandbox) {
+	e.idleSince = time.Now()
+	p.mu.Lock()
+	if p.closed || p.live > p.capacity {
+		// Closed, or shrunk by the autoscaler while the sandbox was in use
+		p.mu.Unlock()
+		_ = p.discard(context.Background(), e)
+		return
+	}
+	p.idle = append(p.idle, e)
+	p.mu.Unlock()
+	p.notify()
+}
+
+// unbind forgets the affinity of a sandbox. p.mu must be held.
+func (p *Pool) unbind(e *pooledSandbox) {
+	if p.affinity[e.affinity] == e {
+		delete(p.affinity, e.affinity)
+	}
+	e.affinity = ""
+}
+
+// discard stops a sandbox and frees its slot.
+func (p *Pool) discard(ctx context.Context, e *pooledSandbox) error {
+	err := e.sandbox.Stop(ctx)
+	p.mu.Lock()
+	p.live--
+	p.unbind(e)
+	p.mu.Unlock()
+	p.notify()
+	return err
+}
+
+// replace discards a sandbox and warms up a fresh one in its place in the background.
+func (p *Pool) replace(ctx context.Context, e *pooledSandbox) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		_ = p.discard(ctx, e)
+		p.mu.Lock()
+		if p.closed || p.live >= p.capacity {
+			p.mu.Unlock()
+			return
+		}
+		p.live++
+		p.mu.Unlock()
+		if fresh, err := p.start(ctx); err == nil {
+			p.putIdle(fresh)
+		}
+	}()
+}
+
+// reapIdle stops sandboxes that have been idle for longer than MaxIdle.
+func (p *Pool) reapIdle() {
+	ticker := time.NewTicker(max(p.cfg.MaxIdle/2, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		p.mu.Lock()
+		var expired []*pooledSandbox
+		kept := p.idle[:0]
+		for _, e := range p.idle {
+			if time.Since(e.idleSince) > p.cfg.MaxIdle && p.live-len(expired) > p.minLive() {
+				expired = append(expired, e)
+			} else {
+				kept = append(kept, e)
+			}
+		}
+		p.idle = kept
+		p.mu.Unlock()
+		for _, e := range expired {
+			_ = p.discard(context.Background(), e)
+		}
+	}
+}
+
+// notify wakes up every waiting Acquire.
+func (p *Pool) notify() {
+	p.mu.Lock()
+	close(p.changed)
+	p.changed = make(chan struct{})
+	p.mu.Unlock()
+	p.recordStats()
+}
+
+// Pool-related errors
+var (
+	ErrInvalidPoolConfig = errors.New("invalid pool configuration")
+	ErrPoolClosed        = errors.New("pool closed")
+	ErrNotFromPool       = errors.New("sandbox was not acquired from this pool")
+	ErrPoolResetFailed   = errors.New("failed to reset pooled sandbox")
+	ErrSandboxNotRunning = errors.New("sandbox not running")
+)
@@ -0,0 +1,142 @@
+package msb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// PoolAutoscale grows a [Pool] between Min and Max warm sandboxes when callers wait for one or
+// most of the pool is in use, and shrinks it when demand drops, so that traffic spikes are
+// absorbed without an external controller. The pool doubles when it grows, to catch up with a
+// spike quickly, and halves at most when it shrinks.
+type PoolAutoscale struct {
+	// Min and Max bound the number of sandboxes the pool keeps alive. Min must be positive.
+	Min int
+	Max int
+	// Interval is how often demand is evaluated. Defaults to 5 seconds.
+	Interval time.Duration
+	// TargetWait is the longest an Acquire may wait for a sandbox before the pool grows.
+	// Defaults to 100ms.
+	TargetWait time.Duration
+	// TargetUtilization is the share of the pool in use, from 0 to 1, above which the pool grows;
+	// it shrinks back to the size at which its peak usage would be at the target. Defaults to
+	// 0.75.
+	TargetUtilization float64
+}
+
+// poolDemand accumulates the demand on a pool between two autoscaler evaluations.
+type poolDemand struct {
+	waiting   int // Acquire calls waiting for a sandbox right now
+	slow      int // Acquire calls that waited longer than TargetWait
+	peakInUse int // most sandboxes in use at once
+}
+
+func (as *PoolAutoscale) validate() error {
+	switch {
+	case as.Min <= 0:
+		return fmt.Errorf("%w: autoscale min must be positive", ErrInvalidPoolConfig)
+	case as.Max < as.Min:
+		return fmt.Errorf("%w: autoscale max must be at least min", ErrInvalidPoolConfig)
+	case as.TargetUtilization < 0 || as.TargetUtilization > 1:
+		return fmt.Errorf("%w: autoscale target utilization must be between 0 and 1", ErrInvalidPoolConfig)
+	}
+	if as.Interval <= 0 {
+		as.Interval = 5 * time.Second
+	}
+	if as.TargetWait <= 0 {
+		as.TargetWait = 100 * time.Millisecond
+	}
+	if as.TargetUtilization == 0 {
+		as.TargetUtilization = 0.75
+	}
+	return nil
+}
+
+// minLive returns the fewest sandboxes the idle reaper leaves alive.
+func (p *Pool) minLive() int {
+	if p.cfg.Autoscale == nil {
+		return 0
+	}
+	return p.cfg.Autoscale.Min
+}
+
+// recordWait accounts for an Acquire call that waited for a sandbox.
+func (p *Pool) recordWait(waited time.Duration) {
+	if p.cfg.Autoscale == nil || waited <= p.cfg.Autoscale.TargetWait {
+		return
+	}
+	p.mu.Lock()
+	p.demand.slow++
+	p.mu.Unlock()
+}
+
+// capacity returns the capacity of a pool of the given capacity after an evaluation of demand:
+// doubled, up to Max, if callers waited or usage peaked above TargetUtilization, and otherwise
+// halved at most, down to the size at which the peak usage would be at the target and no less
+// than Min, if usage peaked below half the target.
+func (as *PoolAutoscale) capacity(capacity int, demand poolDemand) int {
+	utilization := float64(demand.peakInUse) / float64(capacity)
+	switch {
+	case demand.waiting > 0 || demand.slow > 0 || utilization > as.TargetUtilization:
+		return min(capacity*2, as.Max)
+	case utilization < as.TargetUtilization/2:
+		needed := int(float64(demand.peakInUse)/as.TargetUtilization) + 1
+		return max(capacity/2, needed, as.Min)
+	}
+	return capacity
+}
+
+// resizePool returns how many of the idle sandboxes of a pool with live sandboxes to stop, and
+// how many sandboxes to start, for the pool to reach capacity. Sandboxes in use above capacity
+// are stopped as they are released, by putIdle.
+func resizePool(capacity, live, idle int) (surplus, missing int) {
+	return max(min(live-capacity, idle), 0), max(capacity-live, 0)
+}
+
+// autoscale evaluates demand every Interval and resizes the pool until it is closed.
+func (p *Pool) autoscale() {
+	as := p.cfg.Autoscale
+	ticker := time.NewTicker(as.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		demand := p.demand
+		p.demand = poolDemand{waiting: demand.waiting, peakInUse: len(p.inUse)}
+		capacity := as.capacity(p.capacity, demand)
+		if capacity == p.capacity {
+			p.mu.Unlock()
+			continue
+		}
+		p.capacity = capacity
+		surplus, missing := resizePool(capacity, p.live, len(p.idle))
+		// The longest idle sandboxes go first
+		excess := slices.Clone(p.idle[:surplus])
+		p.idle = append(p.idle[:0], p.idle[surplus:]...)
+		p.live += missing
+		p.mu.Unlock()
+		p.recordStats()
+
+		for _, e := range excess {
+			_ = p.discard(context.Background(), e)
+		}
+		for range missing {
+			go func() {
+				if e, err := p.start(context.Background()); err == nil {
+					p.putIdle(e)
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package msb
+
+import "testing"
+
+func TestPoolAutoscaleCapacity(t *testing.T) {
+	as := &PoolAutoscale{Min: 2, Max: 16}
+	if err := as.validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		capacity int
+		demand   poolDemand
+		want     int
+	}{
+		{"steady", 8, poolDemand{peakInUse: 5}, 8},
+		{"waiting callers double", 4, poolDemand{waiting: 1, peakInUse: 1}, 8},
+		{"slow acquires double", 4, poolDemand{slow: 3}, 8},
+		{"busy pool doubles", 4, poolDemand{peakInUse: 4}, 8},
+		{"at the target utilization", 4, poolDemand{peakInUse: 3}, 4},
+		{"growth stops at max", 12, poolDemand{waiting: 2}, 16},
+		{"at max", 16, poolDemand{waiting: 2, peakInUse: 16}, 16},
+		{"idle pool halves", 16, poolDemand{}, 8},
+		{"low usage halves", 16, poolDemand{peakInUse: 2}, 8},
+		{"halves at most", 16, poolDemand{peakInUse: 5}, 8},
+		{"needed size above half", 9, poolDemand{peakInUse: 3}, 5},
+		{"between half the target and the target", 12, poolDemand{peakInUse: 5}, 12},
+		{"shrink stops at min", 3, poolDemand{}, 2},
+		{"at min", 2, poolDemand{}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := as.capacity(tt.capacity, tt.demand); got != tt.want {
+				t.Errorf("capacity(%d, %+v) = %d, want %d", tt.capacity, tt.demand, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResizePool(t *testing.T) {
+	tests := []struct {
+		name                 string
+		capacity, live, idle int
+		surplus, missing     int
+	}{
+		{"grow", 8, 4, 1, 0, 4},
+		{"grow while starting", 8, 6, 0, 0, 2},
+		{"shrink idle pool", 4, 8, 8, 4, 0},
+		{"shrink busy pool", 4, 8, 2, 2, 0}, // the rest are stopped by putIdle on release
+		{"shrink fully busy pool", 4, 8, 0, 0, 0},
+		{"already shrunk by the reaper", 4, 3, 3, 0, 1},
+		{"at capacity", 4, 4, 2, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			surplus, missing := resizePool(tt.capacity, tt.live, tt.idle)
+			if surplus != tt.surplus || missing != tt.missing {
+				t.Errorf("resizePool(%d, %d, %d) = %d, %d; want %d, %d",
+					tt.capacity, tt.live, tt.idle, surplus, missing, tt.surplus, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package msb_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+	"github.com/keithang/microsandbox/sdk/go/msbtest"
+)
+
+// fakePool is a pool of fake sandboxes, recording every sandbox it creates.
+type fakePool struct {
+	*msb.Pool
+	mu      sync.Mutex
+	created []*msbtest.FakeSandbox
+}
+
+func newFakePool(t *testing.T, cfg msb.PoolConfig) *fakePool {
+	t.Helper()
+	fp := &fakePool{}
+	cfg.Image = "img"
+	cfg.NewSandbox = func(options ...msb.Option) msb.LangSandBox {
+		sandbox := msbtest.NewFakeSandbox(msb.LanguagePython, options...)
+		fp.mu.Lock()
+		defer fp.mu.Unlock()
+		fp.created = append(fp.created, sandbox)
+		return sandbox
+	}
+	pool, err := msb.NewPool(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	fp.Pool = pool
+	t.Cleanup(func() { _ = pool.Close(context.Background()) })
+	return fp
+}
+
+func (fp *fakePool) sandbox(i int) *msbtest.FakeSandbox {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	if i >= len(fp.created) {
+		return nil
+	}
+	return fp.created[i]
+}
+
+// waitStats waits for the pool's stats to become want, as replacements happen in the background.
+func (fp *fakePool) waitStats(t *testing.T, want msb.PoolStats) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for fp.Stats() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats() = %+v, want %+v", fp.Stats(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (fp *fakePool) acquire(t *testing.T, opts ...msb.AcquireOption) msb.LangSandBox {
+	t.Helper()
+	sandbox, err := fp.Acquire(context.Background(), opts...)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	return sandbox
+}
+
+func (fp *fakePool) release(t *testing.T, sandbox msb.LangSandBox) {
+	t.Helper()
+	if err := fp.Release(context.Background(), sandbox); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+}
+
+func TestPoolAcquireRelease(t *testing.T) {
+	pool := newFakePool(t, msb.PoolConfig{Size: 2})
+	pool.waitStats(t, msb.PoolStats{Capacity: 2, Live: 2, Idle: 2})
+
+	a := pool.acquire(t)
+	pool.waitStats(t, msb.PoolStats{Capacity: 2, Live: 2, Idle: 1, InUse: 1})
+	b := pool.acquire(t)
+	pool.waitStats(t, msb.PoolStats{Capacity: 2, Live: 2, InUse: 2})
+	if a == b {
+		t.Fatal("the same sandbox was acquired twice")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire from an exhausted pool = %v, want DeadlineExceeded", err)
+	}
+
+	pool.release(t, a)
+	pool.waitStats(t, msb.PoolStats{Capacity: 2, Live: 2, Idle: 1, InUse: 1})
+	if got := pool.acquire(t); got != a {
+		t.Error("Acquire did not hand out the released sandbox")
+	}
+	if err := pool.Release(context.Background(), msbtest.NewFakeSandbox(msb.LanguagePython)); !errors.Is(err, msb.ErrNotFromPool) {
+		t.Errorf("Release of a foreign sandbox = %v, want ErrNotFromPool", err)
+	}
+}
+
+func TestPoolReplacesAfterMaxUses(t *testing.T) {
+	pool := newFakePool(t, msb.PoolConfig{Size: 1, MaxUses: 2})
+
+	first := pool.acquire(t)
+	pool.release(t, first)
+	pool.waitStats(t, msb.PoolStats{Capacity: 1, Live: 1, Idle: 1})
+	if again := pool.acquire(t); again != first {
+		t.Fatal("sandbox replaced before MaxUses")
+	}
+	pool.release(t, first)
+	pool.waitStats(t, msb.PoolStats{Capacity: 1, Live: 1, Idle: 1})
+
+	if pool.sandbox(0).Running() {
+		t.Error("sandbox still running after MaxUses")
+	}
+	if fresh := pool.acquire(t); fresh == first || fresh != pool.sandbox(1) {
+		t.Error("Acquire did not hand out the replacement")
+	}
+}
+
+func TestPoolReplacesOnResetFailure(t *testing.T) {
+	failed := errors.New("scratch files busy")
+	pool := newFakePool(t, msb.PoolConfig{Size: 1, Reset: func(context.Context, msb.LangSandBox) error { return failed }})
+
+	first := pool.acquire(t)
+	err := pool.Release(context.Background(), first)
+	if !errors.Is(err, msb.ErrPoolResetFailed) || !errors.Is(err, failed) {
+		t.Fatalf("Release = %v, want ErrPoolResetFailed", err)
+	}
+	pool.waitStats(t, msb.PoolStats{Capacity: 1, Live: 1, Idle: 1})
+	if pool.sandbox(0).Running() {
+		t.Error("sandbox that failed to reset still running")
+	}
+	if fresh := pool.acquire(t); fresh == first {
+		t.Error("Acquire handed out the sandbox that failed to reset")
+	}
+}
+
+func TestPoolReplacesUnhealthySandbox(t *testing.T) {
+	var unhealthy msb.LangSandBox
+	pool := newFakePool(t, msb.PoolConfig{Size: 1, HealthCheck: func(_ context.Context, sandbox msb.LangSandBox) error {
+		if sandbox == unhealthy {
+			return errors.New("portal not responding")
+		}
+		return nil
+	}})
+
+	unhealthy = pool.acquire(t)
+	pool.release(t, unhealthy)
+	pool.waitStats(t, msb.PoolStats{Capacity: 1, Live: 1, Idle: 1})
+
+	fresh := pool.acquire(t)
+	if fresh == unhealthy {
+		t.Fatal("Acquire handed out the unhealthy sandbox")
+	}
+	pool.waitStats(t, msb.PoolStats{Capacity: 1, Live: 1, InUse: 1})
+	if pool.sandbox(0).Running() {
+		t.Error("unhealthy sandbox still running")
+	}
+}
+
+func TestPoolCloseWhileInUse(t *testing.T) {
+	pool := newFakePool(t, msb.PoolConfig{Size: 2})
+	inUse := pool.acquire(t)
+
+	if err := pool.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	pool.waitStats(t, msb.PoolStats{Capacity: 2, Live: 1, InUse: 1})
+	if _, err := pool.Acquire(context.Background()); !errors.Is(err, msb.ErrPoolClosed) {
+		t.Errorf("Acquire after Close = %v, want ErrPoolClosed", err)
+	}
+	if !inUse.(*msbtest.FakeSandbox).Running() {
+		t.Error("Close stopped a sandbox in use")
+	}
+
+	pool.release(t, inUse)
+	pool.waitStats(t, msb.PoolStats{Capacity: 2})
+	if inUse.(*msbtest.FakeSandbox).Running() {
+		t.Error("sandbox released after Close still running")
+	}
+}
+
+func TestPoolAffinityReturnsToSandbox(t *testing.T) {
+	pool := newFakePool(t, msb.PoolConfig{Size: 2})
+
+	bound := pool.acquire(t, msb.WithAffinity("user-1"))
+	other := pool.acquire(t)
+	pool.release(t, bound)
+	pool.release(t, other) // released last, so handed out first without affinity
+
+	if got := pool.acquire(t, msb.WithAffinity("user-1")); got != bound {
+		t.Error("Acquire with affinity did not return to the key's sandbox")
+	}
+	pool.waitStats(t, msb.PoolStats{Capacity: 2, Live: 2, Idle: 1, InUse: 1})
+}
+
+func TestPoolAffinityRebindsWhenBusy(t *testing.T) {
+	pool := newFakePool(t, msb.PoolConfig{Size: 2})
+
+	busy := pool.acquire(t, msb.WithAffinity("user-1"))
+	handed := pool.acquire(t, msb.WithAffinity("user-1"))
+	if handed == busy {
+		t.Fatal("the same sandbox was acquired twice")
+	}
+	pool.release(t, handed)
+	pool.release(t, busy) // released last, so handed out first without affinity
+
+	if got := pool.acquire(t, msb.WithAffinity("user-1")); got != handed {
+		t.Error("key not rebound to the sandbox handed out while its own was busy")
+	}
+}
+
+func TestPoolAutoscales(t *testing.T) {
+	pool := newFakePool(t, msb.PoolConfig{Autoscale: &msb.PoolAutoscale{Min: 1, Max: 4, Interval: 20 * time.Millisecond}})
+	pool.waitStats(t, msb.PoolStats{Capacity: 1, Live: 1, Idle: 1})
+
+	// A fully used pool grows, warming up a sandbox for the next caller
+	sandbox := pool.acquire(t)
+	pool.waitStats(t, msb.PoolStats{Capacity: 2, Live: 2, Idle: 1, InUse: 1})
+
+	// Once unused, it shrinks back, stopping the surplus idle sandbox
+	pool.release(t, sandbox)
+	pool.waitStats(t, msb.PoolStats{Capacity: 1, Live: 1, Idle: 1})
+}
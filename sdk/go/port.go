@@ -0,0 +1,102 @@
+package msb
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// PortExposer makes servers running in the sandbox reachable from outside it.
+type PortExposer interface {
+	// ExposePort returns the URL at which the server listening on sandboxPort inside the sandbox
+	// is reachable from the host, e.g. a Flask app started for a preview. Ports are published
+	// by the server when the sandbox starts, so sandboxPort must be declared with
+	// [WithPublishedPort]; otherwise ExposePort fails with [ErrPortNotPublished]. It waits until
+	// something listens on the port in the sandbox, or ctx is done.
+	ExposePort(ctx context.Context, sandboxPort int) (*url.URL, error)
+}
+
+// WithPublishedPort publishes sandboxPort on hostPort of the server's host when the sandbox
+// starts, so that [PortExposer.ExposePort] can hand out its URL. A hostPort of 0 publishes it on
+// the same port number. The URL is only reachable where the server's host is.
+func WithPublishedPort(sandboxPort, hostPort int) Option {
+	return func(msb *baseMicroSandbox) {
+		if msb.cfg.ports == nil {
+			msb.cfg.ports = map[int]int{}
+		}
+		msb.cfg.ports[sandboxPort] = cmp.Or(hostPort, sandboxPort)
+	}
+}
+
+// exposePollInterval is how often ExposePort checks whether the port is listening yet.
+const exposePollInterval = 250 * time.Millisecond
+
+type portExposer struct {
+	b *baseMicroSandbox
+}
+
+func (pe portExposer) ExposePort(ctx context.Context, sandboxPort int) (*url.URL, error) {
+	if pe.b.state.Load() != started {
+		return nil, ErrSandboxNotStarted
+	}
+	cfg := pe.b.config()
+	hostPort, ok := cfg.ports[sandboxPort]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrPortNotPublished, sandboxPort)
+	}
+	server, err := url.Parse(cfg.serverUrl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToExposePort, err)
+	}
+	host := server.Hostname()
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+
+	ticker := time.NewTicker(exposePollInterval)
+	defer ticker.Stop()
+	for {
+		exec, err := pe.b.runShell(ctx, listeningScript(sandboxPort))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrFailedToExposePort, err)
+		}
+		if exec.IsSuccess() {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: nothing listens on port %d: %w", ErrFailedToExposePort, sandboxPort, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	pe.b.logger().Debug("Port exposed", "sandbox", cfg.name, "port", sandboxPort, "hostPort", hostPort)
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort(host, strconv.Itoa(hostPort))}, nil
+}
+
+// publishedPorts renders the published ports as the server expects them, "host:guest", and
+// validates them.
+func (cfg *config) publishedPorts() ([]string, error) {
+	ports := make([]string, 0, len(cfg.ports))
+	for _, guest := range slices.Sorted(maps.Keys(cfg.ports)) {
+		host := cfg.ports[guest]
+		if guest < 1 || guest > 65535 || host < 1 || host > 65535 {
+			return nil, fmt.Errorf("%w: %d:%d", ErrInvalidPort, host, guest)
+		}
+		ports = append(ports, fmt.Sprintf("%d:%d", host, guest))
+	}
+	return ports, nil
+}
+
+// Port-related errors
+var (
+	ErrInvalidPort        = errors.New("invalid port")
+	ErrPortNotPublished   = errors.New("port not published; declare it with WithPublishedPort")
+	ErrFailedToExposePort = errors.New("failed to expose port")
+)
@@ -0,0 +1,120 @@
+package msb
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// preconnectTimeout bounds the requests WithPreconnect warms connections with.
+const preconnectTimeout = 10 * time.Second
+
+// WithPreconnect opens up to n connections to the server in the background when the sandbox is
+// built, so that Start and the first executions do not wait for DNS, TCP and TLS. Connections are
+// kept in the client's pool, which the default client keeps 10 of for 30 seconds; over HTTP/2 a
+// single connection carries every request. Failures are only logged: the requests dial again as
+// usual. With [WithHTTPClient] or [WithTransport], the given client's pool is warmed.
+//
+// Whether or not it is set, how long each new connection took to set up is logged at debug level.
+func WithPreconnect(n int) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.preconnect = max(n, 0)
+	}
+}
+
+// startPreconnect warms the connections asked for with WithPreconnect. It runs once the client
+// and the logger are in place.
+func startPreconnect() Option {
+	return func(msb *baseMicroSandbox) {
+		cfg := msb.config()
+		if cfg.preconnect == 0 {
+			return
+		}
+		go msb.rpcClient.preconnect(context.Background(), cfg, cfg.preconnect)
+	}
+}
+
+func (d *jsonRPCHTTPClient) preconnect(ctx context.Context, cfg *config, n int) {
+	ctx, cancel := context.WithTimeout(ctx, preconnectTimeout)
+	defer cancel()
+	begin := time.Now()
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Any answer leaves the connection in the pool; a body-less HEAD is the cheapest
+			req, err := http.NewRequestWithContext(withConnTrace(ctx, cfg, "preconnect"), http.MethodHead, cfg.serverUrl+endpointRoute, nil)
+			if err != nil {
+				cfg.logger.Error("Failed to preconnect to server", "error", err)
+				return
+			}
+			resp, err := d.Do(req)
+			if err != nil {
+				cfg.logger.Error("Failed to preconnect to server", "error", err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	cfg.logger.Debug("Preconnected to server", "connections", n, "duration", time.Since(begin))
+}
+
+// connTrace times the setup of the connections a request dials.
+type connTrace struct {
+	mu                  sync.Mutex
+	dnsStart, dnsDone   time.Time
+	dialStart, dialDone time.Time
+	tlsStart            time.Time
+	addr                string
+}
+
+// withConnTrace returns ctx with a trace that logs how long setting up each connection the
+// request dials takes, once it is ready to carry requests: after the TLS handshake for an HTTPS
+// server, after the TCP connect otherwise. Requests that reuse a pooled connection log nothing.
+// method labels the log line.
+func withConnTrace(ctx context.Context, cfg *config, method string) context.Context {
+	logger := cfg.logger
+	secure := strings.HasPrefix(cfg.serverUrl, "https:")
+	t := &connTrace{}
+	stamp := func(at *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		*at = time.Now()
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { stamp(&t.dnsStart) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { stamp(&t.dnsDone) },
+		ConnectStart: func(string, string) { stamp(&t.dialStart) },
+		ConnectDone: func(_, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dialDone, t.addr = time.Now(), addr
+			if !secure && err == nil {
+				logger.Debug("Connected to server", "method", method, "remote", addr, "protocol", "http/1.1",
+					"dns", t.dnsDone.Sub(t.dnsStart), "dial", t.dialDone.Sub(t.dialStart))
+			}
+		},
+		TLSHandshakeStart: func() { stamp(&t.tlsStart) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err != nil {
+				return
+			}
+			protocol := state.NegotiatedProtocol
+			if protocol == "" {
+				protocol = "http/1.1"
+			}
+			logger.Debug("Connected to server", "method", method, "remote", t.addr, "protocol", protocol,
+				"dns", t.dnsDone.Sub(t.dnsStart), "dial", t.dialDone.Sub(t.dialStart), "tls", time.Since(t.tlsStart))
+		},
+	})
+}
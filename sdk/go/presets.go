@@ -0,0 +1,48 @@
+package msb
+
+import "time"
+
+// Presets bundle the options suited to a threat model, so that a sandbox gets sane defaults in
+// one option. Options passed after a preset override its settings:
+//
+//	sandbox := msb.NewPythonSandbox(msb.PresetUntrustedLLMCode, msb.WithMemoryMB(1024))
+//
+// The server has no network policy yet, so no preset restricts the sandbox's network access;
+// code in the sandbox reaches whatever the VM's network reaches.
+var (
+	// PresetUntrustedLLMCode suits running code written by a language model or an end user: a
+	// small VM (512 MiB, 1 vCPU), 30-second RPCs, responses bounded to 8 MiB, at most 60
+	// executions per minute, strict parsing of results, masking of credential-like environment
+	// variables in errors, and a 10-minute idle timeout, so abandoned sandboxes free their memory.
+	PresetUntrustedLLMCode = withOptions(
+		WithMemoryMB(512),
+		WithCPUs(1),
+		WithTimeout(30*time.Second),
+		WithMaxResponseBytes(8<<20),
+		WithMaxExecutionsPerMinute(60),
+		WithStrictParsing(),
+		WithSecretEnvRedaction(),
+		WithIdleTimeout(10*time.Minute),
+	)
+
+	// PresetTrustedBuild suits builds and tests of the caller's own code: a larger VM (4 GiB,
+	// 4 vCPUs), 30-minute RPCs for long compilations, the shared package cache, masking of
+	// credential-like environment variables in errors, and a 1-hour idle timeout.
+	PresetTrustedBuild = withOptions(
+		WithMemoryMB(4096),
+		WithCPUs(4),
+		WithTimeout(30*time.Minute),
+		WithPackageCache(),
+		WithSecretEnvRedaction(),
+		WithIdleTimeout(time.Hour),
+	)
+)
+
+// withOptions combines opts into a single option applying them in order.
+func withOptions(opts ...Option) Option {
+	return func(msb *baseMicroSandbox) {
+		for _, opt := range opts {
+			opt(msb)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Probe checks the health of a started sandbox periodically in the background, with the same
+// semantics as Kubernetes probes. Exactly one of Command and Port must be set.
+type Probe struct {
+	// Command is a shell command run in the sandbox; the check passes when it exits 0.
+	Command string
+	// Port is a TCP port inside the sandbox; the check passes when something listens on it.
+	Port int
+	// InitialDelay postpones the first check after Start.
+	InitialDelay time.Duration
+	// Interval is the time between checks. Defaults to 10 seconds.
+	Interval time.Duration
+	// Timeout bounds a single check. Defaults to 1 second.
+	Timeout time.Duration
+	// SuccessThreshold is the number of consecutive passing checks after which a failing probe
+	// passes again. Defaults to 1.
+	SuccessThreshold int
+	// FailureThreshold is the number of consecutive failing checks after which the probe fails.
+	// Defaults to 3.
+	FailureThreshold int
+}
+
+// ProbeKind tells readiness from liveness probes.
+type ProbeKind string
+
+const (
+	ProbeReadiness ProbeKind = "readiness"
+	ProbeLiveness  ProbeKind = "liveness"
+)
+
+// HealthState is the state of a sandbox's probes.
+type HealthState struct {
+	// Ready reports whether the readiness probe passes. It starts out false until the probe's
+	// first success, and is true for a started sandbox without a readiness probe.
+	Ready bool
+	// Live reports whether the liveness probe passes. It starts out true, and is true for a
+	// started sandbox without a liveness probe.
+	Live bool
+	// LastError is why the most recent failing check failed, if any.
+	LastError error
+}
+
+// HealthEvent reports a probe changing state.
+type HealthEvent struct {
+	Sandbox string
+	Probe   ProbeKind
+	Healthy bool
+	Err     error // The failing check's error when Healthy is false
+}
+
+// HealthHandler is called when a probe starts or stops passing. It is called from the probe's
+// goroutine and must not block.
+type HealthHandler func(HealthEvent)
+
+// HealthReporter exposes the result of the probes set with [WithReadinessProbe] and
+// [WithLivenessProbe], and checks whether the sandbox accepts executions.
+type HealthReporter interface {
+	// Health returns the current probe state. A stopped sandbox is neither ready nor live.
+	Health() HealthState
+	// HealthCheck checks once that the sandbox accepts executions: that the VM runs commands,
+	// and that the language's interpreter runs code.
+	HealthCheck(ctx context.Context) error
+	// WaitReady blocks until the sandbox accepts executions and the readiness probe passes, the
+	// sandbox stops, or ctx is done. Start may return before the interpreter is up, so WaitReady
+	// repeats HealthCheck until it passes, backing off exponentially from 100ms up to 2s unless
+	// configured otherwise with opts.
+	WaitReady(ctx context.Context, opts ...WaitOption) error
+}
+
+// WaitOption configures [HealthReporter.WaitReady].
+type WaitOption func(*waitConfig)
+
+type waitConfig struct {
+	backoff Backoff
+}
+
+// WithWaitInterval repeats the health check every d.
+func WithWaitInterval(d time.Duration) WaitOption {
+	return func(wc *waitConfig) {
+		wc.backoff = func(int) time.Duration { return d }
+	}
+}
+
+// WithWaitBackoff waits backoff(n) after the n-th failing health check.
+func WithWaitBackoff(backoff Backoff) WaitOption {
+	return func(wc *waitConfig) {
+		wc.backoff = backoff
+	}
+}
+
+// prober runs a started sandbox's probes until the sandbox stops.
+type prober struct {
+	b      *baseMicroSandbox
+	cancel context.CancelFunc
+	done   chan struct{} // closed once the probes are stopped
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	health  HealthState
+	readyCh chan struct{} // closed while ready, replaced when readiness is lost
+}
+
+// startProbes launches the configured probes; it is called once the sandbox has started.
+func (b *baseMicroSandbox) startProbes() {
+	cfg := b.config()
+	p := &prober{
+		b:       b,
+		health:  HealthState{Ready: cfg.readinessProbe == nil, Live: true},
+		done:    make(chan struct{}),
+		readyCh: make(chan struct{}),
+	}
+	if p.health.Ready {
+		close(p.readyCh)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	for kind, probe := range map[ProbeKind]*Probe{ProbeReadiness: cfg.readinessProbe, ProbeLiveness: cfg.livenessProbe} {
+		if probe == nil {
+			continue
+		}
+		p.wg.Add(1)
+		go p.run(ctx, kind, *probe)
+	}
+	if old := b.prober.Swap(p); old != nil {
+		old.stop()
+	}
+}
+
+// stopProbes stops the probes; it is called when the sandbox stops.
+func (b *baseMicroSandbox) stopProbes() {
+	if p := b.prober.Swap(nil); p != nil {
+		p.stop()
+	}
+}
+
+func (p *prober) stop() {
+	p.cancel()
+	p.wg.Wait()
+	close(p.done)
+}
+
+func (p *prober) run(ctx context.Context, kind ProbeKind, probe Probe) {
+	defer p.wg.Done()
+	probe = probe.withDefaults()
+
+	// Readiness starts failing and liveness passing, as in Kubernetes
+	healthy := kind == ProbeLiveness
+	var successes, failures int
+	wait := probe.InitialDelay
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = probe.Interval
+
+		checkCtx, cancel := context.WithTimeout(ctx, probe.Timeout)
+		err := p.check(checkCtx, probe)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			successes, failures = successes+1, 0
+			if !healthy && successes >= probe.SuccessThreshold {
+				healthy = true
+				p.transition(kind, true, nil)
+			}
+			continue
+		}
+		successes, failures = 0, failures+1
+		p.mu.Lock()
+		p.health.LastError = err
+		p.mu.Unlock()
+		if healthy && failures >= probe.FailureThreshold {
+			healthy = false
+			p.transition(kind, false, err)
+		}
+	}
+}
+
+// listeningScript exits 0 if a socket listens on port in the guest. It looks the port up among
+// the kernel's listening sockets, which needs no tools in the image.
+func listeningScript(port int) string {
+	return fmt.Sprintf(`grep -Eq ':%04X [0-9A-F]+:0000 0A' /proc/net/tcp /proc/net/tcp6 2>/dev/null`, port)
+}
+
+// check runs a single probe check.
+func (p *prober) check(ctx context.Context, probe Probe) error {
+	script := probe.Command
+	if probe.Port > 0 {
+		script = listeningScript(probe.Port)
+	}
+	exec, err := p.b.runShell(ctx, script)
+	if err != nil {
+		return err
+	}
+	if !exec.IsSuccess() {
+		if probe.Port > 0 {
+			return fmt.Errorf("%w: nothing listens on port %d", ErrProbeFailed, probe.Port)
+		}
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("%w: exit code %d: %s", ErrProbeFailed, exec.GetExitCode(), strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+func (p *prober) transition(kind ProbeKind, healthy bool, err error) {
+	p.mu.Lock()
+	switch kind {
+	case ProbeReadiness:
+		p.health.Ready = healthy
+		if healthy {
+			close(p.readyCh)
+		} else {
+			p.readyCh = make(chan struct{})
+		}
+	case ProbeLiveness:
+		p.health.Live = healthy
+	}
+	p.mu.Unlock()
+
+	p.b.logger().Info("Probe state changed", "sandbox", p.b.name(), "probe", kind, "healthy", healthy, "error", err)
+	if h := p.b.config().healthHandler; h != nil {
+		h(HealthEvent{Sandbox: p.b.name(), Probe: kind, Healthy: healthy, Err: err})
+	}
+}
+
+type healthReporter struct {
+	b *baseMicroSandbox
+	l Language
+}
+
+func (hr healthReporter) Health() HealthState {
+	p := hr.b.prober.Load()
+	if p == nil {
+		return HealthState{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.health
+}
+
+func (hr healthReporter) HealthCheck(ctx context.Context) error {
+	if hr.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	exec, err := hr.b.runShell(ctx, "true")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHealthCheckFailed, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: command exited with code %d", ErrHealthCheckFailed, exec.GetExitCode())
+	}
+
+	rt, err := lookupLanguage(string(hr.l))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHealthCheckFailed, err)
+	}
+	switch {
+	case rt.repl && rt.eval != "":
+		code := strings.ReplaceAll(rt.eval, "{expr}", `"1"`)
+		exec, err := codeRunner{hr.b, rt.Name}.run(ctx, rt, code)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrHealthCheckFailed, err)
+		}
+		if exec.HasError() {
+			stderr, _ := exec.GetError()
+			return fmt.Errorf("%w: interpreter: %s", ErrHealthCheckFailed, strings.TrimSpace(stderr))
+		}
+	case !rt.shell && !rt.repl:
+		// Running an empty program shows that the interpreter is installed
+		exec, err := hr.b.runShell(ctx, rt.command("/dev/null"))
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrHealthCheckFailed, err)
+		}
+		if !exec.IsSuccess() {
+			return fmt.Errorf("%w: interpreter: %s", ErrHealthCheckFailed, commandStderr(exec))
+		}
+	}
+	return nil
+}
+
+func (hr healthReporter) WaitReady(ctx context.Context, opts ...WaitOption) error {
+	p := hr.b.prober.Load()
+	if p == nil {
+		return ErrSandboxNotStarted
+	}
+	wc := waitConfig{backoff: ExponentialBackoff(100*time.Millisecond, 2*time.Second)}
+	for _, opt := range opts {
+		opt(&wc)
+	}
+	for attempt := 1; ; attempt++ {
+		err := hr.HealthCheck(ctx)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, ErrSandboxNotStarted) {
+			return err
+		}
+		hr.b.logger().Debug("Sandbox not ready yet", "sandbox", hr.b.name(), "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last check: %w)", ctx.Err(), err)
+		case <-p.done:
+			return ErrSandboxNotStarted
+		case <-time.After(wc.backoff(attempt)):
+		}
+	}
+
+	p.mu.Lock()
+	readyCh := p.readyCh
+	p.mu.Unlock()
+	select {
+	case <-readyCh:
+		return nil
+	case <-p.done:
+		return ErrSandboxNotStarted
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withDefaults fills in the documented defaults.
+func (probe Probe) withDefaults() Probe {
+	if probe.Interval <= 0 {
+		probe.Interval = 10 * time.Second
+	}
+	if probe.Timeout <= 0 {
+		probe.Timeout = time.Second
+	}
+	if probe.SuccessThreshold <= 0 {
+		probe.SuccessThreshold = 1
+	}
+	if probe.FailureThreshold <= 0 {
+		probe.FailureThreshold = 3
+	}
+	return probe
+}
+
+// validate checks that exactly one check is configured.
+func (probe Probe) validate() error {
+	if (probe.Command == "") == (probe.Port <= 0) {
+		return fmt.Errorf("%w: set exactly one of Command and Port", ErrInvalidProbe)
+	}
+	return nil
+}
+
+// Probe-related errors
+var (
+	ErrInvalidProbe = errors.New("invalid probe")
+	ErrProbeFailed  = errors.New("probe failed")
+
+	ErrHealthCheckFailed = errors.New("health check failed")
+)
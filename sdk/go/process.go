@@ -0,0 +1,178 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Process is a handle to a command running in the background inside the sandbox, started with
+// Command().Start. Any number of processes can run concurrently in one sandbox, each with its
+// own output. Its methods are safe for concurrent use.
+type Process struct {
+	b      *baseMicroSandbox
+	proc   *guestProcess
+	cmd    string
+	args   []string
+	stdout io.Writer
+	stderr io.Writer
+	keep   func(stream string) bool // whether output of stream goes into the result
+	key    string                   // journal key, if any
+
+	done    chan struct{}
+	started time.Time
+
+	mu     sync.Mutex
+	killed bool
+	result CommandExecution
+	err    error
+}
+
+// processPollInterval is how often a background process is polled for output and exit.
+const processPollInterval = 250 * time.Millisecond
+
+func (b *baseMicroSandbox) startProcess(ctx context.Context, cmd string, args []string, rc runConfig) (*Process, error) {
+	if b.config().journal != nil && rc.journalKey != "" {
+		return b.resumeProcess(ctx, rc.journalKey, cmd, args, rc)
+	}
+	script, err := b.guestCommandScript(ctx, cmd, args, rc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartProcess, err)
+	}
+	proc, err := b.spawn(ctx, script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartProcess, err)
+	}
+	b.logger().Debug("Process started", "sandbox", b.name(), "command", cmd, "id", proc.id)
+	return b.watchProcess(proc, cmd, args, rc, ""), nil
+}
+
+// watchProcess returns a handle to proc that collects its output until it exits.
+func (b *baseMicroSandbox) watchProcess(proc *guestProcess, cmd string, args []string, rc runConfig, key string) *Process {
+	p := &Process{
+		b:       b,
+		proc:    proc,
+		cmd:     cmd,
+		args:    args,
+		stdout:  rc.stdout,
+		stderr:  rc.stderr,
+		keep:    rc.buffered,
+		key:     key,
+		done:    make(chan struct{}),
+		started: time.Now(),
+	}
+	go p.watch()
+	return p
+}
+
+// ID identifies the process within the sandbox. Any client can kill the process by passing it
+// to Cancel.
+func (p *Process) ID() string {
+	return p.proc.id
+}
+
+// Done is closed once the process has exited and its result is available.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait blocks until the process exits or ctx is done, and returns its result.
+// Output is also delivered incrementally to the writers given by [WithStdout] and [WithStderr].
+func (p *Process) Wait(ctx context.Context) (CommandExecution, error) {
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return CommandExecution{}, ctx.Err()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.result, p.err
+}
+
+// Kill terminates the process and everything it started.
+func (p *Process) Kill(ctx context.Context) error {
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+	if err := p.proc.signal(ctx, "KILL"); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToKillProcess, err)
+	}
+	return nil
+}
+
+func (p *Process) watch() {
+	defer close(p.done)
+	ctx := context.Background()
+
+	var (
+		stdoutOff, stderrOff int64
+		lines                []OutputLine
+	)
+	outLines := lineSplitter{stream: "stdout"}
+	errLines := lineSplitter{stream: "stderr"}
+	for {
+		st, err := p.proc.poll(ctx, stdoutOff, stderrOff)
+		if err != nil {
+			if errors.Is(err, ErrSandboxNotStarted) {
+				p.finish(CommandExecution{}, fmt.Errorf("%w: %w", ErrFailedToRunCommand, err))
+				return
+			}
+			time.Sleep(processPollInterval)
+			continue
+		}
+		stdoutOff += int64(len(st.stdout))
+		stderrOff += int64(len(st.stderr))
+		writeIfSet(p.stdout, st.stdout)
+		writeIfSet(p.stderr, st.stderr)
+		batch := p.kept(append(outLines.write(st.stdout), errLines.write(st.stderr)...))
+		lines = append(lines, batch...)
+		p.b.emitOutput(ctx, p.proc.id, batch)
+
+		if !st.running {
+			batch = p.kept(append(outLines.flush(), errLines.flush()...))
+			lines = append(lines, batch...)
+			p.b.emitOutput(ctx, p.proc.id, batch)
+			var reason TerminationReason
+			p.mu.Lock()
+			if p.killed {
+				reason = TerminationReason{Kind: TerminationSignal, Signal: 9, SignalName: signalName(9), Detail: "killed by client"}
+			}
+			p.mu.Unlock()
+			p.finish(commandExecutionFromLines(p.cmd, p.args, st.exitCode, lines, reason), nil)
+			_ = p.proc.remove(ctx)
+			return
+		}
+		time.Sleep(processPollInterval)
+	}
+}
+
+// kept filters out the lines of streams left out of the result with WithUnbufferedOutput.
+func (p *Process) kept(lines []OutputLine) []OutputLine {
+	return slices.DeleteFunc(lines, func(line OutputLine) bool { return !p.keep(line.Stream) })
+}
+
+// finish records the result, journaling it before the job's files are removed so that a crash
+// in between re-attaches to them instead of starting the process again.
+func (p *Process) finish(result CommandExecution, err error) {
+	if j := p.b.config().journal; j != nil && p.key != "" && err == nil {
+		rec := journalCommandResult(result)
+		rec.Op, rec.Key, rec.Kind, rec.ProcessID = journalResult, p.key, journalProcess, p.proc.id
+		if jerr := j.append(rec); jerr != nil {
+			p.b.logger().Error("Failed to journal process result", "sandbox", p.b.name(), "key", p.key, "error", jerr)
+		}
+	}
+	result.duration = time.Since(p.started)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.result, p.err = result, err
+}
+
+// Process-related errors
+var (
+	ErrFailedToStartProcess = errors.New("failed to start process")
+	ErrFailedToKillProcess  = errors.New("failed to kill process")
+)
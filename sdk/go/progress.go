@@ -0,0 +1,66 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// progressPrefix marks a stdout line on which the code reports its progress as JSON. Like rich
+// outputs, progress reports travel through stdout, the only stream the server relays as it is
+// produced, and are picked out by the client.
+const progressPrefix = "__msb_progress__:"
+
+// Progress is a progress report of a running execution.
+type Progress struct {
+	// Percent is how much of the work is done, from 0 to 100, or -1 if the report gave none.
+	Percent float64
+	// Message describes the current step, if the report gave one.
+	Message string
+}
+
+// progressJSON is how code reports its progress, after progressPrefix.
+type progressJSON struct {
+	Percent *float64 `json:"percent"`
+	Message string   `json:"message"`
+}
+
+// parseProgress decodes the report of a progress line. Lines with the prefix but no valid report
+// are not progress lines, and are kept as output.
+func parseProgress(line OutputLine) (Progress, bool) {
+	if line.Stream != "stdout" || !strings.HasPrefix(line.Text, progressPrefix) {
+		return Progress{}, false
+	}
+	var report progressJSON
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line.Text, progressPrefix)), &report); err != nil {
+		return Progress{}, false
+	}
+	p := Progress{Percent: -1, Message: report.Message}
+	if report.Percent != nil {
+		p.Percent = min(max(*report.Percent, 0), 100)
+	}
+	return p, true
+}
+
+// reportProgress sends the report of line to rc.progress, and reports whether line was one.
+func (rc runConfig) reportProgress(ctx context.Context, line OutputLine) bool {
+	if rc.progress == nil {
+		return false
+	}
+	p, ok := parseProgress(line)
+	if !ok {
+		return false
+	}
+	select {
+	case rc.progress <- p:
+	case <-ctx.Done():
+	}
+	return true
+}
+
+func (cr codeRunner) RunWithProgress(ctx context.Context, code string, progress chan<- Progress, opts ...RunOption) (CodeExecution, error) {
+	defer close(progress)
+	return cr.Run(ctx, code, append(opts, func(rc *runConfig) {
+		rc.progress = progress
+	})...)
+}
@@ -0,0 +1,111 @@
+package msb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PromptDetected reports an execution that printed a line without ending it and then went quiet,
+// which is how a command waiting for an answer looks from outside, e.g. apt's
+// "Do you want to continue? [Y/n] " or a script's "Password: ".
+type PromptDetected struct {
+	Stream string // "stdout" or "stderr"
+	Text   string // the unterminated line, up to its last carriage return
+}
+
+// PromptResponse tells an execution stopped at a prompt how to go on. The zero value leaves it
+// waiting, for output that only looked like a prompt.
+type PromptResponse struct {
+	// Input is written to the command's standard input; end it with "\n" to answer a line.
+	Input string
+	// Abort kills the command; its result reports [TerminationPrompt].
+	Abort bool
+}
+
+// WithPromptHandler detects an execution waiting for input and asks fn how to answer, instead
+// of leaving it hanging until its timeout:
+//
+//	exec, err := sandbox.Command().Run(ctx, "apt-get", []string{"install", "curl"},
+//		msb.WithPromptHandler(func(p msb.PromptDetected) msb.PromptResponse {
+//			if strings.HasSuffix(p.Text, "[Y/n] ") {
+//				return msb.PromptResponse{Input: "y\n"}
+//			}
+//			return msb.PromptResponse{Abort: true}
+//		}))
+//
+// A prompt is an unterminated line followed by a second of silence; fn is asked once per prompt,
+// from the goroutine calling Run. The command's standard input is a pipe fed by fn's answers, so
+// programs reading from the terminal itself, such as sudo or ssh asking for a password, are
+// reached with [ShellOpener] instead. Executions given [WithStdin] have their input fixed in
+// advance and are not watched for prompts. The execution runs as with [WithOutputHandler].
+func WithPromptHandler(fn func(PromptDetected) PromptResponse) RunOption {
+	return func(rc *runConfig) {
+		rc.onPrompt = fn
+	}
+}
+
+// promptQuiet is how long an unterminated line must stand before it counts as a prompt.
+const promptQuiet = time.Second
+
+// prompts reports whether the execution is watched for prompts.
+func (rc runConfig) prompts() bool {
+	return rc.onPrompt != nil && rc.stdin == nil
+}
+
+// promptScript runs script with its standard input read from the FIFO "in" in the job directory
+// d, where answers to prompts are written. A writer holds the FIFO open so that the command sees
+// no end of input between answers; it is killed once the command exits.
+func promptScript(d string, script string) string {
+	return `d=` + shellQuote(d) + `; mkfifo "$d/in" || exit 127
+sleep 2147483647 >"$d/in" & w=$!
+(
+` + script + `
+) <"$d/in"; c=$?
+kill $w 2>/dev/null; exit $c`
+}
+
+// spawnPrompted launches script like spawn, with its standard input open for prompt answers.
+func (b *baseMicroSandbox) spawnPrompted(ctx context.Context, script string) (*guestProcess, error) {
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, err
+	}
+	return b.spawnWithID(ctx, id, promptScript(guestJobsDir+"/"+id, script))
+}
+
+// pendingPrompt returns the unterminated line either stream ends with, if any.
+func pendingPrompt(splitters ...*lineSplitter) (PromptDetected, bool) {
+	for _, ls := range splitters {
+		text := string(ls.pending)
+		if i := strings.LastIndexByte(text, '\r'); i >= 0 {
+			text = text[i+1:]
+		}
+		if strings.TrimSpace(text) != "" {
+			return PromptDetected{Stream: ls.stream, Text: text}, true
+		}
+	}
+	return PromptDetected{}, false
+}
+
+// answer writes input to the standard input of a process spawned with spawnPrompted.
+func (p *guestProcess) answer(ctx context.Context, input string) error {
+	d := shellQuote(p.dir())
+	exec, err := p.shell(ctx, `[ -f `+d+`/exit ] && exit 3
+printf '%s' `+base64.StdEncoding.EncodeToString([]byte(input))+` | base64 -d >`+d+`/in`)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToAnswerPrompt, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: the command has exited", ErrFailedToAnswerPrompt)
+	}
+	return nil
+}
+
+// Prompt-related errors
+var (
+	ErrFailedToAnswerPrompt = errors.New("failed to answer prompt")
+)
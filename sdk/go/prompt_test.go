@@ -0,0 +1,45 @@
+package msb_test
+
+import (
+	"context"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+func TestPromptHandler(t *testing.T) {
+	const script = `printf 'Continue? [y/N] '; read answer; echo "got $answer"`
+	tests := []struct {
+		name     string
+		response msb.PromptResponse
+		want     string
+		kind     msb.TerminationKind
+	}{
+		{"answered", msb.PromptResponse{Input: "y\n"}, "Continue? [y/N] got y", msb.TerminationNone},
+		{"aborted", msb.PromptResponse{Abort: true}, "Continue? [y/N] ", msb.TerminationPrompt},
+	}
+	ctx := context.Background()
+	sandbox := newLocalSandbox(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompts []msb.PromptDetected
+			exec, err := sandbox.Command().Run(ctx, "sh", []string{"-c", script},
+				msb.WithPromptHandler(func(p msb.PromptDetected) msb.PromptResponse {
+					prompts = append(prompts, p)
+					return tt.response
+				}))
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if len(prompts) != 1 || prompts[0] != (msb.PromptDetected{Stream: "stdout", Text: "Continue? [y/N] "}) {
+				t.Errorf("prompts = %q, want the one on stdout", prompts)
+			}
+			if out, _ := exec.GetOutput(); out != tt.want {
+				t.Errorf("output = %q, want %q", out, tt.want)
+			}
+			if kind := exec.GetTerminationReason().Kind; kind != tt.kind {
+				t.Errorf("termination = %s, want %s", kind, tt.kind)
+			}
+		})
+	}
+}
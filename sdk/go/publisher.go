@@ -0,0 +1,112 @@
+package msb
+
+import (
+	"context"
+	"time"
+)
+
+// Lifecycle event types
+const (
+	EventExecutionStarted  = "execution.started"
+	EventExecutionFinished = "execution.finished"
+)
+
+// LifecycleEvent reports the start or the end of an execution to a [Publisher]. Its JSON form is
+// meant to be published as is, e.g. as a Kafka record value or an SQS message body.
+type LifecycleEvent struct {
+	Type        string    `json:"type"` // EventExecutionStarted or EventExecutionFinished
+	Time        time.Time `json:"time"`
+	Namespace   string    `json:"namespace"`
+	Sandbox     string    `json:"sandbox"`
+	ExecutionID string    `json:"execution_id"` // Correlates the started and finished events
+	Language    Language  `json:"language,omitempty"`
+	Code        string    `json:"code,omitempty"`
+	Command     string    `json:"command,omitempty"`
+	Args        []string  `json:"args,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+
+	// Finished events only
+	Status      string        `json:"status,omitempty"` // "success" or "error"
+	ExitCode    *int          `json:"exit_code,omitempty"`
+	Stdout      string        `json:"stdout,omitempty"`
+	Stderr      string        `json:"stderr,omitempty"`
+	Termination string        `json:"termination,omitempty"` // TerminationKind, if terminated abnormally
+	Duration    time.Duration `json:"duration_ns,omitempty"`
+	Error       string        `json:"error,omitempty"` // Error returned by Run, if any
+}
+
+// Publisher forwards lifecycle events to an asynchronous pipeline, such as a Kafka topic, a NATS
+// subject or an SQS queue, so consumers get results without polling the SDK. Implementations
+// wrap the broker client of the application's choice; see [PublisherFunc].
+type Publisher interface {
+	Publish(ctx context.Context, ev LifecycleEvent) error
+}
+
+// PublisherFunc adapts a function to a [Publisher]:
+//
+//	msb.WithPublisher(msb.PublisherFunc(func(ctx context.Context, ev msb.LifecycleEvent) error {
+//		data, err := json.Marshal(ev)
+//		if err != nil {
+//			return err
+//		}
+//		return nc.Publish("sandbox.executions", data)
+//	}))
+type PublisherFunc func(ctx context.Context, ev LifecycleEvent) error
+
+func (f PublisherFunc) Publish(ctx context.Context, ev LifecycleEvent) error {
+	return f(ctx, ev)
+}
+
+// publish hands ev to every configured publisher. Publishing failures are logged rather than
+// failing the execution.
+func (b *baseMicroSandbox) publish(ctx context.Context, ev LifecycleEvent) {
+	cfg := b.config()
+	if len(cfg.publishers) == 0 {
+		return
+	}
+	ev.Time = time.Now()
+	ev.Namespace = cfg.namespace
+	ev.Sandbox = cfg.name
+	for _, p := range cfg.publishers {
+		if err := p.Publish(ctx, ev); err != nil {
+			cfg.logger.Error("Publisher failed", "sandbox", cfg.name, "event", ev.Type, "execution", ev.ExecutionID, "error", err)
+		}
+	}
+}
+
+// finishedEvent completes started with the outcome of an execution.
+func finishedEvent[E parsedExecution](started LifecycleEvent, exec E, err error, duration time.Duration) LifecycleEvent {
+	ev := started
+	ev.Type = EventExecutionFinished
+	ev.Duration = duration
+	ev.Status = "success"
+	var reason TerminationReason
+	switch e := any(exec).(type) {
+	case CodeExecution:
+		ev.Stdout, _ = e.GetOutput()
+		ev.Stderr, _ = e.GetError()
+		reason = e.GetTerminationReason()
+		if e.HasError() {
+			ev.Status = "error"
+		}
+	case CommandExecution:
+		ev.Stdout, _ = e.GetOutput()
+		ev.Stderr, _ = e.GetError()
+		reason = e.GetTerminationReason()
+		if e.parsedOK {
+			code := e.GetExitCode()
+			ev.ExitCode = &code
+		}
+		if !e.IsSuccess() {
+			ev.Status = "error"
+		}
+	}
+	if reason.Kind != TerminationNone {
+		ev.Termination = reason.Kind.String()
+	}
+	if err != nil {
+		ev.Status = "error"
+		ev.Error = err.Error()
+	}
+	return ev
+}
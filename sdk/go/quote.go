@@ -0,0 +1,145 @@
+package msb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// QuoteShell quotes s as a single POSIX shell word, so that it reaches the command verbatim
+// whatever it contains.
+func QuoteShell(s string) string {
+	return shellQuote(s)
+}
+
+// ShellJoin quotes every word with [QuoteShell] and joins them into a command line, e.g. for
+// building an `sh -c` script from untrusted arguments.
+func ShellJoin(words ...string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = shellQuote(w)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Literal encodes v as a literal expression of lang. Python and Node.js accept anything that
+// marshals to JSON: strings, numbers, booleans, nil, slices, maps and structs. Bash accepts
+// scalars only, which become a single quoted word.
+func Literal(lang Language, v any) (string, error) {
+	rt, err := lookupLanguage(string(lang))
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnsupportedLiteral, err)
+	}
+	switch rt.Name {
+	case LanguageNodeJS:
+		// JSON is a subset of JavaScript, and encoding/json escapes U+2028 and U+2029
+		return string(raw), nil
+	case LanguagePython:
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var decoded any
+		if err := dec.Decode(&decoded); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrUnsupportedLiteral, err)
+		}
+		var sb strings.Builder
+		writePythonLiteral(&sb, decoded)
+		return sb.String(), nil
+	case LanguageBash:
+		var s any
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrUnsupportedLiteral, err)
+		}
+		switch s := s.(type) {
+		case string:
+			return shellQuote(s), nil
+		case float64, bool:
+			return shellQuote(string(raw)), nil
+		case nil:
+			return "''", nil
+		default:
+			return "", fmt.Errorf("%w: %T in bash", ErrUnsupportedLiteral, v)
+		}
+	default:
+		return "", fmt.Errorf("%w: no literal syntax known for %q", ErrUnsupportedLiteral, rt.Name)
+	}
+}
+
+// writePythonLiteral writes a JSON-decoded value as Python source. JSON strings and numbers are
+// valid Python literals as they are; only the constants and containers need translating.
+func writePythonLiteral(sb *strings.Builder, v any) {
+	switch v := v.(type) {
+	case nil:
+		sb.WriteString("None")
+	case bool:
+		if v {
+			sb.WriteString("True")
+		} else {
+			sb.WriteString("False")
+		}
+	case json.Number:
+		sb.WriteString(v.String())
+	case string:
+		s, _ := json.Marshal(v)
+		sb.Write(s)
+	case []any:
+		sb.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writePythonLiteral(sb, e)
+		}
+		sb.WriteByte(']')
+	case map[string]any:
+		sb.WriteByte('{')
+		for i, k := range slices.Sorted(maps.Keys(v)) {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writePythonLiteral(sb, k)
+			sb.WriteString(": ")
+			writePythonLiteral(sb, v[k])
+		}
+		sb.WriteByte('}')
+	}
+}
+
+// RenderCode fills a code template for lang, embedding every value of data as a literal of the
+// language (see [Literal]), so user data can never break out of its expression. Values are
+// referenced as {{.name}} and must not be quoted in the template:
+//
+//	code, err := msb.RenderCode(msb.LanguagePython, `print(len({{.text}}))`, map[string]any{"text": userInput})
+func RenderCode(lang Language, tmpl string, data map[string]any) (string, error) {
+	literals := make(map[string]string, len(data))
+	for name, v := range data {
+		lit, err := Literal(lang, v)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		literals[name] = lit
+	}
+	t, err := template.New("code").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidCodeTemplate, err)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, literals); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidCodeTemplate, err)
+	}
+	return sb.String(), nil
+}
+
+// Quoting errors
+var (
+	ErrUnsupportedLiteral  = errors.New("value cannot be encoded as a literal")
+	ErrInvalidCodeTemplate = errors.New("invalid code template")
+)
@@ -0,0 +1,79 @@
+package msb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket allows bursts of up to capacity events and refills at capacity per period.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newTokenBucket(capacity int, period time.Duration) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		rate:     float64(capacity) / period.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until a token is available and takes it, or returns ctx's error.
+func (tb *tokenBucket) wait(ctx context.Context) error {
+	for {
+		tb.mu.Lock()
+		now := time.Now()
+		tb.tokens = min(tb.capacity, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+		tb.last = now
+		if tb.tokens >= 1 {
+			tb.tokens--
+			tb.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - tb.tokens) / tb.rate * float64(time.Second))
+		tb.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// waitExecSlot throttles executions when WithMaxExecutionsPerMinute is configured, and waits
+// for one of the slots of WithMaxConcurrentExecutions, which the execution must hand back by
+// calling release once done. It also records the activity for WithIdleTimeout, counts the
+// execution as in flight until released, and waits for the files written through WithSharedDir
+// to be visible in the guest, so that the execution sees them.
+func (b *baseMicroSandbox) waitExecSlot(ctx context.Context) (release func(), err error) {
+	b.lastActive.Store(time.Now().UnixNano())
+	if b.execLimiter != nil {
+		if err := b.execLimiter.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	releaseSlot := func() {}
+	if b.execQueue != nil {
+		if releaseSlot, err = b.execQueue.acquire(ctx, b.config().metrics); err != nil {
+			return nil, err
+		}
+	}
+	b.inFlight.Add(1)
+	if err := b.confirmSharedWrites(ctx); err != nil {
+		// The execution may still not need the files; the next Files().Sync reports them
+		b.logger().Error("Failed to confirm shared directory writes", "sandbox", b.name(), "error", err)
+	}
+	return func() {
+		b.inFlight.Add(-1)
+		releaseSlot()
+	}, nil
+}
@@ -0,0 +1,139 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// redactedMarker replaces secret values in error messages.
+const redactedMarker = "[REDACTED]"
+
+// CommandError describes a command that failed to run or exited non-zero, with every secret
+// registered through [WithSecrets] masked, so its message can be logged or shown to users.
+type CommandError struct {
+	Command  string // The command line, with secrets masked
+	ExitCode int    // The exit code; -1 if the command did not run to completion
+	Detail   string // The underlying failure or the command's last stderr line, with secrets masked
+	Err      error  // The underlying error, if any; its own message is not masked
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("command %s", e.Command)
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" exited with code %d", e.ExitCode)
+	} else {
+		msg += " failed"
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	if e.Err == nil {
+		return ErrCommandFailed
+	}
+	return e.Err
+}
+
+// secretEnvNameParts mark the names of environment variables that WithSecretEnvRedaction masks.
+var secretEnvNameParts = []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL"}
+
+// maskedValues returns the values masked in command errors: the secrets registered with
+// WithSecrets and, with WithSecretEnvRedaction, the values of secret-looking environment variables.
+func (c config) maskedValues() []string {
+	if !c.redactSecretEnv {
+		return c.secrets
+	}
+	values := slices.Clone(c.secrets)
+	for name, value := range c.envVars {
+		upper := strings.ToUpper(name)
+		if slices.ContainsFunc(secretEnvNameParts, func(part string) bool { return strings.Contains(upper, part) }) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// redactor masks secret values in text.
+type redactor struct {
+	r *strings.Replacer // nil when there are no secrets
+}
+
+func newRedactor(secrets []string) redactor {
+	// Longest first, so that a secret containing another one is masked whole
+	secrets = slices.DeleteFunc(slices.Clone(secrets), func(s string) bool { return s == "" })
+	if len(secrets) == 0 {
+		return redactor{}
+	}
+	slices.SortFunc(secrets, func(a, b string) int { return len(b) - len(a) })
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s, redactedMarker)
+	}
+	return redactor{r: strings.NewReplacer(pairs...)}
+}
+
+func (rd redactor) redact(s string) string {
+	if rd.r == nil {
+		return s
+	}
+	return rd.r.Replace(s)
+}
+
+func (rd redactor) redactAll(ss []string) []string {
+	if rd.r == nil {
+		return ss
+	}
+	redacted := make([]string, len(ss))
+	for i, s := range ss {
+		redacted[i] = rd.r.Replace(s)
+	}
+	return redacted
+}
+
+// redactorKey carries the redactor of a sandbox in the context of its HTTP requests.
+type redactorKey struct{}
+
+// RedactSecrets masks in s the secrets of the sandbox that sent the HTTP request whose context is
+// ctx, as registered with [WithSecret] or [WithSecrets]. It is meant for custom transports that
+// keep request or response bodies, such as recorders; s is returned as is for other contexts.
+func RedactSecrets(ctx context.Context, s string) string {
+	rd, _ := ctx.Value(redactorKey{}).(redactor)
+	return rd.redact(s)
+}
+
+// commandError wraps err, the failure to run cmd, in a CommandError with secrets masked.
+func (rd redactor) commandError(cmd string, args []string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CommandError{
+		Command:  rd.redact(commandScript(cmd, args)),
+		ExitCode: -1,
+		Detail:   rd.redact(err.Error()),
+		Err:      err,
+	}
+}
+
+// Err returns nil if the command succeeded, and otherwise a [*CommandError] naming the command
+// and its exit code, with the secrets registered through [WithSecrets] masked.
+func (ce CommandExecution) Err() error {
+	if ce.IsSuccess() {
+		return nil
+	}
+	stderr, _ := ce.GetError()
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	return &CommandError{
+		Command:  ce.redactor.redact(commandScript(ce.GetCommand(), ce.GetArgs())),
+		ExitCode: ce.GetExitCode(),
+		Detail:   ce.redactor.redact(strings.TrimSpace(lines[len(lines)-1])),
+	}
+}
+
+// ErrCommandFailed is matched by a [*CommandError] for a command that ran and exited non-zero.
+var ErrCommandFailed = errors.New("command failed")
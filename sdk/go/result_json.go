@@ -0,0 +1,160 @@
+package msb
+
+import "encoding/json"
+
+// ResultJSONVersion is the version of the canonical JSON encoding of results, produced by the
+// ToJSON methods. Fields may be added within a version; renaming or removing one, or changing
+// its meaning, bumps the version.
+//
+// Every document carries its kind and version, so tooling can parse the output of any front end
+// uniformly:
+//
+//	{"kind": "code_execution", "version": 1, "language": "python", "status": "success",
+//	 "has_error": false, "stdout": "2", "stderr": "", "output": [{"stream": "stdout", "text": "2"}]}
+//	{"kind": "command_execution", "version": 1, "command": "ls", "args": ["-l"], "exit_code": 0,
+//	 "exit_status": "exited", "success": true, "duration_ms": 12, "stdout": "...", "stderr": "",
+//	 "output": [...]}
+//	{"kind": "metrics", "version": 1, "name": "my-sandbox", "namespace": "default",
+//	 "running": true, "cpu_percent": 1.5, "memory_mib": 120, "disk_bytes": 4096, "uptime_ms": 60000}
+//
+// Executions that were terminated abnormally also carry "termination": {"kind": "timeout"}, with
+// "signal" and "signal_name" for signals; executions run with [WithSpecSnapshots] carry "spec",
+// and executions run with [WithResourceUsage] carry "resource_usage": {"cpu_time_ms": 120,
+// "user_cpu_time_ms": 100, "system_cpu_time_ms": 20, "peak_memory_bytes": 52428800,
+// "wall_time_ms": 340, "bytes_read": 1024, "bytes_written": 4096}.
+const ResultJSONVersion = 1
+
+// Result kinds of the canonical JSON encoding
+const (
+	resultKindCodeExecution    = "code_execution"
+	resultKindCommandExecution = "command_execution"
+	resultKindMetrics          = "metrics"
+)
+
+type resultHeader struct {
+	Kind    string `json:"kind"`
+	Version int    `json:"version"`
+}
+
+type terminationJSON struct {
+	Kind       string `json:"kind"`
+	Signal     int    `json:"signal,omitempty"`
+	SignalName string `json:"signal_name,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+func newTerminationJSON(reason TerminationReason) *terminationJSON {
+	if reason.Kind == TerminationNone {
+		return nil
+	}
+	return &terminationJSON{Kind: reason.Kind.String(), Signal: reason.Signal, SignalName: reason.SignalName, Detail: reason.Detail}
+}
+
+// ToJSON encodes the execution in the canonical JSON encoding; see [ResultJSONVersion].
+func (ce CodeExecution) ToJSON() ([]byte, error) {
+	stdout, _ := ce.GetOutput()
+	stderr, _ := ce.GetError()
+	lines, _ := ce.GetOutputLines()
+	var spec *SandboxSpec
+	if s, ok := ce.GetSpec(); ok {
+		spec = &s
+	}
+	return json.Marshal(struct {
+		resultHeader
+		Language    string             `json:"language"`
+		Status      string             `json:"status"`
+		HasError    bool               `json:"has_error"`
+		Stdout      string             `json:"stdout"`
+		Stderr      string             `json:"stderr"`
+		Output      []OutputLine       `json:"output"`
+		Termination *terminationJSON   `json:"termination,omitempty"`
+		Spec        *SandboxSpec       `json:"spec,omitempty"`
+		Usage       *resourceUsageJSON `json:"resource_usage,omitempty"`
+	}{
+		resultHeader: resultHeader{Kind: resultKindCodeExecution, Version: ResultJSONVersion},
+		Language:     ce.GetLanguage(),
+		Status:       ce.GetStatus(),
+		HasError:     ce.HasError(),
+		Stdout:       stdout,
+		Stderr:       stderr,
+		Output:       nonNilLines(lines),
+		Termination:  newTerminationJSON(ce.termination),
+		Spec:         spec,
+		Usage:        newResourceUsageJSON(ce.usage),
+	})
+}
+
+// ToJSON encodes the execution in the canonical JSON encoding; see [ResultJSONVersion].
+func (ce CommandExecution) ToJSON() ([]byte, error) {
+	stdout, _ := ce.GetOutput()
+	stderr, _ := ce.GetError()
+	lines, _ := ce.GetOutputLines()
+	args := ce.GetArgs()
+	if args == nil {
+		args = []string{}
+	}
+	var spec *SandboxSpec
+	if s, ok := ce.GetSpec(); ok {
+		spec = &s
+	}
+	return json.Marshal(struct {
+		resultHeader
+		Command     string             `json:"command"`
+		Args        []string           `json:"args"`
+		ExitCode    int                `json:"exit_code"`
+		ExitStatus  string             `json:"exit_status"`
+		Success     bool               `json:"success"`
+		DurationMS  int64              `json:"duration_ms"`
+		Stdout      string             `json:"stdout"`
+		Stderr      string             `json:"stderr"`
+		Output      []OutputLine       `json:"output"`
+		Termination *terminationJSON   `json:"termination,omitempty"`
+		Spec        *SandboxSpec       `json:"spec,omitempty"`
+		Usage       *resourceUsageJSON `json:"resource_usage,omitempty"`
+	}{
+		resultHeader: resultHeader{Kind: resultKindCommandExecution, Version: ResultJSONVersion},
+		Command:      ce.GetCommand(),
+		Args:         args,
+		ExitCode:     ce.GetExitCode(),
+		ExitStatus:   ce.GetExitStatus().String(),
+		Success:      ce.IsSuccess(),
+		DurationMS:   ce.GetDuration().Milliseconds(),
+		Stdout:       stdout,
+		Stderr:       stderr,
+		Output:       nonNilLines(lines),
+		Termination:  newTerminationJSON(ce.termination),
+		Spec:         spec,
+		Usage:        newResourceUsageJSON(ce.usage),
+	})
+}
+
+// ToJSON encodes the metrics in the canonical JSON encoding; see [ResultJSONVersion].
+func (m Metrics) ToJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		resultHeader
+		Name       string  `json:"name"`
+		Namespace  string  `json:"namespace"`
+		Running    bool    `json:"running"`
+		CPUPercent float64 `json:"cpu_percent"`
+		MemoryMiB  int     `json:"memory_mib"`
+		DiskBytes  int     `json:"disk_bytes"`
+		UptimeMS   int64   `json:"uptime_ms"`
+	}{
+		resultHeader: resultHeader{Kind: resultKindMetrics, Version: ResultJSONVersion},
+		Name:         m.Name,
+		Namespace:    m.Namespace,
+		Running:      m.IsRunning,
+		CPUPercent:   m.CPU,
+		MemoryMiB:    m.MemoryMiB,
+		DiskBytes:    m.DiskBytes,
+		UptimeMS:     m.Uptime.Milliseconds(),
+	})
+}
+
+// nonNilLines encodes missing output as an empty list rather than null.
+func nonNilLines(lines []OutputLine) []OutputLine {
+	if lines == nil {
+		return []OutputLine{}
+	}
+	return lines
+}
@@ -0,0 +1,382 @@
+package msb
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExecutionRecord is the durable result of an execution, as kept by a [ResultStore].
+type ExecutionRecord struct {
+	ID          string        `json:"id"`
+	Namespace   string        `json:"namespace"`
+	Sandbox     string        `json:"sandbox"`
+	Started     time.Time     `json:"started"`
+	Duration    time.Duration `json:"duration_ns"`
+	Language    Language      `json:"language,omitempty"`
+	Code        string        `json:"code,omitempty"`
+	Command     string        `json:"command,omitempty"`
+	Args        []string      `json:"args,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Status      string        `json:"status"` // "success" or "error"
+	ExitCode    *int          `json:"exit_code,omitempty"`
+	Stdout      string        `json:"stdout,omitempty"`
+	Stderr      string        `json:"stderr,omitempty"`
+	Termination string        `json:"termination,omitempty"` // TerminationKind, if terminated abnormally
+	Error       string        `json:"error,omitempty"`       // Error returned by Run, if any
+}
+
+// ResultQuery selects the records returned by [ResultStore.List]. Zero fields match every record.
+type ResultQuery struct {
+	Namespace string
+	Sandbox   string
+	Since     time.Time // Only executions started at or after Since
+	Tag       string    // Only executions tagged Tag with WithTags
+	Limit     int       // At most Limit records, the most recent ones
+}
+
+// ResultStore keeps the results of executions by execution ID, so applications get a durable run
+// history. [NewFileResultStore] and [NewSQLResultStore] provide ready-made stores; see
+// [WithResultStore] to persist every execution automatically.
+type ResultStore interface {
+	// Put stores rec, replacing any record with the same ID.
+	Put(ctx context.Context, rec ExecutionRecord) error
+	// Get returns the record with the given ID, or an error wrapping [ErrResultNotFound].
+	Get(ctx context.Context, id string) (ExecutionRecord, error)
+	// List returns the records matching q, most recent first.
+	List(ctx context.Context, q ResultQuery) ([]ExecutionRecord, error)
+}
+
+// WithResultStore stores the result of every execution in store, under the execution ID of its
+// lifecycle events (see [WithPublisher]). Storing happens synchronously when the execution
+// finishes; failures are logged and do not affect the execution.
+func WithResultStore(store ResultStore) Option {
+	return WithPublisher(PublisherFunc(func(ctx context.Context, ev LifecycleEvent) error {
+		if ev.Type != EventExecutionFinished {
+			return nil
+		}
+		return store.Put(context.WithoutCancel(ctx), recordFromEvent(ev))
+	}))
+}
+
+// recordFromEvent converts a finished lifecycle event to a record.
+func recordFromEvent(ev LifecycleEvent) ExecutionRecord {
+	return ExecutionRecord{
+		ID:          ev.ExecutionID,
+		Namespace:   ev.Namespace,
+		Sandbox:     ev.Sandbox,
+		Started:     ev.Time.Add(-ev.Duration),
+		Duration:    ev.Duration,
+		Language:    ev.Language,
+		Code:        ev.Code,
+		Command:     ev.Command,
+		Args:        ev.Args,
+		Tags:        ev.Tags,
+		Status:      ev.Status,
+		ExitCode:    ev.ExitCode,
+		Stdout:      ev.Stdout,
+		Stderr:      ev.Stderr,
+		Termination: ev.Termination,
+		Error:       ev.Error,
+	}
+}
+
+func (q ResultQuery) matches(rec ExecutionRecord) bool {
+	return (q.Namespace == "" || rec.Namespace == q.Namespace) &&
+		(q.Sandbox == "" || rec.Sandbox == q.Sandbox) &&
+		!rec.Started.Before(q.Since) &&
+		(q.Tag == "" || slices.Contains(rec.Tags, q.Tag))
+}
+
+// FileResultStore is a [ResultStore] keeping one JSON file per execution in a directory. Listing
+// reads every file, so it suits local tools and moderate histories; see [SQLResultStore] for
+// larger ones.
+type FileResultStore struct {
+	dir string
+}
+
+// NewFileResultStore returns a store in dir, creating the directory if needed.
+func NewFileResultStore(dir string) (*FileResultStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	return &FileResultStore{dir: dir}, nil
+}
+
+func (s *FileResultStore) path(id string) (string, error) {
+	if !validGuestJobID(id) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidExecutionID, id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *FileResultStore) Put(_ context.Context, rec ExecutionRecord) error {
+	path, err := s.path(rec.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	// Write to a temporary file first, so readers never see a partial record
+	tmp, err := os.CreateTemp(s.dir, ".tmp-"+rec.ID+"-*")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	return nil
+}
+
+func (s *FileResultStore) Get(_ context.Context, id string) (ExecutionRecord, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return ExecutionRecord{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ExecutionRecord{}, fmt.Errorf("%w: %q", ErrResultNotFound, id)
+	}
+	if err != nil {
+		return ExecutionRecord{}, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	var rec ExecutionRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return ExecutionRecord{}, fmt.Errorf("%w: %s: %w", ErrResultStoreFailed, path, err)
+	}
+	return rec, nil
+}
+
+func (s *FileResultStore) List(ctx context.Context, q ResultQuery) ([]ExecutionRecord, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	var records []ExecutionRecord
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() || !validGuestJobID(id) {
+			continue
+		}
+		rec, err := s.Get(ctx, id)
+		if errors.Is(err, ErrResultNotFound) {
+			continue // Replaced or removed since the directory was read
+		}
+		if err != nil {
+			return nil, err
+		}
+		if q.matches(rec) {
+			records = append(records, rec)
+		}
+	}
+	sortRecords(records)
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, nil
+}
+
+// sortRecords orders records most recent first.
+func sortRecords(records []ExecutionRecord) {
+	slices.SortStableFunc(records, func(a, b ExecutionRecord) int {
+		return cmp.Or(b.Started.Compare(a.Started), strings.Compare(a.ID, b.ID))
+	})
+}
+
+// SQLDialect selects the query syntax of a [SQLResultStore]'s database.
+type SQLDialect int
+
+const (
+	SQLDialectSQLite   SQLDialect = iota // SQLite, with ? placeholders
+	SQLDialectMySQL                      // MySQL and MariaDB, with ? placeholders
+	SQLDialectPostgres                   // PostgreSQL, with $n placeholders
+)
+
+// resultTable is the table a SQLResultStore keeps its records in, and resultTagTable their
+// tags, one row per tag.
+const (
+	resultTable    = "msb_executions"
+	resultTagTable = "msb_execution_tags"
+)
+
+// SQLResultStore is a [ResultStore] keeping records in a SQL database, through a [*sql.DB]
+// opened with the driver of the application's choice. Namespace, sandbox, start time and tags
+// are columns, for querying; the full record is kept as JSON.
+type SQLResultStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+}
+
+// NewSQLResultStore returns a store in db, creating its table if needed.
+//
+//	db, err := sql.Open("sqlite", "runs.db")
+//	store, err := msb.NewSQLResultStore(ctx, db, msb.SQLDialectSQLite)
+//	sandbox := msb.NewPythonSandbox(msb.WithResultStore(store))
+func NewSQLResultStore(ctx context.Context, db *sql.DB, dialect SQLDialect) (*SQLResultStore, error) {
+	s := &SQLResultStore{db: db, dialect: dialect}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + resultTable + ` (
+			id VARCHAR(64) PRIMARY KEY,
+			namespace VARCHAR(255) NOT NULL,
+			sandbox VARCHAR(255) NOT NULL,
+			started BIGINT NOT NULL,
+			record TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + resultTagTable + ` (
+			id VARCHAR(64) NOT NULL,
+			tag VARCHAR(255) NOT NULL,
+			PRIMARY KEY (id, tag)
+		)`,
+	}
+	// MySQL has no CREATE INDEX IF NOT EXISTS; its table is small enough to list without one
+	if dialect != SQLDialectMySQL {
+		stmts = append(stmts, `CREATE INDEX IF NOT EXISTS `+resultTable+`_started ON `+resultTable+` (started)`)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+		}
+	}
+	return s, nil
+}
+
+// query rewrites the ? placeholders of query for the store's dialect.
+func (s *SQLResultStore) query(query string) string {
+	if s.dialect != SQLDialectPostgres {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (s *SQLResultStore) Put(ctx context.Context, rec ExecutionRecord) error {
+	if !validGuestJobID(rec.ID) {
+		return fmt.Errorf("%w: %q", ErrInvalidExecutionID, rec.ID)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	// Upserts differ between databases, so replace the record within a transaction instead
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, table := range []string{resultTable, resultTagTable} {
+		if _, err := tx.ExecContext(ctx, s.query(`DELETE FROM `+table+` WHERE id = ?`), rec.ID); err != nil {
+			return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.query(`INSERT INTO `+resultTable+` (id, namespace, sandbox, started, record) VALUES (?, ?, ?, ?, ?)`),
+		rec.ID, rec.Namespace, rec.Sandbox, rec.Started.UnixNano(), string(data)); err != nil {
+		return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	for _, tag := range slices.Compact(slices.Sorted(slices.Values(rec.Tags))) {
+		if _, err := tx.ExecContext(ctx, s.query(`INSERT INTO `+resultTagTable+` (id, tag) VALUES (?, ?)`), rec.ID, tag); err != nil {
+			return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	return nil
+}
+
+func (s *SQLResultStore) Get(ctx context.Context, id string) (ExecutionRecord, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.query(`SELECT record FROM `+resultTable+` WHERE id = ?`), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ExecutionRecord{}, fmt.Errorf("%w: %q", ErrResultNotFound, id)
+	}
+	if err != nil {
+		return ExecutionRecord{}, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	var rec ExecutionRecord
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return ExecutionRecord{}, fmt.Errorf("%w: %q: %w", ErrResultStoreFailed, id, err)
+	}
+	return rec, nil
+}
+
+func (s *SQLResultStore) List(ctx context.Context, q ResultQuery) ([]ExecutionRecord, error) {
+	query := `SELECT record FROM ` + resultTable + ` WHERE 1 = 1`
+	var args []any
+	if q.Namespace != "" {
+		query += ` AND namespace = ?`
+		args = append(args, q.Namespace)
+	}
+	if q.Sandbox != "" {
+		query += ` AND sandbox = ?`
+		args = append(args, q.Sandbox)
+	}
+	if !q.Since.IsZero() {
+		query += ` AND started >= ?`
+		args = append(args, q.Since.UnixNano())
+	}
+	if q.Tag != "" {
+		query += ` AND id IN (SELECT id FROM ` + resultTagTable + ` WHERE tag = ?)`
+		args = append(args, q.Tag)
+	}
+	query += ` ORDER BY started DESC, id`
+	if q.Limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(q.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.query(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	defer rows.Close()
+	var records []ExecutionRecord
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+		}
+		var rec ExecutionRecord
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResultStoreFailed, err)
+	}
+	return records, nil
+}
+
+// Result store errors
+var (
+	ErrResultStoreFailed  = errors.New("result store failed")
+	ErrResultNotFound     = errors.New("execution result not found")
+	ErrInvalidExecutionID = errors.New("invalid execution ID")
+)
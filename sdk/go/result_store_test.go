@@ -0,0 +1,51 @@
+package msb_test
+
+import (
+	"context"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+	"github.com/keithang/microsandbox/sdk/go/msbtest"
+)
+
+func TestResultStoreListsByTag(t *testing.T) {
+	ctx := context.Background()
+	store, err := msb.NewFileResultStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sandbox := msbtest.NewFakeSandbox(msb.LanguagePython, msb.WithResultStore(store))
+	sandbox.HandleCommand("echo", msbtest.Result{Stdout: "ok"})
+	if err := sandbox.Start(ctx, "img", 0, 0); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	runs := [][]string{{"eval-run-41"}, {"eval-run-42", "baseline"}, nil}
+	for i, tags := range runs {
+		if _, err := sandbox.Command().Run(ctx, "echo", []string{string(rune('a' + i))}, msb.WithTags(tags...)); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+
+	tests := []struct {
+		tag  string
+		want int
+	}{
+		{"", 3},
+		{"eval-run-42", 1},
+		{"baseline", 1},
+		{"eval-run-4", 0},
+	}
+	for _, tt := range tests {
+		records, err := store.List(ctx, msb.ResultQuery{Tag: tt.tag})
+		if err != nil {
+			t.Fatalf("List(%q): %v", tt.tag, err)
+		}
+		if len(records) != tt.want {
+			t.Errorf("List(%q) = %d records, want %d", tt.tag, len(records), tt.want)
+		}
+	}
+	records, _ := store.List(ctx, msb.ResultQuery{Tag: "eval-run-42"})
+	if len(records) == 1 && (len(records[0].Args) != 1 || records[0].Args[0] != "b") {
+		t.Errorf("record tagged eval-run-42 has args %v, want [b]", records[0].Args)
+	}
+}
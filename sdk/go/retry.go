@@ -0,0 +1,69 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Backoff returns how long to wait before retry number attempt (starting at 1).
+type Backoff func(attempt int) time.Duration
+
+// ExponentialBackoff doubles the delay after every attempt, starting at base and capped at
+// maxDelay, with full jitter so that clients retrying together spread out.
+func ExponentialBackoff(base, maxDelay time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := maxDelay
+		if shift := attempt - 1; shift < 62 && base<<shift > 0 && base<<shift < maxDelay {
+			d = base << shift
+		}
+		return rand.N(d + 1)
+	}
+}
+
+type retryPolicy struct {
+	maxAttempts int
+	backoff     Backoff
+}
+
+// retryable reports whether a failed RPC may safely be sent again. Any call rejected with
+// 429 or 503 was not acted upon; metrics reads are idempotent and also survive transport
+// failures and gateway errors.
+func retryable(method rpcMethod, err error) bool {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return method == methodSandboxMetricsGet
+		}
+		return false
+	}
+	return method == methodSandboxMetricsGet && errors.Is(err, ErrSendRequestFailed)
+}
+
+// sendWithRetry sends the request, retrying transient failures as configured with WithRetry.
+func (d *jsonRPCHTTPClient) sendWithRetry(ctx context.Context, cfg *config, method rpcMethod, params any, header http.Header) (jsonRPCResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := d.doAuthenticated(ctx, cfg, method, params, header)
+		if err == nil || attempt >= cfg.retry.maxAttempts || !retryable(method, err) || ctx.Err() != nil {
+			return resp, err
+		}
+		delay := cfg.retry.backoff(attempt)
+		var mErr *MaintenanceError
+		if errors.As(err, &mErr) {
+			delay = max(delay, mErr.RetryAfter)
+		}
+		cfg.logger.Info("Retrying RPC", "method", string(method), "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package msb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// richOutputPrefix marks a stdout line carrying a Jupyter-style MIME bundle as JSON. The server
+// only relays text, so rich outputs travel through stdout and are picked out by the client.
+const richOutputPrefix = "__msb_display__:"
+
+// RichOutput is a value displayed by the code, in every representation it offered, keyed by MIME
+// type, e.g. "image/png", "text/html", "application/json" and "text/plain". Binary data such as
+// images is decoded; JSON is kept as raw JSON.
+type RichOutput struct {
+	Data map[string][]byte
+}
+
+// Image returns the displayed PNG or JPEG image, if there is one.
+func (r RichOutput) Image() ([]byte, bool) {
+	for _, mime := range []string{"image/png", "image/jpeg"} {
+		if data, ok := r.Data[mime]; ok {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// isRichOutputLine reports whether line carries a rich output rather than plain text.
+func isRichOutputLine(line OutputLine) bool {
+	return line.Stream == "stdout" && strings.HasPrefix(line.Text, richOutputPrefix)
+}
+
+// parseRichOutput decodes the MIME bundle of a rich output line. Image types other than SVG are
+// base64-encoded in the bundle, as in Jupyter.
+func parseRichOutput(line OutputLine) (RichOutput, bool) {
+	var bundle map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line.Text, richOutputPrefix)), &bundle); err != nil {
+		return RichOutput{}, false
+	}
+	out := RichOutput{Data: make(map[string][]byte, len(bundle))}
+	for mime, raw := range bundle {
+		var s string
+		isString := json.Unmarshal(raw, &s) == nil
+		switch {
+		case mime == "application/json" || strings.HasSuffix(mime, "+json"):
+			out.Data[mime] = raw
+		case !isString:
+			out.Data[mime] = raw
+		case strings.HasPrefix(mime, "image/") && mime != "image/svg+xml":
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				continue
+			}
+			out.Data[mime] = data
+		default:
+			out.Data[mime] = []byte(s)
+		}
+	}
+	return out, true
+}
+
+// RichOutputHelper returns code that defines a display function in the sandbox's interpreter,
+// to be run once before code that produces rich outputs. display(obj) emits a value in the rich
+// representations it offers, which the results' GetRichOutputs and GetImages then return.
+//
+// In Python, display understands matplotlib figures, PIL images and anything with Jupyter's
+// _repr_html_, _repr_png_ or _repr_json_ methods, such as pandas DataFrames, and
+// matplotlib.pyplot.show displays every open figure. In Node.js, display(data, mime) emits data
+// under the given MIME type; Buffers are base64-encoded, and the type defaults to "text/html"
+// for strings and "application/json" otherwise.
+//
+//	helper, _ := msb.RichOutputHelper(msb.LanguagePython)
+//	_, err := sandbox.Code().Run(ctx, helper)
+//	exec, err := sandbox.Code().Run(ctx, "import matplotlib.pyplot as plt\nplt.plot([1, 2, 3])\nplt.show()")
+//	images := exec.GetImages()
+func RichOutputHelper(lang Language) (string, error) {
+	rt, err := lookupLanguage(string(lang))
+	if err != nil {
+		return "", err
+	}
+	switch rt.Name {
+	case LanguagePython:
+		return pythonRichOutputHelper, nil
+	case LanguageNodeJS:
+		return nodeRichOutputHelper, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrNoRichOutputHelper, rt.Name)
+	}
+}
+
+const pythonRichOutputHelper = `def display(*objs):
+    import base64, io, json, sys
+    for obj in objs:
+        bundle = {}
+        if hasattr(obj, "savefig"):
+            buf = io.BytesIO()
+            obj.savefig(buf, format="png", bbox_inches="tight")
+            bundle["image/png"] = base64.b64encode(buf.getvalue()).decode()
+        elif hasattr(obj, "save") and hasattr(obj, "mode"):
+            buf = io.BytesIO()
+            obj.save(buf, format="PNG")
+            bundle["image/png"] = base64.b64encode(buf.getvalue()).decode()
+        for mime, method in (("text/html", "_repr_html_"), ("image/png", "_repr_png_"), ("application/json", "_repr_json_")):
+            if mime in bundle or not hasattr(obj, method):
+                continue
+            data = getattr(obj, method)()
+            if data is None:
+                continue
+            if isinstance(data, bytes):
+                data = base64.b64encode(data).decode()
+            bundle[mime] = data
+        if not bundle and isinstance(obj, (dict, list)):
+            bundle["application/json"] = obj
+        bundle["text/plain"] = repr(obj)
+        sys.stdout.write("` + richOutputPrefix + `" + json.dumps(bundle, default=str) + "\n")
+        sys.stdout.flush()
+
+try:
+    import matplotlib
+    matplotlib.use("Agg")
+    import matplotlib.pyplot as _msb_plt
+
+    def _msb_show(*args, **kwargs):
+        for num in _msb_plt.get_fignums():
+            display(_msb_plt.figure(num))
+        _msb_plt.close("all")
+
+    _msb_plt.show = _msb_show
+except ImportError:
+    pass
+`
+
+const nodeRichOutputHelper = `globalThis.display = (data, mime) => {
+  mime = mime || (typeof data === "string" ? "text/html" : "application/json");
+  const bundle = { [mime]: Buffer.isBuffer(data) ? data.toString("base64") : data };
+  process.stdout.write("` + richOutputPrefix + `" + JSON.stringify(bundle) + "\n");
+};
+`
+
+// Rich output errors
+var (
+	ErrNoRichOutputHelper = errors.New("no rich output helper for language")
+)
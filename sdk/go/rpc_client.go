@@ -7,17 +7,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"time"
 )
 
 // rpcClient is an internal interface for keeping the microsandbox interactions decoupled from the kind of transport being used
 type rpcClient interface {
-	startSandbox(ctx context.Context, cfg *config, image string, memory int, cpus int) error
+	startSandbox(ctx context.Context, cfg *config, image string, memory int, cpus int, envs []string, ports []string) (string, error)
 	stopSandbox(ctx context.Context, cfg *config) error
-	runRepl(ctx context.Context, cfg *config, lang progLang, code string) (*executionResult, error)
+	runRepl(ctx context.Context, cfg *config, lang Language, code string) (*executionResult, error)
 	runCommand(ctx context.Context, cfg *config, command string, args []string) (*executionResult, error)
 	getMetrics(ctx context.Context, cfg *config) (*sandboxMetrics, error)
+	listSandboxes(ctx context.Context, cfg *config, namespace string) ([]sandboxMetrics, error)
+	serverInfo(ctx context.Context, cfg *config) (*ServerInfo, error)
+	preconnect(ctx context.Context, cfg *config, n int)
 }
 
 // rpcMethod represents a JSON-RPC method name
@@ -30,10 +34,22 @@ const (
 	methodSandboxReplRun    rpcMethod = "sandbox.repl.run"
 	methodSandboxCommandRun rpcMethod = "sandbox.command.run"
 	methodSandboxMetricsGet rpcMethod = "sandbox.metrics.get"
+	methodInitialize        rpcMethod = "initialize" // MCP handshake, served on mcpRoute
 )
 
-// endpoint routing path
-const endpointRoute = "/api/v1/rpc"
+// endpoint routing paths
+const (
+	endpointRoute = "/api/v1/rpc"
+	mcpRoute      = "/mcp"
+)
+
+// route returns the path the method is served on.
+func (m rpcMethod) route() string {
+	if m == methodInitialize {
+		return mcpRoute
+	}
+	return endpointRoute
+}
 
 // JSON-RPC request/response types
 type jsonRPCRequest struct {
@@ -48,12 +64,15 @@ type jsonRPCResponse struct {
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *jsonRPCError   `json:"error,omitempty"`
 	ID      string          `json:"id"`
+
+	date   time.Time   // the HTTP Date header, i.e. the server's clock; zero if absent
+	header http.Header // the HTTP response header
 }
 
 type jsonRPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Data    any    `json:"data,omitempty"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
 }
 
 // Request parameter types
@@ -64,9 +83,12 @@ type startParams struct {
 }
 
 type startConfig struct {
-	Image  string `json:"image"`
-	Memory int    `json:"memory"`
-	CPUs   int    `json:"cpus"`
+	Image   string   `json:"image"`
+	Memory  int      `json:"memory"`
+	CPUs    int      `json:"cpus"`
+	Volumes []string `json:"volumes,omitempty"`
+	Ports   []string `json:"ports,omitempty"`
+	Envs    []string `json:"envs,omitempty"`
 }
 
 type stopParams struct {
@@ -91,12 +113,13 @@ type commandRunParams struct {
 
 type metricsGetParams struct {
 	Namespace   string `json:"namespace"`
-	SandboxName string `json:"sandbox"`
+	SandboxName string `json:"sandbox,omitempty"` // empty lists every sandbox of the namespace
 }
 
 // Response types
 type executionResult struct {
-	output json.RawMessage `json:"-"` // Store raw JSON for flexible parsing
+	output     json.RawMessage `json:"-"` // Store raw JSON for flexible parsing
+	serverDate time.Time       // when the server replied, by its own clock; zero if unknown
 }
 
 type metricsResult struct {
@@ -104,12 +127,12 @@ type metricsResult struct {
 }
 
 type sandboxMetrics struct {
-	Name        string  `json:"name"`
-	Namespace   string  `json:"namespace"`
-	Running     bool    `json:"running"`
-	CPUUsage    float64 `json:"cpu_usage"`
-	MemoryUsage int     `json:"memory_usage"`
-	DiskUsage   int     `json:"disk_usage"`
+	Name        string   `json:"name"`
+	Namespace   string   `json:"namespace"`
+	Running     bool     `json:"running"`
+	CPUUsage    *float64 `json:"cpu_usage"`    // nil when the server cannot measure it
+	MemoryUsage *int     `json:"memory_usage"` // nil when the server cannot measure it
+	DiskUsage   *int     `json:"disk_usage"`   // nil when the server cannot measure it
 }
 
 var _ rpcClient = &jsonRPCHTTPClient{}
@@ -119,29 +142,58 @@ type jsonRPCHTTPClient struct {
 }
 
 func newDefaultJsonRPCHTTPClient() rpcClient {
-	return newJsonRPCHTTPClient(
-		&http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns:       10,
-				IdleConnTimeout:    30 * time.Second,
-				DisableCompression: true,
-			},
-		},
-	)
+	return newJsonRPCHTTPClient(&http.Client{Transport: newDefaultTransport()})
+}
+
+// newDefaultTransport pools connections to the server, which every request goes to, and
+// speaks HTTP/2 to TLS servers that offer it.
+func newDefaultTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     30 * time.Second,
+		DisableCompression:  true,
+	}
 }
 
 func newJsonRPCHTTPClient(c *http.Client) rpcClient {
 	return &jsonRPCHTTPClient{c}
 }
 
-func (d *jsonRPCHTTPClient) makeJSONRPCRequest(ctx context.Context, serverURL string, method rpcMethod, params any, apiKey string, logger Logger, reqIdPrd ReqIdProducer) (resp jsonRPCResponse, err error) {
+func (d *jsonRPCHTTPClient) doJSONRPCRequest(ctx context.Context, cfg *config, method rpcMethod, params any, header http.Header) (resp jsonRPCResponse, err error) {
+	logger := cfg.logger
+	rd := newRedactor(cfg.maskedValues())
+	parent := ctx
+	timeout := cfg.rpcTimeout(method)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if cfg.adaptiveTimeout != nil {
+		begin := time.Now()
+		defer func() {
+			var rpcErr *RPCError
+			switch {
+			case err == nil, errors.As(err, &rpcErr):
+				// The server answered, so the call took as long as it needs
+				cfg.adaptiveTimeout.observe(method, time.Since(begin))
+			case errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil:
+				cfg.adaptiveTimeout.observe(method, timeout)
+			}
+		}()
+	}
 	req := &jsonRPCRequest{
 		JSONRPC: "2.0",
 		Method:  string(method),
 		Params:  params,
 	}
-	if reqIdPrd != nil {
-		req.ID = reqIdPrd()
+	if cfg.reqIDPrd != nil {
+		req.ID = cfg.reqIDPrd()
 	}
 
 	logger.Debug("Making JSON-RPC request", "method", string(method), "id", req.ID)
@@ -151,16 +203,42 @@ func (d *jsonRPCHTTPClient) makeJSONRPCRequest(ctx context.Context, serverURL st
 		logger.Error("Failed to marshal JSON-RPC request", "method", string(method), "error", err)
 		return resp, fmt.Errorf("%w: %w", ErrMarshalReqFailed, err)
 	}
+	compressed := false
+	if cfg.rpcCompression != nil {
+		if reqBytes, compressed, err = cfg.rpcCompression.encode(reqBytes); err != nil {
+			logger.Error("Failed to compress JSON-RPC request", "method", string(method), "error", err)
+			return resp, err
+		}
+	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s%s", serverURL, endpointRoute), bytes.NewReader(reqBytes))
+	httpReq, err := http.NewRequestWithContext(withConnTrace(context.WithValue(ctx, redactorKey{}, rd), cfg, string(method)), http.MethodPost, fmt.Sprintf("%s%s", cfg.serverUrl, method.route()), bytes.NewReader(reqBytes))
 	if err != nil {
 		logger.Error("Failed to create HTTP request", "method", string(method), "error", err)
 		return resp, fmt.Errorf("%w: %w", ErrCreateRequestFailed, err)
 	}
 
+	for name, values := range header {
+		httpReq.Header[name] = values
+	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	if apiKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	if cfg.rpcCompression != nil {
+		cfg.rpcCompression.setHeaders(httpReq, compressed)
+	}
+	if httpReq.Header.Get("Authorization") == "" {
+		apiKey, err := cfg.credential(ctx)
+		if err != nil {
+			logger.Error("Failed to get API key", "method", string(method), "error", err)
+			return resp, err
+		}
+		if apiKey != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+	}
+	if cfg.signer != nil {
+		if err := signRequest(cfg.signer, httpReq, reqBytes); err != nil {
+			logger.Error("Failed to sign request", "method", string(method), "error", err)
+			return resp, err
+		}
 	}
 
 	httpResp, err := d.Do(httpReq)
@@ -174,48 +252,119 @@ func (d *jsonRPCHTTPClient) makeJSONRPCRequest(ctx context.Context, serverURL st
 		}
 	}()
 
-	if httpResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(httpResp.Body)
-		logger.Error("HTTP request failed", "method", string(method), "status", httpResp.StatusCode, "body", string(body))
-		return resp, fmt.Errorf("%w: status %d: %s", ErrRequestFailed, httpResp.StatusCode, string(body))
+	if cfg.rpcCompression != nil && cfg.rpcCompression.observe(httpResp, compressed) {
+		logger.Debug("Server rejected compressed request, resending it uncompressed", "method", string(method))
+		return d.doJSONRPCRequest(parent, cfg, method, params, header)
 	}
-
-	respBytes, err := io.ReadAll(httpResp.Body)
+	body, err := decodeResponseBody(httpResp)
 	if err != nil {
-		return resp, fmt.Errorf("%w: %w", ErrReadResponseFailed, err)
+		logger.Error("Failed to decompress HTTP response", "method", string(method), "error", err)
+		return resp, err
+	}
+	if cfg.maxResponseBytes > 0 {
+		body = &maxBytesReader{r: body, remaining: cfg.maxResponseBytes, method: method, limit: cfg.maxResponseBytes}
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		errBody, readErr := io.ReadAll(body)
+		if errors.Is(readErr, ErrResponseTooLarge) {
+			logger.Error("HTTP response exceeded size limit", "method", string(method), "status", httpResp.StatusCode, "limit", cfg.maxResponseBytes)
+			return resp, readErr
+		}
+		// The server may echo the request, secrets included
+		errBody = []byte(rd.redact(string(errBody)))
+		logger.Error("HTTP request failed", "method", string(method), "status", httpResp.StatusCode, "body", string(errBody))
+		rpcErr := newHTTPStatusError(method, httpResp.StatusCode, errBody)
+		if mErr := newMaintenanceError(method, httpResp, rpcErr); mErr != nil {
+			if cfg.maintenanceHandler != nil {
+				cfg.maintenanceHandler(cfg.name, mErr)
+			}
+			return resp, mErr
+		}
+		return resp, rpcErr
 	}
 
-	var jsonResp jsonRPCResponse
-	if err := json.Unmarshal(respBytes, &jsonResp); err != nil {
-		return resp, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	jsonResp, err := decodeJSONRPCResponse(method, body)
+	if err != nil {
+		logger.Error("Failed to decode JSON-RPC response", "method", string(method), "error", err)
+		return resp, err
 	}
 
 	if jsonResp.Error != nil {
+		jsonResp.Error.Message = rd.redact(jsonResp.Error.Message)
+		if len(jsonResp.Error.Data) > 0 {
+			jsonResp.Error.Data = json.RawMessage(rd.redact(string(jsonResp.Error.Data)))
+		}
 		logger.Error("JSON-RPC error", "method", string(method), "error", jsonResp.Error.Message, "code", jsonResp.Error.Code)
-		return resp, fmt.Errorf("%w: %s", ErrRPCCall, jsonResp.Error.Message)
+		return resp, newJSONRPCError(method, httpResp.StatusCode, jsonResp.Error)
 	}
 
+	jsonResp.header = httpResp.Header
+	jsonResp.date, _ = http.ParseTime(httpResp.Header.Get("Date"))
 	logger.Debug("JSON-RPC request completed successfully", "method", string(method), "id", req.ID)
 	return jsonResp, nil
 }
 
-func (d *jsonRPCHTTPClient) startSandbox(ctx context.Context, cfg *config, image string, memory int, cpus int) error {
+// decodeJSONRPCResponse incrementally decodes a JSON-RPC response from the body as it is delivered,
+// so chunked responses are consumed without buffering them upfront. A body that ends (or breaks)
+// before a complete document arrives yields a [*TruncatedResponseError] carrying the bytes received;
+// an empty or blank body, or one that is not JSON, yields [ErrUnmarshalRespFailed].
+func decodeJSONRPCResponse(method rpcMethod, body io.Reader) (jsonRPCResponse, error) {
+	var (
+		received bytes.Buffer
+		jsonResp jsonRPCResponse
+	)
+	dec := json.NewDecoder(io.TeeReader(body, &received))
+	if err := dec.Decode(&jsonResp); err != nil {
+		// An empty or blank body is no document at all, rather than a cut-off one
+		started := len(bytes.TrimSpace(received.Bytes())) > 0
+		var syntaxErr *json.SyntaxError
+		switch {
+		case errors.Is(err, ErrResponseTooLarge):
+			return jsonResp, err
+		case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF) && started:
+			return jsonResp, &TruncatedResponseError{Method: string(method), Received: received.Bytes()}
+		case errors.As(err, &syntaxErr):
+			return jsonResp, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+		default:
+			if started {
+				// the transport broke off mid-document; keep what we got for diagnosis
+				return jsonResp, fmt.Errorf("%w: %w", &TruncatedResponseError{Method: string(method), Received: received.Bytes()}, err)
+			}
+			if errors.Is(err, io.EOF) {
+				return jsonResp, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+			}
+			return jsonResp, fmt.Errorf("%w: %w", ErrReadResponseFailed, err)
+		}
+	}
+	return jsonResp, nil
+}
+
+func (d *jsonRPCHTTPClient) startSandbox(ctx context.Context, cfg *config, image string, memory int, cpus int, envs []string, ports []string) (string, error) {
 	params := startParams{
 		Namespace: cfg.namespace,
 		Sandbox:   cfg.name,
 		Config: startConfig{
-			Image:  image,
-			Memory: memory,
-			CPUs:   cpus,
+			Image:   image,
+			Memory:  memory,
+			CPUs:    cpus,
+			Volumes: cfg.volumes,
+			Ports:   ports,
+			Envs:    envs,
 		},
 	}
 
 	cfg.logger.Info("Starting sandbox", "name", cfg.name, "namespace", cfg.namespace, "image", image, "memory", memory, "cpus", cpus)
-	_, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxStart, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
-	if err == nil {
-		cfg.logger.Info("Sandbox started successfully", "name", cfg.name)
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxStart, params)
+	if err != nil {
+		return "", err
 	}
-	return err
+	cfg.logger.Info("Sandbox started successfully", "name", cfg.name)
+
+	// The server replies with a human-readable status message; it is informational only.
+	var message string
+	_ = json.Unmarshal(resp.Result, &message)
+	return message, nil
 }
 
 func (d *jsonRPCHTTPClient) stopSandbox(ctx context.Context, cfg *config) error {
@@ -225,23 +374,23 @@ func (d *jsonRPCHTTPClient) stopSandbox(ctx context.Context, cfg *config) error
 	}
 
 	cfg.logger.Info("Stopping sandbox", "name", cfg.name, "namespace", cfg.namespace)
-	_, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxStop, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
+	_, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxStop, params)
 	if err == nil {
 		cfg.logger.Info("Sandbox stopped successfully", "name", cfg.name)
 	}
 	return err
 }
 
-func (d *jsonRPCHTTPClient) runRepl(ctx context.Context, cfg *config, lang progLang, code string) (*executionResult, error) {
+func (d *jsonRPCHTTPClient) runRepl(ctx context.Context, cfg *config, lang Language, code string) (*executionResult, error) {
 	params := replRunParams{
 		Namespace: cfg.namespace,
 		Sandbox:   cfg.name,
-		Language:  lang.String(),
+		Language:  string(lang),
 		Code:      code,
 	}
 
-	cfg.logger.Debug("Executing code in REPL", "sandbox", cfg.name, "language", lang.String())
-	resp, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxReplRun, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
+	cfg.logger.Debug("Executing code in REPL", "sandbox", cfg.name, "language", string(lang))
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxReplRun, params)
 	if err != nil {
 		return nil, err
 	}
@@ -258,13 +407,13 @@ func (d *jsonRPCHTTPClient) runCommand(ctx context.Context, cfg *config, command
 		Timeout:   int(d.Timeout),
 	}
 
-	cfg.logger.Debug("Executing command", "sandbox", cfg.name, "command", command, "args", args)
-	resp, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxCommandRun, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
+	cfg.logger.Debug("Executing command", "sandbox", cfg.name, "command", command, "args", newRedactor(cfg.maskedValues()).redactAll(args))
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxCommandRun, params)
 	if err != nil {
 		return nil, err
 	}
 
-	return &executionResult{output: resp.Result}, nil
+	return &executionResult{output: resp.Result, serverDate: resp.date}, nil
 }
 
 func (d *jsonRPCHTTPClient) getMetrics(ctx context.Context, cfg *config) (*sandboxMetrics, error) {
@@ -274,7 +423,27 @@ func (d *jsonRPCHTTPClient) getMetrics(ctx context.Context, cfg *config) (*sandb
 	}
 
 	cfg.logger.Debug("Getting sandbox metrics", "sandbox", cfg.name)
-	resp, err := d.makeJSONRPCRequest(ctx, cfg.serverUrl, methodSandboxMetricsGet, params, cfg.apiKey, cfg.logger, cfg.reqIDPrd)
+	sandboxes, err := d.requestMetrics(ctx, cfg, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the first sandbox (should be the only one for this specific request)
+	if len(sandboxes) == 0 {
+		return &sandboxMetrics{}, nil
+	}
+
+	return &sandboxes[0], nil
+}
+
+// listSandboxes returns the metrics of every sandbox in namespace, or in all namespaces for "*".
+func (d *jsonRPCHTTPClient) listSandboxes(ctx context.Context, cfg *config, namespace string) ([]sandboxMetrics, error) {
+	cfg.logger.Debug("Listing sandboxes", "namespace", namespace)
+	return d.requestMetrics(ctx, cfg, metricsGetParams{Namespace: namespace})
+}
+
+func (d *jsonRPCHTTPClient) requestMetrics(ctx context.Context, cfg *config, params metricsGetParams) ([]sandboxMetrics, error) {
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodSandboxMetricsGet, params)
 	if err != nil {
 		return nil, err
 	}
@@ -284,13 +453,7 @@ func (d *jsonRPCHTTPClient) getMetrics(ctx context.Context, cfg *config) (*sandb
 		cfg.logger.Error("Failed to unmarshal metrics result", "error", err)
 		return nil, fmt.Errorf("%w: %w", ErrUnmarshalMetricsFailed, err)
 	}
-
-	// Return the first sandbox (should be the only one for this specific request)
-	if len(result.Sandboxes) == 0 {
-		return &sandboxMetrics{}, nil
-	}
-
-	return &result.Sandboxes[0], nil
+	return result.Sandboxes, nil
 }
 
 // --- Error definitions ---
@@ -304,4 +467,60 @@ var (
 	ErrUnmarshalMetricsFailed  = errors.New("failed to unmarshal metrics result")
 	ErrRequestFailed           = errors.New("request failed")
 	ErrRPCCall                 = errors.New("RPC error")
+	ErrTruncatedResponse       = errors.New("truncated response")
+	ErrResponseTooLarge        = errors.New("response too large")
 )
+
+// ResponseTooLargeError is returned when a response body exceeds the limit configured with
+// [WithMaxResponseBytes]. It matches [ErrResponseTooLarge] via errors.Is.
+type ResponseTooLargeError struct {
+	Method string // JSON-RPC method whose response was rejected
+	Limit  int64  // Configured limit in bytes
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("%s: %s exceeded %d bytes", ErrResponseTooLarge, e.Method, e.Limit)
+}
+
+func (e *ResponseTooLargeError) Unwrap() error {
+	return ErrResponseTooLarge
+}
+
+// maxBytesReader fails with a [*ResponseTooLargeError] as soon as more than limit bytes are read,
+// so oversized bodies are never fully buffered.
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+	method    rpcMethod
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.remaining < 0 {
+		return 0, &ResponseTooLargeError{Method: string(m.method), Limit: m.limit}
+	}
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		return n, &ResponseTooLargeError{Method: string(m.method), Limit: m.limit}
+	}
+	return n, err
+}
+
+// TruncatedResponseError is returned when a response body ends before a complete JSON-RPC
+// document could be decoded. It matches [ErrTruncatedResponse] via errors.Is.
+type TruncatedResponseError struct {
+	Method   string // JSON-RPC method whose response was cut short
+	Received []byte // Raw bytes received before the body ended
+}
+
+func (e *TruncatedResponseError) Error() string {
+	return fmt.Sprintf("%s: %s after %d bytes", ErrTruncatedResponse, e.Method, len(e.Received))
+}
+
+func (e *TruncatedResponseError) Unwrap() error {
+	return ErrTruncatedResponse
+}
@@ -0,0 +1,47 @@
+package msb_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+func TestResponseDecodingErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantErr       error
+		wantTruncated bool
+	}{
+		{"empty", "", msb.ErrUnmarshalRespFailed, false},
+		{"whitespace only", " \r\n\t", msb.ErrUnmarshalRespFailed, false},
+		{"not JSON", "<html>bad gateway</html>", msb.ErrUnmarshalRespFailed, false},
+		{"truncated", `{"jsonrpc":"2.0","id":"1","result":{"sandboxes":[`, msb.ErrTruncatedResponse, true},
+		{"truncated after whitespace", "\n" + `{"jsonrpc":"2.0"`, msb.ErrTruncatedResponse, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(srv.Close)
+			client := msb.NewClient(msb.WithServerUrl(srv.URL), msb.WithApiKey("key"))
+
+			_, err := client.ListSandboxes(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var truncated *msb.TruncatedResponseError
+			if errors.As(err, &truncated) != tt.wantTruncated {
+				t.Fatalf("error = %v, want TruncatedResponseError: %t", err, tt.wantTruncated)
+			}
+			if tt.wantTruncated && string(truncated.Received) != tt.body {
+				t.Errorf("Received = %q, want %q", truncated.Received, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package msb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultRPCCompressMinBytes is the smallest request body WithRPCCompression compresses when no
+// threshold is given.
+const defaultRPCCompressMinBytes = 8 << 10
+
+// WithRPCCompression gzip-compresses RPC payloads, which speeds up shipping large code bodies and
+// outputs over slow links to remote servers. Responses are requested with "Accept-Encoding: gzip"
+// and decompressed as they are read; [WithMaxResponseBytes] bounds their decompressed size.
+// Request bodies of at least minBytes, or 8 KiB if minBytes <= 0, are compressed once the server
+// has listed gzip in the Accept-Encoding header of a response, as servers accepting compressed
+// requests do (RFC 7694); until then, and after the server rejects a compressed request with 415
+// Unsupported Media Type, which is resent uncompressed, requests are sent as is. The
+// microsandbox server compresses neither way itself, so this pays off behind a proxy that does.
+//
+// File transfers are compressed separately, with [WithDefaultCompression].
+func WithRPCCompression(minBytes int) Option {
+	if minBytes <= 0 {
+		minBytes = defaultRPCCompressMinBytes
+	}
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.rpcCompression = &rpcCompression{minBytes: minBytes}
+	}
+}
+
+// rpcCompression negotiates the compression of RPC payloads with the server. It is shared by the
+// config snapshots of a sandbox.
+type rpcCompression struct {
+	minBytes int
+	accepted atomic.Bool // the server accepts gzip-compressed requests
+}
+
+// encode returns the body to send for reqBytes, and whether it is compressed.
+func (c *rpcCompression) encode(reqBytes []byte) ([]byte, bool, error) {
+	if !c.accepted.Load() || len(reqBytes) < c.minBytes {
+		return reqBytes, false, nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(reqBytes); err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrCompressionFailed, err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrCompressionFailed, err)
+	}
+	return buf.Bytes(), true, nil
+}
+
+// setHeaders marks the request as accepting compressed responses, and as compressed if it is.
+func (c *rpcCompression) setHeaders(req *http.Request, compressed bool) {
+	req.Header.Set("Accept-Encoding", "gzip")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+}
+
+// observe learns from the response whether the server accepts compressed requests. It reports
+// whether the compressed request was rejected and must be resent as is.
+func (c *rpcCompression) observe(resp *http.Response, compressed bool) (resend bool) {
+	if compressed && resp.StatusCode == http.StatusUnsupportedMediaType {
+		c.accepted.Store(false)
+		return true
+	}
+	if acceptsGzip(resp.Header) {
+		c.accepted.Store(true)
+	}
+	return false
+}
+
+// acceptsGzip reports whether the Accept-Encoding header lists gzip with a non-zero quality.
+func acceptsGzip(h http.Header) bool {
+	for _, value := range h.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(coding, ";")
+			if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+			if weight, err := strconv.ParseFloat(q, 64); !ok || err != nil || weight > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeResponseBody returns a reader of the decompressed response body.
+func decodeResponseBody(resp *http.Response) (io.Reader, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+	r, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCompressionFailed, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,164 @@
+package msb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// RPCError describes a failed RPC, preserving everything the server sent back.
+// It matches [ErrRPCCall] (JSON-RPC level failures) or [ErrRequestFailed] (HTTP level failures)
+// via errors.Is, so existing sentinel checks keep working.
+type RPCError struct {
+	Method     string          // JSON-RPC method that failed
+	StatusCode int             // HTTP status code of the response
+	Code       int             // JSON-RPC or server error code; 0 if the server sent none
+	Message    string          // Human-readable error message
+	Data       json.RawMessage // JSON-RPC error data, if any
+	Raw        json.RawMessage // The raw error payload as received (may not be valid JSON)
+
+	kind error
+}
+
+func (e *RPCError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.kind, e.Method)
+	if e.StatusCode != 0 && e.kind == ErrRequestFailed {
+		msg += fmt.Sprintf(": status %d", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Code != 0 {
+		msg += fmt.Sprintf(" (code %d)", e.Code)
+	}
+	return msg
+}
+
+func (e *RPCError) Unwrap() error {
+	return e.kind
+}
+
+// Error codes reported in [RPCError.Code]: the standard JSON-RPC codes, and the codes the server
+// attaches to errors it raises itself.
+const (
+	CodeParseError              = -32700
+	CodeInvalidRequest          = -32600
+	CodeMethodNotFound          = -32601
+	CodeInvalidParams           = -32602
+	CodeInternalError           = -32603
+	CodeInvalidCredentials      = 1001
+	CodeInvalidToken            = 1004
+	CodeExpiredToken            = 1005
+	CodeTokenRequired           = 1006
+	CodeInvalidInput            = 2001
+	CodeAccessDenied            = 3001
+	CodeInsufficientPermissions = 3002
+	CodeResourceNotFound        = 4001
+	CodeDatabaseError           = 5001
+	CodeInternalServerError     = 5002
+)
+
+// Is classifies the error, so that errors.Is(err, [ErrUnauthenticated]) and the like tell failure
+// causes apart without inspecting codes and messages. Classification relies on the error code and
+// HTTP status where the server sets them, and on the message for failures reported by the sandbox.
+func (e *RPCError) Is(target error) bool {
+	switch target {
+	case ErrUnauthenticated:
+		return e.StatusCode == http.StatusUnauthorized ||
+			slices.Contains([]int{CodeInvalidCredentials, CodeInvalidToken, CodeExpiredToken, CodeTokenRequired}, e.Code)
+	case ErrPermissionDenied:
+		return e.StatusCode == http.StatusForbidden || e.Code == CodeAccessDenied || e.Code == CodeInsufficientPermissions
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.Code == CodeResourceNotFound
+	case ErrInvalidArgument:
+		return e.StatusCode == http.StatusBadRequest ||
+			slices.Contains([]int{CodeInvalidRequest, CodeInvalidParams, CodeInvalidInput}, e.Code)
+	case ErrUnsupportedLanguage:
+		return strings.Contains(e.Message, "Unsupported language")
+	case ErrOutOfMemory:
+		msg := strings.ToLower(e.Message)
+		return strings.Contains(msg, "out of memory") || strings.Contains(msg, "cannot allocate memory")
+	}
+	return false
+}
+
+// GetErrorData decodes the structured data attached to an [*RPCError] found in err's chain.
+// It decodes the JSON-RPC error data if present, or else the raw error payload.
+func GetErrorData[T any](err error) (T, error) {
+	var data T
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return data, ErrNoErrorData
+	}
+	payload := rpcErr.Data
+	if len(payload) == 0 {
+		payload = rpcErr.Raw
+	}
+	if len(payload) == 0 {
+		return data, ErrNoErrorData
+	}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return data, fmt.Errorf("%w: %w", ErrNoErrorData, err)
+	}
+	return data, nil
+}
+
+func newJSONRPCError(method rpcMethod, status int, e *jsonRPCError) *RPCError {
+	raw, _ := json.Marshal(e)
+	return &RPCError{
+		Method:     string(method),
+		StatusCode: status,
+		Code:       e.Code,
+		Message:    e.Message,
+		Data:       e.Data,
+		Raw:        raw,
+		kind:       ErrRPCCall,
+	}
+}
+
+// newHTTPStatusError builds the error for a non-200 response. The server answers either with a
+// JSON-RPC error envelope (errors forwarded from the sandbox) or with its own {"error", "code"}
+// body (errors raised by the server itself); anything else is kept verbatim.
+func newHTTPStatusError(method rpcMethod, status int, body []byte) *RPCError {
+	e := &RPCError{
+		Method:     string(method),
+		StatusCode: status,
+		Message:    string(body),
+		Raw:        body,
+		kind:       ErrRequestFailed,
+	}
+
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+		Code  int             `json:"code"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || len(envelope.Error) == 0 {
+		return e
+	}
+	var rpcErr jsonRPCError
+	if err := json.Unmarshal(envelope.Error, &rpcErr); err == nil && rpcErr.Message != "" {
+		e.Code, e.Message, e.Data = rpcErr.Code, rpcErr.Message, rpcErr.Data
+		return e
+	}
+	var message string
+	if err := json.Unmarshal(envelope.Error, &message); err == nil {
+		e.Code, e.Message = envelope.Code, message
+	}
+	return e
+}
+
+// ErrNoErrorData is returned by [GetErrorData] when no structured error payload is available.
+var ErrNoErrorData = errors.New("no error data")
+
+// Error classes matched by [RPCError]
+var (
+	ErrUnauthenticated     = errors.New("unauthenticated")
+	ErrPermissionDenied    = errors.New("permission denied")
+	ErrNotFound            = errors.New("not found")
+	ErrInvalidArgument     = errors.New("invalid argument")
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	ErrOutOfMemory         = errors.New("out of memory")
+)
@@ -0,0 +1,183 @@
+package msb
+
+import (
+	"io"
+	"maps"
+	"strings"
+	"time"
+)
+
+// RunOption configures a single execution.
+// Options are applied in the order they are provided to Run.
+type RunOption func(*runConfig)
+
+type runConfig struct {
+	language Language
+	timeout  time.Duration
+	stdout   io.Writer
+	stderr   io.Writer
+	stdin    io.Reader
+	env      map[string]string
+	workdir  string
+	onOutput func(OutputLine)
+	onPrompt func(PromptDetected) PromptResponse
+	sampling OutputSampling
+	watchdog time.Duration
+	// unbuffered drops the output streamed to stdout and stderr from the result
+	unbuffered bool
+	// maxOutputBytes overrides the sandbox's cap on output kept in the result; < 0 lifts it
+	maxOutputBytes int64
+	// resourceUsage measures the execution in the guest
+	resourceUsage bool
+	// progress receives the progress reports of the execution; see RunWithProgress
+	progress chan<- Progress
+	// exitError reports commands that did not succeed as an ExitError
+	exitError bool
+
+	journalKey string
+	tags       []string
+}
+
+func newRunConfig(opts []RunOption) runConfig {
+	var rc runConfig
+	for _, opt := range opts {
+		opt(&rc)
+	}
+	return rc
+}
+
+// polled reports whether the execution must run as a guest process polled by the client,
+// rather than through a single blocking RPC.
+func (rc runConfig) polled() bool {
+	return rc.timeout > 0 || rc.onOutput != nil || rc.watchdog > 0 || rc.stdout != nil || rc.stderr != nil || rc.progress != nil || rc.onPrompt != nil
+}
+
+// buffered reports whether output of stream is kept in the execution's result.
+func (rc runConfig) buffered(stream string) bool {
+	if !rc.unbuffered {
+		return true
+	}
+	switch stream {
+	case "stdout":
+		return rc.stdout == nil
+	case "stderr":
+		return rc.stderr == nil
+	}
+	return true
+}
+
+// WithLanguage runs code in the given language instead of the sandbox's default one.
+// The language must have been enabled with [WithLanguages], and its runtime installed in the
+// guest, or the execution fails with [ErrUnsupportedLanguage]; [LanguageBash] snippets run as
+// shell scripts.
+func WithLanguage(language Language) RunOption {
+	return func(rc *runConfig) {
+		rc.language = language
+	}
+}
+
+// WithExecTimeout kills an execution that runs longer than timeout. Unlike a server-side timeout,
+// the output captured before the kill is kept, and the returned execution's TimedOut method
+// reports true. Code runs with a timeout the same way as with [WithOutputHandler].
+func WithExecTimeout(timeout time.Duration) RunOption {
+	return func(rc *runConfig) {
+		rc.timeout = timeout
+	}
+}
+
+// WithStdout writes an execution's standard output to w as it is produced, e.g. to a file, a
+// terminal or a websocket, as well as to the returned result; see [WithUnbufferedOutput] to keep
+// large outputs out of memory. It applies to Code().Run, Command().Run and Command().Start.
+// Code runs with it the same way as with [WithOutputHandler].
+func WithStdout(w io.Writer) RunOption {
+	return func(rc *runConfig) {
+		rc.stdout = w
+	}
+}
+
+// WithStderr writes an execution's standard error to w as it is produced, like [WithStdout].
+func WithStderr(w io.Writer) RunOption {
+	return func(rc *runConfig) {
+		rc.stderr = w
+	}
+}
+
+// WithUnbufferedOutput leaves the output written to [WithStdout] and [WithStderr] writers out
+// of the returned result, whose GetOutput or GetError is then empty, so that outputs of hundreds
+// of megabytes stream through without being held in memory. Output sinks and lifecycle events
+// do not see it either.
+func WithUnbufferedOutput() RunOption {
+	return func(rc *runConfig) {
+		rc.unbuffered = true
+	}
+}
+
+// WithOutputHandler streams an execution's output to fn line by line as it is produced, instead
+// of only returning it once the execution completes. fn is called from the goroutine calling Run.
+// The execution is polled as a background guest process, so code does not run in the sandbox's
+// REPL session: each streamed snippet starts a fresh interpreter and sees no earlier REPL state.
+// [WithOutputSampling] bounds how many lines fn receives.
+func WithOutputHandler(fn func(OutputLine)) RunOption {
+	return func(rc *runConfig) {
+		rc.onOutput = fn
+	}
+}
+
+// WithStdin feeds the data read from r to the command's standard input. The data is read in full
+// and uploaded to the sandbox before the command starts. It applies to Command().Run and
+// Command().Start.
+func WithStdin(r io.Reader) RunOption {
+	return func(rc *runConfig) {
+		rc.stdin = r
+	}
+}
+
+// WithStdinString feeds s to the command's standard input; see [WithStdin].
+func WithStdinString(s string) RunOption {
+	return WithStdin(strings.NewReader(s))
+}
+
+// WithExecEnv sets environment variables for a single execution, overriding the sandbox-level
+// ones. Code run with an environment executes in a fresh interpreter process, the same way as with
+// [WithOutputHandler], since the environment of the sandbox's REPL cannot be changed per call.
+func WithExecEnv(env map[string]string) RunOption {
+	return func(rc *runConfig) {
+		if rc.env == nil {
+			rc.env = map[string]string{}
+		}
+		maps.Copy(rc.env, env)
+	}
+}
+
+// WithWatchdog kills an execution that goes quiet: no output and no CPU activity in the sandbox
+// for idle. It catches silent deadlocks that a generous [WithExecTimeout] would take long to notice,
+// without cutting short executions that are busy computing. The returned execution's
+// GetTerminationReason reports [TerminationStalled]. Code runs under a watchdog the same way as
+// with [WithOutputHandler].
+func WithWatchdog(idle time.Duration) RunOption {
+	return func(rc *runConfig) {
+		rc.watchdog = idle
+	}
+}
+
+// WithTags labels an execution with tags, e.g. the experiment it belongs to. Tags reach exec
+// hooks and lifecycle events, and are kept with the execution's record in a [ResultStore],
+// whose List finds executions by tag:
+//
+//	sandbox := msb.NewPythonSandbox(msb.WithResultStore(store))
+//	exec, err := sandbox.Code().Run(ctx, code, msb.WithTags("eval-run-42"))
+//	records, err := store.List(ctx, msb.ResultQuery{Tag: "eval-run-42"})
+func WithTags(tags ...string) RunOption {
+	return func(rc *runConfig) {
+		rc.tags = append(rc.tags, tags...)
+	}
+}
+
+// WithJournalKey records the execution in the sandbox's journal under key, so that after a
+// crash it is replayed or re-attached rather than run again; see [Journal]. Keys must be unique
+// within a journal. Without [WithJournal] the key is ignored.
+func WithJournalKey(key string) RunOption {
+	return func(rc *runConfig) {
+		rc.journalKey = key
+	}
+}
@@ -0,0 +1,557 @@
+package msb
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SandboxfileName is the name of a project's sandbox definition file, as created by the msb CLI.
+const SandboxfileName = "Sandboxfile"
+
+// ProjectConfig is a project's declarative sandbox definitions, as read from its Sandboxfile by
+// [LoadConfig], so the CLI and Go services share one source of truth.
+type ProjectConfig struct {
+	Path      string // File the definitions were read from
+	Sandboxes map[string]SandboxDefinition
+}
+
+// SandboxDefinition is a sandbox of a [ProjectConfig]. Volumes, ports and environment variables
+// keep the Sandboxfile's notation.
+type SandboxDefinition struct {
+	Image     string
+	MemoryMB  int               // 0 if not set
+	CPUs      int               // 0 if not set
+	Volumes   []string          // "host:guest", or a single path mounted at the same place
+	Ports     []string          // "host:guest", or a single port published as is
+	Envs      []string          // "NAME=value"
+	Workdir   string            // Working directory of code and commands
+	Shell     string            // Not used by the SDK
+	Scripts   map[string]string // Not used by the SDK; run them with Command().Run
+	Command   []string          // Not used by the SDK
+	DependsOn []string          // Not used by the SDK; start those sandboxes first
+	Scope     string            // Network scope; not used by the SDK
+}
+
+// LoadConfig reads the sandbox definitions of a Sandboxfile. path may also be the project's
+// directory, holding the Sandboxfile. Only the YAML the CLI reads for sandboxes is supported:
+// block and flow mappings and sequences, and plain, quoted and block scalars; anchors, aliases
+// and tags are not. Sections other than sandboxes, such as modules and builds, are ignored.
+func LoadConfig(path string) (*ProjectConfig, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, SandboxfileName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToLoadConfig, err)
+	}
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, path, err)
+	}
+	cfg := &ProjectConfig{Path: path, Sandboxes: map[string]SandboxDefinition{}}
+	root, ok := doc.(map[string]any)
+	if !ok && doc != nil {
+		return nil, fmt.Errorf("%w: %s: not a mapping", ErrInvalidConfig, path)
+	}
+	sandboxes, ok := root["sandboxes"].(map[string]any)
+	if !ok && root["sandboxes"] != nil {
+		return nil, fmt.Errorf("%w: %s: sandboxes: not a mapping", ErrInvalidConfig, path)
+	}
+	for name, v := range sandboxes {
+		def, err := sandboxDefinitionFrom(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: sandbox %q: %w", ErrInvalidConfig, path, name, err)
+		}
+		cfg.Sandboxes[name] = def
+	}
+	return cfg, nil
+}
+
+// sandboxDefinitionFrom decodes a sandbox of a parsed Sandboxfile.
+func sandboxDefinitionFrom(v any) (SandboxDefinition, error) {
+	fields, ok := v.(map[string]any)
+	if !ok {
+		return SandboxDefinition{}, errors.New("not a mapping")
+	}
+	var (
+		def  SandboxDefinition
+		errs []error
+	)
+	str := func(key string) string {
+		s, ok := fields[key].(string)
+		if !ok && fields[key] != nil {
+			errs = append(errs, fmt.Errorf("%s: not a scalar", key))
+		}
+		return s
+	}
+	num := func(key string) int {
+		s := str(key)
+		if s == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid number %q", key, s))
+		}
+		return n
+	}
+	list := func(key string) []string {
+		items, ok := fields[key].([]any)
+		if !ok && fields[key] != nil {
+			errs = append(errs, fmt.Errorf("%s: not a sequence", key))
+		}
+		var strs []string
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: item not a scalar", key))
+			}
+			strs = append(strs, s)
+		}
+		return strs
+	}
+
+	def.Image = str("image")
+	def.MemoryMB = num("memory")
+	def.CPUs = num("cpus")
+	def.Volumes = list("volumes")
+	def.Ports = list("ports")
+	def.Envs = list("envs")
+	def.Workdir = str("workdir")
+	def.Shell = str("shell")
+	def.Command = list("command")
+	def.DependsOn = list("depends_on")
+	def.Scope = str("scope")
+	if scripts, ok := fields["scripts"].(map[string]any); ok {
+		def.Scripts = map[string]string{}
+		for name, script := range scripts {
+			s, ok := script.(string)
+			if !ok {
+				errs = append(errs, fmt.Errorf("scripts: %s: not a scalar", name))
+			}
+			def.Scripts[name] = s
+		}
+	} else if fields["scripts"] != nil {
+		errs = append(errs, errors.New("scripts: not a mapping"))
+	}
+	if def.Image == "" {
+		errs = append(errs, errors.New("image: missing"))
+	}
+	return def, errors.Join(errs...)
+}
+
+// Options returns the options creating the sandbox name as defined: its name, image, memory,
+// CPUs, environment variables, volumes, published ports and working directory. Relative volume
+// paths resolve against the Sandboxfile's directory, as the CLI does, so they are only found if
+// the server runs on this machine. It fails with [ErrSandboxNotDefined] if there is no such
+// sandbox.
+func (c *ProjectConfig) Options(name string) ([]Option, error) {
+	def, ok := c.Sandboxes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrSandboxNotDefined, name)
+	}
+	opts := []Option{WithName(name), WithImage(def.Image), WithMemoryMB(def.MemoryMB), WithCPUs(def.CPUs)}
+	if def.Workdir != "" {
+		opts = append(opts, WithWorkdir(def.Workdir))
+	}
+	if len(def.Envs) > 0 {
+		env := map[string]string{}
+		for _, pair := range def.Envs {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("%w: sandbox %q: invalid env %q", ErrInvalidConfig, name, pair)
+			}
+			env[key] = value
+		}
+		opts = append(opts, WithEnvVars(env))
+	}
+	for _, volume := range def.Volumes {
+		host, guest, ok := strings.Cut(volume, ":")
+		if !ok {
+			guest = host
+		}
+		if !filepath.IsAbs(host) && c.Path != "" {
+			host = filepath.Join(filepath.Dir(c.Path), host)
+		}
+		opts = append(opts, WithVolume(host, guest, false))
+	}
+	for _, port := range def.Ports {
+		hostStr, guestStr, ok := strings.Cut(port, ":")
+		if !ok {
+			guestStr = hostStr
+		}
+		host, hostErr := strconv.Atoi(hostStr)
+		guest, guestErr := strconv.Atoi(guestStr)
+		if hostErr != nil || guestErr != nil {
+			return nil, fmt.Errorf("%w: sandbox %q: invalid port %q", ErrInvalidConfig, name, port)
+		}
+		opts = append(opts, WithPublishedPort(guest, host))
+	}
+	return opts, nil
+}
+
+// NewSandboxFromConfig creates the sandbox name as defined in cfg; see [ProjectConfig.Options].
+// opts apply on top of the definition, e.g. for the server's URL. Its language is the one whose
+// image the definition uses, or [LanguageBash] for other images, whose code then runs as shell
+// scripts; pass the options to [NewSandboxWithLanguage] to choose another one.
+//
+//	cfg, err := msb.LoadConfig(".")
+//	sandbox, err := msb.NewSandboxFromConfig(cfg, "api", msb.WithNamespace("ci"))
+//	err = sandbox.Start(ctx, "", 0, 0) // Image, memory and CPUs come from the Sandboxfile
+func NewSandboxFromConfig(cfg *ProjectConfig, name string, opts ...Option) (LangSandBox, error) {
+	options, err := cfg.Options(name)
+	if err != nil {
+		return nil, err
+	}
+	return newLangSandbox(imageLanguage(cfg.Sandboxes[name].Image), append(options, opts...)...), nil
+}
+
+// imageLanguage returns the registered language whose image is image, whatever its tag or
+// digest, or LanguageBash.
+func imageLanguage(image string) Language {
+	repo, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	languageRegistry.RLock()
+	defer languageRegistry.RUnlock()
+	for _, key := range slices.Sorted(maps.Keys(languageRegistry.byName)) {
+		if rt := languageRegistry.byName[key]; rt.Image != "" && rt.Image == repo {
+			return rt.Name
+		}
+	}
+	return LanguageBash
+}
+
+// yamlLine is a significant line of a YAML document.
+type yamlLine struct {
+	num    int // 1-based line number, for errors
+	indent int
+	text   string // Without indentation and comment
+	raw    string // Without indentation, for block scalars
+}
+
+// parseYAML parses the subset of YAML described in LoadConfig into nested map[string]any,
+// []any and string values.
+func parseYAML(doc string) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot indent YAML", i+1)
+		}
+		text := strings.TrimSpace(stripYAMLComment(trimmed))
+		if text == "---" || text == "..." {
+			continue
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(raw) - len(trimmed), text: text, raw: trimmed})
+	}
+	p := &yamlParser{lines: lines}
+	p.skipBlank()
+	if p.i == len(p.lines) {
+		return nil, nil
+	}
+	v, err := p.node(p.lines[p.i].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.skipBlank(); p.i < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.i].num)
+	}
+	return v, nil
+}
+
+// stripYAMLComment removes a comment from a line: a # at its start or after a space, outside
+// quotes.
+func stripYAMLComment(s string) string {
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			if i == 0 || strings.ContainsRune(" [{,:", rune(s[i-1])) {
+				quote = r
+			}
+		case r == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.i < len(p.lines) && p.lines[p.i].text == "" {
+		p.i++
+	}
+}
+
+// node parses the block node whose lines start at indent.
+func (p *yamlParser) node(indent int) (any, error) {
+	p.skipBlank()
+	line := p.lines[p.i]
+	if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+		return p.sequence(indent)
+	}
+	if _, _, ok := cutYAMLKey(line.text); ok {
+		return p.mapping(indent)
+	}
+	p.i++
+	return yamlScalar(line.text, line.num)
+}
+
+func (p *yamlParser) sequence(indent int) (any, error) {
+	items := []any{}
+	for p.skipBlank(); p.i < len(p.lines) && p.lines[p.i].indent == indent; p.skipBlank() {
+		line := p.lines[p.i]
+		if line.text != "-" && !strings.HasPrefix(line.text, "- ") {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			p.i++
+			item, err := p.nested(indent, line.num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		// The item's content continues the sequence entry as a node of its own, indented past
+		// the dash, e.g. the first key of a mapping
+		offset := len(line.text) - len(rest)
+		p.lines[p.i] = yamlLine{num: line.num, indent: indent + offset, text: rest, raw: line.raw[offset:]}
+		item, err := p.node(indent + offset)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.skipBlank(); p.i < len(p.lines) && p.lines[p.i].indent == indent; p.skipBlank() {
+		line := p.lines[p.i]
+		key, value, ok := cutYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a key", line.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.i++
+		var (
+			v   any
+			err error
+		)
+		switch {
+		case value == "":
+			// A sequence may be indented as much as its key
+			if p.skipBlank(); p.i < len(p.lines) && p.lines[p.i].indent == indent && strings.HasPrefix(p.lines[p.i].text, "-") {
+				v, err = p.sequence(indent)
+			} else {
+				v, err = p.nested(indent, line.num)
+			}
+		case value[0] == '|' || value[0] == '>':
+			v = p.blockScalar(indent, value)
+		default:
+			v, err = yamlScalar(value, line.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// nested parses the node indented past parent, or returns nil if there is none.
+func (p *yamlParser) nested(parent, num int) (any, error) {
+	if p.skipBlank(); p.i == len(p.lines) || p.lines[p.i].indent <= parent {
+		return nil, nil
+	}
+	return p.node(p.lines[p.i].indent)
+}
+
+// blockScalar reads the literal (|) or folded (>) scalar indented past parent, with header.
+func (p *yamlParser) blockScalar(parent int, header string) string {
+	var (
+		lines  []string
+		indent = -1
+	)
+	for ; p.i < len(p.lines); p.i++ {
+		line := p.lines[p.i]
+		if line.raw == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if line.indent <= parent {
+			break
+		}
+		if indent < 0 {
+			indent = line.indent
+		}
+		lines = append(lines, strings.Repeat(" ", max(line.indent-indent, 0))+line.raw)
+	}
+	// Trailing blank lines belong to what follows
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		p.i--
+	}
+	sep := "\n"
+	if header[0] == '>' {
+		sep = " "
+	}
+	s := strings.Join(lines, sep)
+	switch {
+	case strings.Contains(header, "-"):
+	case strings.Contains(header, "+") || s != "":
+		s += "\n"
+	}
+	return s
+}
+
+// cutYAMLKey splits a "key: value" line. Keys may be quoted; a colon only ends a key when
+// followed by a space or the end of the line.
+func cutYAMLKey(text string) (key, value string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 || !strings.HasPrefix(text[end+2:], ":") {
+			return "", "", false
+		}
+		rest := text[end+3:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		k, err := yamlScalar(text[:end+2], 0)
+		if err != nil {
+			return "", "", false
+		}
+		return k.(string), strings.TrimSpace(rest), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// yamlScalar parses an inline value: a quoted or plain scalar, or a flow sequence or mapping.
+func yamlScalar(text string, num int) (any, error) {
+	switch {
+	case text == "~" || text == "null":
+		return nil, nil
+	case text[0] == '"':
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid double-quoted string %s", num, text)
+		}
+		return s, nil
+	case text[0] == '\'':
+		if len(text) < 2 || text[len(text)-1] != '\'' {
+			return nil, fmt.Errorf("line %d: invalid single-quoted string %s", num, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case text[0] == '[' || text[0] == '{':
+		closing := map[byte]byte{'[': ']', '{': '}'}[text[0]]
+		if text[len(text)-1] != closing {
+			return nil, fmt.Errorf("line %d: flow collections must end on their line", num)
+		}
+		items, err := splitYAMLFlow(text[1:len(text)-1], num)
+		if err != nil {
+			return nil, err
+		}
+		if text[0] == '[' {
+			seq := []any{}
+			for _, item := range items {
+				v, err := yamlScalar(item, num)
+				if err != nil {
+					return nil, err
+				}
+				seq = append(seq, v)
+			}
+			return seq, nil
+		}
+		m := map[string]any{}
+		for _, item := range items {
+			key, value, ok := cutYAMLKey(item)
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected a key in %s", num, text)
+			}
+			var v any
+			if value != "" {
+				if v, err = yamlScalar(value, num); err != nil {
+					return nil, err
+				}
+			}
+			m[key] = v
+		}
+		return m, nil
+	default:
+		return text, nil
+	}
+}
+
+// splitYAMLFlow splits the items of a flow collection at the commas outside quotes and nested
+// collections.
+func splitYAMLFlow(s string, num int) ([]string, error) {
+	var (
+		items []string
+		depth int
+		quote byte
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return nil, fmt.Errorf("line %d: unbalanced flow collection", num)
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return slices.DeleteFunc(items, func(item string) bool { return item == "" }), nil
+}
+
+// Sandboxfile-related errors
+var (
+	ErrFailedToLoadConfig = errors.New("failed to load sandbox config")
+	ErrInvalidConfig      = errors.New("invalid sandbox config")
+	ErrSandboxNotDefined  = errors.New("sandbox not defined in config")
+)
@@ -0,0 +1,260 @@
+package msb_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+// The fixtures are those of microsandbox-core/lib/config/microsandbox/config.rs, indented as
+// they are in the Rust sources, plus flow collections and block scalars.
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want map[string]msb.SandboxDefinition
+	}{
+		{
+			name: "empty",
+			doc: `
+            # Empty config with no fields
+        `,
+			want: map[string]msb.SandboxDefinition{},
+		},
+		{
+			name: "empty sections",
+			doc: `
+            meta: {}
+            modules: {}
+            builds: {}
+            sandboxes: {}
+        `,
+			want: map[string]msb.SandboxDefinition{},
+		},
+		{
+			name: "minimal sandbox",
+			doc: `
+            sandboxes:
+              test:
+                image: "alpine:latest"
+        `,
+			want: map[string]msb.SandboxDefinition{"test": {Image: "alpine:latest"}},
+		},
+		{
+			name: "basic",
+			doc: `
+            meta:
+              authors:
+                - "John Doe <john@example.com>"
+              description: "Test configuration"
+              homepage: "https://example.com"
+              repository: "https://github.com/example/test"
+              readme: "./README.md"
+              tags:
+                - "test"
+                - "example"
+              icon: "./icon.png"
+
+            sandboxes:
+              test_sandbox:
+                version: "1.0.0"
+                image: "alpine:latest"
+                memory: 1024
+                cpus: 2
+                volumes:
+                  - "./src:/app/src"
+                ports:
+                  - "8080:80"
+                envs:
+                  - "DEBUG=true"
+                workdir: "/app"
+                shell: "/bin/sh"
+                scripts:
+                  start: "echo 'Hello, World!'"
+        `,
+			want: map[string]msb.SandboxDefinition{"test_sandbox": {
+				Image:    "alpine:latest",
+				MemoryMB: 1024,
+				CPUs:     2,
+				Volumes:  []string{"./src:/app/src"},
+				Ports:    []string{"8080:80"},
+				Envs:     []string{"DEBUG=true"},
+				Workdir:  "/app",
+				Shell:    "/bin/sh",
+				Scripts:  map[string]string{"start": "echo 'Hello, World!'"},
+			}},
+		},
+		{
+			name: "full",
+			doc: `
+            meta:
+              description: "Full test configuration"
+
+            modules:
+              "./database.yaml":
+                database: {}
+              "./redis.yaml":
+                redis:
+                  as: "cache"
+
+            builds:
+              base_build:
+                image: "python:3.11-slim"
+                memory: 2048
+                cpus: 2
+                volumes:
+                  - "./requirements.txt:/build/requirements.txt"
+                envs:
+                  - "PYTHON_VERSION=3.11"
+                workdir: "/build"
+                shell: "/bin/bash"
+                steps:
+                  - "pip install -r requirements.txt"
+                imports:
+                  requirements: "./requirements.txt"
+                exports:
+                  packages: "/build/dist/packages"
+
+            sandboxes:
+              api:
+                version: "1.0.0"
+                image: "python:3.11-slim"
+                memory: 1024
+                cpus: 1
+                volumes:
+                  - "./api:/app/src"
+                ports:
+                  - "8000:8000"
+                envs:
+                  - "DEBUG=false"
+                depends_on:
+                  - "database"
+                  - "cache"
+                workdir: "/app"
+                shell: "/bin/bash"
+                scripts:
+                  start: "python -m uvicorn src.main:app"
+                scope: "public"
+        `,
+			want: map[string]msb.SandboxDefinition{"api": {
+				Image:     "python:3.11-slim",
+				MemoryMB:  1024,
+				CPUs:      1,
+				Volumes:   []string{"./api:/app/src"},
+				Ports:     []string{"8000:8000"},
+				Envs:      []string{"DEBUG=false"},
+				Workdir:   "/app",
+				Shell:     "/bin/bash",
+				Scripts:   map[string]string{"start": "python -m uvicorn src.main:app"},
+				DependsOn: []string{"database", "cache"},
+				Scope:     "public",
+			}},
+		},
+		{
+			name: "flow collections",
+			doc: `
+sandboxes:
+  web: {image: 'nginx:1.27', ports: ["8080:80", 8443:443], envs: [A=1, "B=two, three"]}
+  worker:
+    image: python
+    depends_on: ["web"]
+    command: [python, -m, "worker"]
+    volumes: []
+`,
+			want: map[string]msb.SandboxDefinition{
+				"web": {
+					Image: "nginx:1.27",
+					Ports: []string{"8080:80", "8443:443"},
+					Envs:  []string{"A=1", "B=two, three"},
+				},
+				"worker": {
+					Image:     "python",
+					DependsOn: []string{"web"},
+					Command:   []string{"python", "-m", "worker"},
+				},
+			},
+		},
+		{
+			name: "block scalars",
+			doc: `
+  sandboxes:
+    app:
+      image: alpine   # the smallest
+      scripts:
+        setup: |
+          apk add curl
+            --no-cache
+
+          echo done
+        banner: >-
+          Hello
+          world
+        keep: |+
+          kept
+
+      envs:
+      - "GREETING=hi # not a comment"
+`,
+			want: map[string]msb.SandboxDefinition{"app": {
+				Image: "alpine",
+				Envs:  []string{"GREETING=hi # not a comment"},
+				Scripts: map[string]string{
+					"setup":  "apk add curl\n  --no-cache\n\necho done\n",
+					"banner": "Hello world",
+					"keep":   "kept\n",
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := msb.LoadConfig(writeSandboxfile(t, tt.doc))
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if !reflect.DeepEqual(cfg.Sandboxes, tt.want) {
+				t.Errorf("sandboxes = %+v, want %+v", cfg.Sandboxes, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"tab indentation", "sandboxes:\n\tapp:\n\t\timage: alpine\n"},
+		{"duplicate key", "sandboxes:\n  app:\n    image: alpine\n    image: debian\n"},
+		{"unterminated flow", "sandboxes:\n  app: {image: alpine,\n    memory: 512}\n"},
+		{"dedent below the root", "    sandboxes:\n      app:\n        image: alpine\n  extra: 1\n"},
+		{"not a mapping", "- alpine\n"},
+		{"sandbox not a mapping", "sandboxes:\n  app: alpine\n"},
+		{"missing image", "sandboxes:\n  app:\n    memory: 512\n"},
+		{"invalid number", "sandboxes:\n  app:\n    image: alpine\n    memory: lots\n"},
+		{"scalar for a sequence", "sandboxes:\n  app:\n    image: alpine\n    ports: 8080\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := msb.LoadConfig(writeSandboxfile(t, tt.doc))
+			if !errors.Is(err, msb.ErrInvalidConfig) {
+				t.Errorf("LoadConfig error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+// writeSandboxfile writes doc as the Sandboxfile of a temporary project and returns the
+// project's directory.
+func writeSandboxfile(t *testing.T, doc string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, msb.SandboxfileName), []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
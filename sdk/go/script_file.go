@@ -0,0 +1,90 @@
+package msb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (cr codeRunner) RunScriptFile(ctx context.Context, path string, opts ...RunOption) (CodeExecution, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return CodeExecution{}, fmt.Errorf("%w: %w", ErrFailedToReadScript, err)
+	}
+	lang, err := detectScriptLanguage(path, source)
+	if err != nil {
+		return CodeExecution{}, err
+	}
+	// Explicit options come last, so that WithLanguage overrides the detected language
+	opts = append([]RunOption{WithLanguage(lang)}, opts...)
+	return cr.Run(ctx, stripShebang(string(source)), opts...)
+}
+
+// detectScriptLanguage infers the language of a script from its extension, or else its shebang.
+func detectScriptLanguage(path string, source []byte) (Language, error) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != "" {
+		languageRegistry.RLock()
+		rt, ok := languageRegistry.byExtension[ext]
+		languageRegistry.RUnlock()
+		if ok {
+			return rt.Name, nil
+		}
+	}
+	if interpreter := shebangInterpreter(source); interpreter != "" {
+		// Versioned interpreters such as python3.12 are looked up without their version
+		for _, name := range []string{interpreter, strings.TrimRight(interpreter, "0123456789.")} {
+			if rt, err := lookupLanguage(name); err == nil {
+				return rt.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownScriptLanguage, path)
+}
+
+// shebangInterpreter returns the name of the interpreter in the script's #! line, looking through
+// env, or "" if it has none.
+func shebangInterpreter(source []byte) string {
+	line, _, _ := bytes.Cut(source, []byte("\n"))
+	rest, ok := bytes.CutPrefix(line, []byte("#!"))
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(string(rest))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, f := range fields[1:] {
+			// Skip env's options and variable assignments, e.g. `env -S` or `env PYTHONUTF8=1`
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interpreter = filepath.Base(f)
+				break
+			}
+		}
+	}
+	return interpreter
+}
+
+// stripShebang blanks out the #! line, which not every REPL accepts, keeping line numbers intact.
+func stripShebang(source string) string {
+	if !strings.HasPrefix(source, "#!") {
+		return source
+	}
+	_, rest, found := strings.Cut(source, "\n")
+	if !found {
+		return ""
+	}
+	return "\n" + rest
+}
+
+// Script-related errors
+var (
+	ErrFailedToReadScript    = errors.New("failed to read script file")
+	ErrUnknownScriptLanguage = errors.New("cannot infer the language of script file")
+)
@@ -0,0 +1,163 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MinServerVersion is the oldest server version the SDK supports.
+const MinServerVersion = "0.2.0"
+
+// ServerInfoReporter reports which server a sandbox talks to.
+type ServerInfoReporter interface {
+	// ServerInfo asks the server for its name, version and features the first time it is
+	// called, and returns the cached result afterwards, until the sandbox stops. It does not
+	// need the sandbox to be started.
+	ServerInfo(ctx context.Context) (ServerInfo, error)
+}
+
+// ServerInfo describes a microsandbox server, as reported by its MCP endpoint.
+type ServerInfo struct {
+	Name    string // e.g. "microsandbox-server"
+	Version string // e.g. "0.2.6"
+	// ProtocolVersion is the version of the Model Context Protocol the server speaks.
+	ProtocolVersion string
+	// Features lists the capabilities the server advertises, e.g. "toolCalling", sorted.
+	Features []string
+}
+
+// AtLeast reports whether the server's version is version or newer. Versions are compared
+// numerically, component by component; pre-release and build suffixes are ignored.
+func (si ServerInfo) AtLeast(version string) bool {
+	return compareVersions(si.Version, version) >= 0
+}
+
+// HasFeature reports whether the server advertises feature.
+func (si ServerInfo) HasFeature(feature string) bool {
+	return slices.Contains(si.Features, feature)
+}
+
+// WithServerVersionCheck makes Start ask the server for its version before starting the
+// sandbox, and fail with [ErrIncompatibleServer] if it is older than [MinServerVersion] or too
+// old to report a version. It costs one extra request on the first Start.
+func WithServerVersionCheck() Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.serverVersionCheck = true
+	}
+}
+
+type serverInfoReporter struct {
+	b *baseMicroSandbox
+}
+
+func (r serverInfoReporter) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	if info := r.b.serverInfo.Load(); info != nil {
+		return *info, nil
+	}
+	info, err := r.b.rpcClient.serverInfo(ctx, r.b.config())
+	if err != nil {
+		return ServerInfo{}, fmt.Errorf("%w: %w", ErrFailedToGetServerInfo, err)
+	}
+	r.b.logger().Debug("Detected server", "sandbox", r.b.name(), "server", info.Name, "version", info.Version)
+	r.b.serverInfo.Store(info)
+	return *info, nil
+}
+
+// checkServerVersion fails with ErrIncompatibleServer if the server is older than
+// MinServerVersion.
+func (b *baseMicroSandbox) checkServerVersion(ctx context.Context) error {
+	info, err := serverInfoReporter{b}.ServerInfo(ctx)
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && rpcErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: the server does not report its version; this SDK requires %s or newer", ErrIncompatibleServer, MinServerVersion)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.AtLeast(MinServerVersion) {
+		return fmt.Errorf("%w: server version %s, this SDK requires %s or newer", ErrIncompatibleServer, info.Version, MinServerVersion)
+	}
+	return nil
+}
+
+type initializeResult struct {
+	ProtocolVersion string                     `json:"protocolVersion"`
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	ServerInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"serverInfo"`
+}
+
+func (d *jsonRPCHTTPClient) serverInfo(ctx context.Context, cfg *config) (*ServerInfo, error) {
+	params := map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "microsandbox-go-sdk", "version": "1.0.0"},
+	}
+	resp, err := d.makeJSONRPCRequest(ctx, cfg, methodInitialize, params)
+	if err != nil {
+		return nil, err
+	}
+	var result initializeResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnmarshalRespFailed, err)
+	}
+	return &ServerInfo{
+		Name:            result.ServerInfo.Name,
+		Version:         result.ServerInfo.Version,
+		ProtocolVersion: result.ProtocolVersion,
+		Features:        slices.Sorted(maps.Keys(result.Capabilities)),
+	}, nil
+}
+
+// mcpProtocolVersion is the Model Context Protocol version sent in the handshake.
+const mcpProtocolVersion = "2024-11-05"
+
+// compareVersions compares dotted numeric versions, such as "0.2.6" or "v1.0.0-rc1", returning
+// -1, 0 or 1. Missing components count as 0; unparseable ones as 0 too.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range max(len(pa), len(pb)) {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, s := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(s)
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// Server info-related errors
+var (
+	ErrFailedToGetServerInfo = errors.New("failed to get server info")
+	ErrIncompatibleServer    = errors.New("incompatible server version")
+)
@@ -0,0 +1,257 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ServiceManager runs supervised long-lived processes (dev servers, workers) inside the sandbox.
+type ServiceManager interface {
+	// Start launches the service described by spec and supervises it in the background
+	// until the returned Service is stopped or the sandbox goes away.
+	Start(ctx context.Context, spec ServiceSpec) (*Service, error)
+}
+
+// RestartPolicy decides whether an exited service is launched again.
+type RestartPolicy int
+
+const (
+	RestartNever     RestartPolicy = iota // Leave the service stopped once it exits
+	RestartOnFailure                      // Restart only after a non-zero exit
+	RestartAlways                         // Restart after any exit
+)
+
+// ServiceSpec declares a supervised service.
+type ServiceSpec struct {
+	// Command is the shell command line that runs the service in the foreground.
+	Command string
+	// Restart is the restart policy applied when the service exits. Defaults to RestartNever.
+	Restart RestartPolicy
+	// MaxRestarts bounds the number of restarts; 0 means unlimited.
+	MaxRestarts int
+	// ReadinessCommand is a shell command that exits 0 once the service is ready
+	// (e.g. "curl -sf localhost:8000/health"). If empty, the service is ready once launched.
+	ReadinessCommand string
+	// CheckInterval is how often the service is polled for liveness, readiness and output.
+	// Defaults to one second.
+	CheckInterval time.Duration
+	// Stdout and Stderr, if set, receive the service's output as it is produced.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Service is a handle to a supervised service. Its methods are safe for concurrent use.
+type Service struct {
+	b    *baseMicroSandbox
+	spec ServiceSpec
+
+	cancel  context.CancelFunc
+	done    chan struct{}
+	readyCh chan struct{}
+
+	mu        sync.Mutex
+	proc      *guestProcess
+	restarts  int
+	err       error
+	readyOnce sync.Once
+}
+
+type serviceManager struct {
+	b *baseMicroSandbox
+}
+
+func (sm serviceManager) Start(ctx context.Context, spec ServiceSpec) (*Service, error) {
+	if spec.Command == "" {
+		return nil, ErrServiceCommandRequired
+	}
+	if spec.CheckInterval <= 0 {
+		spec.CheckInterval = time.Second
+	}
+	proc, err := sm.b.spawn(ctx, spec.Command)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToStartService, err)
+	}
+	sm.b.logger().Info("Service started", "sandbox", sm.b.name(), "command", spec.Command, "pid", proc.pid)
+
+	superCtx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		b:       sm.b,
+		spec:    spec,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		readyCh: make(chan struct{}),
+		proc:    proc,
+	}
+	if spec.ReadinessCommand == "" {
+		s.markReady()
+	}
+	go s.supervise(superCtx)
+	return s, nil
+}
+
+// Ready reports whether the readiness check has passed at least once.
+func (s *Service) Ready() bool {
+	select {
+	case <-s.readyCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitReady blocks until the service is ready, the service terminates, or ctx is done.
+func (s *Service) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.readyCh:
+		return nil
+	case <-s.done:
+		if err := s.Err(); err != nil {
+			return err
+		}
+		return ErrServiceExited
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Restarts returns how many times the service has been restarted.
+func (s *Service) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// Done is closed once the service is no longer supervised, either because it was stopped
+// or because it exited and the restart policy did not allow another run.
+func (s *Service) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the reason supervision ended, if it ended abnormally.
+func (s *Service) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Stop terminates the service and stops supervising it.
+func (s *Service) Stop(ctx context.Context) error {
+	s.cancel()
+	<-s.done
+
+	s.mu.Lock()
+	proc := s.proc
+	s.mu.Unlock()
+
+	if err := proc.signal(ctx, "KILL"); err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToStopService, err)
+	}
+	_ = proc.remove(ctx)
+	s.b.logger().Info("Service stopped", "sandbox", s.b.name(), "command", s.spec.Command)
+	return nil
+}
+
+func (s *Service) markReady() {
+	s.readyOnce.Do(func() { close(s.readyCh) })
+}
+
+func (s *Service) supervise(ctx context.Context) {
+	defer close(s.done)
+
+	var stdoutOff, stderrOff int64
+	ticker := time.NewTicker(s.spec.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		proc := s.proc
+		s.mu.Unlock()
+
+		st, err := proc.poll(ctx, stdoutOff, stderrOff)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.b.logger().Error("Service poll failed", "sandbox", s.b.name(), "error", err)
+			if errors.Is(err, ErrSandboxNotStarted) {
+				s.fail(err)
+				return
+			}
+			continue
+		}
+		stdoutOff += int64(len(st.stdout))
+		stderrOff += int64(len(st.stderr))
+		writeIfSet(s.spec.Stdout, st.stdout)
+		writeIfSet(s.spec.Stderr, st.stderr)
+
+		if st.running {
+			if !s.Ready() && s.checkReadiness(ctx) {
+				s.markReady()
+			}
+			continue
+		}
+
+		// the process exited: apply the restart policy
+		s.mu.Lock()
+		restart := s.spec.Restart == RestartAlways || (s.spec.Restart == RestartOnFailure && st.exitCode != 0)
+		if s.spec.MaxRestarts > 0 && s.restarts >= s.spec.MaxRestarts {
+			restart = false
+		}
+		s.mu.Unlock()
+
+		if !restart {
+			if st.exitCode != 0 {
+				s.fail(fmt.Errorf("%w: exit code %d", ErrServiceExited, st.exitCode))
+			}
+			return
+		}
+
+		_ = proc.remove(ctx)
+		next, err := s.b.spawn(ctx, s.spec.Command)
+		if err != nil {
+			s.fail(fmt.Errorf("%w: %w", ErrFailedToStartService, err))
+			return
+		}
+		s.b.logger().Info("Service restarted", "sandbox", s.b.name(), "command", s.spec.Command, "exitCode", st.exitCode)
+		s.mu.Lock()
+		s.proc = next
+		s.restarts++
+		s.mu.Unlock()
+		stdoutOff, stderrOff = 0, 0
+	}
+}
+
+func (s *Service) checkReadiness(ctx context.Context) bool {
+	exec, err := s.b.runShell(ctx, s.spec.ReadinessCommand)
+	return err == nil && exec.IsSuccess()
+}
+
+func (s *Service) fail(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func writeIfSet(w io.Writer, p []byte) {
+	if w != nil && len(p) > 0 {
+		_, _ = w.Write(p)
+	}
+}
+
+// Service-related errors
+var (
+	ErrServiceCommandRequired = errors.New("service command must be specified")
+	ErrFailedToStartService   = errors.New("failed to start service")
+	ErrFailedToStopService    = errors.New("failed to stop service")
+	ErrServiceExited          = errors.New("service exited")
+)
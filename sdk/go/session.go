@@ -0,0 +1,164 @@
+package msb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// SessionOpener creates isolated interpreter sessions.
+type SessionOpener interface {
+	// NewSession returns a new session in the sandbox's interpreter. Sessions are cheap: no
+	// state exists in the sandbox until the first RunCode. Sessions are supported for Python
+	// and Node.js.
+	NewSession() (*Session, error)
+}
+
+// Session is an isolated namespace in the sandbox's interpreter: the variables, functions and
+// imports defined by one RunCode are visible to the next RunCode of the same session, and to no
+// other session. Any number of sessions can be used concurrently, though the interpreter runs
+// one execution at a time. [CodeRunner.Run] executes in the interpreter's global namespace,
+// which no session shares.
+type Session struct {
+	b      *baseMicroSandbox
+	rt     *languageRuntime
+	id     string
+	closed atomic.Bool
+}
+
+// Python and Node.js keep each session's namespace in a table in the interpreter; the helper
+// creating them is defined on first use, and again if the interpreter was restarted. The value
+// of a trailing expression is printed, as at the interactive prompt. The templates render to a
+// single line, the Python helper travelling base64-encoded, so that the REPL takes each as a
+// single input.
+const (
+	pythonSessionHelper = `def __msb_session_run(sid, src):
+    import ast
+    ns = __msb_sessions.setdefault(sid, {"__name__": "__main__", "__builtins__": __builtins__})
+    tree = ast.parse(src, "<session>", "exec")
+    last = None
+    if tree.body and isinstance(tree.body[-1], ast.Expr):
+        last = ast.Expression(tree.body.pop().value)
+    exec(compile(tree, "<session>", "exec"), ns)
+    if last is not None:
+        value = eval(compile(last, "<session>", "eval"), ns)
+        if value is not None:
+            print(repr(value))
+__msb_sessions = {}
+`
+	pythonSessionRun   = `exec(__import__("base64").b64decode("{helper}").decode()) if "__msb_session_run" not in globals() else None; __msb_session_run({id}, {code})`
+	pythonSessionReset = `globals().get("__msb_sessions", {}).pop({id}, None) and None`
+
+	nodeSessionRun = `globalThis.__msbSessionRun ??= (() => { ` +
+		`const vm = require("vm"), util = require("util"); ` +
+		`const sessions = globalThis.__msbSessions = new Map(); ` +
+		`return (sid, src) => { ` +
+		`let ctx = sessions.get(sid); ` +
+		`if (!ctx) { ctx = vm.createContext({ console, require, process, Buffer, URL, TextEncoder, TextDecoder, setTimeout, setInterval, setImmediate, clearTimeout, clearInterval, clearImmediate }); sessions.set(sid, ctx); } ` +
+		`const value = vm.runInContext(src, ctx, { filename: "session" }); ` +
+		`if (value !== undefined) console.log(util.inspect(value)); }; ` +
+		`})(); __msbSessionRun({id}, {code})`
+	nodeSessionReset = `globalThis.__msbSessions?.delete({id}), undefined`
+)
+
+type sessionOpener struct {
+	b *baseMicroSandbox
+	l Language
+}
+
+func (so sessionOpener) NewSession() (*Session, error) {
+	rt, err := lookupLanguage(string(so.l))
+	if err != nil {
+		return nil, err
+	}
+	if rt.sessionRun == "" {
+		return nil, fmt.Errorf("%w: %q", ErrSessionsUnsupported, rt.Name)
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{b: so.b, rt: rt, id: id}, nil
+}
+
+// ID returns the session's identifier.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// RunCode runs code in the session's namespace. Canceling ctx interrupts the code where the
+// language supports it, as with [CodeRunner.Run].
+func (s *Session) RunCode(ctx context.Context, code string) (CodeExecution, error) {
+	if s.closed.Load() {
+		return CodeExecution{}, ErrSessionClosed
+	}
+	if s.b.state.Load() != started {
+		return CodeExecution{}, ErrSandboxNotStarted
+	}
+	release, err := s.b.waitExecSlot(ctx)
+	if err != nil {
+		return CodeExecution{}, err
+	}
+	defer release()
+	exec, err := withExecHooks(ctx, s.b, ExecEvent{Language: s.rt.Name, Code: code}, func() (CodeExecution, error) {
+		return codeRunner{s.b, s.rt.Name}.run(ctx, s.rt, s.script(s.rt.sessionRun, code))
+	})
+	exec.spec = s.b.spec.Load()
+	s.b.emitExecutionOutput(ctx, exec.parsed.OutputLines)
+	return exec, err
+}
+
+// Reset discards everything defined in the session, which starts over empty on the next
+// RunCode.
+func (s *Session) Reset(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrSessionClosed
+	}
+	return s.reset(ctx)
+}
+
+// Close discards the session's namespace. The session cannot be used afterwards.
+func (s *Session) Close(ctx context.Context) error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	return s.reset(ctx)
+}
+
+func (s *Session) reset(ctx context.Context) error {
+	if s.b.state.Load() != started {
+		return ErrSandboxNotStarted
+	}
+	exec, err := codeRunner{s.b, s.rt.Name}.run(ctx, s.rt, s.script(s.rt.sessionReset, ""))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToResetSession, err)
+	}
+	if exec.HasError() {
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("%w: %s", ErrFailedToResetSession, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// script renders a session template. Code and ID travel as string literals, so they cannot
+// break out of the template.
+func (s *Session) script(tmpl string, code string) string {
+	id, _ := json.Marshal(s.id)
+	lit, _ := json.Marshal(code)
+	return strings.NewReplacer(
+		"{helper}", base64.StdEncoding.EncodeToString([]byte(pythonSessionHelper)),
+		"{id}", string(id),
+		"{code}", string(lit),
+	).Replace(tmpl)
+}
+
+// Session-related errors
+var (
+	ErrSessionsUnsupported  = errors.New("language does not support sessions")
+	ErrSessionClosed        = errors.New("session closed")
+	ErrFailedToResetSession = errors.New("failed to reset session")
+)
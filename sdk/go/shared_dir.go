@@ -0,0 +1,130 @@
+package msb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WithSharedDir mounts hostDir at guestDir inside the sandbox, like [WithVolume], and lets
+// [FileSystem.WriteFile] and [FileSystem.ReadFile] move files under guestDir through the local
+// filesystem instead of the RPC, when the client runs on the same machine as the server. This
+// makes exchanging large data, such as tensors or dataframes, as fast as writing a local file;
+// with hostDir on a tmpfs such as /dev/shm, the data never touches the disk. hostDir must be
+// absolute.
+//
+// Whether the client and the guest see the same directory is checked once per Start, by
+// writing a file on one side and reading it on the other. If they do not, for example because
+// the server runs elsewhere, transfers fall back to the RPC.
+func WithSharedDir(hostDir, guestDir string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.volumes = append(msb.cfg.volumes, hostDir+":"+guestDir)
+		msb.cfg.sharedDir = sharedDir{host: hostDir, guest: path.Clean(guestDir)}
+	}
+}
+
+// sharedDir is a directory mounted into the guest that the client can reach locally.
+type sharedDir struct {
+	host, guest string
+}
+
+// Shared directory availability, once probed after Start
+const (
+	sharedDirUnknown int32 = iota
+	sharedDirAvailable
+	sharedDirUnavailable
+)
+
+// sharedPath returns the local path of the guest file name, if name lies in the shared directory
+// and the directory is shared with the guest.
+func (b *baseMicroSandbox) sharedPath(ctx context.Context, name string) (string, bool) {
+	dir := b.config().sharedDir
+	if dir.host == "" {
+		return "", false
+	}
+	rel, ok := strings.CutPrefix(path.Clean(name), dir.guest+"/")
+	if !ok || !fs.ValidPath(rel) {
+		return "", false
+	}
+	if b.sharedDirState.Load() == sharedDirUnknown {
+		shared, err := b.probeSharedDir(ctx, dir)
+		if err != nil {
+			// Probe again on the next transfer
+			return "", false
+		}
+		state := sharedDirAvailable
+		if !shared {
+			state = sharedDirUnavailable
+			b.logger().Info("Shared directory not reachable locally, transferring files through the server", "sandbox", b.name(), "dir", dir.host)
+		}
+		b.sharedDirState.CompareAndSwap(sharedDirUnknown, state)
+	}
+	if b.sharedDirState.Load() != sharedDirAvailable {
+		return "", false
+	}
+	return filepath.Join(dir.host, filepath.FromSlash(rel)), true
+}
+
+// probeSharedDir reports whether a file written to the host directory is visible in the guest.
+// It fails if the guest could not be asked.
+func (b *baseMicroSandbox) probeSharedDir(ctx context.Context, dir sharedDir) (bool, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return false, err
+	}
+	marker := hex.EncodeToString(token)
+	name := ".msb-probe-" + marker
+	local := filepath.Join(dir.host, name)
+	if err := os.WriteFile(local, []byte(marker), 0o644); err != nil {
+		// The directory does not exist here, so the server must be elsewhere
+		return false, nil
+	}
+	defer os.Remove(local)
+	exec, err := b.runShell(ctx, `cat `+shellQuote(dir.guest+"/"+name))
+	if err != nil {
+		return false, err
+	}
+	out, _ := exec.GetOutput()
+	return exec.IsSuccess() && strings.TrimSpace(out) == marker, nil
+}
+
+// readSharedFile reads the local path of a shared file, feeding it to h as well if it is not nil.
+func readSharedFile(local string, h hash.Hash) ([]byte, error) {
+	if h == nil {
+		return os.ReadFile(local)
+	}
+	file, err := os.Open(local)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.TeeReader(file, h))
+}
+
+// writeSharedFile writes data to the local path of a shared file, through a temporary file so
+// that readers never see it partially written.
+func writeSharedFile(local string, data []byte, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+		return err
+	}
+	tmp := local + ".msb-part"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm.Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, local); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package msb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShellOpener opens interactive shells inside the sandbox.
+type ShellOpener interface {
+	// OpenShell starts an interactive shell attached to a pseudo-terminal inside the sandbox,
+	// sized 24x80 until resized. The guest needs either util-linux's script or Python to
+	// allocate the terminal.
+	OpenShell(ctx context.Context) (*Shell, error)
+}
+
+// Shell is an interactive shell session on a pseudo-terminal inside the sandbox, e.g. for a
+// terminal UI. Reads return what the terminal displays, including the echo of written input and
+// escape sequences; writes are typed into the terminal. Like [MessageChannel], output is polled,
+// so reads lag behind the terminal by up to PollInterval. Read and Write may be used
+// concurrently with each other, but each is serialized.
+type Shell struct {
+	proc *guestProcess
+
+	// ctx bounds the RPCs of Read, Write and Resize; it is canceled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex
+
+	readMu    sync.Mutex
+	outOffset int64
+	pending   []byte // received output not yet returned by Read
+
+	// PollInterval is how often Read checks for new output. Defaults to 100ms.
+	PollInterval time.Duration
+}
+
+var _ io.ReadWriteCloser = (*Shell)(nil)
+
+// shellCloseTimeout bounds the requests tearing a shell down, since Close takes no context.
+const shellCloseTimeout = 10 * time.Second
+
+// shellScript runs an interactive shell on a pseudo-terminal fed from the job's "in" FIFO. The
+// shell records its terminal's device, so that it can be resized from outside.
+const shellScript = `d=$MSB_SHELL_DIR
+mkfifo "$d/in" || exit 127
+# The writer keeping the FIFO open is orphaned, since script waits for all of its children
+( sleep 2147483647 >"$d/in" & ) && exec <"$d/in"
+export TERM=xterm
+sh='tty >"$MSB_SHELL_DIR/tty"; stty rows 24 cols 80; exec "$(command -v bash || echo sh)" -i'
+if command -v script >/dev/null 2>&1; then
+  exec script -qfc "$sh" /dev/null
+elif py=$(command -v python3 || command -v python); then
+  exec "$py" -c 'import pty, sys; pty.spawn(sys.argv[1:])' sh -c "$sh"
+fi
+echo "no way to allocate a pseudo-terminal: install script or python" >&2
+exit 127`
+
+type shellOpener struct {
+	b *baseMicroSandbox
+	l Language
+}
+
+func (o shellOpener) OpenShell(ctx context.Context) (*Shell, error) {
+	// If detection fails, the shell reports a missing terminal when it exits right away
+	if caps, err := (capabilityReporter{o.b, o.l}).Capabilities(ctx); err == nil && !caps.PTY {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToOpenShell, unavailable(capabilityPTY))
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return nil, err
+	}
+	dir := guestJobsDir + "/" + id
+	proc, err := o.b.spawnWithID(ctx, id, `MSB_SHELL_DIR=`+shellQuote(dir)+`; export MSB_SHELL_DIR
+`+shellScript)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToOpenShell, err)
+	}
+	sessCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	return &Shell{proc: proc, ctx: sessCtx, cancel: cancel, PollInterval: 100 * time.Millisecond}, nil
+}
+
+// Read blocks until the terminal displays something and reads it into p. It returns [io.EOF]
+// once the shell has exited and all of its output has been read.
+func (s *Shell) Read(p []byte) (int, error) {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+
+	for len(s.pending) == 0 {
+		st, err := s.proc.poll(s.ctx, s.outOffset, -1)
+		if err != nil {
+			return 0, s.closedOr(err)
+		}
+		s.outOffset += int64(len(st.stdout))
+		s.pending = st.stdout
+		if len(st.stdout) > 0 {
+			break
+		}
+		if !st.running {
+			if st.exitCode == 127 && s.outOffset == 0 {
+				return 0, s.failure()
+			}
+			return 0, io.EOF
+		}
+		select {
+		case <-s.ctx.Done():
+			return 0, ErrShellClosed
+		case <-time.After(s.PollInterval):
+		}
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Write types p into the terminal.
+func (s *Shell) Write(p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	d := shellQuote(s.proc.dir())
+	script := `[ -f ` + d + `/exit ] && exit 3
+printf '%s' ` + base64.StdEncoding.EncodeToString(p) + ` | base64 -d >` + d + `/in`
+	exec, err := s.proc.b.runShell(s.ctx, script)
+	if err != nil {
+		return 0, s.closedOr(err)
+	}
+	if !exec.IsSuccess() {
+		return 0, ErrShellClosed
+	}
+	return len(p), nil
+}
+
+// Resize sets the terminal's size, which signals the programs running in it to redraw.
+func (s *Shell) Resize(ctx context.Context, rows, cols int) error {
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("%w: invalid size %dx%d", ErrFailedToResizeShell, rows, cols)
+	}
+	exec, err := s.proc.b.runShell(ctx, `stty -F "$(cat `+shellQuote(s.proc.dir()+"/tty")+`)" rows `+strconv.Itoa(rows)+` cols `+strconv.Itoa(cols))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToResizeShell, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToResizeShell, commandStderr(exec))
+	}
+	return nil
+}
+
+// Close terminates the shell and everything running in it, and removes its bookkeeping from
+// the sandbox.
+func (s *Shell) Close() error {
+	s.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), shellCloseTimeout)
+	defer cancel()
+	if err := s.proc.signal(ctx, "KILL"); err != nil {
+		return err
+	}
+	return s.proc.remove(ctx)
+}
+
+// closedOr reports err, or ErrShellClosed if it stems from the shell being closed.
+func (s *Shell) closedOr(err error) error {
+	if s.ctx.Err() != nil {
+		return ErrShellClosed
+	}
+	return err
+}
+
+// failure explains why the shell could not start, from what it wrote to stderr.
+func (s *Shell) failure() error {
+	st, err := s.proc.poll(s.ctx, -1, 0)
+	if err != nil || len(st.stderr) == 0 {
+		return ErrFailedToOpenShell
+	}
+	return fmt.Errorf("%w: %s", ErrFailedToOpenShell, strings.TrimSpace(string(st.stderr)))
+}
+
+// Shell errors
+var (
+	ErrFailedToOpenShell   = errors.New("failed to open shell")
+	ErrFailedToResizeShell = errors.New("failed to resize shell")
+	ErrShellClosed         = errors.New("shell closed")
+)
@@ -0,0 +1,55 @@
+package msb
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SpecRecorder exposes the environment snapshot recorded with [WithSpecSnapshots].
+type SpecRecorder interface {
+	// Spec returns the effective spec of the running sandbox, or false if none was recorded.
+	Spec() (SandboxSpec, bool)
+}
+
+// SandboxSpec is the effective environment a sandbox was started with. It is attached to every
+// execution result when [WithSpecSnapshots] is enabled, so results can be traced back to the
+// exact environment that produced them. The server does not report resolved image digests,
+// so Image is the reference as requested.
+type SandboxSpec struct {
+	Name      string     `json:"name"`
+	Namespace string     `json:"namespace"`
+	ServerURL string     `json:"server_url"`
+	Image     string     `json:"image"`
+	MemoryMB  int        `json:"memory_mb"`
+	CPUs      int        `json:"cpus"`
+	Volumes   []string   `json:"volumes,omitempty"`
+	Languages []Language `json:"languages,omitempty"`
+	EnvHash   string     `json:"env_hash,omitempty"` // SHA-256 of the guest's sorted environment at startup
+	StartedAt time.Time  `json:"started_at"`
+}
+
+// recordSpec snapshots the effective spec after a successful start.
+func (b *baseMicroSandbox) recordSpec(ctx context.Context, image string, memoryMB int, cpus int) {
+	cfg := b.config()
+	spec := &SandboxSpec{
+		Name:      cfg.name,
+		Namespace: cfg.namespace,
+		ServerURL: cfg.serverUrl,
+		Image:     image,
+		MemoryMB:  memoryMB,
+		CPUs:      cpus,
+		Volumes:   slices.Clone(cfg.volumes),
+		Languages: slices.Clone(cfg.languages),
+		StartedAt: time.Now(),
+	}
+	exec, err := b.runShell(ctx, `env | sort | sha256sum | cut -d' ' -f1`)
+	if err == nil && exec.IsSuccess() {
+		out, _ := exec.GetOutput()
+		spec.EnvHash = strings.TrimSpace(out)
+	} else {
+		cfg.logger.Error("Failed to hash sandbox environment", "sandbox", cfg.name, "error", err)
+	}
+	b.spec.Store(spec)
+}
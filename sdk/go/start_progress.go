@@ -0,0 +1,68 @@
+package msb
+
+import "time"
+
+// StartPhase identifies a step of sandbox startup.
+type StartPhase int
+
+const (
+	// StartPhaseRequested is reported when the start request is sent. The server pulls the
+	// image (if needed) and boots the VM before answering, which dominates cold start time.
+	StartPhaseRequested StartPhase = iota
+	// StartPhaseRunning is reported once the server acknowledges that the sandbox is running.
+	// The guest may not accept executions yet; Start then waits for it, up to WithReadyTimeout.
+	StartPhaseRunning
+	// StartPhaseReady is reported once the sandbox accepts executions, right before Start
+	// returns. The Elapsed time since StartPhaseRunning is how long the guest took to be ready.
+	StartPhaseReady
+	// StartPhaseFailed is reported when startup fails; StartProgress.Err carries the cause.
+	StartPhaseFailed
+)
+
+func (p StartPhase) String() string {
+	switch p {
+	case StartPhaseRequested:
+		return "requested"
+	case StartPhaseRunning:
+		return "running"
+	case StartPhaseReady:
+		return "ready"
+	case StartPhaseFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// StartProgress describes a single startup progress event.
+type StartProgress struct {
+	Phase   StartPhase
+	Elapsed time.Duration // Time since Start was called
+	Message string        // Server-provided status message, if any
+	Err     error         // Set for StartPhaseFailed
+}
+
+// StartProgressFunc receives startup progress events. It is called synchronously from Start,
+// so it should return quickly.
+type StartProgressFunc func(StartProgress)
+
+type startProgressReporter struct {
+	fn    StartProgressFunc
+	begin time.Time
+}
+
+func newStartProgressReporter(fn StartProgressFunc) startProgressReporter {
+	return startProgressReporter{fn: fn, begin: time.Now()}
+}
+
+func (r startProgressReporter) report(phase StartPhase, message string, err error) {
+	if r.fn == nil {
+		return
+	}
+	r.fn(StartProgress{
+		Phase:   phase,
+		Elapsed: time.Since(r.begin),
+		Message: message,
+		Err:     err,
+	})
+}
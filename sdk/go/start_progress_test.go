@@ -0,0 +1,59 @@
+package msb_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+	"github.com/keithang/microsandbox/sdk/go/msbtest"
+)
+
+func TestStartProgressReportsReadinessWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int64 // readiness checks failing before one succeeds
+		wantPhases []msb.StartPhase
+		wantErr    error
+	}{
+		{"ready", 1, []msb.StartPhase{msb.StartPhaseRequested, msb.StartPhaseRunning, msb.StartPhaseReady}, nil},
+		{"never ready", 1 << 30, []msb.StartPhase{msb.StartPhaseRequested, msb.StartPhaseRunning, msb.StartPhaseFailed}, msb.ErrReadyTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []msb.StartProgress
+			sandbox := msbtest.NewFakeSandbox(msb.LanguagePython,
+				msb.WithReadyTimeout(500*time.Millisecond),
+				msb.WithStartProgress(func(p msb.StartProgress) { events = append(events, p) }))
+			var checks atomic.Int64
+			sandbox.HandleCommandFunc(func(command string, args []string) (msbtest.Result, bool) {
+				if command != "sh" || !slices.Equal(args, []string{"-c", "true"}) {
+					return msbtest.Result{}, false
+				}
+				if checks.Add(1) <= tt.failures {
+					return msbtest.Result{ExitCode: 1}, true
+				}
+				return msbtest.Result{}, true
+			})
+
+			err := sandbox.Start(context.Background(), "img", 0, 0)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Start error = %v, want %v", err, tt.wantErr)
+			}
+			var phases []msb.StartPhase
+			for _, ev := range events {
+				phases = append(phases, ev.Phase)
+			}
+			if !slices.Equal(phases, tt.wantPhases) {
+				t.Fatalf("phases = %v, want %v", phases, tt.wantPhases)
+			}
+			// The failed readiness check makes the wait last at least one pause
+			if wait := events[2].Elapsed - events[1].Elapsed; wait < 100*time.Millisecond {
+				t.Errorf("readiness wait = %v, want it reported apart from the running phase", wait)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithStartTimeout bounds the start request, during which the server pulls the image if needed
+// and boots the VM. It takes precedence over [WithTimeout] and [WithAdaptiveTimeout] for that
+// request, so cold starts can be given minutes while executions fail fast. Start fails with
+// [ErrStartTimeout] when it expires, and asks the server to stop the sandbox in case it goes on
+// booting it.
+func WithStartTimeout(d time.Duration) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.startTimeout = d
+	}
+}
+
+// WithReadyTimeout makes Start wait, once the VM has booted, until the sandbox accepts
+// executions, for at most d. A sandbox that never becomes ready is broken rather than slow:
+// Start stops it and fails with [ErrReadyTimeout]. Without it, Start returns as soon as the VM
+// has booted, and the first execution waits for the sandbox to become ready.
+func WithReadyTimeout(d time.Duration) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.readyTimeout = d
+	}
+}
+
+// readyPollInterval bounds the pause between two readiness checks.
+const readyPollInterval = time.Second
+
+// startTimeoutError tells a start request that ran out of its WithStartTimeout from one that
+// failed otherwise.
+func (b *baseMicroSandbox) startTimeoutError(ctx context.Context, err error) error {
+	d := b.config().startTimeout
+	if d <= 0 || ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if stopErr := b.rpcClient.stopSandbox(context.WithoutCancel(ctx), b.config()); stopErr != nil {
+		b.logger().Info("Failed to stop sandbox after start timeout", "sandbox", b.name(), "error", stopErr)
+	}
+	return fmt.Errorf("%w after %s: %w", ErrStartTimeout, d, err)
+}
+
+// waitReady runs a no-op command until it succeeds, for at most the WithReadyTimeout.
+func (b *baseMicroSandbox) waitReady(ctx context.Context) error {
+	d := b.config().readyTimeout
+	if d <= 0 {
+		return nil
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	pause := 100 * time.Millisecond
+	for {
+		exec, err := b.runShell(ctx, "true")
+		if err == nil && exec.IsSuccess() {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("readiness check exited with code %d", exec.GetExitCode())
+		}
+		select {
+		case <-ctx.Done():
+			if parent.Err() != nil {
+				return parent.Err()
+			}
+			return fmt.Errorf("%w after %s: %w", ErrReadyTimeout, d, err)
+		case <-time.After(pause):
+		}
+		pause = min(2*pause, readyPollInterval)
+	}
+}
+
+// Start timeout-related errors
+var (
+	ErrStartTimeout = errors.New("sandbox did not boot in time")
+	ErrReadyTimeout = errors.New("sandbox did not become ready in time")
+)
@@ -4,5 +4,7 @@ type state = uint32
 
 const (
 	off state = iota
+	starting
 	started
+	stopping
 )
@@ -0,0 +1,54 @@
+package msb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// guestCommandScript renders cmd and args as a guest script, applying the per-execution
+// environment and standard input of rc.
+func (b *baseMicroSandbox) guestCommandScript(ctx context.Context, cmd string, args []string, rc runConfig) (string, error) {
+	prelude, err := b.shellPrelude(rc)
+	if err != nil {
+		return "", err
+	}
+	script := commandScript(cmd, args)
+	if rc.stdin != nil {
+		if script, err = b.stdinScript(ctx, script, rc.stdin); err != nil {
+			return "", err
+		}
+	}
+	return prelude + script, nil
+}
+
+// stdinScript uploads the data read from stdin to the guest and wraps script so that it runs with
+// the data as standard input. The file is unlinked as soon as it is opened, so nothing is left
+// behind however the command ends.
+func (b *baseMicroSandbox) stdinScript(ctx context.Context, script string, stdin io.Reader) (string, error) {
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	id, err := newGuestJobID()
+	if err != nil {
+		return "", err
+	}
+	path := guestPayloadsDir + "/" + id
+	if err := b.uploadPayload(ctx, path, data); err != nil {
+		return "", err
+	}
+	f := shellQuote(path)
+	return `exec <` + f + ` && rm -f ` + f + ` && exec ` + script, nil
+}
+
+// withCommand reports cmd and args as the executed command, for results of wrapper scripts.
+func (ce CommandExecution) withCommand(cmd string, args []string) CommandExecution {
+	if !ce.parsedOK {
+		return ce
+	}
+	ce.parsed.Command = cmd
+	ce.parsed.Args = args
+	ce.Output, _ = json.Marshal(ce.parsed)
+	return ce
+}
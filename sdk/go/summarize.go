@@ -0,0 +1,149 @@
+package msb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// charsPerToken approximates how many characters of program output make up one LLM token.
+const charsPerToken = 4
+
+// errorLinePattern matches lines that typically carry the reason an execution failed: exception
+// messages, tracebacks, compiler diagnostics and test failures.
+var errorLinePattern = regexp.MustCompile(`(Traceback|Exception|Error)\b|(?i)\b(error|panic|fatal|failed|failure)\b`)
+
+// Summarize condenses an execution into a plain-text report of roughly at most maxTokens LLM
+// tokens, ready to be pasted into a prompt: its status, exit code and termination reason, the
+// lines that look like errors, and the head and tail of stdout and stderr with the middle cut
+// out. A maxTokens <= 0 means no limit.
+func Summarize[E CodeExecution | CommandExecution](exec E, maxTokens int) string {
+	var (
+		header      strings.Builder
+		lines       []OutputLine
+		termination TerminationReason
+	)
+	switch e := any(exec).(type) {
+	case CodeExecution:
+		fmt.Fprintf(&header, "status: %s\n", e.GetStatus())
+		lines, _ = e.GetOutputLines()
+		termination = e.GetTerminationReason()
+	case CommandExecution:
+		status := "success"
+		if !e.IsSuccess() {
+			status = "error"
+		}
+		fmt.Fprintf(&header, "status: %s\nexit code: %d\n", status, e.GetExitCode())
+		lines, _ = e.GetOutputLines()
+		termination = e.GetTerminationReason()
+	}
+	if termination.Kind != TerminationNone {
+		fmt.Fprintf(&header, "terminated: %s", termination.Kind)
+		if termination.SignalName != "" {
+			fmt.Fprintf(&header, " (%s)", termination.SignalName)
+		}
+		header.WriteString("\n")
+	}
+
+	var stdout, stderr, errLines []string
+	for _, line := range lines {
+		if line.Stream == "stderr" {
+			stderr = append(stderr, line.Text)
+		} else {
+			stdout = append(stdout, line.Text)
+		}
+		if errorLinePattern.MatchString(line.Text) {
+			errLines = append(errLines, line.Text)
+		}
+	}
+
+	budget := -1
+	if maxTokens > 0 {
+		budget = max(maxTokens*charsPerToken-header.Len(), 0)
+	}
+	var out strings.Builder
+	out.WriteString(header.String())
+	// Error lines are the most telling part, but may only take a quarter of the budget, so that
+	// some context survives; stderr may take half of the rest, stdout gets what is left.
+	errBudget, streamBudget := budget, budget
+	if budget >= 0 {
+		errBudget = budget / 4
+		streamBudget = budget - min(errBudget, textLen(errLines))
+	}
+	writeSection(&out, "error lines", errLines, errBudget)
+	stdoutBudget, stderrBudget := streamBudget, streamBudget
+	if streamBudget >= 0 {
+		stderrBudget = min(textLen(stderr), max(streamBudget/2, streamBudget-textLen(stdout)))
+		stdoutBudget = streamBudget - stderrBudget
+	}
+	writeSection(&out, "stdout", stdout, stdoutBudget)
+	writeSection(&out, "stderr", stderr, stderrBudget)
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// writeSection writes lines under a title, keeping as many lines from the head and the tail as
+// fit in budget characters; a negative budget keeps them all.
+func writeSection(out *strings.Builder, title string, lines []string, budget int) {
+	if len(lines) == 0 {
+		return
+	}
+	if budget < 0 || textLen(lines) <= budget {
+		fmt.Fprintf(out, "%s:\n", title)
+		for _, line := range lines {
+			out.WriteString(line + "\n")
+		}
+		return
+	}
+
+	// Favor the tail, where errors and final results usually are
+	headBudget := budget / 3
+	tailBudget := budget - headBudget
+	var head, tail []string
+	for _, line := range lines {
+		line = truncateLine(line, headBudget)
+		if len(line)+1 > headBudget {
+			break
+		}
+		headBudget -= len(line) + 1
+		head = append(head, line)
+	}
+	for i := len(lines) - 1; i >= len(head); i-- {
+		line := truncateLine(lines[i], tailBudget)
+		if len(line)+1 > tailBudget {
+			break
+		}
+		tailBudget -= len(line) + 1
+		tail = append(tail, line)
+	}
+	omitted := len(lines) - len(head) - len(tail)
+
+	fmt.Fprintf(out, "%s (%d lines, %d omitted):\n", title, len(lines), omitted)
+	for _, line := range head {
+		out.WriteString(line + "\n")
+	}
+	if omitted > 0 {
+		fmt.Fprintf(out, "... [%d lines omitted] ...\n", omitted)
+	}
+	for i := len(tail) - 1; i >= 0; i-- {
+		out.WriteString(tail[i] + "\n")
+	}
+}
+
+// truncateLine shortens a line longer than budget, marking the cut, so that one huge line does
+// not crowd out all the others.
+func truncateLine(line string, budget int) string {
+	const marker = " [...]"
+	if len(line)+1 <= budget || budget <= len(marker)+1 {
+		return line
+	}
+	return strings.ToValidUTF8(line[:budget-len(marker)-1], "") + marker
+}
+
+// textLen is the number of characters lines take up, one per line for the newline.
+func textLen(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		n += len(line) + 1
+	}
+	return n
+}
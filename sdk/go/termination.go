@@ -0,0 +1,147 @@
+package msb
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// TerminationKind classifies why an execution ended abnormally.
+type TerminationKind int
+
+const (
+	// TerminationNone means the execution ran to completion on its own.
+	TerminationNone TerminationKind = iota
+	// TerminationTimeout means the server killed the execution when its timeout expired.
+	// The server discards the partial output in that case.
+	TerminationTimeout
+	// TerminationSignal means the process was killed by a signal, as reported by a shell's
+	// 128+N exit code. Use [CrashReporter] to find out whether the OOM killer was involved.
+	TerminationSignal
+	// TerminationStalled means the client killed the execution after it produced no output and
+	// no CPU activity for the period set with [WithWatchdog].
+	TerminationStalled
+	// TerminationPrompt means the client killed the execution at a prompt, as the handler set
+	// with [WithPromptHandler] asked.
+	TerminationPrompt
+)
+
+func (k TerminationKind) String() string {
+	switch k {
+	case TerminationNone:
+		return "none"
+	case TerminationTimeout:
+		return "timeout"
+	case TerminationSignal:
+		return "signal"
+	case TerminationStalled:
+		return "stalled"
+	case TerminationPrompt:
+		return "prompt"
+	default:
+		return "unknown"
+	}
+}
+
+// parseTerminationKind is the inverse of TerminationKind.String.
+func parseTerminationKind(s string) (TerminationKind, bool) {
+	for k := TerminationNone; k <= TerminationPrompt; k++ {
+		if k.String() == s {
+			return k, true
+		}
+	}
+	return TerminationNone, false
+}
+
+// ExitStatus tells how a command ended, as reported by [CommandExecution.GetExitStatus].
+type ExitStatus int
+
+const (
+	// ExitNotRun means there is no result: the command failed to run, e.g. because the request
+	// did not reach the sandbox.
+	ExitNotRun ExitStatus = iota
+	// ExitUnparsed means the server's result could not be parsed; the raw Output holds it.
+	ExitUnparsed
+	// ExitExited means the command exited on its own, with the code returned by GetExitCode.
+	ExitExited
+	// ExitKilled means the command was terminated by a timeout, a signal such as SIGKILL from
+	// the OOM killer, a watchdog, or a prompt handler; GetTerminationReason tells which.
+	ExitKilled
+)
+
+func (s ExitStatus) String() string {
+	switch s {
+	case ExitNotRun:
+		return "not run"
+	case ExitUnparsed:
+		return "unparsed"
+	case ExitExited:
+		return "exited"
+	case ExitKilled:
+		return "killed"
+	default:
+		return "unknown"
+	}
+}
+
+// TerminationReason explains why an execution was terminated, letting callers tell a timeout
+// from a killed process when GetStatus reports "error".
+type TerminationReason struct {
+	Kind       TerminationKind
+	Signal     int    // Terminating signal for TerminationSignal
+	SignalName string // e.g. "SIGKILL" for TerminationSignal
+	Detail     string // Server-provided message, if any
+}
+
+// terminationFromExitCode infers a signal termination from a shell's 128+N exit code.
+func terminationFromExitCode(code int) TerminationReason {
+	if code > 128 && code < 128+65 {
+		sig := code - 128
+		return TerminationReason{Kind: TerminationSignal, Signal: sig, SignalName: signalName(sig)}
+	}
+	return TerminationReason{}
+}
+
+// terminationFromError recognizes the portal's timeout errors, which replace the execution result.
+func terminationFromError(err error) (TerminationReason, bool) {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return TerminationReason{}, false
+	}
+	msg := strings.ToLower(rpcErr.Message)
+	if strings.Contains(msg, "timed out after") || strings.Contains(msg, "timeout after") {
+		return TerminationReason{Kind: TerminationTimeout, Detail: rpcErr.Message}, true
+	}
+	return TerminationReason{}, false
+}
+
+// terminatedCodeExecution builds the result returned alongside a server timeout error, so the
+// termination reason can be inspected; it returns the zero value for any other error.
+func terminatedCodeExecution(language Language, err error) CodeExecution {
+	reason, ok := terminationFromError(err)
+	if !ok {
+		return CodeExecution{}
+	}
+	exec := CodeExecution{
+		parsed:      executionData{Status: "error", Language: string(language)},
+		parsedOK:    true,
+		termination: reason,
+	}
+	exec.Output, _ = json.Marshal(exec.parsed)
+	return exec
+}
+
+// terminatedCommandExecution is the command counterpart of terminatedCodeExecution.
+func terminatedCommandExecution(cmd string, args []string, err error) CommandExecution {
+	reason, ok := terminationFromError(err)
+	if !ok {
+		return CommandExecution{}
+	}
+	exec := CommandExecution{
+		parsed:      commandData{Command: cmd, Args: args, ExitCode: -1},
+		parsedOK:    true,
+		termination: reason,
+	}
+	exec.Output, _ = json.Marshal(exec.parsed)
+	return exec
+}
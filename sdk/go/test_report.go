@@ -0,0 +1,291 @@
+package msb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TestRunner runs test suites inside the sandbox and reports their results.
+type TestRunner interface {
+	// Run executes the tests found at path with the given framework and parses the
+	// framework's machine-readable output into a [TestReport].
+	// Failing tests are not an error; check [TestReport.Failed] instead.
+	Run(ctx context.Context, framework TestFramework, path string) (TestReport, error)
+}
+
+// TestFramework identifies a supported test framework.
+type TestFramework string
+
+const (
+	TestFrameworkPytest TestFramework = "pytest"
+	TestFrameworkJest   TestFramework = "jest"
+	TestFrameworkGo     TestFramework = "go"
+)
+
+// TestStatus is the outcome of a single test case.
+type TestStatus string
+
+const (
+	TestPassed  TestStatus = "passed"
+	TestFailed  TestStatus = "failed"
+	TestSkipped TestStatus = "skipped"
+)
+
+// TestReport summarizes a test run.
+type TestReport struct {
+	Framework TestFramework
+	Cases     []TestCase
+	Passed    int
+	Failed    int
+	Skipped   int
+	Execution CommandExecution // The underlying command execution, including the framework's console output on stderr
+}
+
+// TestCase is the result of a single test.
+type TestCase struct {
+	Suite    string // Test class, file or package, depending on the framework
+	Name     string
+	Status   TestStatus
+	Message  string // Failure or skip message, if any
+	Duration time.Duration
+}
+
+// OK reports whether the run contained at least one test and no failures.
+func (r TestReport) OK() bool {
+	return r.Failed == 0 && len(r.Cases) > 0
+}
+
+type testRunner struct {
+	b *baseMicroSandbox
+}
+
+func (tr testRunner) Run(ctx context.Context, framework TestFramework, path string) (TestReport, error) {
+	script, err := framework.script(path)
+	if err != nil {
+		return TestReport{}, err
+	}
+	exec, err := tr.b.runShell(ctx, script)
+	if err != nil {
+		return TestReport{}, fmt.Errorf("%w: %w", ErrFailedToRunTests, err)
+	}
+	report := TestReport{Framework: framework, Execution: exec}
+	raw, err := exec.GetOutput()
+	if err != nil {
+		return report, fmt.Errorf("%w: %w", ErrFailedToParseTestReport, err)
+	}
+	switch framework {
+	case TestFrameworkPytest:
+		report.Cases, err = parseJUnitReport(raw)
+	case TestFrameworkJest:
+		report.Cases, err = parseJestReport(raw)
+	case TestFrameworkGo:
+		report.Cases, err = parseGoTestReport(raw)
+	}
+	if err != nil {
+		return report, fmt.Errorf("%w: %w", ErrFailedToParseTestReport, err)
+	}
+	for _, c := range report.Cases {
+		switch c.Status {
+		case TestPassed:
+			report.Passed++
+		case TestFailed:
+			report.Failed++
+		case TestSkipped:
+			report.Skipped++
+		}
+	}
+	return report, nil
+}
+
+// script builds the guest script for the framework. The framework's human-readable output
+// is redirected to stderr so that stdout carries only the machine-readable report.
+func (f TestFramework) script(path string) (string, error) {
+	p := shellQuote(path)
+	switch f {
+	case TestFrameworkPytest:
+		return `out=$(mktemp) && python -m pytest ` + p + ` -q --junitxml="$out" >&2; cat "$out"; rm -f "$out"`, nil
+	case TestFrameworkJest:
+		return `out=$(mktemp) && npx --no-install jest ` + p + ` --json --outputFile="$out" >&2; cat "$out"; rm -f "$out"`, nil
+	case TestFrameworkGo:
+		return `cd ` + p + ` && go test -json ./...`, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownTestFramework, string(f))
+	}
+}
+
+// --- report parsers ---
+
+type junitCase struct {
+	ClassName string  `xml:"classname,attr"`
+	Name      string  `xml:"name,attr"`
+	Time      float64 `xml:"time,attr"`
+	Failure   *struct {
+		Message string `xml:"message,attr"`
+		Body    string `xml:",chardata"`
+	} `xml:"failure"`
+	Error *struct {
+		Message string `xml:"message,attr"`
+		Body    string `xml:",chardata"`
+	} `xml:"error"`
+	Skipped *struct {
+		Message string `xml:"message,attr"`
+	} `xml:"skipped"`
+}
+
+type junitSuite struct {
+	Cases []junitCase `xml:"testcase"`
+}
+
+func parseJUnitReport(raw string) ([]TestCase, error) {
+	// pytest emits <testsuites> wrapping one or more suites; older versions emit a bare <testsuite>
+	var doc struct {
+		XMLName xml.Name
+		Suites  []junitSuite `xml:"testsuite"`
+		Cases   []junitCase  `xml:"testcase"`
+	}
+	if err := xml.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, err
+	}
+	junitCases := doc.Cases
+	for _, s := range doc.Suites {
+		junitCases = append(junitCases, s.Cases...)
+	}
+
+	cases := make([]TestCase, 0, len(junitCases))
+	for _, jc := range junitCases {
+		c := TestCase{
+			Suite:    jc.ClassName,
+			Name:     jc.Name,
+			Status:   TestPassed,
+			Duration: time.Duration(jc.Time * float64(time.Second)),
+		}
+		switch {
+		case jc.Failure != nil:
+			c.Status = TestFailed
+			c.Message = firstNonEmpty(jc.Failure.Message, strings.TrimSpace(jc.Failure.Body))
+		case jc.Error != nil:
+			c.Status = TestFailed
+			c.Message = firstNonEmpty(jc.Error.Message, strings.TrimSpace(jc.Error.Body))
+		case jc.Skipped != nil:
+			c.Status = TestSkipped
+			c.Message = jc.Skipped.Message
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+func parseJestReport(raw string) ([]TestCase, error) {
+	var doc struct {
+		TestResults []struct {
+			Name             string `json:"name"`
+			AssertionResults []struct {
+				FullName        string   `json:"fullName"`
+				Status          string   `json:"status"`
+				Duration        float64  `json:"duration"`
+				FailureMessages []string `json:"failureMessages"`
+			} `json:"assertionResults"`
+		} `json:"testResults"`
+	}
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, err
+	}
+
+	var cases []TestCase
+	for _, file := range doc.TestResults {
+		for _, ar := range file.AssertionResults {
+			c := TestCase{
+				Suite:    file.Name,
+				Name:     ar.FullName,
+				Message:  strings.Join(ar.FailureMessages, "\n"),
+				Duration: time.Duration(ar.Duration * float64(time.Millisecond)),
+			}
+			switch ar.Status {
+			case "passed":
+				c.Status = TestPassed
+			case "failed":
+				c.Status = TestFailed
+			default: // pending, skipped, todo, disabled
+				c.Status = TestSkipped
+			}
+			cases = append(cases, c)
+		}
+	}
+	return cases, nil
+}
+
+func parseGoTestReport(raw string) ([]TestCase, error) {
+	type event struct {
+		Action  string  `json:"Action"`
+		Package string  `json:"Package"`
+		Test    string  `json:"Test"`
+		Elapsed float64 `json:"Elapsed"`
+		Output  string  `json:"Output"`
+	}
+
+	var (
+		cases  []TestCase
+		output = map[string]*strings.Builder{}
+		parsed bool
+	)
+	sc := bufio.NewScanner(strings.NewReader(raw))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var ev event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			continue // go test interleaves non-JSON build output on failures
+		}
+		parsed = true
+		if ev.Test == "" {
+			continue
+		}
+		key := ev.Package + "/" + ev.Test
+		switch ev.Action {
+		case "output":
+			if output[key] == nil {
+				output[key] = &strings.Builder{}
+			}
+			output[key].WriteString(ev.Output)
+		case "pass", "fail", "skip":
+			c := TestCase{
+				Suite:    ev.Package,
+				Name:     ev.Test,
+				Status:   map[string]TestStatus{"pass": TestPassed, "fail": TestFailed, "skip": TestSkipped}[ev.Action],
+				Duration: time.Duration(ev.Elapsed * float64(time.Second)),
+			}
+			if c.Status != TestPassed && output[key] != nil {
+				c.Message = strings.TrimSpace(output[key].String())
+			}
+			cases = append(cases, c)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if !parsed {
+		return nil, errors.New("no test events found in output")
+	}
+	return cases, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Test-related errors
+var (
+	ErrFailedToRunTests        = errors.New("failed to run tests")
+	ErrFailedToParseTestReport = errors.New("failed to parse test report")
+	ErrUnknownTestFramework    = errors.New("unknown test framework")
+)
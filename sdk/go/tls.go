@@ -0,0 +1,59 @@
+package msb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// WithTLSConfig configures TLS for servers behind internal PKI, e.g. with a private CA or mutual
+// TLS, while keeping the default client otherwise. See [TLSConfigFromFiles] to load certificates
+// from PEM files. Like [WithTransport] and [WithHTTPClient], it replaces the client set by those.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(msb *baseMicroSandbox) {
+		transport := newDefaultTransport()
+		transport.TLSClientConfig = config.Clone()
+		msb.rpcClient = newJsonRPCHTTPClient(&http.Client{Transport: transport})
+	}
+}
+
+// TLSConfigFromFiles returns a TLS configuration trusting the CA certificates in caFile, in
+// addition to the system's, and presenting the client certificate in certFile with its key in
+// keyFile for mutual TLS. Files are PEM-encoded; empty names are skipped.
+//
+//	tlsConfig, err := msb.TLSConfigFromFiles("/etc/pki/ca.pem", "/etc/pki/client.pem", "/etc/pki/client-key.pem")
+//	if err != nil {
+//		return err
+//	}
+//	sandbox := msb.NewPythonSandbox(msb.WithServerUrl("https://msb.internal:5555"), msb.WithTLSConfig(tlsConfig))
+func TLSConfigFromFiles(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLSConfig, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates in %s", ErrInvalidTLSConfig, caFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTLSConfig, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// TLS-related errors
+var ErrInvalidTLSConfig = errors.New("invalid TLS configuration")
@@ -0,0 +1,69 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// TokenProvider returns the API key to authenticate an RPC with, e.g. from Vault or a cloud KMS,
+// so keys can be rotated without restarting the process. It is called before every request and
+// must be safe for concurrent use; providers that cache keys should serve them from the cache,
+// unless [IsTokenRefresh] reports that the server just rejected the cached one.
+type TokenProvider func(ctx context.Context) (string, error)
+
+// WithTokenProvider authenticates every RPC with the key returned by provider. When the server
+// rejects a request with 401 Unauthorized, the provider is asked again, with a context for which
+// [IsTokenRefresh] reports true, and the request is retried once with the new key. It takes
+// precedence over WithApiKey and the MSB_API_KEY environment variable.
+//
+//	msb.WithTokenProvider(func(ctx context.Context) (string, error) {
+//		secret, err := vault.KVv2("secret").Get(ctx, "microsandbox")
+//		if err != nil {
+//			return "", err
+//		}
+//		return secret.Data["api_key"].(string), nil
+//	})
+func WithTokenProvider(provider TokenProvider) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.tokenProvider = provider
+	}
+}
+
+type tokenRefreshKey struct{}
+
+// IsTokenRefresh reports whether a [TokenProvider] is called because the server rejected the
+// previous key, in which case a cached key must not be returned again.
+func IsTokenRefresh(ctx context.Context) bool {
+	refresh, _ := ctx.Value(tokenRefreshKey{}).(bool)
+	return refresh
+}
+
+// credential returns the key to authenticate a request with.
+func (cfg *config) credential(ctx context.Context) (string, error) {
+	if cfg.tokenProvider == nil {
+		return cfg.apiKey, nil
+	}
+	key, err := cfg.tokenProvider(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTokenProviderFailed, err)
+	}
+	return key, nil
+}
+
+// doAuthenticated sends the request, and sends it again with a refreshed key if the server
+// rejected the one from the token provider. A 401 means the request was not acted upon, so it is
+// safe to repeat.
+func (d *jsonRPCHTTPClient) doAuthenticated(ctx context.Context, cfg *config, method rpcMethod, params any, header http.Header) (jsonRPCResponse, error) {
+	resp, err := d.doJSONRPCRequest(ctx, cfg, method, params, header)
+	var rpcErr *RPCError
+	if cfg.tokenProvider == nil || header.Get("Authorization") != "" || !errors.As(err, &rpcErr) || rpcErr.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	cfg.logger.Info("Refreshing API key rejected by server", "method", string(method))
+	return d.doJSONRPCRequest(context.WithValue(ctx, tokenRefreshKey{}, true), cfg, method, params, header)
+}
+
+// Token-related errors
+var ErrTokenProviderFailed = errors.New("token provider failed")
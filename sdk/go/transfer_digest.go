@@ -0,0 +1,52 @@
+package msb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// VerifyAgainst fails a download with [ErrChecksumMismatch] unless the SHA-256 digest of the
+// data received matches the given hex string. For ReadFile, that is the file's contents; for
+// DownloadDir, the tar archive of the directory, which is checked before anything is extracted.
+func VerifyAgainst(hexDigest string) TransferOption {
+	return func(tc *transferConfig) {
+		tc.verify = strings.ToLower(strings.TrimSpace(hexDigest))
+	}
+}
+
+// WithDigest stores in digest the hex SHA-256 digest of the data a download received, computed
+// as it arrives rather than in a second pass, e.g. to record it next to the file written to disk:
+//
+//	var digest string
+//	data, err := sandbox.Files().ReadFile(ctx, "/out/model.bin", msb.WithDigest(&digest))
+func WithDigest(digest *string) TransferOption {
+	return func(tc *transferConfig) {
+		tc.digest = digest
+	}
+}
+
+// newDigest returns the hash to feed downloaded data to, or nil if the transfer asks for none.
+func (tc transferConfig) newDigest() hash.Hash {
+	if tc.verify == "" && tc.digest == nil {
+		return nil
+	}
+	return sha256.New()
+}
+
+// checkDigest reports the digest of h and verifies it, if h is not nil.
+func (tc transferConfig) checkDigest(h hash.Hash) error {
+	if h == nil {
+		return nil
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if tc.digest != nil {
+		*tc.digest = sum
+	}
+	if tc.verify != "" && sum != tc.verify {
+		return fmt.Errorf("%w: want %s, got %s", ErrChecksumMismatch, tc.verify, sum)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package msb_test
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+	"github.com/keithang/microsandbox/sdk/go/msbtest"
+)
+
+// newLocalSandbox returns a started fake sandbox whose shell scripts run on this machine.
+func newLocalSandbox(t *testing.T) *msbtest.FakeSandbox {
+	t.Helper()
+	sandbox := msbtest.NewFakeSandbox(msb.LanguagePython)
+	sandbox.HandleCommandFunc(func(command string, args []string) (msbtest.Result, bool) {
+		if command != "sh" || len(args) != 2 {
+			return msbtest.Result{}, false
+		}
+		out, err := exec.Command("sh", "-c", args[1]).Output()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return msbtest.Result{Stdout: string(out), Stderr: string(exitErr.Stderr), ExitCode: exitErr.ExitCode()}, true
+		}
+		return msbtest.Result{Stdout: string(out)}, true
+	})
+	if err := sandbox.Start(context.Background(), "img", 0, 0); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return sandbox
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestReadFileDigest(t *testing.T) {
+	ctx := context.Background()
+	sandbox := newLocalSandbox(t)
+	content := []byte("model weights\n")
+	name := filepath.Join(t.TempDir(), "model.bin")
+	if err := os.WriteFile(name, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts []msb.TransferOption
+	}{
+		{"plain", nil},
+		{"compressed", []msb.TransferOption{msb.WithCompression(msb.GzipCompression(gzip.BestSpeed))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var digest string
+			opts := append(tt.opts, msb.WithDigest(&digest), msb.VerifyAgainst(sha256Hex(content)))
+			data, err := sandbox.Files().ReadFile(ctx, name, opts...)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if string(data) != string(content) {
+				t.Errorf("data = %q, want %q", data, content)
+			}
+			if digest != sha256Hex(content) {
+				t.Errorf("digest = %s, want %s", digest, sha256Hex(content))
+			}
+
+			_, err = sandbox.Files().ReadFile(ctx, name, append(tt.opts, msb.VerifyAgainst(sha256Hex([]byte("other"))))...)
+			if !errors.Is(err, msb.ErrChecksumMismatch) || !errors.Is(err, msb.ErrFailedToReadFile) {
+				t.Errorf("ReadFile error = %v, want ErrChecksumMismatch", err)
+			}
+		})
+	}
+}
+
+func TestDownloadDirVerifiesBeforeExtracting(t *testing.T) {
+	ctx := context.Background()
+	sandbox := newLocalSandbox(t)
+	remote := t.TempDir()
+	if err := os.WriteFile(filepath.Join(remote, "out.txt"), []byte("result"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	local := t.TempDir()
+	err := sandbox.Files().DownloadDir(ctx, remote, local, msb.VerifyAgainst(sha256Hex([]byte("other"))))
+	if !errors.Is(err, msb.ErrChecksumMismatch) {
+		t.Fatalf("DownloadDir error = %v, want ErrChecksumMismatch", err)
+	}
+	if entries, _ := os.ReadDir(local); len(entries) != 0 {
+		t.Errorf("extracted %d entries despite the mismatch", len(entries))
+	}
+
+	var digest string
+	if err := sandbox.Files().DownloadDir(ctx, remote, local, msb.WithDigest(&digest)); err != nil {
+		t.Fatalf("DownloadDir: %v", err)
+	}
+	if len(digest) != sha256.Size*2 {
+		t.Errorf("digest = %q, want a hex SHA-256", digest)
+	}
+	if data, err := os.ReadFile(filepath.Join(local, "out.txt")); err != nil || string(data) != "result" {
+		t.Errorf("out.txt = %q, %v; want %q", data, err, "result")
+	}
+}
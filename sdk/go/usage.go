@@ -0,0 +1,175 @@
+package msb
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WithResourceUsage measures what the execution consumes inside the guest, reported by
+// GetResourceUsage of its result, e.g. to bill executions or to benchmark commands without
+// wrapping them in /usr/bin/time. The guest measures the processes the execution runs, so the
+// figures leave out the RPCs around it, unlike sampling [Metrics] before and after, and are not
+// mixed up with concurrent executions.
+//
+// Code runs as a fresh interpreter process, as with [WithExecEnv], rather than in the sandbox's
+// REPL. Executions streamed with [WithOutputHandler], [WithStdout] or [WithStderr], or run with
+// [WithExecTimeout] or [WithWatchdog], are not measured.
+func WithResourceUsage() RunOption {
+	return func(rc *runConfig) {
+		rc.resourceUsage = true
+	}
+}
+
+// ResourceUsage is what an execution consumed inside the guest.
+type ResourceUsage struct {
+	// CPUTime is the user and system CPU time of the execution's processes, counted in 10ms
+	// ticks. Processes left running in the background when the execution ends are not counted.
+	CPUTime time.Duration
+	// UserCPUTime and SystemCPUTime split CPUTime into the time spent in user and kernel mode,
+	// as rusage's ru_utime and ru_stime do.
+	UserCPUTime   time.Duration
+	SystemCPUTime time.Duration
+	// PeakMemoryBytes is the most memory the execution's processes used at once, page cache
+	// included, as the guest kernel's memory cgroup counts it; 0 if the guest has no memory
+	// cgroup controller.
+	PeakMemoryBytes int64
+	// WallTime is how long the execution ran in the guest, to the hundredth of a second.
+	WallTime time.Duration
+	// BytesWritten is what the execution's processes wrote to files, pipes and sockets, their
+	// output included.
+	BytesWritten int64
+	// BytesRead is what the execution's processes read from files, pipes and sockets.
+	BytesRead int64
+}
+
+// usageTag starts the line a measured execution reports its resource usage on.
+const usageTag = "\x1emsb-resource-usage"
+
+// usageClockTicks is the rate at which the kernel reports CPU times in /proc, USER_HZ, which
+// Linux fixes to 100 on every architecture.
+const usageClockTicks = 100
+
+// usagePrelude starts measuring: it moves the shell into a memory cgroup of its own, v2 or v1,
+// created in its current one, to read its peak memory, and records the uptime, the user and
+// system CPU time of the shell's waited-for children and the bytes they read and wrote.
+const usagePrelude = `msb_usage() {
+	read -r msb_t _ </proc/uptime
+	read -r msb_st </proc/$$/stat
+	set -- ${msb_st##*)}
+	msb_u=${14} msb_s=${15}
+	msb_r=0 msb_w=0
+	if [ -r /proc/$$/io ]; then
+		while read -r msb_k msb_v; do
+			case $msb_k in rchar:) msb_r=$msb_v ;; wchar:) msb_w=$msb_v ;; esac
+		done </proc/$$/io
+	fi
+}
+if [ -f /sys/fs/cgroup/cgroup.controllers ]; then
+	msb_home=/sys/fs/cgroup msb_ctl= msb_peak=memory.peak
+else
+	msb_home=/sys/fs/cgroup/memory msb_ctl=memory msb_peak=memory.max_usage_in_bytes
+fi
+while IFS=: read -r _ msb_k msb_v; do
+	case ",$msb_k," in *",$msb_ctl,"*) msb_home=${msb_home}${msb_v%/} ;; esac
+done </proc/$$/cgroup
+msb_cg=$msb_home/msb-exec-$$
+if [ -d "$msb_home" ] && mkdir "$msb_cg"; then
+	[ -n "$msb_ctl" ] || grep -qw memory "$msb_cg/cgroup.controllers" ||
+		echo +memory >"$msb_home/cgroup.subtree_control"
+	echo $$ >"$msb_cg/cgroup.procs" || { rmdir "$msb_cg"; msb_cg=; }
+else
+	msb_cg=
+fi 2>/dev/null
+msb_usage; msb_t0=$msb_t msb_u0=$msb_u msb_s0=$msb_s msb_r0=$msb_r msb_w0=$msb_w
+`
+
+// usageEpilogue ends measuring and prints the usage line.
+const usageEpilogue = `
+msb_usage; msb_m=0
+if [ -n "$msb_cg" ]; then
+	read -r msb_m <"$msb_cg/$msb_peak" || msb_m=0
+	echo $$ >"$msb_home/cgroup.procs" && rmdir "$msb_cg"
+fi 2>/dev/null
+printf '%s %s %s %s %s %s %s %s %s %s %s %s\n' '` + usageTag + `' \
+	$msb_t0 $msb_t $msb_u0 $msb_u $msb_s0 $msb_s $msb_r0 $msb_r $msb_w0 $msb_w $msb_m`
+
+// usageScript measures body, a command run in a child process of the shell, such as a
+// subshell, and prints the usage line on stdout after it. body's exit status is left in $s.
+func usageScript(body string) string {
+	return usagePrelude + body + "\ns=$?" + usageEpilogue
+}
+
+// withResourceUsage takes the usage line printed by usageScript out of the output, and records
+// the usage it reports.
+func (ce CommandExecution) withResourceUsage() CommandExecution {
+	lines := ce.parsed.OutputLines
+	i := slices.IndexFunc(lines, func(line OutputLine) bool {
+		return line.Stream == "stdout" && strings.HasPrefix(line.Text, usageTag+" ")
+	})
+	if i < 0 {
+		return ce
+	}
+	var (
+		t0, t1                         float64
+		u0, u1, s0, s1, r0, r1, w0, w1 int64
+		u                              ResourceUsage
+	)
+	if _, err := fmt.Sscan(strings.TrimPrefix(lines[i].Text, usageTag), &t0, &t1, &u0, &u1, &s0, &s1, &r0, &r1, &w0, &w1, &u.PeakMemoryBytes); err != nil {
+		return ce
+	}
+	u.WallTime = time.Duration((t1 - t0) * float64(time.Second)).Round(10 * time.Millisecond)
+	u.UserCPUTime = time.Duration(u1-u0) * time.Second / usageClockTicks
+	u.SystemCPUTime = time.Duration(s1-s0) * time.Second / usageClockTicks
+	u.CPUTime = u.UserCPUTime + u.SystemCPUTime
+	u.BytesRead = r1 - r0
+	u.BytesWritten = w1 - w0
+	ce.parsed.OutputLines = append(lines[:i:i], lines[i+1:]...)
+	ce.Output, _ = json.Marshal(ce.parsed)
+	ce.usage = &u
+	return ce
+}
+
+// resourceUsageJSON is the canonical JSON encoding of a ResourceUsage.
+type resourceUsageJSON struct {
+	CPUTimeMS       int64 `json:"cpu_time_ms"`
+	UserCPUTimeMS   int64 `json:"user_cpu_time_ms"`
+	SystemCPUTimeMS int64 `json:"system_cpu_time_ms"`
+	PeakMemoryBytes int64 `json:"peak_memory_bytes"`
+	WallTimeMS      int64 `json:"wall_time_ms"`
+	BytesRead       int64 `json:"bytes_read"`
+	BytesWritten    int64 `json:"bytes_written"`
+}
+
+func newResourceUsageJSON(u *ResourceUsage) *resourceUsageJSON {
+	if u == nil {
+		return nil
+	}
+	return &resourceUsageJSON{
+		CPUTimeMS:       u.CPUTime.Milliseconds(),
+		UserCPUTimeMS:   u.UserCPUTime.Milliseconds(),
+		SystemCPUTimeMS: u.SystemCPUTime.Milliseconds(),
+		PeakMemoryBytes: u.PeakMemoryBytes,
+		WallTimeMS:      u.WallTime.Milliseconds(),
+		BytesRead:       u.BytesRead,
+		BytesWritten:    u.BytesWritten,
+	}
+}
+
+// usage is the inverse of newResourceUsageJSON.
+func (u *resourceUsageJSON) usage() *ResourceUsage {
+	if u == nil {
+		return nil
+	}
+	return &ResourceUsage{
+		CPUTime:         time.Duration(u.CPUTimeMS) * time.Millisecond,
+		UserCPUTime:     time.Duration(u.UserCPUTimeMS) * time.Millisecond,
+		SystemCPUTime:   time.Duration(u.SystemCPUTimeMS) * time.Millisecond,
+		PeakMemoryBytes: u.PeakMemoryBytes,
+		WallTime:        time.Duration(u.WallTimeMS) * time.Millisecond,
+		BytesRead:       u.BytesRead,
+		BytesWritten:    u.BytesWritten,
+	}
+}
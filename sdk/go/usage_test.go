@@ -0,0 +1,43 @@
+package msb_test
+
+import (
+	"context"
+	"testing"
+
+	msb "github.com/keithang/microsandbox/sdk/go"
+)
+
+func TestCommandResourceUsage(t *testing.T) {
+	ctx := context.Background()
+	sandbox := newLocalSandbox(t)
+	exec, err := sandbox.Command().Run(ctx, "sh", []string{"-c", "head -c 100000 /dev/zero | cat >/dev/null; echo done"},
+		msb.WithResourceUsage())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out, _ := exec.GetOutput(); out != "done" {
+		t.Errorf("output = %q, want the usage line left out", out)
+	}
+	usage, ok := exec.GetResourceUsage()
+	if !ok {
+		t.Fatal("no resource usage reported")
+	}
+	if usage.CPUTime != usage.UserCPUTime+usage.SystemCPUTime {
+		t.Errorf("CPUTime = %v, want user %v + system %v", usage.CPUTime, usage.UserCPUTime, usage.SystemCPUTime)
+	}
+	if usage.BytesRead < 100000 || usage.BytesWritten < 100000 {
+		t.Errorf("read %d and wrote %d bytes, want at least 100000 each", usage.BytesRead, usage.BytesWritten)
+	}
+
+	data, err := exec.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON: %v", err)
+	}
+	parsed, err := msb.ParseExecution(data)
+	if err != nil {
+		t.Fatalf("ParseExecution: %v", err)
+	}
+	if got, _ := parsed.(msb.CommandExecution).GetResourceUsage(); got.BytesRead != usage.BytesRead || got.UserCPUTime != usage.UserCPUTime.Truncate(1e6) {
+		t.Errorf("usage after a JSON round trip = %+v, want %+v", got, usage)
+	}
+}
@@ -0,0 +1,91 @@
+package msb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WithVolume maps hostPath on the server's machine to guestPath inside the sandbox, so large
+// datasets need not be copied through executions. A relative hostPath resolves against the
+// namespace's directory on the server. guestPath must be absolute; neither may contain ':'.
+// Invalid mounts make Start fail with [ErrInvalidVolume], and mounts the server refuses with
+// [ErrVolumeRejected].
+//
+// A readOnly mount is remounted read-only inside the guest once the sandbox starts, which guards
+// against accidental writes but not against code running as root remounting it.
+func WithVolume(hostPath, guestPath string, readOnly bool) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.volumes = append(msb.cfg.volumes, hostPath+":"+guestPath)
+		if readOnly {
+			msb.cfg.readOnlyVolumes = append(msb.cfg.readOnlyVolumes, guestPath)
+		}
+	}
+}
+
+// validateVolumes checks the volumes against what the server accepts.
+func (cfg *config) validateVolumes() error {
+	for _, volume := range cfg.volumes {
+		host, guest, _ := strings.Cut(volume, ":")
+		switch {
+		case host == "" || guest == "":
+			return fmt.Errorf("%w: %q: empty path", ErrInvalidVolume, volume)
+		case strings.Contains(guest, ":"):
+			return fmt.Errorf("%w: %q: paths cannot contain ':'", ErrInvalidVolume, volume)
+		case !path.IsAbs(guest):
+			return fmt.Errorf("%w: %q: guest path must be absolute", ErrInvalidVolume, volume)
+		case path.Clean(guest) == "/":
+			return fmt.Errorf("%w: %q: cannot mount over the guest's root", ErrInvalidVolume, volume)
+		}
+	}
+	if host := cfg.sharedDir.host; host != "" && !filepath.IsAbs(host) {
+		return fmt.Errorf("%w: %q: shared directory must be absolute on the host", ErrInvalidVolume, host)
+	}
+	return nil
+}
+
+// volumeError marks a start failure as a rejected volume if the server's error is about one.
+func volumeError(cfg *config, err error) error {
+	var rpcErr *RPCError
+	if len(cfg.volumes) == 0 || !errors.As(err, &rpcErr) {
+		return err
+	}
+	msg := strings.ToLower(rpcErr.Message)
+	for _, hint := range []string{"volume", "mount", "path pair", "host path"} {
+		if strings.Contains(msg, hint) {
+			return fmt.Errorf("%w: %w", ErrVolumeRejected, err)
+		}
+	}
+	return err
+}
+
+// protectReadOnlyVolumes remounts the read-only volumes read-only inside the guest.
+func (b *baseMicroSandbox) protectReadOnlyVolumes(ctx context.Context) error {
+	guests := b.config().readOnlyVolumes
+	if len(guests) == 0 {
+		return nil
+	}
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	for _, guest := range guests {
+		script.WriteString("mount -o remount,ro " + shellQuote(guest) + "\n")
+	}
+	exec, err := b.runShell(ctx, script.String())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToProtectVolume, err)
+	}
+	if !exec.IsSuccess() {
+		return fmt.Errorf("%w: %s", ErrFailedToProtectVolume, commandStderr(exec))
+	}
+	return nil
+}
+
+// Volume-related errors
+var (
+	ErrInvalidVolume         = errors.New("invalid volume")
+	ErrVolumeRejected        = errors.New("volume rejected by server")
+	ErrFailedToProtectVolume = errors.New("failed to make volume read-only")
+)
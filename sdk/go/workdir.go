@@ -0,0 +1,76 @@
+package msb
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WithWorkdir runs every command and code execution from dir inside the sandbox, instead of
+// wherever the guest's processes happen to start. The directory must exist; executions fail
+// otherwise. The REPL of each language is moved to dir before it first runs code, so code running
+// in the REPL keeps sharing its state. See [WithExecWorkdir] to override it per execution.
+func WithWorkdir(dir string) Option {
+	return func(msb *baseMicroSandbox) {
+		msb.cfg.workdir = dir
+	}
+}
+
+// WithExecWorkdir runs a single execution from dir inside the sandbox, overriding
+// [WithWorkdir]. Code run with a working directory executes in a fresh interpreter process, the
+// same way as with [WithOutputHandler], so that the REPL's directory is left alone.
+func WithExecWorkdir(dir string) RunOption {
+	return func(rc *runConfig) {
+		rc.workdir = dir
+	}
+}
+
+// shellPrelude returns the lines that set up the environment and working directory of a shell
+// script running an execution.
+func (b *baseMicroSandbox) shellPrelude(rc runConfig) (string, error) {
+	exports, err := envExports(rc.env)
+	if err != nil {
+		return "", err
+	}
+	return exports + workdirPrelude(cmp.Or(rc.workdir, b.config().workdir)), nil
+}
+
+// workdirPrelude returns the line changing to dir, or "" if dir is empty.
+func workdirPrelude(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	return "cd " + shellQuote(dir) + " || exit 1\n"
+}
+
+// moveReplToWorkdir changes the working directory of the REPL of rt to the sandbox's, the first
+// time the REPL runs code since Start or since the working directory changed.
+func (b *baseMicroSandbox) moveReplToWorkdir(ctx context.Context, rt *languageRuntime) error {
+	cfg := b.config()
+	if cfg.workdir == "" || rt.chdir == "" {
+		return nil
+	}
+	if dir, ok := b.replWorkdirs.Load(rt.Name); ok && dir == cfg.workdir {
+		return nil
+	}
+	literal, err := Literal(rt.Name, cfg.workdir)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToChangeWorkdir, err)
+	}
+	result, err := b.rpcClient.runRepl(ctx, cfg, rt.Name, strings.ReplaceAll(rt.chdir, "{dir}", literal))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToChangeWorkdir, err)
+	}
+	exec := NewCodeExecution(result.output)
+	if exec.HasError() {
+		stderr, _ := exec.GetError()
+		return fmt.Errorf("%w: %s", ErrFailedToChangeWorkdir, strings.TrimSpace(stderr))
+	}
+	b.replWorkdirs.Store(rt.Name, cfg.workdir)
+	return nil
+}
+
+// Workdir-related errors
+var ErrFailedToChangeWorkdir = errors.New("failed to change working directory")